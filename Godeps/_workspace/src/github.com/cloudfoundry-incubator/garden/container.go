@@ -232,9 +232,10 @@ type ContainerInfoEntry struct {
 }
 
 type Metrics struct {
-	MemoryStat ContainerMemoryStat
-	CPUStat    ContainerCPUStat
-	DiskStat   ContainerDiskStat
+	MemoryStat  ContainerMemoryStat
+	CPUStat     ContainerCPUStat
+	DiskStat    ContainerDiskStat
+	NetworkStat ContainerNetworkStat
 }
 
 type ContainerMetricsEntry struct {
@@ -284,6 +285,21 @@ type ContainerDiskStat struct {
 	InodesUsed uint64
 }
 
+// ContainerNetworkStat holds the counters of the container's network
+// interface, from the container's point of view: Tx is the traffic the
+// container sent.
+type ContainerNetworkStat struct {
+	RxBytes   uint64
+	RxPackets uint64
+	RxDropped uint64
+	RxErrors  uint64
+
+	TxBytes   uint64
+	TxPackets uint64
+	TxDropped uint64
+	TxErrors  uint64
+}
+
 type ContainerBandwidthStat struct {
 	InRate   uint64
 	InBurst  uint64
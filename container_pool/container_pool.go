@@ -1,7 +1,6 @@
 package container_pool
 
 import (
-	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -25,6 +24,7 @@ import (
 	"github.com/cloudfoundry-incubator/garden-linux/network/bridgemgr"
 	"github.com/cloudfoundry-incubator/garden-linux/network/iptables"
 	"github.com/cloudfoundry-incubator/garden-linux/network/subnets"
+	"github.com/cloudfoundry-incubator/garden-linux/network/tc"
 	"github.com/cloudfoundry-incubator/garden-linux/old/bandwidth_manager"
 	"github.com/cloudfoundry-incubator/garden-linux/old/cgroups_manager"
 	"github.com/cloudfoundry-incubator/garden-linux/old/logging"
@@ -42,6 +42,7 @@ var vcapUid int = 10001
 //go:generate counterfeiter -o fake_container_pool/FakeFilterProvider.go . FilterProvider
 type FilterProvider interface {
 	ProvideFilter(containerId string) network.Filter
+	ProvideInstanceChain(containerId string) iptables.InstanceChain
 }
 
 //go:generate counterfeiter -o fake_subnet_pool/FakeSubnetPool.go . SubnetPool
@@ -71,6 +72,10 @@ type LinuxContainerPool struct {
 	externalIP net.IP
 	mtu        int
 
+	// additionalExternalIPs are the other IPs of the host which ports may be
+	// mapped on
+	additionalExternalIPs []net.IP
+
 	portPool linux_container.PortPool
 
 	bridges bridgemgr.BridgeManager
@@ -82,6 +87,14 @@ type LinuxContainerPool struct {
 
 	quotaManager quota_manager.QuotaManager
 
+	shaper tc.Shaper
+
+	interfaceStatter network.InterfaceStatter
+
+	snapshotSaver linux_container.SnapshotSaver
+
+	events linux_backend.EventEmitter
+
 	containerIDs chan string
 }
 
@@ -92,6 +105,7 @@ func New(
 	rootfsProviders map[string]rootfs_provider.RootFSProvider,
 	uidNamespaceOffset int,
 	externalIP net.IP,
+	additionalExternalIPs []net.IP,
 	mtu int,
 	subnetPool SubnetPool,
 	bridges bridgemgr.BridgeManager,
@@ -101,6 +115,10 @@ func New(
 	denyNetworks, allowNetworks []string,
 	runner command_runner.CommandRunner,
 	quotaManager quota_manager.QuotaManager,
+	shaper tc.Shaper,
+	interfaceStatter network.InterfaceStatter,
+	snapshotSaver linux_container.SnapshotSaver,
+	events linux_backend.EventEmitter,
 ) *LinuxContainerPool {
 	pool := &LinuxContainerPool{
 		logger: logger.Session("pool"),
@@ -119,6 +137,8 @@ func New(
 		externalIP: externalIP,
 		mtu:        mtu,
 
+		additionalExternalIPs: additionalExternalIPs,
+
 		subnetPool: subnetPool,
 
 		bridges: bridges,
@@ -132,6 +152,14 @@ func New(
 
 		quotaManager: quotaManager,
 
+		shaper: shaper,
+
+		interfaceStatter: interfaceStatter,
+
+		snapshotSaver: snapshotSaver,
+
+		events: events,
+
 		containerIDs: make(chan string),
 	}
 
@@ -198,6 +226,10 @@ func (p *LinuxContainerPool) Prune(keep map[string]bool) error {
 			continue
 		}
 
+		if !entry.IsDir() { // e.g. the container journal
+			continue
+		}
+
 		_, found := keep[id]
 		if found {
 			continue
@@ -234,6 +266,11 @@ func (p *LinuxContainerPool) Create(spec garden.ContainerSpec) (c linux_backend.
 
 	pLog.Info("creating")
 
+	dnsConfig, err := network.ParseDNSConfig(spec.Properties)
+	if err != nil {
+		return nil, err
+	}
+
 	resources, err := p.acquirePoolResources(spec, id)
 	if err != nil {
 		return nil, err
@@ -242,6 +279,8 @@ func (p *LinuxContainerPool) Create(spec garden.ContainerSpec) (c linux_backend.
 		p.releasePoolResources(resources)
 	})
 
+	resources.DNS = dnsConfig
+
 	pLog.Info("acquired-pool-resources")
 
 	handle := getHandle(spec.Handle, id)
@@ -274,17 +313,19 @@ func (p *LinuxContainerPool) Create(spec garden.ContainerSpec) (c linux_backend.
 		p.runner,
 		cgroups_manager.New(p.sysconfig.CgroupPath, id),
 		p.quotaManager,
-		bandwidth_manager.New(containerPath, id, p.runner),
+		bandwidth_manager.New(containerPath, p.shaper),
 		process_tracker.New(containerPath, p.runner),
 		rootFSEnv.Merge(specEnv),
 		p.filterProvider.ProvideFilter(id),
+		p.filterProvider.ProvideInstanceChain(id),
+		p.interfaceStatter,
+		p.snapshotSaver,
+		p.events,
 	), nil
 }
 
 func (p *LinuxContainerPool) Restore(snapshot io.Reader) (linux_backend.Container, error) {
-	var containerSnapshot linux_container.ContainerSnapshot
-
-	err := json.NewDecoder(snapshot).Decode(&containerSnapshot)
+	containerSnapshot, err := linux_container.DecodeSnapshot(snapshot)
 	if err != nil {
 		return nil, err
 	}
@@ -325,7 +366,7 @@ func (p *LinuxContainerPool) Restore(snapshot io.Reader) (linux_backend.Containe
 
 	cgroupsManager := cgroups_manager.New(p.sysconfig.CgroupPath, id)
 
-	bandwidthManager := bandwidth_manager.New(containerPath, id, p.runner)
+	bandwidthManager := bandwidth_manager.New(containerPath, p.shaper)
 
 	containerLogger := p.logger.Session(id)
 
@@ -334,6 +375,18 @@ func (p *LinuxContainerPool) Restore(snapshot io.Reader) (linux_backend.Containe
 		return nil, err
 	}
 
+	containerResources := linux_backend.NewResources(
+		resources.UserUID,
+		resources.RootUID,
+		resources.Network,
+		resources.Bridge,
+		resources.Ports,
+		p.externalIP,
+	)
+
+	containerResources.DNS = resources.DNS
+	containerResources.AdditionalExternalIPs = p.additionalExternalIPs
+
 	container := linux_container.NewLinuxContainer(
 		containerLogger,
 		id,
@@ -341,14 +394,7 @@ func (p *LinuxContainerPool) Restore(snapshot io.Reader) (linux_backend.Containe
 		containerPath,
 		containerSnapshot.Properties,
 		containerSnapshot.GraceTime,
-		linux_backend.NewResources(
-			resources.UserUID,
-			resources.RootUID,
-			resources.Network,
-			resources.Bridge,
-			resources.Ports,
-			p.externalIP,
-		),
+		containerResources,
 		p.portPool,
 		p.runner,
 		cgroupsManager,
@@ -357,10 +403,22 @@ func (p *LinuxContainerPool) Restore(snapshot io.Reader) (linux_backend.Containe
 		process_tracker.New(containerPath, p.runner),
 		containerEnv,
 		p.filterProvider.ProvideFilter(id),
+		p.filterProvider.ProvideInstanceChain(id),
+		p.interfaceStatter,
+		p.snapshotSaver,
+		p.events,
 	)
 
 	err = container.Restore(containerSnapshot)
 	if err != nil {
+		p.undoRestore(rLog, container, containerResources)
+		return nil, err
+	}
+
+	err = container.RestoreCheckpoint()
+	if err != nil {
+		rLog.Error("failed-to-restore-checkpoint", err)
+		p.undoRestore(rLog, container, containerResources)
 		return nil, err
 	}
 
@@ -369,6 +427,28 @@ func (p *LinuxContainerPool) Restore(snapshot io.Reader) (linux_backend.Containe
 	return container, nil
 }
 
+// undoRestore stops what restoring a container started, releases what it
+// acquired, and tears down the network rules it set up, but leaves the
+// container's directory, and so its checkpoint, alone so that restoring it can
+// be retried.
+func (p *LinuxContainerPool) undoRestore(logger lager.Logger, container linux_backend.Container, resources *linux_backend.Resources) {
+	container.Cleanup()
+
+	id := container.ID()
+
+	if err := p.filterProvider.ProvideInstanceChain(id).TearDown(); err != nil {
+		logger.Error("failed-to-tear-down-instance-chain", err)
+	}
+
+	p.filterProvider.ProvideFilter(id).TearDown()
+
+	if err := p.bridges.Release(resources.Bridge, id); err != nil {
+		logger.Error("failed-to-release-bridge", err)
+	}
+
+	p.releasePoolResources(resources)
+}
+
 func (p *LinuxContainerPool) Destroy(container linux_backend.Container) error {
 	pLog := p.logger.Session("destroy", lager.Data{
 		"id": container.ID(),
@@ -465,6 +545,7 @@ func (p *LinuxContainerPool) saveRootFSProvider(id string, provider string) erro
 
 func (p *LinuxContainerPool) acquirePoolResources(spec garden.ContainerSpec, id string) (*linux_backend.Resources, error) {
 	resources := linux_backend.NewResources(0, 1, nil, "", nil, p.externalIP)
+	resources.AdditionalExternalIPs = p.additionalExternalIPs
 
 	subnet, ip, err := parseNetworkSpec(spec.Network)
 	if err != nil {
@@ -496,7 +577,7 @@ func (p *LinuxContainerPool) acquireUID(resources *linux_backend.Resources, priv
 }
 
 func (p *LinuxContainerPool) releasePoolResources(resources *linux_backend.Resources) {
-	for _, port := range resources.Ports {
+	for _, port := range resources.PortsCopy() {
 		p.portPool.Release(port)
 	}
 
@@ -570,6 +651,13 @@ func (p *LinuxContainerPool) acquireSystemResources(id, handle, containerPath, r
 		"root_uid":             strconv.FormatUint(uint64(resources.RootUID), 10),
 		"PATH":                 os.Getenv("PATH"),
 	}
+
+	if resources.Network.IPv6Subnet != nil {
+		env["network_ipv6_cidr"] = resources.Network.IPv6Subnet.String()
+		env["network_container_ipv6"] = resources.Network.IPv6.String()
+		env["network_host_ipv6"] = subnets.GatewayIP(resources.Network.IPv6Subnet).String()
+	}
+
 	create.Env = env.Array()
 
 	pRunner := logging.Runner{
@@ -605,6 +693,15 @@ func (p *LinuxContainerPool) acquireSystemResources(id, handle, containerPath, r
 		return nil, err
 	}
 
+	// the rootfs is written before the container pivots into it
+	if resources.DNS != nil {
+		err = resources.DNS.WriteTo(rootfsPath)
+		if err != nil {
+			p.logger.Error("write-dns-config-failed", err)
+			return nil, err
+		}
+	}
+
 	filterLog := pLog.Session("setup-filter")
 
 	filterLog.Debug("starting")
@@ -641,11 +738,17 @@ func (p *LinuxContainerPool) releaseSystemResources(logger lager.Logger, id stri
 
 	bridgeName, err := ioutil.ReadFile(path.Join(p.depotPath, id, "bridge-name"))
 	if err == nil {
+		p.releaseBridgeIPv6(logger, &pRunner, id, string(bridgeName))
+
 		if err := p.bridges.Release(string(bridgeName), id); err != nil {
 			return fmt.Errorf("containerpool: release bridge %s: %v", bridgeName, err)
 		}
 	}
 
+	if err := p.filterProvider.ProvideInstanceChain(id).TearDown(); err != nil {
+		return fmt.Errorf("containerpool: tear down instance chain: %v", err)
+	}
+
 	destroy := exec.Command(path.Join(p.binPath, "destroy.sh"), path.Join(p.depotPath, id))
 
 	err = pRunner.Run(destroy)
@@ -661,6 +764,30 @@ func (p *LinuxContainerPool) releaseSystemResources(logger lager.Logger, id stri
 	return nil
 }
 
+// releaseBridgeIPv6 removes the gateway address of the container's own IPv6
+// subnet from its bridge, which outlives the container if other containers
+// share it. Failing to remove it is logged rather than returned, as it is not
+// there if the container never started.
+func (p *LinuxContainerPool) releaseBridgeIPv6(logger lager.Logger, runner command_runner.CommandRunner, id string, bridgeName string) {
+	config, err := process.EnvFromFile(path.Join(p.depotPath, id, "etc", "config"))
+	if err != nil || config["network_ipv6_cidr"] == "" {
+		return
+	}
+
+	_, ipv6Net, err := net.ParseCIDR(config["network_ipv6_cidr"])
+	if err != nil {
+		logger.Error("failed-to-parse-ipv6-subnet", err)
+		return
+	}
+
+	prefix, _ := ipv6Net.Mask.Size()
+
+	del := exec.Command("ip", "-6", "addr", "del", fmt.Sprintf("%s/%d", config["network_host_ipv6"], prefix), "dev", bridgeName)
+	if err := runner.Run(del); err != nil {
+		logger.Error("failed-to-remove-bridge-ipv6", err)
+	}
+}
+
 func getHandle(handle, id string) string {
 	if handle != "" {
 		return handle
@@ -13,6 +13,7 @@ import (
 	"os/exec"
 	"path"
 	"path/filepath"
+	"strings"
 	"time"
 
 	. "github.com/onsi/ginkgo"
@@ -23,12 +24,16 @@ import (
 	"github.com/cloudfoundry-incubator/garden-linux/container_pool/fake_container_pool"
 	"github.com/cloudfoundry-incubator/garden-linux/container_pool/fake_subnet_pool"
 	"github.com/cloudfoundry-incubator/garden-linux/linux_backend"
+	backendFakes "github.com/cloudfoundry-incubator/garden-linux/linux_backend/fakes"
 	"github.com/cloudfoundry-incubator/garden-linux/linux_container"
+	containerFakes "github.com/cloudfoundry-incubator/garden-linux/linux_container/fakes"
 	"github.com/cloudfoundry-incubator/garden-linux/network"
 	"github.com/cloudfoundry-incubator/garden-linux/network/bridgemgr/fake_bridge_manager"
 	"github.com/cloudfoundry-incubator/garden-linux/network/fakes"
 	"github.com/cloudfoundry-incubator/garden-linux/network/iptables"
+	iptablesFakes "github.com/cloudfoundry-incubator/garden-linux/network/iptables/fakes"
 	"github.com/cloudfoundry-incubator/garden-linux/network/subnets"
+	tcFakes "github.com/cloudfoundry-incubator/garden-linux/network/tc/fakes"
 	"github.com/cloudfoundry-incubator/garden-linux/old/port_pool/fake_port_pool"
 	"github.com/cloudfoundry-incubator/garden-linux/old/quota_manager/fake_quota_manager"
 	"github.com/cloudfoundry-incubator/garden-linux/old/rootfs_provider"
@@ -52,6 +57,11 @@ var _ = Describe("Container pool", func() {
 	var fakeBridges *fake_bridge_manager.FakeBridgeManager
 	var fakeFilterProvider *fake_container_pool.FakeFilterProvider
 	var fakeFilter *fakes.FakeFilter
+	var fakeInstanceChain *iptablesFakes.FakeInstanceChain
+	var fakeSnapshotSaver *containerFakes.FakeSnapshotSaver
+	var fakeShaper *tcFakes.FakeShaper
+	var fakeInterfaceStatter *fakes.FakeInterfaceStatter
+	var fakeEventEmitter *backendFakes.FakeEventEmitter
 	var pool *container_pool.LinuxContainerPool
 	var config sysconfig.Config
 
@@ -78,9 +88,16 @@ var _ = Describe("Container pool", func() {
 			return fakeFilter
 		}
 
+		fakeInstanceChain = new(iptablesFakes.FakeInstanceChain)
+		fakeFilterProvider.ProvideInstanceChainReturns(fakeInstanceChain)
+
 		fakeRunner = fake_command_runner.New()
 		fakeQuotaManager = fake_quota_manager.New()
 		fakePortPool = fake_port_pool.New(1000)
+		fakeSnapshotSaver = new(containerFakes.FakeSnapshotSaver)
+		fakeShaper = new(tcFakes.FakeShaper)
+		fakeInterfaceStatter = new(fakes.FakeInterfaceStatter)
+		fakeEventEmitter = new(backendFakes.FakeEventEmitter)
 		defaultFakeRootFSProvider = new(fake_rootfs_provider.FakeRootFSProvider)
 		fakeRootFSProvider = new(fake_rootfs_provider.FakeRootFSProvider)
 
@@ -102,6 +119,7 @@ var _ = Describe("Container pool", func() {
 			},
 			700000,
 			net.ParseIP("1.2.3.4"),
+			[]net.IP{net.ParseIP("1.2.3.5")},
 			345,
 			fakeSubnetPool,
 			fakeBridges,
@@ -112,6 +130,10 @@ var _ = Describe("Container pool", func() {
 			[]string{"1.1.1.1/32", "", "2.2.2.2/32"},
 			fakeRunner,
 			fakeQuotaManager,
+			fakeShaper,
+			fakeInterfaceStatter,
+			fakeSnapshotSaver,
+			fakeEventEmitter,
 		)
 	})
 
@@ -295,6 +317,15 @@ var _ = Describe("Container pool", func() {
 			Expect(container.Properties()).To(Equal(properties))
 		})
 
+		It("creates containers whose ports may be mapped on the external IPs", func() {
+			container, err := pool.Create(garden.ContainerSpec{})
+			Expect(err).ToNot(HaveOccurred())
+
+			resources := container.(*linux_container.LinuxContainer).Resources()
+			Expect(resources.ExternalIP).To(Equal(net.ParseIP("1.2.3.4")))
+			Expect(resources.AdditionalExternalIPs).To(Equal([]net.IP{net.ParseIP("1.2.3.5")}))
+		})
+
 		It("sets up iptable filters for the container", func() {
 			container, err := pool.Create(garden.ContainerSpec{})
 			Expect(err).ToNot(HaveOccurred())
@@ -417,6 +448,42 @@ var _ = Describe("Container pool", func() {
 			})
 		})
 
+		Context("when the network is dual-stack", func() {
+			It("executes create.sh with the IPv6 network in the environment", func() {
+				dualStackNetwork := &linux_backend.Network{}
+				dualStackNetwork.IP, dualStackNetwork.Subnet, _ = net.ParseCIDR("10.2.0.1/30")
+				dualStackNetwork.IPv6, dualStackNetwork.IPv6Subnet, _ = net.ParseCIDR("fd00::1/126")
+				fakeSubnetPool.AcquireReturns(dualStackNetwork, nil)
+
+				container, err := pool.Create(garden.ContainerSpec{})
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(fakeRunner).To(HaveExecutedSerially(
+					fake_command_runner.CommandSpec{
+						Path: "/root/path/create.sh",
+						Args: []string{path.Join(depotPath, container.ID())},
+						Env: []string{
+							"PATH=" + os.Getenv("PATH"),
+							"bridge_iface=bridge-for-10.2.0.0/30-" + container.ID(),
+							"container_iface_mtu=345",
+							"external_ip=1.2.3.4",
+							"id=" + container.ID(),
+							"network_cidr=10.2.0.0/30",
+							"network_cidr_suffix=30",
+							"network_container_ip=10.2.0.1",
+							"network_container_ipv6=fd00::1",
+							"network_host_ip=10.2.0.2",
+							"network_host_ipv6=fd00::2",
+							"network_ipv6_cidr=fd00::/126",
+							"root_uid=700000",
+							"rootfs_path=/provided/rootfs/path",
+							"user_uid=710001",
+						},
+					},
+				))
+			})
+		})
+
 		Context("when the Network parameter is specified", func() {
 			It("executes create.sh with the correct args and environment", func() {
 				differentNetwork := &linux_backend.Network{}
@@ -918,6 +985,84 @@ var _ = Describe("Container pool", func() {
 			})
 		})
 
+		Context("when DNS configuration is specified", func() {
+			var rootfsPath string
+
+			BeforeEach(func() {
+				var err error
+				rootfsPath, err = ioutil.TempDir("", "rootfs")
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(os.Mkdir(filepath.Join(rootfsPath, "etc"), 0755)).To(Succeed())
+				Expect(ioutil.WriteFile(filepath.Join(rootfsPath, "etc", "hosts"), []byte("127.0.0.1 localhost\n"), 0644)).To(Succeed())
+
+				defaultFakeRootFSProvider.ProvideRootFSReturns(rootfsPath, nil, nil)
+			})
+
+			AfterEach(func() {
+				os.RemoveAll(rootfsPath)
+			})
+
+			It("writes it into the rootfs and keeps it in the container's resources", func() {
+				container, err := pool.Create(garden.ContainerSpec{
+					Properties: garden.Properties{
+						network.DNSServersProperty: "8.8.8.8",
+						network.ExtraHostsProperty: "db:10.0.0.5",
+					},
+				})
+				Expect(err).ToNot(HaveOccurred())
+
+				resolvConf, err := ioutil.ReadFile(filepath.Join(rootfsPath, "etc", "resolv.conf"))
+				Expect(err).ToNot(HaveOccurred())
+				Expect(string(resolvConf)).To(Equal("nameserver 8.8.8.8\n"))
+
+				hosts, err := ioutil.ReadFile(filepath.Join(rootfsPath, "etc", "hosts"))
+				Expect(err).ToNot(HaveOccurred())
+				Expect(string(hosts)).To(Equal("127.0.0.1 localhost\n10.0.0.5 db\n"))
+
+				Expect(container.(*linux_container.LinuxContainer).Resources().DNS).To(Equal(&network.DNSConfig{
+					Servers:    []net.IP{net.ParseIP("8.8.8.8")},
+					ExtraHosts: []network.HostEntry{{Hostname: "db", IP: net.ParseIP("10.0.0.5")}},
+				}))
+			})
+
+			Context("when it is invalid", func() {
+				It("returns an error without acquiring any resources", func() {
+					_, err := pool.Create(garden.ContainerSpec{
+						Properties: garden.Properties{
+							network.DNSServersProperty: "not-an-ip",
+						},
+					})
+					Expect(err).To(BeAssignableToTypeOf(network.InvalidDNSPropertyError{}))
+
+					Expect(fakeSubnetPool.AcquireCallCount()).To(Equal(0))
+					Expect(fakeRunner.ExecutedCommands()).To(BeEmpty())
+				})
+			})
+
+			Context("when it cannot be written", func() {
+				var err error
+
+				BeforeEach(func() {
+					Expect(os.RemoveAll(filepath.Join(rootfsPath, "etc"))).To(Succeed())
+
+					_, err = pool.Create(garden.ContainerSpec{
+						Properties: garden.Properties{
+							network.DNSServersProperty: "8.8.8.8",
+						},
+					})
+				})
+
+				It("returns the error", func() {
+					Expect(err).To(BeAssignableToTypeOf(network.DNSConfigError{}))
+				})
+
+				itReleasesTheIPBlock()
+				itCleansUpTheRootfs()
+				itDeletesTheContainerDirectory()
+			})
+		})
+
 		Context("when executing create.sh fails", func() {
 			nastyError := errors.New("oh no!")
 			var err error
@@ -987,9 +1132,15 @@ var _ = Describe("Container pool", func() {
 		var containerNetwork *linux_backend.Network
 		var rootUID int
 		var bridgeName string
+		var checkpoint string
+		var dnsConfig *network.DNSConfig
+		var limits linux_container.LimitsSnapshot
 
 		BeforeEach(func() {
 			rootUID = 10001
+			checkpoint = ""
+			dnsConfig = nil
+			limits = linux_container.LimitsSnapshot{}
 
 			buf = new(bytes.Buffer)
 			snapshot = buf
@@ -1005,15 +1156,17 @@ var _ = Describe("Container pool", func() {
 		JustBeforeEach(func() {
 			err := json.NewEncoder(buf).Encode(
 				linux_container.ContainerSnapshot{
+					Version: linux_container.CurrentSnapshotVersion,
+
 					ID:     "some-restored-id",
 					Handle: "some-restored-handle",
 
 					GraceTime: 1 * time.Second,
 
 					State: "some-restored-state",
-					Events: []string{
-						"some-restored-event",
-						"some-other-restored-event",
+					Events: []linux_container.Event{
+						{Type: "some-restored-event", Count: 1},
+						{Type: "some-other-restored-event", Count: 1},
 					},
 
 					Resources: linux_container.ResourcesSnapshot{
@@ -1022,11 +1175,16 @@ var _ = Describe("Container pool", func() {
 						Network: containerNetwork,
 						Bridge:  bridgeName,
 						Ports:   []uint32{61001, 61002, 61003},
+						DNS:     dnsConfig,
 					},
 
 					Properties: map[string]string{
 						"foo": "bar",
 					},
+
+					Limits: limits,
+
+					Checkpoint: checkpoint,
 				},
 			)
 			Expect(err).ToNot(HaveOccurred())
@@ -1053,6 +1211,23 @@ var _ = Describe("Container pool", func() {
 
 			Expect(linuxContainer.Resources().Network).To(Equal(containerNetwork))
 			Expect(linuxContainer.Resources().Bridge).To(Equal("some-bridge"))
+			Expect(linuxContainer.Resources().AdditionalExternalIPs).To(Equal([]net.IP{net.ParseIP("1.2.3.5")}))
+		})
+
+		Context("when the snapshot has DNS configuration", func() {
+			BeforeEach(func() {
+				dnsConfig = &network.DNSConfig{
+					Servers:       []net.IP{net.ParseIP("8.8.8.8")},
+					SearchDomains: []string{"example.com"},
+				}
+			})
+
+			It("restores it into the container's resources", func() {
+				container, err := pool.Restore(snapshot)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(container.(*linux_container.LinuxContainer).Resources().DNS).To(Equal(dnsConfig))
+			})
 		})
 
 		It("removes its network from the pool", func() {
@@ -1155,6 +1330,119 @@ var _ = Describe("Container pool", func() {
 				})
 			})
 		})
+		Context("when the snapshot has a checkpoint", func() {
+			BeforeEach(func() {
+				checkpoint = "/some/images/dir"
+			})
+
+			It("restores the container's processes from it", func() {
+				container, err := pool.Restore(snapshot)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(fakeRunner).To(HaveExecutedSerially(
+					fake_command_runner.CommandSpec{
+						Path: "criu",
+						Args: []string{
+							"restore",
+							"--images-dir", "/some/images/dir",
+							"--restore-detached",
+							"--pidfile", path.Join(depotPath, "some-restored-id", "run", "wshd.pid"),
+							"--manage-cgroups",
+							"--tcp-established",
+							"--ext-unix-sk",
+							"--file-locks",
+						},
+					},
+				))
+
+				linuxContainer := container.(*linux_container.LinuxContainer)
+				Expect(linuxContainer.State()).To(Equal(linux_container.StateActive))
+			})
+
+			Context("when restoring the checkpoint fails", func() {
+				disaster := errors.New("oh no!")
+
+				BeforeEach(func() {
+					fakeRunner.WhenRunning(
+						fake_command_runner.CommandSpec{
+							Path: "criu",
+						}, func(*exec.Cmd) error {
+							return disaster
+						},
+					)
+				})
+
+				It("returns the error", func() {
+					_, err := pool.Restore(snapshot)
+					Expect(err).To(Equal(disaster))
+				})
+
+				It("releases the network, the ports and the bridge", func() {
+					pool.Restore(snapshot)
+
+					Expect(fakeSubnetPool.ReleaseCallCount()).To(Equal(1))
+					Expect(fakeSubnetPool.ReleaseArgsForCall(0)).To(Equal(containerNetwork))
+
+					Expect(fakePortPool.Released).To(ContainElement(uint32(61001)))
+					Expect(fakePortPool.Released).To(ContainElement(uint32(61002)))
+					Expect(fakePortPool.Released).To(ContainElement(uint32(61003)))
+
+					Expect(fakeBridges.ReleaseCallCount()).To(Equal(1))
+					bridgeName, containerId := fakeBridges.ReleaseArgsForCall(0)
+					Expect(bridgeName).To(Equal("some-bridge"))
+					Expect(containerId).To(Equal("some-restored-id"))
+				})
+
+				It("tears down the network rules it set up", func() {
+					pool.Restore(snapshot)
+
+					Expect(fakeInstanceChain.TearDownCallCount()).To(Equal(1))
+					Expect(fakeFilter.TearDownCallCount()).To(Equal(1))
+				})
+
+				Context("when the snapshot has memory limits", func() {
+					BeforeEach(func() {
+						limits.Memory = &garden.MemoryLimits{LimitInBytes: 1024}
+					})
+
+					It("stops the OOM notifier it started", func() {
+						pool.Restore(snapshot)
+
+						Expect(fakeRunner).To(HaveKilled(fake_command_runner.CommandSpec{
+							Path: path.Join(depotPath, "some-restored-id", "bin", "oom"),
+						}))
+					})
+				})
+
+				It("keeps the container's directory, to retry restoring it", func() {
+					pool.Restore(snapshot)
+
+					Expect(fakeRunner).ToNot(HaveExecutedSerially(fake_command_runner.CommandSpec{
+						Path: "/root/path/destroy.sh",
+					}))
+				})
+			})
+		})
+
+		Context("when the snapshot is from a newer version", func() {
+			BeforeEach(func() {
+				snapshot = strings.NewReader(fmt.Sprintf(
+					`{"Version":%d,"ID":"some-restored-id"}`,
+					linux_container.CurrentSnapshotVersion+1,
+				))
+			})
+
+			It("returns a FutureSnapshotVersionError", func() {
+				_, err := pool.Restore(snapshot)
+				Expect(err).To(BeAssignableToTypeOf(linux_container.FutureSnapshotVersionError{}))
+			})
+
+			It("does not remove its network from the pool", func() {
+				pool.Restore(snapshot)
+
+				Expect(fakeSubnetPool.RemoveCallCount()).To(Equal(0))
+			})
+		})
 	})
 
 	Describe("pruning", func() {
@@ -1302,6 +1590,25 @@ var _ = Describe("Container pool", func() {
 				})
 			})
 
+			Context("when a file is found in the depot", func() {
+				BeforeEach(func() {
+					err := ioutil.WriteFile(path.Join(depotPath, "journal"), []byte("{}"), 0644)
+					Expect(err).ToNot(HaveOccurred())
+				})
+
+				It("is not destroyed", func() {
+					err := pool.Prune(map[string]bool{})
+					Expect(err).ToNot(HaveOccurred())
+
+					Expect(fakeRunner).ToNot(HaveExecutedSerially(
+						fake_command_runner.CommandSpec{
+							Path: "/root/path/destroy.sh",
+							Args: []string{path.Join(depotPath, "journal")},
+						},
+					))
+				})
+			})
+
 			Context("when a container to keep is specified", func() {
 				It("is not destroyed", func() {
 					err := pool.Prune(map[string]bool{"container-2": true})
@@ -1415,6 +1722,66 @@ var _ = Describe("Container pool", func() {
 				Expect(containerId).To(Equal(createdContainer.ID()))
 			})
 
+			Context("when the container has its own IPv6 subnet", func() {
+				BeforeEach(func() {
+					err := os.MkdirAll(path.Join(depotPath, createdContainer.ID(), "etc"), 0755)
+					Expect(err).ToNot(HaveOccurred())
+
+					err = ioutil.WriteFile(path.Join(depotPath, createdContainer.ID(), "etc", "config"), []byte(
+						"network_ipv6_cidr=fd00::4/126\n"+
+							"network_host_ipv6=fd00::5\n"+
+							"network_container_ipv6=fd00::6\n",
+					), 0644)
+					Expect(err).ToNot(HaveOccurred())
+				})
+
+				It("removes the IPv6 gateway address from the bridge before releasing it", func() {
+					fakeBridges.ReleaseStub = func(string, string) error {
+						Expect(fakeRunner).To(HaveExecutedSerially(
+							fake_command_runner.CommandSpec{
+								Path: "ip",
+								Args: []string{"-6", "addr", "del", "fd00::5/126", "dev", "the-bridge"},
+							},
+						))
+
+						return nil
+					}
+
+					err := pool.Destroy(createdContainer)
+					Expect(err).ToNot(HaveOccurred())
+
+					Expect(fakeBridges.ReleaseCallCount()).To(Equal(1))
+				})
+
+				Context("when removing the address fails", func() {
+					BeforeEach(func() {
+						fakeRunner.WhenRunning(fake_command_runner.CommandSpec{
+							Path: "ip",
+						}, func(*exec.Cmd) error {
+							return errors.New("Cannot assign requested address")
+						})
+					})
+
+					It("releases the bridge all the same", func() {
+						err := pool.Destroy(createdContainer)
+						Expect(err).ToNot(HaveOccurred())
+
+						Expect(fakeBridges.ReleaseCallCount()).To(Equal(1))
+					})
+				})
+			})
+
+			Context("when the container has no IPv6 subnet", func() {
+				It("does not touch the bridge's addresses", func() {
+					err := pool.Destroy(createdContainer)
+					Expect(err).ToNot(HaveOccurred())
+
+					Expect(fakeRunner).ToNot(HaveExecutedSerially(
+						fake_command_runner.CommandSpec{Path: "ip"},
+					))
+				})
+			})
+
 			Context("when the releasing the bridge fails", func() {
 				It("returns the error", func() {
 					releaseErr := errors.New("jam in the bridge")
@@ -1434,6 +1801,45 @@ var _ = Describe("Container pool", func() {
 			Expect(fakeFilter.TearDownCallCount()).To(Equal(1))
 		})
 
+		It("tears down the instance chains before running destroy.sh", func() {
+			fakeRunner.WhenRunning(
+				fake_command_runner.CommandSpec{
+					Path: "/root/path/destroy.sh",
+				},
+				func(*exec.Cmd) error {
+					Expect(fakeInstanceChain.TearDownCallCount()).To(Equal(1))
+					return nil
+				},
+			)
+
+			err := pool.Destroy(createdContainer)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(fakeFilterProvider.ProvideInstanceChainArgsForCall(0)).To(Equal(createdContainer.ID()))
+			Expect(fakeInstanceChain.TearDownCallCount()).To(Equal(1))
+		})
+
+		Context("when tearing down the instance chains fails", func() {
+			BeforeEach(func() {
+				fakeInstanceChain.TearDownReturns(errors.New("chain reaction"))
+			})
+
+			It("returns the error", func() {
+				err := pool.Destroy(createdContainer)
+				Expect(err).To(MatchError("containerpool: tear down instance chain: chain reaction"))
+			})
+
+			It("does not run destroy.sh", func() {
+				pool.Destroy(createdContainer)
+
+				Expect(fakeRunner).ToNot(HaveExecutedSerially(
+					fake_command_runner.CommandSpec{
+						Path: "/root/path/destroy.sh",
+					},
+				))
+			})
+		})
+
 		Context("when the container has a rootfs provider defined", func() {
 			BeforeEach(func() {
 				err := os.MkdirAll(path.Join(depotPath, createdContainer.ID()), 0755)
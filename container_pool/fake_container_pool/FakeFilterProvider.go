@@ -6,6 +6,7 @@ import (
 
 	"github.com/cloudfoundry-incubator/garden-linux/container_pool"
 	"github.com/cloudfoundry-incubator/garden-linux/network"
+	"github.com/cloudfoundry-incubator/garden-linux/network/iptables"
 )
 
 type FakeFilterProvider struct {
@@ -17,6 +18,14 @@ type FakeFilterProvider struct {
 	provideFilterReturns struct {
 		result1 network.Filter
 	}
+	ProvideInstanceChainStub        func(containerId string) iptables.InstanceChain
+	provideInstanceChainMutex       sync.RWMutex
+	provideInstanceChainArgsForCall []struct {
+		containerId string
+	}
+	provideInstanceChainReturns struct {
+		result1 iptables.InstanceChain
+	}
 }
 
 func (fake *FakeFilterProvider) ProvideFilter(containerId string) network.Filter {
@@ -51,4 +60,36 @@ func (fake *FakeFilterProvider) ProvideFilterReturns(result1 network.Filter) {
 	}{result1}
 }
 
+func (fake *FakeFilterProvider) ProvideInstanceChain(containerId string) iptables.InstanceChain {
+	fake.provideInstanceChainMutex.Lock()
+	fake.provideInstanceChainArgsForCall = append(fake.provideInstanceChainArgsForCall, struct {
+		containerId string
+	}{containerId})
+	fake.provideInstanceChainMutex.Unlock()
+	if fake.ProvideInstanceChainStub != nil {
+		return fake.ProvideInstanceChainStub(containerId)
+	} else {
+		return fake.provideInstanceChainReturns.result1
+	}
+}
+
+func (fake *FakeFilterProvider) ProvideInstanceChainCallCount() int {
+	fake.provideInstanceChainMutex.RLock()
+	defer fake.provideInstanceChainMutex.RUnlock()
+	return len(fake.provideInstanceChainArgsForCall)
+}
+
+func (fake *FakeFilterProvider) ProvideInstanceChainArgsForCall(i int) string {
+	fake.provideInstanceChainMutex.RLock()
+	defer fake.provideInstanceChainMutex.RUnlock()
+	return fake.provideInstanceChainArgsForCall[i].containerId
+}
+
+func (fake *FakeFilterProvider) ProvideInstanceChainReturns(result1 iptables.InstanceChain) {
+	fake.ProvideInstanceChainStub = nil
+	fake.provideInstanceChainReturns = struct {
+		result1 iptables.InstanceChain
+	}{result1}
+}
+
 var _ container_pool.FilterProvider = new(FakeFilterProvider)
@@ -2,10 +2,13 @@ package fake_container_pool
 
 import (
 	"io"
+	"net"
 	"sync"
 	"time"
 
 	"github.com/cloudfoundry-incubator/garden"
+	"github.com/cloudfoundry-incubator/garden-linux/linux_backend"
+	"github.com/cloudfoundry-incubator/garden-linux/old/bandwidth_manager"
 	"github.com/cloudfoundry-incubator/garden/fakes"
 )
 
@@ -16,12 +19,51 @@ type FakeContainer struct {
 
 	SnapshotError  error
 	SavedSnapshots []io.Writer
+	SnapshotsSaved int
 	snapshotMutex  *sync.RWMutex
 
 	StartError error
 	Started    bool
 
 	CleanedUp bool
+
+	GracefulStopSpecs   []linux_backend.StopSpec
+	GracefulStopResults []linux_backend.ProcessStopResult
+	GracefulStopError   error
+
+	PauseError  error
+	Paused      bool
+	ResumeError error
+
+	CheckpointError error
+	CheckpointedTo  []string
+
+	RestoreCheckpointError error
+	CheckpointRestored     bool
+
+	NetInWithProtocolError error
+	NetInOnExternalIPError error
+	NetInRemoveError       error
+	RemovedNetIns          []uint32
+	MappedPorts            []linux_backend.PortMapping
+
+	BulkNetOutError   error
+	NetOutRemoveError error
+	RemovedNetOuts    []garden.NetOutRule
+	GrantedNetOuts    []garden.NetOutRule
+
+	AllowTrafficError   error
+	AllowedTraffic      []linux_backend.TrafficPolicy
+	AllowedDestinations [][]net.IP
+	DenyTrafficError    error
+	DeniedTraffic       []linux_backend.TrafficPolicy
+	Policies            []linux_backend.TrafficPolicy
+
+	NetworkMetricsError  error
+	ReportedNetworkStats linux_backend.NetworkMetrics
+
+	LimitDirectionalBandwidthError error
+	DirectionalBandwidthLimits     bandwidth_manager.Limits
 }
 
 func NewFakeContainer(spec garden.ContainerSpec) *FakeContainer {
@@ -68,6 +110,170 @@ func (c *FakeContainer) Cleanup() {
 	c.CleanedUp = true
 }
 
+func (c *FakeContainer) GracefulStop(spec linux_backend.StopSpec) ([]linux_backend.ProcessStopResult, error) {
+	if c.GracefulStopError != nil {
+		return nil, c.GracefulStopError
+	}
+
+	c.GracefulStopSpecs = append(c.GracefulStopSpecs, spec)
+
+	return c.GracefulStopResults, nil
+}
+
+func (c *FakeContainer) Pause() error {
+	if c.PauseError != nil {
+		return c.PauseError
+	}
+
+	c.Paused = true
+
+	return nil
+}
+
+func (c *FakeContainer) Resume() error {
+	if c.ResumeError != nil {
+		return c.ResumeError
+	}
+
+	c.Paused = false
+
+	return nil
+}
+
+func (c *FakeContainer) Checkpoint(dir string) error {
+	if c.CheckpointError != nil {
+		return c.CheckpointError
+	}
+
+	c.CheckpointedTo = append(c.CheckpointedTo, dir)
+
+	return nil
+}
+
+func (c *FakeContainer) RestoreCheckpoint() error {
+	if c.RestoreCheckpointError != nil {
+		return c.RestoreCheckpointError
+	}
+
+	c.CheckpointRestored = true
+
+	return nil
+}
+
+func (c *FakeContainer) NetInWithProtocol(hostPort, containerPort uint32, protocol garden.Protocol) (uint32, uint32, error) {
+	if c.NetInWithProtocolError != nil {
+		return 0, 0, c.NetInWithProtocolError
+	}
+
+	c.MappedPorts = append(c.MappedPorts, linux_backend.PortMapping{
+		HostPort:      hostPort,
+		ContainerPort: containerPort,
+		Protocol:      protocol,
+	})
+
+	return hostPort, containerPort, nil
+}
+
+func (c *FakeContainer) NetInOnExternalIP(externalIP net.IP, hostPort, containerPort uint32, protocol garden.Protocol) (uint32, uint32, error) {
+	if c.NetInOnExternalIPError != nil {
+		return 0, 0, c.NetInOnExternalIPError
+	}
+
+	c.MappedPorts = append(c.MappedPorts, linux_backend.PortMapping{
+		HostPort:      hostPort,
+		ContainerPort: containerPort,
+		Protocol:      protocol,
+		ExternalIP:    externalIP,
+	})
+
+	return hostPort, containerPort, nil
+}
+
+func (c *FakeContainer) NetInRemove(hostPort uint32) error {
+	if c.NetInRemoveError != nil {
+		return c.NetInRemoveError
+	}
+
+	c.RemovedNetIns = append(c.RemovedNetIns, hostPort)
+
+	return nil
+}
+
+func (c *FakeContainer) NetIns() []linux_backend.PortMapping {
+	return c.MappedPorts
+}
+
+func (c *FakeContainer) BulkNetOut(rules []garden.NetOutRule) error {
+	if c.BulkNetOutError != nil {
+		return c.BulkNetOutError
+	}
+
+	c.GrantedNetOuts = append(c.GrantedNetOuts, rules...)
+
+	return nil
+}
+
+func (c *FakeContainer) NetOutRemove(rule garden.NetOutRule) error {
+	if c.NetOutRemoveError != nil {
+		return c.NetOutRemoveError
+	}
+
+	c.RemovedNetOuts = append(c.RemovedNetOuts, rule)
+
+	return nil
+}
+
+func (c *FakeContainer) NetOutRules() []garden.NetOutRule {
+	return c.GrantedNetOuts
+}
+
+func (c *FakeContainer) AllowTraffic(policy linux_backend.TrafficPolicy, destinations []net.IP) error {
+	if c.AllowTrafficError != nil {
+		return c.AllowTrafficError
+	}
+
+	c.AllowedTraffic = append(c.AllowedTraffic, policy)
+	c.AllowedDestinations = append(c.AllowedDestinations, destinations)
+
+	return nil
+}
+
+func (c *FakeContainer) DenyTraffic(policy linux_backend.TrafficPolicy) error {
+	if c.DenyTrafficError != nil {
+		return c.DenyTrafficError
+	}
+
+	c.DeniedTraffic = append(c.DeniedTraffic, policy)
+
+	return nil
+}
+
+func (c *FakeContainer) TrafficPolicies() []linux_backend.TrafficPolicy {
+	return c.Policies
+}
+
+func (c *FakeContainer) NetworkMetrics() (linux_backend.NetworkMetrics, error) {
+	if c.NetworkMetricsError != nil {
+		return linux_backend.NetworkMetrics{}, c.NetworkMetricsError
+	}
+
+	return c.ReportedNetworkStats, nil
+}
+
+func (c *FakeContainer) LimitDirectionalBandwidth(limits bandwidth_manager.Limits) error {
+	if c.LimitDirectionalBandwidthError != nil {
+		return c.LimitDirectionalBandwidthError
+	}
+
+	c.DirectionalBandwidthLimits = limits
+
+	return nil
+}
+
+func (c *FakeContainer) CurrentDirectionalBandwidthLimits() (bandwidth_manager.Limits, error) {
+	return c.DirectionalBandwidthLimits, nil
+}
+
 func (c *FakeContainer) GraceTime() time.Duration {
 	return c.Spec.GraceTime
 }
@@ -84,3 +290,16 @@ func (c *FakeContainer) Snapshot(snapshot io.Writer) error {
 
 	return nil
 }
+
+func (c *FakeContainer) SaveSnapshot() error {
+	if c.SnapshotError != nil {
+		return c.SnapshotError
+	}
+
+	c.snapshotMutex.Lock()
+	defer c.snapshotMutex.Unlock()
+
+	c.SnapshotsSaved++
+
+	return nil
+}
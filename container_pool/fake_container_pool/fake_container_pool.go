@@ -3,6 +3,7 @@ package fake_container_pool
 import (
 	"fmt"
 	"io"
+	"io/ioutil"
 
 	"github.com/cloudfoundry-incubator/garden"
 	"github.com/cloudfoundry-incubator/garden-linux/linux_backend"
@@ -86,13 +87,22 @@ func (p *FakeContainerPool) Restore(snapshot io.Reader) (linux_backend.Container
 		return nil, p.RestoreError
 	}
 
-	var handle string
-
-	_, err := fmt.Fscanf(snapshot, "%s", &handle)
-	if err != nil && err != io.EOF {
+	body, err := ioutil.ReadAll(snapshot)
+	if err != nil {
 		return nil, err
 	}
 
+	// snapshots are either JSON with a handle, or just the handle
+	var handle string
+	if header, err := linux_backend.ReadSnapshotHeader(body); err == nil {
+		handle = header.Handle
+	} else {
+		_, err := fmt.Sscanf(string(body), "%s", &handle)
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+	}
+
 	container := NewFakeContainer(
 		garden.ContainerSpec{
 			Handle: handle,
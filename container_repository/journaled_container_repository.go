@@ -0,0 +1,308 @@
+package container_repository
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/cloudfoundry-incubator/garden-linux/linux_backend"
+	"github.com/pivotal-golang/lager"
+)
+
+const (
+	journalOpAdd    = "add"
+	journalOpDelete = "delete"
+)
+
+type journalEntry struct {
+	Op     string
+	ID     string
+	Handle string
+
+	Snapshot json.RawMessage `json:",omitempty"`
+}
+
+// JournaledContainerRepository is an in-memory repository which also appends
+// every Add and Delete to a journal file as they happen, so that the set of
+// live containers can be recovered after the server dies without a clean
+// shutdown. It is also a snapshot saver, so that each container's latest
+// snapshot is journaled whenever its state changes, not just when it is added.
+type JournaledContainerRepository struct {
+	*InMemoryContainerRepository
+
+	logger lager.Logger
+
+	journalPath  string
+	journalMutex *sync.Mutex
+}
+
+func NewJournaled(logger lager.Logger, journalPath string) *JournaledContainerRepository {
+	return &JournaledContainerRepository{
+		InMemoryContainerRepository: New(),
+
+		logger: logger.Session("journal"),
+
+		journalPath:  journalPath,
+		journalMutex: &sync.Mutex{},
+	}
+}
+
+func (cr *JournaledContainerRepository) Add(container linux_backend.Container) {
+	cr.InMemoryContainerRepository.Add(container)
+
+	cr.record(cr.addEntry(container))
+}
+
+// Save journals the container's current snapshot, superseding the one it was
+// added with. Containers which are not in the repository, e.g. because they
+// have since been deleted, are not journaled, so that they are not revived.
+func (cr *JournaledContainerRepository) Save(container linux_backend.Container) error {
+	entry := cr.addEntry(container)
+
+	cr.journalMutex.Lock()
+	defer cr.journalMutex.Unlock()
+
+	found, err := cr.FindByHandle(container.Handle())
+	if err != nil || found.ID() != container.ID() {
+		return nil
+	}
+
+	return cr.recordLocked(entry)
+}
+
+func (cr *JournaledContainerRepository) Delete(container linux_backend.Container) {
+	cr.InMemoryContainerRepository.Delete(container)
+
+	cr.record(journalEntry{
+		Op:     journalOpDelete,
+		ID:     container.ID(),
+		Handle: container.Handle(),
+	})
+}
+
+// Replay reads the journal and restores every container which was added but
+// never deleted, using the given restore function. Containers which are
+// already in the repository (e.g. restored from a snapshot) are left alone.
+//
+// The journal is then compacted down to the containers which are live.
+func (cr *JournaledContainerRepository) Replay(restore func(io.Reader) (linux_backend.Container, error)) error {
+	rLog := cr.logger.Session("replay")
+
+	entries, err := cr.readEntries()
+	if err != nil {
+		rLog.Error("failed-to-read", err)
+		return err
+	}
+
+	for _, entry := range entries {
+		if _, err := cr.FindByHandle(entry.Handle); err == nil {
+			continue
+		}
+
+		if entry.Snapshot == nil {
+			rLog.Info("no-snapshot", lager.Data{"id": entry.ID})
+			continue
+		}
+
+		container, err := restore(bytes.NewReader(entry.Snapshot))
+		if err != nil {
+			rLog.Error("failed-to-restore", err, lager.Data{"id": entry.ID})
+			continue
+		}
+
+		cr.InMemoryContainerRepository.Add(container)
+
+		rLog.Info("restored", lager.Data{"id": entry.ID})
+	}
+
+	return cr.compact()
+}
+
+// Epochs returns the epoch of the latest journaled snapshot of each container
+// which was added but never deleted, by ID.
+func (cr *JournaledContainerRepository) Epochs() (map[string]uint64, error) {
+	entries, err := cr.readEntries()
+	if err != nil {
+		return nil, err
+	}
+
+	epochs := map[string]uint64{}
+	for _, entry := range entries {
+		if entry.Snapshot == nil {
+			continue
+		}
+
+		header, err := linux_backend.ReadSnapshotHeader(entry.Snapshot)
+		if err != nil {
+			cr.logger.Error("failed-to-read-snapshot-header", err, lager.Data{"id": entry.ID})
+			continue
+		}
+
+		epochs[entry.ID] = header.Epoch
+	}
+
+	return epochs, nil
+}
+
+// addEntry returns an add entry holding the container's current snapshot.
+func (cr *JournaledContainerRepository) addEntry(container linux_backend.Container) journalEntry {
+	entry := journalEntry{
+		Op:     journalOpAdd,
+		ID:     container.ID(),
+		Handle: container.Handle(),
+	}
+
+	snapshot := new(bytes.Buffer)
+	if err := container.Snapshot(snapshot); err != nil {
+		cr.logger.Error("failed-to-snapshot", err, lager.Data{
+			"id": container.ID(),
+		})
+	} else {
+		entry.Snapshot = json.RawMessage(bytes.TrimSpace(snapshot.Bytes()))
+	}
+
+	return entry
+}
+
+func (cr *JournaledContainerRepository) record(entry journalEntry) {
+	cr.journalMutex.Lock()
+	defer cr.journalMutex.Unlock()
+
+	cr.recordLocked(entry)
+}
+
+func (cr *JournaledContainerRepository) recordLocked(entry journalEntry) error {
+	journal, err := os.OpenFile(cr.journalPath, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		cr.logger.Error("failed-to-open", err)
+		return err
+	}
+
+	defer journal.Close()
+
+	err = json.NewEncoder(journal).Encode(entry)
+	if err != nil {
+		cr.logger.Error("failed-to-record", err, lager.Data{
+			"op": entry.Op,
+			"id": entry.ID,
+		})
+		return err
+	}
+
+	err = journal.Sync()
+	if err != nil {
+		cr.logger.Error("failed-to-sync", err)
+		return err
+	}
+
+	return nil
+}
+
+// readEntries returns the latest add entry of every container that has not
+// since been deleted, in the order they were first added.
+func (cr *JournaledContainerRepository) readEntries() ([]journalEntry, error) {
+	cr.journalMutex.Lock()
+	defer cr.journalMutex.Unlock()
+
+	journal, err := os.Open(cr.journalPath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer journal.Close()
+
+	order := []string{}
+	live := map[string]journalEntry{}
+
+	decoder := json.NewDecoder(bufio.NewReader(journal))
+	for {
+		var entry journalEntry
+
+		err := decoder.Decode(&entry)
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			// a torn write at the tail of the journal is expected after a crash
+			cr.logger.Error("truncated-journal", err)
+			break
+		}
+
+		switch entry.Op {
+		case journalOpAdd:
+			if _, found := live[entry.ID]; !found {
+				order = append(order, entry.ID)
+			}
+
+			live[entry.ID] = entry
+		case journalOpDelete:
+			delete(live, entry.ID)
+		}
+	}
+
+	entries := []journalEntry{}
+	for _, id := range order {
+		if entry, found := live[id]; found {
+			entries = append(entries, entry)
+			delete(live, id)
+		}
+	}
+
+	return entries, nil
+}
+
+func (cr *JournaledContainerRepository) compact() error {
+	containers := cr.All()
+
+	cr.journalMutex.Lock()
+	defer cr.journalMutex.Unlock()
+
+	tmp, err := ioutil.TempFile(filepath.Dir(cr.journalPath), filepath.Base(cr.journalPath))
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(tmp)
+
+	for _, container := range containers {
+		entry := journalEntry{
+			Op:     journalOpAdd,
+			ID:     container.ID(),
+			Handle: container.Handle(),
+		}
+
+		snapshot := new(bytes.Buffer)
+		if err := container.Snapshot(snapshot); err == nil {
+			entry.Snapshot = json.RawMessage(bytes.TrimSpace(snapshot.Bytes()))
+		}
+
+		if err := encoder.Encode(entry); err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+			return err
+		}
+	}
+
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	return os.Rename(tmp.Name(), cr.journalPath)
+}
@@ -0,0 +1,227 @@
+package container_repository_test
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pivotal-golang/lager/lagertest"
+
+	"github.com/cloudfoundry-incubator/garden-linux/container_repository"
+	"github.com/cloudfoundry-incubator/garden-linux/linux_backend"
+	"github.com/cloudfoundry-incubator/garden-linux/linux_backend/fakes"
+)
+
+var _ = Describe("JournaledContainerRepository", func() {
+	var journalPath string
+	var repo *container_repository.JournaledContainerRepository
+
+	newContainer := func(handle string) *fakes.FakeContainer {
+		container := new(fakes.FakeContainer)
+		container.IDReturns("id-" + handle)
+		container.HandleReturns(handle)
+		container.SnapshotStub = func(out io.Writer) error {
+			_, err := fmt.Fprintf(out, `{"Handle":%q}`+"\n", handle)
+			return err
+		}
+
+		return container
+	}
+
+	restored := []string{}
+
+	restore := func(snapshot io.Reader) (linux_backend.Container, error) {
+		body, err := ioutil.ReadAll(snapshot)
+		Expect(err).ToNot(HaveOccurred())
+
+		restored = append(restored, string(body))
+
+		var handle string
+		fmt.Sscanf(string(body), `{"Handle":%q}`, &handle)
+
+		return newContainer(handle), nil
+	}
+
+	BeforeEach(func() {
+		tmpdir, err := ioutil.TempDir("", "journal")
+		Expect(err).ToNot(HaveOccurred())
+
+		journalPath = path.Join(tmpdir, "journal")
+
+		restored = []string{}
+
+		repo = container_repository.NewJournaled(lagertest.NewTestLogger("test"), journalPath)
+	})
+
+	It("keeps track of containers in memory", func() {
+		container := newContainer("some-handle")
+		repo.Add(container)
+
+		found, err := repo.FindByHandle("some-handle")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(found).To(Equal(container))
+
+		repo.Delete(container)
+
+		_, err = repo.FindByHandle("some-handle")
+		Expect(err).To(HaveOccurred())
+	})
+
+	Describe("replaying", func() {
+		var replayedRepo *container_repository.JournaledContainerRepository
+
+		BeforeEach(func() {
+			repo.Add(newContainer("handle-a"))
+			repo.Add(newContainer("handle-b"))
+			repo.Delete(newContainer("handle-a"))
+			repo.Add(newContainer("handle-c"))
+
+			replayedRepo = container_repository.NewJournaled(lagertest.NewTestLogger("test"), journalPath)
+		})
+
+		It("restores the containers which were added but never deleted", func() {
+			Expect(replayedRepo.Replay(restore)).To(Succeed())
+
+			Expect(restored).To(Equal([]string{
+				`{"Handle":"handle-b"}`,
+				`{"Handle":"handle-c"}`,
+			}))
+
+			Expect(replayedRepo.All()).To(HaveLen(2))
+
+			_, err := replayedRepo.FindByHandle("handle-b")
+			Expect(err).ToNot(HaveOccurred())
+
+			_, err = replayedRepo.FindByHandle("handle-c")
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("compacts the journal down to the live containers", func() {
+			Expect(replayedRepo.Replay(restore)).To(Succeed())
+
+			restored = []string{}
+
+			Expect(container_repository.NewJournaled(lagertest.NewTestLogger("test"), journalPath).Replay(restore)).To(Succeed())
+			Expect(restored).To(HaveLen(2))
+		})
+
+		Context("when a container is already in the repository", func() {
+			BeforeEach(func() {
+				replayedRepo.Add(newContainer("handle-b"))
+			})
+
+			It("does not restore it again", func() {
+				Expect(replayedRepo.Replay(restore)).To(Succeed())
+
+				Expect(restored).To(Equal([]string{
+					`{"Handle":"handle-c"}`,
+				}))
+			})
+		})
+
+		Context("when restoring a container fails", func() {
+			It("skips it", func() {
+				Expect(replayedRepo.Replay(func(io.Reader) (linux_backend.Container, error) {
+					return nil, errors.New("oh no!")
+				})).To(Succeed())
+
+				Expect(replayedRepo.All()).To(BeEmpty())
+			})
+		})
+
+		Context("when the journal ends with a torn write", func() {
+			BeforeEach(func() {
+				journal, err := os.OpenFile(journalPath, os.O_WRONLY|os.O_APPEND, 0644)
+				Expect(err).ToNot(HaveOccurred())
+
+				_, err = journal.Write([]byte(`{"Op":"delete","ID":"id-han`))
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(journal.Close()).To(Succeed())
+			})
+
+			It("replays everything before it", func() {
+				Expect(replayedRepo.Replay(restore)).To(Succeed())
+				Expect(restored).To(HaveLen(2))
+			})
+		})
+
+		Context("when there is no journal", func() {
+			BeforeEach(func() {
+				Expect(os.Remove(journalPath)).To(Succeed())
+			})
+
+			It("restores nothing", func() {
+				Expect(replayedRepo.Replay(restore)).To(Succeed())
+				Expect(restored).To(BeEmpty())
+			})
+		})
+	})
+	Describe("saving", func() {
+		It("journals the latest snapshot of the container", func() {
+			container := newContainer("some-handle")
+			repo.Add(container)
+
+			container.SnapshotStub = func(out io.Writer) error {
+				_, err := fmt.Fprintf(out, `{"Handle":"some-handle","Epoch":2}`+"\n")
+				return err
+			}
+
+			Expect(repo.Save(container)).To(Succeed())
+
+			Expect(container_repository.NewJournaled(lagertest.NewTestLogger("test"), journalPath).Replay(restore)).To(Succeed())
+			Expect(restored).To(Equal([]string{
+				`{"Handle":"some-handle","Epoch":2}`,
+			}))
+		})
+
+		Context("when the container has been deleted", func() {
+			It("does not journal it", func() {
+				container := newContainer("some-handle")
+				repo.Add(container)
+				repo.Delete(container)
+
+				Expect(repo.Save(container)).To(Succeed())
+
+				Expect(container_repository.NewJournaled(lagertest.NewTestLogger("test"), journalPath).Replay(restore)).To(Succeed())
+				Expect(restored).To(BeEmpty())
+			})
+		})
+	})
+
+	Describe("Epochs", func() {
+		withEpoch := func(handle string, epoch uint64) *fakes.FakeContainer {
+			container := newContainer(handle)
+			container.SnapshotStub = func(out io.Writer) error {
+				_, err := fmt.Fprintf(out, `{"Handle":%q,"Epoch":%d}`+"\n", handle, epoch)
+				return err
+			}
+
+			return container
+		}
+
+		It("returns the epoch of the latest snapshot of each live container", func() {
+			repo.Add(withEpoch("handle-a", 1))
+			repo.Add(withEpoch("handle-b", 2))
+			repo.Add(withEpoch("handle-b", 5))
+			repo.Add(withEpoch("handle-c", 3))
+			repo.Delete(withEpoch("handle-c", 3))
+
+			Expect(container_repository.NewJournaled(lagertest.NewTestLogger("test"), journalPath).Epochs()).To(Equal(map[string]uint64{
+				"id-handle-a": 1,
+				"id-handle-b": 5,
+			}))
+		})
+
+		Context("when there is no journal", func() {
+			It("returns no epochs", func() {
+				Expect(repo.Epochs()).To(BeEmpty())
+			})
+		})
+	})
+})
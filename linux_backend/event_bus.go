@@ -0,0 +1,181 @@
+package linux_backend
+
+import (
+	"sync"
+	"time"
+
+	"github.com/cloudfoundry-incubator/garden"
+)
+
+type EventType string
+
+const (
+	EventCreated        = EventType("created")
+	EventStarted        = EventType("started")
+	EventStopped        = EventType("stopped")
+	EventDestroyed      = EventType("destroyed")
+	EventOOM            = EventType("oom")
+	EventProcessExited  = EventType("process-exited")
+	EventLimitChanged   = EventType("limit-changed")
+	EventNetRuleAdded   = EventType("net-rule-added")
+	EventNetRuleRemoved = EventType("net-rule-removed")
+)
+
+type ContainerEvent struct {
+	Type EventType
+	Time time.Time
+
+	Handle     string
+	Properties garden.Properties
+
+	Data map[string]string
+}
+
+//go:generate counterfeiter -o fakes/fake_event_emitter.go . EventEmitter
+type EventEmitter interface {
+	Emit(ContainerEvent)
+}
+
+// EventFilter selects the events of the container with the given handle
+// and/or of containers with all of the given properties. The zero value
+// selects every event.
+type EventFilter struct {
+	Handle     string
+	Properties garden.Properties
+}
+
+func (f EventFilter) Matches(event ContainerEvent) bool {
+	if f.Handle != "" && f.Handle != event.Handle {
+		return false
+	}
+
+	for key, val := range f.Properties {
+		if event.Properties[key] != val {
+			return false
+		}
+	}
+
+	return true
+}
+
+// subscriptionBufferSize is the number of events a subscriber may fall
+// behind by before further events to it are dropped.
+const subscriptionBufferSize = 64
+
+type EventSubscription struct {
+	Events <-chan ContainerEvent
+
+	events chan ContainerEvent
+	filter EventFilter
+}
+
+// EventBus fans container lifecycle events out to subscribers, and keeps the
+// most recent of them in a ring buffer so that they can be replayed.
+type EventBus struct {
+	mutex sync.RWMutex
+
+	subscriptions map[*EventSubscription]struct{}
+
+	recent     []ContainerEvent
+	nextRecent int
+	numRecent  int
+}
+
+// NewEventBus creates an EventBus which keeps the given number of recent
+// events. A negative size is treated as 0, keeping none.
+func NewEventBus(historySize int) *EventBus {
+	if historySize < 0 {
+		historySize = 0
+	}
+
+	return &EventBus{
+		subscriptions: make(map[*EventSubscription]struct{}),
+
+		recent: make([]ContainerEvent, historySize),
+	}
+}
+
+// Emit records the event and delivers it to every matching subscriber. A
+// subscriber that is not keeping up misses the event rather than blocking
+// the emitter.
+func (b *EventBus) Emit(event ContainerEvent) {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if len(b.recent) > 0 {
+		b.recent[b.nextRecent] = event
+		b.nextRecent = (b.nextRecent + 1) % len(b.recent)
+
+		if b.numRecent < len(b.recent) {
+			b.numRecent++
+		}
+	}
+
+	for subscription := range b.subscriptions {
+		if !subscription.filter.Matches(event) {
+			continue
+		}
+
+		select {
+		case subscription.events <- event:
+		default:
+		}
+	}
+}
+
+func (b *EventBus) Subscribe(filter EventFilter) *EventSubscription {
+	events := make(chan ContainerEvent, subscriptionBufferSize)
+
+	subscription := &EventSubscription{
+		Events: events,
+
+		events: events,
+		filter: filter,
+	}
+
+	b.mutex.Lock()
+	b.subscriptions[subscription] = struct{}{}
+	b.mutex.Unlock()
+
+	return subscription
+}
+
+// Unsubscribe stops delivery to the subscription and closes its channel.
+func (b *EventBus) Unsubscribe(subscription *EventSubscription) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if _, found := b.subscriptions[subscription]; !found {
+		return
+	}
+
+	delete(b.subscriptions, subscription)
+	close(subscription.events)
+}
+
+// Recent returns the buffered events matching the filter, oldest first.
+func (b *EventBus) Recent(filter EventFilter) []ContainerEvent {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+
+	events := []ContainerEvent{}
+
+	start := b.nextRecent - b.numRecent
+	if start < 0 {
+		start += len(b.recent)
+	}
+
+	for i := 0; i < b.numRecent; i++ {
+		event := b.recent[(start+i)%len(b.recent)]
+
+		if filter.Matches(event) {
+			events = append(events, event)
+		}
+	}
+
+	return events
+}
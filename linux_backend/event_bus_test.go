@@ -0,0 +1,211 @@
+package linux_backend_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudfoundry-incubator/garden"
+	"github.com/cloudfoundry-incubator/garden-linux/linux_backend"
+)
+
+var _ = Describe("EventBus", func() {
+	var bus *linux_backend.EventBus
+
+	BeforeEach(func() {
+		bus = linux_backend.NewEventBus(3)
+	})
+
+	Describe("Emit", func() {
+		It("timestamps events which have no time", func() {
+			subscription := bus.Subscribe(linux_backend.EventFilter{})
+
+			bus.Emit(linux_backend.ContainerEvent{
+				Type:   linux_backend.EventCreated,
+				Handle: "some-handle",
+			})
+
+			var event linux_backend.ContainerEvent
+			Eventually(subscription.Events).Should(Receive(&event))
+
+			Expect(event.Time).To(BeTemporally("~", time.Now(), time.Second))
+		})
+
+		It("keeps the time of events which have one", func() {
+			subscription := bus.Subscribe(linux_backend.EventFilter{})
+
+			someTime := time.Unix(123, 0)
+
+			bus.Emit(linux_backend.ContainerEvent{
+				Type: linux_backend.EventCreated,
+				Time: someTime,
+			})
+
+			var event linux_backend.ContainerEvent
+			Eventually(subscription.Events).Should(Receive(&event))
+
+			Expect(event.Time).To(Equal(someTime))
+		})
+
+		Context("when a subscriber is not keeping up", func() {
+			It("drops its events rather than blocking", func() {
+				bus.Subscribe(linux_backend.EventFilter{})
+
+				done := make(chan struct{})
+
+				go func() {
+					for i := 0; i < 1000; i++ {
+						bus.Emit(linux_backend.ContainerEvent{Type: linux_backend.EventOOM})
+					}
+
+					close(done)
+				}()
+
+				Eventually(done).Should(BeClosed())
+			})
+		})
+	})
+
+	Describe("Subscribe", func() {
+		BeforeEach(func() {
+			bus = linux_backend.NewEventBus(0)
+		})
+
+		It("delivers every event when the filter is empty", func() {
+			subscription := bus.Subscribe(linux_backend.EventFilter{})
+
+			bus.Emit(linux_backend.ContainerEvent{Type: linux_backend.EventCreated, Handle: "a"})
+			bus.Emit(linux_backend.ContainerEvent{Type: linux_backend.EventStarted, Handle: "b"})
+
+			var event linux_backend.ContainerEvent
+			Eventually(subscription.Events).Should(Receive(&event))
+			Expect(event.Type).To(Equal(linux_backend.EventCreated))
+
+			Eventually(subscription.Events).Should(Receive(&event))
+			Expect(event.Type).To(Equal(linux_backend.EventStarted))
+		})
+
+		It("delivers only the events of the given handle", func() {
+			subscription := bus.Subscribe(linux_backend.EventFilter{Handle: "b"})
+
+			bus.Emit(linux_backend.ContainerEvent{Type: linux_backend.EventCreated, Handle: "a"})
+			bus.Emit(linux_backend.ContainerEvent{Type: linux_backend.EventStarted, Handle: "b"})
+
+			var event linux_backend.ContainerEvent
+			Eventually(subscription.Events).Should(Receive(&event))
+			Expect(event.Handle).To(Equal("b"))
+
+			Consistently(subscription.Events).ShouldNot(Receive())
+		})
+
+		It("delivers only the events of containers with all of the given properties", func() {
+			subscription := bus.Subscribe(linux_backend.EventFilter{
+				Properties: garden.Properties{"a": "b", "c": "d"},
+			})
+
+			bus.Emit(linux_backend.ContainerEvent{
+				Handle:     "some",
+				Properties: garden.Properties{"a": "b"},
+			})
+
+			bus.Emit(linux_backend.ContainerEvent{
+				Handle:     "other",
+				Properties: garden.Properties{"a": "b", "c": "d", "e": "f"},
+			})
+
+			var event linux_backend.ContainerEvent
+			Eventually(subscription.Events).Should(Receive(&event))
+			Expect(event.Handle).To(Equal("other"))
+
+			Consistently(subscription.Events).ShouldNot(Receive())
+		})
+	})
+
+	Describe("Unsubscribe", func() {
+		It("stops delivery and closes the channel", func() {
+			subscription := bus.Subscribe(linux_backend.EventFilter{})
+
+			bus.Unsubscribe(subscription)
+
+			bus.Emit(linux_backend.ContainerEvent{Type: linux_backend.EventCreated})
+
+			Eventually(subscription.Events).Should(BeClosed())
+		})
+
+		It("can be called more than once", func() {
+			subscription := bus.Subscribe(linux_backend.EventFilter{})
+
+			bus.Unsubscribe(subscription)
+			bus.Unsubscribe(subscription)
+		})
+	})
+
+	Describe("Recent", func() {
+		It("returns the events emitted before, oldest first", func() {
+			bus.Emit(linux_backend.ContainerEvent{Type: linux_backend.EventCreated})
+			bus.Emit(linux_backend.ContainerEvent{Type: linux_backend.EventStarted})
+
+			Expect(eventTypes(bus.Recent(linux_backend.EventFilter{}))).To(Equal([]linux_backend.EventType{
+				linux_backend.EventCreated,
+				linux_backend.EventStarted,
+			}))
+		})
+
+		It("only keeps as many events as it was told to", func() {
+			bus.Emit(linux_backend.ContainerEvent{Type: linux_backend.EventCreated})
+			bus.Emit(linux_backend.ContainerEvent{Type: linux_backend.EventStarted})
+			bus.Emit(linux_backend.ContainerEvent{Type: linux_backend.EventOOM})
+			bus.Emit(linux_backend.ContainerEvent{Type: linux_backend.EventStopped})
+			bus.Emit(linux_backend.ContainerEvent{Type: linux_backend.EventDestroyed})
+
+			Expect(eventTypes(bus.Recent(linux_backend.EventFilter{}))).To(Equal([]linux_backend.EventType{
+				linux_backend.EventOOM,
+				linux_backend.EventStopped,
+				linux_backend.EventDestroyed,
+			}))
+		})
+
+		It("returns only the events matching the filter", func() {
+			bus.Emit(linux_backend.ContainerEvent{Type: linux_backend.EventCreated, Handle: "a"})
+			bus.Emit(linux_backend.ContainerEvent{Type: linux_backend.EventStarted, Handle: "b"})
+
+			Expect(eventTypes(bus.Recent(linux_backend.EventFilter{Handle: "b"}))).To(Equal([]linux_backend.EventType{
+				linux_backend.EventStarted,
+			}))
+		})
+
+		Context("when told to keep a negative number of events", func() {
+			BeforeEach(func() {
+				bus = linux_backend.NewEventBus(-1)
+			})
+
+			It("keeps none", func() {
+				bus.Emit(linux_backend.ContainerEvent{Type: linux_backend.EventCreated})
+
+				Expect(bus.Recent(linux_backend.EventFilter{})).To(BeEmpty())
+			})
+		})
+
+		Context("when no history is kept", func() {
+			BeforeEach(func() {
+				bus = linux_backend.NewEventBus(0)
+			})
+
+			It("returns no events", func() {
+				bus.Emit(linux_backend.ContainerEvent{Type: linux_backend.EventCreated})
+
+				Expect(bus.Recent(linux_backend.EventFilter{})).To(BeEmpty())
+			})
+		})
+	})
+})
+
+func eventTypes(events []linux_backend.ContainerEvent) []linux_backend.EventType {
+	types := []linux_backend.EventType{}
+	for _, event := range events {
+		types = append(types, event.Type)
+	}
+
+	return types
+}
@@ -3,11 +3,13 @@ package fakes
 
 import (
 	"io"
+	"net"
 	"sync"
 	"time"
 
 	"github.com/cloudfoundry-incubator/garden"
 	"github.com/cloudfoundry-incubator/garden-linux/linux_backend"
+	"github.com/cloudfoundry-incubator/garden-linux/old/bandwidth_manager"
 )
 
 type FakeContainer struct {
@@ -45,13 +47,135 @@ type FakeContainer struct {
 	snapshotReturns struct {
 		result1 error
 	}
-	CleanupStub        func()
-	cleanupMutex       sync.RWMutex
-	cleanupArgsForCall []struct{}
-	HandleStub         func() string
-	handleMutex        sync.RWMutex
-	handleArgsForCall  []struct{}
-	handleReturns      struct {
+	SaveSnapshotStub        func() error
+	saveSnapshotMutex       sync.RWMutex
+	saveSnapshotArgsForCall []struct{}
+	saveSnapshotReturns     struct {
+		result1 error
+	}
+	CleanupStub             func()
+	cleanupMutex            sync.RWMutex
+	cleanupArgsForCall      []struct{}
+	GracefulStopStub        func(arg1 linux_backend.StopSpec) ([]linux_backend.ProcessStopResult, error)
+	gracefulStopMutex       sync.RWMutex
+	gracefulStopArgsForCall []struct {
+		arg1 linux_backend.StopSpec
+	}
+	gracefulStopReturns struct {
+		result1 []linux_backend.ProcessStopResult
+		result2 error
+	}
+	PauseStub        func() error
+	pauseMutex       sync.RWMutex
+	pauseArgsForCall []struct{}
+	pauseReturns     struct {
+		result1 error
+	}
+	ResumeStub        func() error
+	resumeMutex       sync.RWMutex
+	resumeArgsForCall []struct{}
+	resumeReturns     struct {
+		result1 error
+	}
+	CheckpointStub        func(dir string) error
+	checkpointMutex       sync.RWMutex
+	checkpointArgsForCall []struct {
+		dir string
+	}
+	checkpointReturns struct {
+		result1 error
+	}
+	RestoreCheckpointStub        func() error
+	restoreCheckpointMutex       sync.RWMutex
+	restoreCheckpointArgsForCall []struct{}
+	restoreCheckpointReturns     struct {
+		result1 error
+	}
+	NetInRemoveStub        func(hostPort uint32) error
+	netInRemoveMutex       sync.RWMutex
+	netInRemoveArgsForCall []struct {
+		hostPort uint32
+	}
+	netInRemoveReturns struct {
+		result1 error
+	}
+	NetInsStub        func() []linux_backend.PortMapping
+	netInsMutex       sync.RWMutex
+	netInsArgsForCall []struct{}
+	netInsReturns     struct {
+		result1 []linux_backend.PortMapping
+	}
+	BulkNetOutStub        func(arg1 []garden.NetOutRule) error
+	bulkNetOutMutex       sync.RWMutex
+	bulkNetOutArgsForCall []struct {
+		arg1 []garden.NetOutRule
+	}
+	bulkNetOutReturns struct {
+		result1 error
+	}
+	NetOutRemoveStub        func(arg1 garden.NetOutRule) error
+	netOutRemoveMutex       sync.RWMutex
+	netOutRemoveArgsForCall []struct {
+		arg1 garden.NetOutRule
+	}
+	netOutRemoveReturns struct {
+		result1 error
+	}
+	NetOutRulesStub        func() []garden.NetOutRule
+	netOutRulesMutex       sync.RWMutex
+	netOutRulesArgsForCall []struct{}
+	netOutRulesReturns     struct {
+		result1 []garden.NetOutRule
+	}
+	AllowTrafficStub        func(policy linux_backend.TrafficPolicy, destinations []net.IP) error
+	allowTrafficMutex       sync.RWMutex
+	allowTrafficArgsForCall []struct {
+		policy       linux_backend.TrafficPolicy
+		destinations []net.IP
+	}
+	allowTrafficReturns struct {
+		result1 error
+	}
+	DenyTrafficStub        func(policy linux_backend.TrafficPolicy) error
+	denyTrafficMutex       sync.RWMutex
+	denyTrafficArgsForCall []struct {
+		policy linux_backend.TrafficPolicy
+	}
+	denyTrafficReturns struct {
+		result1 error
+	}
+	TrafficPoliciesStub        func() []linux_backend.TrafficPolicy
+	trafficPoliciesMutex       sync.RWMutex
+	trafficPoliciesArgsForCall []struct{}
+	trafficPoliciesReturns     struct {
+		result1 []linux_backend.TrafficPolicy
+	}
+	NetworkMetricsStub        func() (linux_backend.NetworkMetrics, error)
+	networkMetricsMutex       sync.RWMutex
+	networkMetricsArgsForCall []struct{}
+	networkMetricsReturns     struct {
+		result1 linux_backend.NetworkMetrics
+		result2 error
+	}
+	LimitDirectionalBandwidthStub        func(arg1 bandwidth_manager.Limits) error
+	limitDirectionalBandwidthMutex       sync.RWMutex
+	limitDirectionalBandwidthArgsForCall []struct {
+		arg1 bandwidth_manager.Limits
+	}
+	limitDirectionalBandwidthReturns struct {
+		result1 error
+	}
+	CurrentDirectionalBandwidthLimitsStub        func() (bandwidth_manager.Limits, error)
+	currentDirectionalBandwidthLimitsMutex       sync.RWMutex
+	currentDirectionalBandwidthLimitsArgsForCall []struct{}
+	currentDirectionalBandwidthLimitsReturns     struct {
+		result1 bandwidth_manager.Limits
+		result2 error
+	}
+	HandleStub        func() string
+	handleMutex       sync.RWMutex
+	handleArgsForCall []struct{}
+	handleReturns     struct {
 		result1 string
 	}
 	StopStub        func(kill bool) error
@@ -158,6 +282,31 @@ type FakeContainer struct {
 		result2 uint32
 		result3 error
 	}
+	NetInWithProtocolStub        func(hostPort uint32, containerPort uint32, protocol garden.Protocol) (uint32, uint32, error)
+	netInWithProtocolMutex       sync.RWMutex
+	netInWithProtocolArgsForCall []struct {
+		hostPort      uint32
+		containerPort uint32
+		protocol      garden.Protocol
+	}
+	netInWithProtocolReturns struct {
+		result1 uint32
+		result2 uint32
+		result3 error
+	}
+	NetInOnExternalIPStub        func(externalIP net.IP, hostPort uint32, containerPort uint32, protocol garden.Protocol) (uint32, uint32, error)
+	netInOnExternalIPMutex       sync.RWMutex
+	netInOnExternalIPArgsForCall []struct {
+		externalIP    net.IP
+		hostPort      uint32
+		containerPort uint32
+		protocol      garden.Protocol
+	}
+	netInOnExternalIPReturns struct {
+		result1 uint32
+		result2 uint32
+		result3 error
+	}
 	NetOutStub        func(netOutRule garden.NetOutRule) error
 	netOutMutex       sync.RWMutex
 	netOutArgsForCall []struct {
@@ -364,6 +513,30 @@ func (fake *FakeContainer) SnapshotReturns(result1 error) {
 	}{result1}
 }
 
+func (fake *FakeContainer) SaveSnapshot() error {
+	fake.saveSnapshotMutex.Lock()
+	fake.saveSnapshotArgsForCall = append(fake.saveSnapshotArgsForCall, struct{}{})
+	fake.saveSnapshotMutex.Unlock()
+	if fake.SaveSnapshotStub != nil {
+		return fake.SaveSnapshotStub()
+	} else {
+		return fake.saveSnapshotReturns.result1
+	}
+}
+
+func (fake *FakeContainer) SaveSnapshotCallCount() int {
+	fake.saveSnapshotMutex.RLock()
+	defer fake.saveSnapshotMutex.RUnlock()
+	return len(fake.saveSnapshotArgsForCall)
+}
+
+func (fake *FakeContainer) SaveSnapshotReturns(result1 error) {
+	fake.SaveSnapshotStub = nil
+	fake.saveSnapshotReturns = struct {
+		result1 error
+	}{result1}
+}
+
 func (fake *FakeContainer) Cleanup() {
 	fake.cleanupMutex.Lock()
 	fake.cleanupArgsForCall = append(fake.cleanupArgsForCall, struct{}{})
@@ -379,6 +552,458 @@ func (fake *FakeContainer) CleanupCallCount() int {
 	return len(fake.cleanupArgsForCall)
 }
 
+func (fake *FakeContainer) GracefulStop(arg1 linux_backend.StopSpec) ([]linux_backend.ProcessStopResult, error) {
+	fake.gracefulStopMutex.Lock()
+	fake.gracefulStopArgsForCall = append(fake.gracefulStopArgsForCall, struct {
+		arg1 linux_backend.StopSpec
+	}{arg1})
+	fake.gracefulStopMutex.Unlock()
+	if fake.GracefulStopStub != nil {
+		return fake.GracefulStopStub(arg1)
+	} else {
+		return fake.gracefulStopReturns.result1, fake.gracefulStopReturns.result2
+	}
+}
+
+func (fake *FakeContainer) GracefulStopCallCount() int {
+	fake.gracefulStopMutex.RLock()
+	defer fake.gracefulStopMutex.RUnlock()
+	return len(fake.gracefulStopArgsForCall)
+}
+
+func (fake *FakeContainer) GracefulStopArgsForCall(i int) linux_backend.StopSpec {
+	fake.gracefulStopMutex.RLock()
+	defer fake.gracefulStopMutex.RUnlock()
+	return fake.gracefulStopArgsForCall[i].arg1
+}
+
+func (fake *FakeContainer) GracefulStopReturns(result1 []linux_backend.ProcessStopResult, result2 error) {
+	fake.GracefulStopStub = nil
+	fake.gracefulStopReturns = struct {
+		result1 []linux_backend.ProcessStopResult
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeContainer) Pause() error {
+	fake.pauseMutex.Lock()
+	fake.pauseArgsForCall = append(fake.pauseArgsForCall, struct{}{})
+	fake.pauseMutex.Unlock()
+	if fake.PauseStub != nil {
+		return fake.PauseStub()
+	} else {
+		return fake.pauseReturns.result1
+	}
+}
+
+func (fake *FakeContainer) PauseCallCount() int {
+	fake.pauseMutex.RLock()
+	defer fake.pauseMutex.RUnlock()
+	return len(fake.pauseArgsForCall)
+}
+
+func (fake *FakeContainer) PauseReturns(result1 error) {
+	fake.PauseStub = nil
+	fake.pauseReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeContainer) Resume() error {
+	fake.resumeMutex.Lock()
+	fake.resumeArgsForCall = append(fake.resumeArgsForCall, struct{}{})
+	fake.resumeMutex.Unlock()
+	if fake.ResumeStub != nil {
+		return fake.ResumeStub()
+	} else {
+		return fake.resumeReturns.result1
+	}
+}
+
+func (fake *FakeContainer) ResumeCallCount() int {
+	fake.resumeMutex.RLock()
+	defer fake.resumeMutex.RUnlock()
+	return len(fake.resumeArgsForCall)
+}
+
+func (fake *FakeContainer) ResumeReturns(result1 error) {
+	fake.ResumeStub = nil
+	fake.resumeReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeContainer) Checkpoint(dir string) error {
+	fake.checkpointMutex.Lock()
+	fake.checkpointArgsForCall = append(fake.checkpointArgsForCall, struct {
+		dir string
+	}{dir})
+	fake.checkpointMutex.Unlock()
+	if fake.CheckpointStub != nil {
+		return fake.CheckpointStub(dir)
+	} else {
+		return fake.checkpointReturns.result1
+	}
+}
+
+func (fake *FakeContainer) CheckpointCallCount() int {
+	fake.checkpointMutex.RLock()
+	defer fake.checkpointMutex.RUnlock()
+	return len(fake.checkpointArgsForCall)
+}
+
+func (fake *FakeContainer) CheckpointArgsForCall(i int) string {
+	fake.checkpointMutex.RLock()
+	defer fake.checkpointMutex.RUnlock()
+	return fake.checkpointArgsForCall[i].dir
+}
+
+func (fake *FakeContainer) CheckpointReturns(result1 error) {
+	fake.CheckpointStub = nil
+	fake.checkpointReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeContainer) RestoreCheckpoint() error {
+	fake.restoreCheckpointMutex.Lock()
+	fake.restoreCheckpointArgsForCall = append(fake.restoreCheckpointArgsForCall, struct{}{})
+	fake.restoreCheckpointMutex.Unlock()
+	if fake.RestoreCheckpointStub != nil {
+		return fake.RestoreCheckpointStub()
+	} else {
+		return fake.restoreCheckpointReturns.result1
+	}
+}
+
+func (fake *FakeContainer) RestoreCheckpointCallCount() int {
+	fake.restoreCheckpointMutex.RLock()
+	defer fake.restoreCheckpointMutex.RUnlock()
+	return len(fake.restoreCheckpointArgsForCall)
+}
+
+func (fake *FakeContainer) RestoreCheckpointReturns(result1 error) {
+	fake.RestoreCheckpointStub = nil
+	fake.restoreCheckpointReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeContainer) NetInRemove(hostPort uint32) error {
+	fake.netInRemoveMutex.Lock()
+	fake.netInRemoveArgsForCall = append(fake.netInRemoveArgsForCall, struct {
+		hostPort uint32
+	}{hostPort})
+	fake.netInRemoveMutex.Unlock()
+	if fake.NetInRemoveStub != nil {
+		return fake.NetInRemoveStub(hostPort)
+	} else {
+		return fake.netInRemoveReturns.result1
+	}
+}
+
+func (fake *FakeContainer) NetInRemoveCallCount() int {
+	fake.netInRemoveMutex.RLock()
+	defer fake.netInRemoveMutex.RUnlock()
+	return len(fake.netInRemoveArgsForCall)
+}
+
+func (fake *FakeContainer) NetInRemoveArgsForCall(i int) uint32 {
+	fake.netInRemoveMutex.RLock()
+	defer fake.netInRemoveMutex.RUnlock()
+	return fake.netInRemoveArgsForCall[i].hostPort
+}
+
+func (fake *FakeContainer) NetInRemoveReturns(result1 error) {
+	fake.NetInRemoveStub = nil
+	fake.netInRemoveReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeContainer) NetIns() []linux_backend.PortMapping {
+	fake.netInsMutex.Lock()
+	fake.netInsArgsForCall = append(fake.netInsArgsForCall, struct{}{})
+	fake.netInsMutex.Unlock()
+	if fake.NetInsStub != nil {
+		return fake.NetInsStub()
+	} else {
+		return fake.netInsReturns.result1
+	}
+}
+
+func (fake *FakeContainer) NetInsCallCount() int {
+	fake.netInsMutex.RLock()
+	defer fake.netInsMutex.RUnlock()
+	return len(fake.netInsArgsForCall)
+}
+
+func (fake *FakeContainer) NetInsReturns(result1 []linux_backend.PortMapping) {
+	fake.NetInsStub = nil
+	fake.netInsReturns = struct {
+		result1 []linux_backend.PortMapping
+	}{result1}
+}
+
+func (fake *FakeContainer) BulkNetOut(arg1 []garden.NetOutRule) error {
+	fake.bulkNetOutMutex.Lock()
+	fake.bulkNetOutArgsForCall = append(fake.bulkNetOutArgsForCall, struct {
+		arg1 []garden.NetOutRule
+	}{arg1})
+	fake.bulkNetOutMutex.Unlock()
+	if fake.BulkNetOutStub != nil {
+		return fake.BulkNetOutStub(arg1)
+	} else {
+		return fake.bulkNetOutReturns.result1
+	}
+}
+
+func (fake *FakeContainer) BulkNetOutCallCount() int {
+	fake.bulkNetOutMutex.RLock()
+	defer fake.bulkNetOutMutex.RUnlock()
+	return len(fake.bulkNetOutArgsForCall)
+}
+
+func (fake *FakeContainer) BulkNetOutArgsForCall(i int) []garden.NetOutRule {
+	fake.bulkNetOutMutex.RLock()
+	defer fake.bulkNetOutMutex.RUnlock()
+	return fake.bulkNetOutArgsForCall[i].arg1
+}
+
+func (fake *FakeContainer) BulkNetOutReturns(result1 error) {
+	fake.BulkNetOutStub = nil
+	fake.bulkNetOutReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeContainer) NetOutRemove(arg1 garden.NetOutRule) error {
+	fake.netOutRemoveMutex.Lock()
+	fake.netOutRemoveArgsForCall = append(fake.netOutRemoveArgsForCall, struct {
+		arg1 garden.NetOutRule
+	}{arg1})
+	fake.netOutRemoveMutex.Unlock()
+	if fake.NetOutRemoveStub != nil {
+		return fake.NetOutRemoveStub(arg1)
+	} else {
+		return fake.netOutRemoveReturns.result1
+	}
+}
+
+func (fake *FakeContainer) NetOutRemoveCallCount() int {
+	fake.netOutRemoveMutex.RLock()
+	defer fake.netOutRemoveMutex.RUnlock()
+	return len(fake.netOutRemoveArgsForCall)
+}
+
+func (fake *FakeContainer) NetOutRemoveArgsForCall(i int) garden.NetOutRule {
+	fake.netOutRemoveMutex.RLock()
+	defer fake.netOutRemoveMutex.RUnlock()
+	return fake.netOutRemoveArgsForCall[i].arg1
+}
+
+func (fake *FakeContainer) NetOutRemoveReturns(result1 error) {
+	fake.NetOutRemoveStub = nil
+	fake.netOutRemoveReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeContainer) NetOutRules() []garden.NetOutRule {
+	fake.netOutRulesMutex.Lock()
+	fake.netOutRulesArgsForCall = append(fake.netOutRulesArgsForCall, struct{}{})
+	fake.netOutRulesMutex.Unlock()
+	if fake.NetOutRulesStub != nil {
+		return fake.NetOutRulesStub()
+	} else {
+		return fake.netOutRulesReturns.result1
+	}
+}
+
+func (fake *FakeContainer) NetOutRulesCallCount() int {
+	fake.netOutRulesMutex.RLock()
+	defer fake.netOutRulesMutex.RUnlock()
+	return len(fake.netOutRulesArgsForCall)
+}
+
+func (fake *FakeContainer) NetOutRulesReturns(result1 []garden.NetOutRule) {
+	fake.NetOutRulesStub = nil
+	fake.netOutRulesReturns = struct {
+		result1 []garden.NetOutRule
+	}{result1}
+}
+
+func (fake *FakeContainer) AllowTraffic(policy linux_backend.TrafficPolicy, destinations []net.IP) error {
+	fake.allowTrafficMutex.Lock()
+	fake.allowTrafficArgsForCall = append(fake.allowTrafficArgsForCall, struct {
+		policy       linux_backend.TrafficPolicy
+		destinations []net.IP
+	}{policy, destinations})
+	fake.allowTrafficMutex.Unlock()
+	if fake.AllowTrafficStub != nil {
+		return fake.AllowTrafficStub(policy, destinations)
+	} else {
+		return fake.allowTrafficReturns.result1
+	}
+}
+
+func (fake *FakeContainer) AllowTrafficCallCount() int {
+	fake.allowTrafficMutex.RLock()
+	defer fake.allowTrafficMutex.RUnlock()
+	return len(fake.allowTrafficArgsForCall)
+}
+
+func (fake *FakeContainer) AllowTrafficArgsForCall(i int) (linux_backend.TrafficPolicy, []net.IP) {
+	fake.allowTrafficMutex.RLock()
+	defer fake.allowTrafficMutex.RUnlock()
+	return fake.allowTrafficArgsForCall[i].policy, fake.allowTrafficArgsForCall[i].destinations
+}
+
+func (fake *FakeContainer) AllowTrafficReturns(result1 error) {
+	fake.AllowTrafficStub = nil
+	fake.allowTrafficReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeContainer) DenyTraffic(policy linux_backend.TrafficPolicy) error {
+	fake.denyTrafficMutex.Lock()
+	fake.denyTrafficArgsForCall = append(fake.denyTrafficArgsForCall, struct {
+		policy linux_backend.TrafficPolicy
+	}{policy})
+	fake.denyTrafficMutex.Unlock()
+	if fake.DenyTrafficStub != nil {
+		return fake.DenyTrafficStub(policy)
+	} else {
+		return fake.denyTrafficReturns.result1
+	}
+}
+
+func (fake *FakeContainer) DenyTrafficCallCount() int {
+	fake.denyTrafficMutex.RLock()
+	defer fake.denyTrafficMutex.RUnlock()
+	return len(fake.denyTrafficArgsForCall)
+}
+
+func (fake *FakeContainer) DenyTrafficArgsForCall(i int) linux_backend.TrafficPolicy {
+	fake.denyTrafficMutex.RLock()
+	defer fake.denyTrafficMutex.RUnlock()
+	return fake.denyTrafficArgsForCall[i].policy
+}
+
+func (fake *FakeContainer) DenyTrafficReturns(result1 error) {
+	fake.DenyTrafficStub = nil
+	fake.denyTrafficReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeContainer) TrafficPolicies() []linux_backend.TrafficPolicy {
+	fake.trafficPoliciesMutex.Lock()
+	fake.trafficPoliciesArgsForCall = append(fake.trafficPoliciesArgsForCall, struct{}{})
+	fake.trafficPoliciesMutex.Unlock()
+	if fake.TrafficPoliciesStub != nil {
+		return fake.TrafficPoliciesStub()
+	} else {
+		return fake.trafficPoliciesReturns.result1
+	}
+}
+
+func (fake *FakeContainer) TrafficPoliciesCallCount() int {
+	fake.trafficPoliciesMutex.RLock()
+	defer fake.trafficPoliciesMutex.RUnlock()
+	return len(fake.trafficPoliciesArgsForCall)
+}
+
+func (fake *FakeContainer) TrafficPoliciesReturns(result1 []linux_backend.TrafficPolicy) {
+	fake.TrafficPoliciesStub = nil
+	fake.trafficPoliciesReturns = struct {
+		result1 []linux_backend.TrafficPolicy
+	}{result1}
+}
+
+func (fake *FakeContainer) NetworkMetrics() (linux_backend.NetworkMetrics, error) {
+	fake.networkMetricsMutex.Lock()
+	fake.networkMetricsArgsForCall = append(fake.networkMetricsArgsForCall, struct{}{})
+	fake.networkMetricsMutex.Unlock()
+	if fake.NetworkMetricsStub != nil {
+		return fake.NetworkMetricsStub()
+	} else {
+		return fake.networkMetricsReturns.result1, fake.networkMetricsReturns.result2
+	}
+}
+
+func (fake *FakeContainer) NetworkMetricsCallCount() int {
+	fake.networkMetricsMutex.RLock()
+	defer fake.networkMetricsMutex.RUnlock()
+	return len(fake.networkMetricsArgsForCall)
+}
+
+func (fake *FakeContainer) NetworkMetricsReturns(result1 linux_backend.NetworkMetrics, result2 error) {
+	fake.NetworkMetricsStub = nil
+	fake.networkMetricsReturns = struct {
+		result1 linux_backend.NetworkMetrics
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeContainer) LimitDirectionalBandwidth(arg1 bandwidth_manager.Limits) error {
+	fake.limitDirectionalBandwidthMutex.Lock()
+	fake.limitDirectionalBandwidthArgsForCall = append(fake.limitDirectionalBandwidthArgsForCall, struct {
+		arg1 bandwidth_manager.Limits
+	}{arg1})
+	fake.limitDirectionalBandwidthMutex.Unlock()
+	if fake.LimitDirectionalBandwidthStub != nil {
+		return fake.LimitDirectionalBandwidthStub(arg1)
+	} else {
+		return fake.limitDirectionalBandwidthReturns.result1
+	}
+}
+
+func (fake *FakeContainer) LimitDirectionalBandwidthCallCount() int {
+	fake.limitDirectionalBandwidthMutex.RLock()
+	defer fake.limitDirectionalBandwidthMutex.RUnlock()
+	return len(fake.limitDirectionalBandwidthArgsForCall)
+}
+
+func (fake *FakeContainer) LimitDirectionalBandwidthArgsForCall(i int) bandwidth_manager.Limits {
+	fake.limitDirectionalBandwidthMutex.RLock()
+	defer fake.limitDirectionalBandwidthMutex.RUnlock()
+	return fake.limitDirectionalBandwidthArgsForCall[i].arg1
+}
+
+func (fake *FakeContainer) LimitDirectionalBandwidthReturns(result1 error) {
+	fake.LimitDirectionalBandwidthStub = nil
+	fake.limitDirectionalBandwidthReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeContainer) CurrentDirectionalBandwidthLimits() (bandwidth_manager.Limits, error) {
+	fake.currentDirectionalBandwidthLimitsMutex.Lock()
+	fake.currentDirectionalBandwidthLimitsArgsForCall = append(fake.currentDirectionalBandwidthLimitsArgsForCall, struct{}{})
+	fake.currentDirectionalBandwidthLimitsMutex.Unlock()
+	if fake.CurrentDirectionalBandwidthLimitsStub != nil {
+		return fake.CurrentDirectionalBandwidthLimitsStub()
+	} else {
+		return fake.currentDirectionalBandwidthLimitsReturns.result1, fake.currentDirectionalBandwidthLimitsReturns.result2
+	}
+}
+
+func (fake *FakeContainer) CurrentDirectionalBandwidthLimitsCallCount() int {
+	fake.currentDirectionalBandwidthLimitsMutex.RLock()
+	defer fake.currentDirectionalBandwidthLimitsMutex.RUnlock()
+	return len(fake.currentDirectionalBandwidthLimitsArgsForCall)
+}
+
+func (fake *FakeContainer) CurrentDirectionalBandwidthLimitsReturns(result1 bandwidth_manager.Limits, result2 error) {
+	fake.CurrentDirectionalBandwidthLimitsStub = nil
+	fake.currentDirectionalBandwidthLimitsReturns = struct {
+		result1 bandwidth_manager.Limits
+		result2 error
+	}{result1, result2}
+}
+
 func (fake *FakeContainer) Handle() string {
 	fake.handleMutex.Lock()
 	fake.handleArgsForCall = append(fake.handleArgsForCall, struct{}{})
@@ -789,6 +1414,79 @@ func (fake *FakeContainer) NetInReturns(result1 uint32, result2 uint32, result3
 	}{result1, result2, result3}
 }
 
+func (fake *FakeContainer) NetInWithProtocol(hostPort uint32, containerPort uint32, protocol garden.Protocol) (uint32, uint32, error) {
+	fake.netInWithProtocolMutex.Lock()
+	fake.netInWithProtocolArgsForCall = append(fake.netInWithProtocolArgsForCall, struct {
+		hostPort      uint32
+		containerPort uint32
+		protocol      garden.Protocol
+	}{hostPort, containerPort, protocol})
+	fake.netInWithProtocolMutex.Unlock()
+	if fake.NetInWithProtocolStub != nil {
+		return fake.NetInWithProtocolStub(hostPort, containerPort, protocol)
+	} else {
+		return fake.netInWithProtocolReturns.result1, fake.netInWithProtocolReturns.result2, fake.netInWithProtocolReturns.result3
+	}
+}
+
+func (fake *FakeContainer) NetInWithProtocolCallCount() int {
+	fake.netInWithProtocolMutex.RLock()
+	defer fake.netInWithProtocolMutex.RUnlock()
+	return len(fake.netInWithProtocolArgsForCall)
+}
+
+func (fake *FakeContainer) NetInWithProtocolArgsForCall(i int) (uint32, uint32, garden.Protocol) {
+	fake.netInWithProtocolMutex.RLock()
+	defer fake.netInWithProtocolMutex.RUnlock()
+	return fake.netInWithProtocolArgsForCall[i].hostPort, fake.netInWithProtocolArgsForCall[i].containerPort, fake.netInWithProtocolArgsForCall[i].protocol
+}
+
+func (fake *FakeContainer) NetInWithProtocolReturns(result1 uint32, result2 uint32, result3 error) {
+	fake.NetInWithProtocolStub = nil
+	fake.netInWithProtocolReturns = struct {
+		result1 uint32
+		result2 uint32
+		result3 error
+	}{result1, result2, result3}
+}
+
+func (fake *FakeContainer) NetInOnExternalIP(externalIP net.IP, hostPort uint32, containerPort uint32, protocol garden.Protocol) (uint32, uint32, error) {
+	fake.netInOnExternalIPMutex.Lock()
+	fake.netInOnExternalIPArgsForCall = append(fake.netInOnExternalIPArgsForCall, struct {
+		externalIP    net.IP
+		hostPort      uint32
+		containerPort uint32
+		protocol      garden.Protocol
+	}{externalIP, hostPort, containerPort, protocol})
+	fake.netInOnExternalIPMutex.Unlock()
+	if fake.NetInOnExternalIPStub != nil {
+		return fake.NetInOnExternalIPStub(externalIP, hostPort, containerPort, protocol)
+	} else {
+		return fake.netInOnExternalIPReturns.result1, fake.netInOnExternalIPReturns.result2, fake.netInOnExternalIPReturns.result3
+	}
+}
+
+func (fake *FakeContainer) NetInOnExternalIPCallCount() int {
+	fake.netInOnExternalIPMutex.RLock()
+	defer fake.netInOnExternalIPMutex.RUnlock()
+	return len(fake.netInOnExternalIPArgsForCall)
+}
+
+func (fake *FakeContainer) NetInOnExternalIPArgsForCall(i int) (net.IP, uint32, uint32, garden.Protocol) {
+	fake.netInOnExternalIPMutex.RLock()
+	defer fake.netInOnExternalIPMutex.RUnlock()
+	return fake.netInOnExternalIPArgsForCall[i].externalIP, fake.netInOnExternalIPArgsForCall[i].hostPort, fake.netInOnExternalIPArgsForCall[i].containerPort, fake.netInOnExternalIPArgsForCall[i].protocol
+}
+
+func (fake *FakeContainer) NetInOnExternalIPReturns(result1 uint32, result2 uint32, result3 error) {
+	fake.NetInOnExternalIPStub = nil
+	fake.netInOnExternalIPReturns = struct {
+		result1 uint32
+		result2 uint32
+		result3 error
+	}{result1, result2, result3}
+}
+
 func (fake *FakeContainer) NetOut(netOutRule garden.NetOutRule) error {
 	fake.netOutMutex.Lock()
 	fake.netOutArgsForCall = append(fake.netOutArgsForCall, struct {
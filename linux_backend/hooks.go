@@ -62,6 +62,11 @@ func configureHostNetwork(config process.Env, configurer network.Configurer) err
 		return err
 	}
 
+	_, gatewayIPv6, ipv6Net, err := ipv6Network(config)
+	if err != nil {
+		return err
+	}
+
 	err = configurer.ConfigureHost(&network.HostConfig{
 		HostIntf:      config["network_host_iface"],
 		BridgeName:    config["bridge_iface"],
@@ -70,6 +75,8 @@ func configureHostNetwork(config process.Env, configurer network.Configurer) err
 		ContainerPid:  containerPid,
 		Subnet:        ipNet,
 		Mtu:           int(mtu),
+		BridgeIPv6:    gatewayIPv6,
+		IPv6Subnet:    ipv6Net,
 	})
 	if err != nil {
 		return err
@@ -90,6 +97,11 @@ func configureContainerNetwork(config process.Env, configurer network.Configurer
 		return err
 	}
 
+	containerIPv6, gatewayIPv6, ipv6Net, err := ipv6Network(config)
+	if err != nil {
+		return err
+	}
+
 	err = configurer.ConfigureContainer(&network.ContainerConfig{
 		Hostname:      config["id"],
 		ContainerIntf: config["network_container_iface"],
@@ -97,6 +109,9 @@ func configureContainerNetwork(config process.Env, configurer network.Configurer
 		GatewayIP:     net.ParseIP(config["network_host_ip"]),
 		Subnet:        ipNet,
 		Mtu:           int(mtu),
+		ContainerIPv6: containerIPv6,
+		GatewayIPv6:   gatewayIPv6,
+		IPv6Subnet:    ipv6Net,
 	})
 	if err != nil {
 		return err
@@ -105,6 +120,21 @@ func configureContainerNetwork(config process.Env, configurer network.Configurer
 	return nil
 }
 
+// ipv6Network returns the container and gateway IPv6 addresses and subnet from the
+// config, or nils if the container is not dual-stack.
+func ipv6Network(config process.Env) (net.IP, net.IP, *net.IPNet, error) {
+	if config["network_ipv6_cidr"] == "" {
+		return nil, nil, nil, nil
+	}
+
+	_, ipNet, err := net.ParseCIDR(config["network_ipv6_cidr"])
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	return net.ParseIP(config["network_container_ipv6"]), net.ParseIP(config["network_host_ipv6"]), ipNet, nil
+}
+
 func must(err error) {
 	if err != nil {
 		panic(err)
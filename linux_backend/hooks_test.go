@@ -126,6 +126,40 @@ var _ = Describe("Hooks", func() {
 					Expect(hostConfig.Mtu).To(Equal(5000))
 				})
 
+				It("does not configure IPv6 for a container which is not dual-stack", func() {
+					Expect(func() { hooks.Main(hook.PARENT_AFTER_CLONE) }).ToNot(Panic())
+
+					hostConfig := fakeNetworkConfigurer.ConfigureHostArgsForCall(0)
+					Expect(hostConfig.IPv6Subnet).To(BeNil())
+				})
+
+				Context("when the container is dual-stack", func() {
+					BeforeEach(func() {
+						config["network_ipv6_cidr"] = "fd00::/126"
+						config["network_container_ipv6"] = "fd00::1"
+						config["network_host_ipv6"] = "fd00::2"
+					})
+
+					It("configures the bridge's IPv6 address", func() {
+						Expect(func() { hooks.Main(hook.PARENT_AFTER_CLONE) }).ToNot(Panic())
+
+						hostConfig := fakeNetworkConfigurer.ConfigureHostArgsForCall(0)
+						Expect(hostConfig.BridgeIPv6).To(Equal(net.ParseIP("fd00::2")))
+						_, expectedSubnet, _ := net.ParseCIDR("fd00::/126")
+						Expect(hostConfig.IPv6Subnet).To(Equal(expectedSubnet))
+					})
+
+					Context("when the IPv6 CIDR is badly formatted", func() {
+						BeforeEach(func() {
+							config["network_ipv6_cidr"] = "fd00::/126/9"
+						})
+
+						It("panics", func() {
+							Expect(func() { hooks.Main(hook.PARENT_AFTER_CLONE) }).To(Panic())
+						})
+					})
+				})
+
 				Context("when the network configurer fails", func() {
 					BeforeEach(func() {
 						fakeNetworkConfigurer.ConfigureHostReturns(errors.New("oh no!"))
@@ -230,6 +264,24 @@ var _ = Describe("Hooks", func() {
 					Expect(networkConfig.Mtu).To(Equal(5000))
 				})
 
+				Context("when the container is dual-stack", func() {
+					BeforeEach(func() {
+						config["network_ipv6_cidr"] = "fd00::/126"
+						config["network_container_ipv6"] = "fd00::1"
+						config["network_host_ipv6"] = "fd00::2"
+					})
+
+					It("configures the container's IPv6 address and gateway", func() {
+						Expect(func() { hooks.Main(hook.CHILD_AFTER_PIVOT) }).ToNot(Panic())
+
+						networkConfig := fakeNetworkConfigurer.ConfigureContainerArgsForCall(0)
+						Expect(networkConfig.ContainerIPv6).To(Equal(net.ParseIP("fd00::1")))
+						Expect(networkConfig.GatewayIPv6).To(Equal(net.ParseIP("fd00::2")))
+						_, expectedSubnet, _ := net.ParseCIDR("fd00::/126")
+						Expect(networkConfig.IPv6Subnet).To(Equal(expectedSubnet))
+					})
+				})
+
 				Context("when the network configurer returns an error", func() {
 					BeforeEach(func() {
 						fakeNetworkConfigurer.ConfigureContainerReturns(errors.New("oh no!"))
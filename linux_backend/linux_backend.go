@@ -1,14 +1,19 @@
 package linux_backend
 
 import (
+	"bytes"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"net"
 	"os"
 	"path"
 	"time"
 
 	"github.com/cloudfoundry-incubator/garden"
+	"github.com/cloudfoundry-incubator/garden-linux/network"
+	"github.com/cloudfoundry-incubator/garden-linux/network/iptables"
+	"github.com/cloudfoundry-incubator/garden-linux/old/bandwidth_manager"
 	"github.com/cloudfoundry-incubator/garden-linux/old/system_info"
 	"github.com/pivotal-golang/lager"
 )
@@ -22,11 +27,104 @@ type Container interface {
 	Start() error
 
 	Snapshot(io.Writer) error
+	SaveSnapshot() error
 	Cleanup()
 
+	GracefulStop(StopSpec) ([]ProcessStopResult, error)
+
+	Pause() error
+	Resume() error
+
+	Checkpoint(dir string) error
+	RestoreCheckpoint() error
+
+	NetInWithProtocol(hostPort, containerPort uint32, protocol garden.Protocol) (uint32, uint32, error)
+	NetInOnExternalIP(externalIP net.IP, hostPort, containerPort uint32, protocol garden.Protocol) (uint32, uint32, error)
+	NetInRemove(hostPort uint32) error
+	NetIns() []PortMapping
+	BulkNetOut([]garden.NetOutRule) error
+	NetOutRemove(garden.NetOutRule) error
+	NetOutRules() []garden.NetOutRule
+
+	AllowTraffic(policy TrafficPolicy, destinations []net.IP) error
+	DenyTraffic(policy TrafficPolicy) error
+	TrafficPolicies() []TrafficPolicy
+
+	NetworkMetrics() (NetworkMetrics, error)
+
+	LimitDirectionalBandwidth(bandwidth_manager.Limits) error
+	CurrentDirectionalBandwidthLimits() (bandwidth_manager.Limits, error)
+
 	garden.Container
 }
 
+// A PortMapping forwards a port of the host to a port of a container, for
+// TCP, UDP, or both (garden.ProtocolAll).
+type PortMapping struct {
+	HostPort      uint32
+	ContainerPort uint32
+	Protocol      garden.Protocol
+
+	// ExternalIP is the IP of the host the port is mapped on, if it is not
+	// the default external IP.
+	ExternalIP net.IP
+}
+
+// A TrafficPolicy allows the container with the source handle to reach a port
+// of the container with the destination handle, whatever their IPs are.
+//
+// TCP and UDP policies are for a single port. ICMP and garden.ProtocolAll
+// policies are for the whole destination, so their Port must be 0.
+type TrafficPolicy struct {
+	SourceHandle      string
+	DestinationHandle string
+	Protocol          garden.Protocol
+	Port              uint16
+}
+
+type InvalidTrafficPolicyError struct {
+	Policy TrafficPolicy
+	Reason string
+}
+
+func (e InvalidTrafficPolicyError) Error() string {
+	return fmt.Sprintf("invalid traffic policy: %s: %+v", e.Reason, e.Policy)
+}
+
+func (p TrafficPolicy) Validate() error {
+	switch p.Protocol {
+	case garden.ProtocolTCP, garden.ProtocolUDP:
+		if p.Port == 0 {
+			return InvalidTrafficPolicyError{Policy: p, Reason: "a port is required"}
+		}
+	case garden.ProtocolAll, garden.ProtocolICMP:
+		if p.Port != 0 {
+			return InvalidTrafficPolicyError{Policy: p, Reason: "a port cannot be given for the protocol"}
+		}
+	default:
+		return InvalidTrafficPolicyError{Policy: p, Reason: "unknown protocol"}
+	}
+
+	return nil
+}
+
+// NetworkMetrics are the network counters of a container. garden.Metrics
+// carries only those of its interface.
+type NetworkMetrics struct {
+	// Interface holds the counters of the container's network interface, from
+	// the container's point of view: Tx is the traffic the container sent.
+	Interface network.InterfaceStats
+
+	// NetOutRules holds the counters of the rules of the container's filter
+	// chain, which its outbound traffic is matched against.
+	NetOutRules []iptables.RuleCounter
+}
+
+type ContainerNetworkMetricsEntry struct {
+	Metrics NetworkMetrics
+	Err     *garden.Error
+}
+
 type ContainerPool interface {
 	Setup() error
 	Create(garden.ContainerSpec) (Container, error)
@@ -44,14 +142,28 @@ type ContainerRepository interface {
 	Delete(Container)
 }
 
+// A ContainerJournal is a ContainerRepository which durably records every Add
+// and Delete, and every snapshot saved in between, so that live containers can
+// be re-adopted with their latest state after a crash rather than pruned.
+type ContainerJournal interface {
+	Replay(restore func(io.Reader) (Container, error)) error
+
+	// Epochs returns the epoch of the latest journaled snapshot of each live
+	// container, by ID, so that older snapshot files can be told apart.
+	Epochs() (map[string]uint64, error)
+}
+
 type LinuxBackend struct {
 	logger lager.Logger
 
 	containerPool ContainerPool
 	systemInfo    system_info.Provider
 	snapshotsPath string
+	snapshotSaver *SnapshotSaver
 
 	containerRepo ContainerRepository
+
+	events EventEmitter
 }
 
 type HandleExistsError struct {
@@ -76,6 +188,7 @@ func New(
 	containerRepo ContainerRepository,
 	systemInfo system_info.Provider,
 	snapshotsPath string,
+	events EventEmitter,
 ) *LinuxBackend {
 	return &LinuxBackend{
 		logger: logger.Session("backend"),
@@ -83,8 +196,11 @@ func New(
 		containerPool: containerPool,
 		systemInfo:    systemInfo,
 		snapshotsPath: snapshotsPath,
+		snapshotSaver: NewSnapshotSaver(snapshotsPath),
 
 		containerRepo: containerRepo,
+
+		events: events,
 	}
 }
 
@@ -93,11 +209,16 @@ func (b *LinuxBackend) Setup() error {
 }
 
 func (b *LinuxBackend) Start() error {
+	keep := map[string]bool{}
+
 	if b.snapshotsPath != "" {
 		_, err := os.Stat(b.snapshotsPath)
 		if err == nil {
-			b.restoreSnapshots()
-			os.RemoveAll(b.snapshotsPath)
+			// the containers of snapshots which failed to restore are kept
+			// too, so that restoring them can be retried
+			for _, id := range b.restoreSnapshots() {
+				keep[id] = true
+			}
 		}
 
 		err = os.MkdirAll(b.snapshotsPath, 0755)
@@ -106,7 +227,15 @@ func (b *LinuxBackend) Start() error {
 		}
 	}
 
-	keep := map[string]bool{}
+	if journal, ok := b.containerRepo.(ContainerJournal); ok {
+		err := journal.Replay(b.containerPool.Restore)
+		if err != nil {
+			b.logger.Error("failed-to-replay-journal", err)
+			return err
+		}
+	}
+
+	b.reapplyTrafficPolicies()
 
 	containers := b.containerRepo.All()
 
@@ -157,6 +286,15 @@ func (b *LinuxBackend) Create(spec garden.ContainerSpec) (garden.Container, erro
 
 	b.containerRepo.Add(container)
 
+	err = b.saveSnapshot(container)
+	if err != nil {
+		b.logger.Error("failed-to-save-snapshot", err, lager.Data{
+			"container": container.ID(),
+		})
+	}
+
+	b.emit(EventCreated, container)
+
 	return container, nil
 }
 
@@ -173,9 +311,166 @@ func (b *LinuxBackend) Destroy(handle string) error {
 
 	b.containerRepo.Delete(container)
 
+	b.denyTrafficTo(handle)
+
+	err = b.snapshotSaver.Remove(container)
+	if err != nil {
+		b.logger.Error("failed-to-remove-snapshot", err, lager.Data{
+			"container": container.ID(),
+		})
+	}
+
+	b.emit(EventDestroyed, container)
+
 	return nil
 }
 
+// AllowTraffic allows the source container of the policy to reach the
+// destination container, at the destination's current IPs.
+func (b *LinuxBackend) AllowTraffic(policy TrafficPolicy) error {
+	if err := policy.Validate(); err != nil {
+		return err
+	}
+
+	source, err := b.containerRepo.FindByHandle(policy.SourceHandle)
+	if err != nil {
+		return err
+	}
+
+	destinations, err := b.containerIPs(policy.DestinationHandle)
+	if err != nil {
+		return err
+	}
+
+	return source.AllowTraffic(policy, destinations)
+}
+
+func (b *LinuxBackend) DenyTraffic(policy TrafficPolicy) error {
+	source, err := b.containerRepo.FindByHandle(policy.SourceHandle)
+	if err != nil {
+		return err
+	}
+
+	return source.DenyTraffic(policy)
+}
+
+// Checkpoint dumps the processes of the container with the handle into dir,
+// leaving it checkpointed until RestoreCheckpoint brings them back.
+func (b *LinuxBackend) Checkpoint(handle string, dir string) error {
+	container, err := b.containerRepo.FindByHandle(handle)
+	if err != nil {
+		return err
+	}
+
+	return container.Checkpoint(dir)
+}
+
+// RestoreCheckpoint brings back the processes of the container with the handle
+// from its checkpoint, if it has one.
+func (b *LinuxBackend) RestoreCheckpoint(handle string) error {
+	container, err := b.containerRepo.FindByHandle(handle)
+	if err != nil {
+		return err
+	}
+
+	return container.RestoreCheckpoint()
+}
+
+// TrafficPolicies returns the policies which the container with the handle is
+// the source or the destination of.
+func (b *LinuxBackend) TrafficPolicies(handle string) ([]TrafficPolicy, error) {
+	if _, err := b.containerRepo.FindByHandle(handle); err != nil {
+		return nil, err
+	}
+
+	policies := []TrafficPolicy{}
+	for _, container := range b.containerRepo.All() {
+		for _, policy := range container.TrafficPolicies() {
+			if policy.SourceHandle == handle || policy.DestinationHandle == handle {
+				policies = append(policies, policy)
+			}
+		}
+	}
+
+	return policies, nil
+}
+
+// containerIPv6Property is the property in which a dual-stack container
+// reports its IPv6 address.
+const containerIPv6Property = "garden.network.container-ipv6"
+
+// containerIPs returns the IPv4 address of the container with the handle,
+// followed by its IPv6 address if it is dual-stack.
+func (b *LinuxBackend) containerIPs(handle string) ([]net.IP, error) {
+	container, err := b.containerRepo.FindByHandle(handle)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := container.Info()
+	if err != nil {
+		return nil, err
+	}
+
+	ip := net.ParseIP(info.ContainerIP)
+	if ip == nil {
+		return nil, fmt.Errorf("linux_backend: container has no IP: %s", handle)
+	}
+
+	ips := []net.IP{ip}
+	if ipv6 := net.ParseIP(info.Properties[containerIPv6Property]); ipv6 != nil {
+		ips = append(ips, ipv6)
+	}
+
+	return ips, nil
+}
+
+// reapplyTrafficPolicies applies the rules of the restored policies for the
+// current IPs of their destinations, and drops the policies whose destination
+// did not survive the restart.
+func (b *LinuxBackend) reapplyTrafficPolicies() {
+	pLog := b.logger.Session("reapply-traffic-policies")
+
+	for _, container := range b.containerRepo.All() {
+		for _, policy := range container.TrafficPolicies() {
+			destinations, err := b.containerIPs(policy.DestinationHandle)
+			if err != nil {
+				pLog.Info("destination-gone", lager.Data{"policy": policy})
+
+				err = container.DenyTraffic(policy)
+				if err != nil {
+					pLog.Error("failed-to-deny", err, lager.Data{"policy": policy})
+				}
+
+				continue
+			}
+
+			err = container.AllowTraffic(policy, destinations)
+			if err != nil {
+				pLog.Error("failed-to-allow", err, lager.Data{"policy": policy})
+			}
+		}
+	}
+}
+
+// denyTrafficTo removes the policies which allow traffic to a container which
+// has been destroyed, so that its IP is not reachable by whichever container
+// is given it next.
+func (b *LinuxBackend) denyTrafficTo(handle string) {
+	for _, container := range b.containerRepo.All() {
+		for _, policy := range container.TrafficPolicies() {
+			if policy.DestinationHandle != handle {
+				continue
+			}
+
+			err := container.DenyTraffic(policy)
+			if err != nil {
+				b.logger.Error("failed-to-deny-traffic", err, lager.Data{"policy": policy})
+			}
+		}
+	}
+}
+
 func (b *LinuxBackend) Containers(props garden.Properties) ([]garden.Container, error) {
 	return toGardenContainers(b.containerRepo.Query(withProperties(props))), nil
 }
@@ -224,6 +519,30 @@ func (b *LinuxBackend) BulkMetrics(handles []string) (map[string]garden.Containe
 	return metrics, nil
 }
 
+// BulkNetworkMetrics returns the network counters of the containers with the
+// given handles, keyed by handle like BulkMetrics. The garden API carries
+// only the interface counters, so the rule counters are served by the debug
+// server.
+func (b *LinuxBackend) BulkNetworkMetrics(handles []string) (map[string]ContainerNetworkMetricsEntry, error) {
+	containers := b.containerRepo.Query(withHandles(handles))
+
+	metrics := make(map[string]ContainerNetworkMetricsEntry)
+	for _, container := range containers {
+		metric, err := container.NetworkMetrics()
+		if err != nil {
+			metrics[container.Handle()] = ContainerNetworkMetricsEntry{
+				Err: garden.NewError(err.Error()),
+			}
+		} else {
+			metrics[container.Handle()] = ContainerNetworkMetricsEntry{
+				Metrics: metric,
+			}
+		}
+	}
+
+	return metrics, nil
+}
+
 func (b *LinuxBackend) GraceTime(container garden.Container) time.Duration {
 	return container.(Container).GraceTime()
 }
@@ -240,7 +559,10 @@ func (b *LinuxBackend) Stop() {
 	}
 }
 
-func (b *LinuxBackend) restoreSnapshots() {
+// restoreSnapshots restores the newest snapshot of each container, unless the
+// journal has a newer one, and returns the IDs of the containers whose
+// snapshots failed to restore. Those snapshots are kept for the next start.
+func (b *LinuxBackend) restoreSnapshots() []string {
 	sLog := b.logger.Session("restore")
 
 	entries, err := ioutil.ReadDir(b.snapshotsPath)
@@ -250,52 +572,134 @@ func (b *LinuxBackend) restoreSnapshots() {
 		})
 	}
 
+	journaled := map[string]uint64{}
+	if journal, ok := b.containerRepo.(ContainerJournal); ok {
+		journaled, err = journal.Epochs()
+		if err != nil {
+			sLog.Error("failed-to-read-journal-epochs", err)
+		}
+	}
+
+	type snapshotFile struct {
+		path   string
+		data   []byte
+		header SnapshotHeader
+	}
+
+	files := []*snapshotFile{}
+	newest := map[string]*snapshotFile{}
+
 	for _, entry := range entries {
 		snapshot := path.Join(b.snapshotsPath, entry.Name())
 
+		if isTemporarySnapshot(entry.Name()) {
+			os.Remove(snapshot)
+			continue
+		}
+
+		data, err := ioutil.ReadFile(snapshot)
+		if err != nil {
+			sLog.Error("failed-to-open", err, lager.Data{"snapshot": entry.Name()})
+			continue
+		}
+
+		// snapshots whose header cannot be read cannot be compared, but are
+		// restored all the same
+		header, _ := ReadSnapshotHeader(data)
+
+		file := &snapshotFile{path: snapshot, data: data, header: header}
+		files = append(files, file)
+
+		if header.ID == "" {
+			continue
+		}
+
+		if current, found := newest[header.ID]; !found || header.Epoch > current.header.Epoch {
+			newest[header.ID] = file
+		}
+	}
+
+	failed := []string{}
+	stale := []*snapshotFile{}
+
+	for _, file := range files {
 		lLog := sLog.Session("load", lager.Data{
-			"snapshot": entry.Name(),
+			"snapshot": path.Base(file.path),
 		})
 
+		if id := file.header.ID; id != "" {
+			if newest[id] != file {
+				lLog.Info("skipping-stale-snapshot", lager.Data{"epoch": file.header.Epoch})
+				stale = append(stale, file)
+				continue
+			}
+
+			if epoch, found := journaled[id]; found && epoch > file.header.Epoch {
+				// the journal will restore the container from its newer snapshot
+				lLog.Info("skipping-snapshot-older-than-journal", lager.Data{
+					"epoch":         file.header.Epoch,
+					"journal-epoch": epoch,
+				})
+				continue
+			}
+		}
+
 		lLog.Debug("loading")
 
-		file, err := os.Open(snapshot)
+		container, err := b.restore(bytes.NewReader(file.data))
 		if err != nil {
-			lLog.Error("failed-to-open", err)
+			lLog.Error("failed-to-restore", err)
+
+			if file.header.ID != "" {
+				failed = append(failed, file.header.ID)
+			}
+
+			continue
+		}
+
+		if container.ID() != path.Base(file.path) {
+			os.Remove(file.path)
 		}
 
-		_, err = b.restore(file)
+		err = b.saveSnapshot(container)
 		if err != nil {
-			lLog.Error("failed-to-restore", err)
+			lLog.Error("failed-to-save", err)
 		}
 	}
-}
 
-func (b *LinuxBackend) saveSnapshot(container Container) error {
-	if b.snapshotsPath == "" {
-		return nil
+	for _, file := range stale {
+		// stale snapshots are only removed once the newer one has restored,
+		// so that they cannot resurrect a container later on
+		if _, err := b.containerRepo.FindByHandle(newest[file.header.ID].header.Handle); err == nil {
+			os.Remove(file.path)
+		}
 	}
 
+	return failed
+}
+
+// saveSnapshot has the container save its snapshot, rather than saving it
+// here, so that it is ordered with the saves the container makes itself.
+func (b *LinuxBackend) saveSnapshot(container Container) error {
 	b.logger.Info("save-snapshot", lager.Data{
 		"container": container.ID(),
 	})
 
-	snapshotPath := path.Join(b.snapshotsPath, container.ID())
+	return container.SaveSnapshot()
+}
 
-	snapshot, err := os.Create(snapshotPath)
-	if err != nil {
-		return &FailedToSnapshotError{err}
-	}
+func (b *LinuxBackend) emit(eventType EventType, container Container) {
+	properties, _ := container.Properties()
 
-	err = container.Snapshot(snapshot)
-	if err != nil {
-		return &FailedToSnapshotError{err}
-	}
+	b.events.Emit(ContainerEvent{
+		Type: eventType,
 
-	return snapshot.Close()
+		Handle:     container.Handle(),
+		Properties: properties,
+	})
 }
 
-func (b *LinuxBackend) restore(snapshot io.Reader) (garden.Container, error) {
+func (b *LinuxBackend) restore(snapshot io.Reader) (Container, error) {
 	container, err := b.containerPool.Restore(snapshot)
 	if err != nil {
 		return nil, err
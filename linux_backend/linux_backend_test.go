@@ -3,9 +3,12 @@ package linux_backend_test
 import (
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"net"
 	"os"
 	"path"
+	"strings"
 	"time"
 
 	. "github.com/onsi/ginkgo"
@@ -17,6 +20,7 @@ import (
 	"github.com/cloudfoundry-incubator/garden-linux/container_repository"
 	"github.com/cloudfoundry-incubator/garden-linux/linux_backend"
 	"github.com/cloudfoundry-incubator/garden-linux/linux_backend/fakes"
+	"github.com/cloudfoundry-incubator/garden-linux/network"
 	"github.com/cloudfoundry-incubator/garden-linux/old/system_info/fake_system_info"
 )
 
@@ -28,12 +32,14 @@ var _ = Describe("LinuxBackend", func() {
 	var containerRepo linux_backend.ContainerRepository
 	var linuxBackend *linux_backend.LinuxBackend
 	var snapshotsPath string
+	var fakeEventEmitter *fakes.FakeEventEmitter
 
 	BeforeEach(func() {
 		logger = lagertest.NewTestLogger("test")
 		fakeContainerPool = fake_container_pool.New()
 		containerRepo = container_repository.New()
 		fakeSystemInfo = fake_system_info.NewFakeProvider()
+		fakeEventEmitter = new(fakes.FakeEventEmitter)
 
 		snapshotsPath = ""
 	})
@@ -45,6 +51,7 @@ var _ = Describe("LinuxBackend", func() {
 			containerRepo,
 			fakeSystemInfo,
 			snapshotsPath,
+			fakeEventEmitter,
 		)
 	})
 
@@ -165,17 +172,110 @@ var _ = Describe("LinuxBackend", func() {
 				}))
 			})
 
+			Context("when a snapshot was left half-written", func() {
+				BeforeEach(func() {
+					file, err := os.Create(path.Join(snapshotsPath, ".some-id-123"))
+					Expect(err).ToNot(HaveOccurred())
+
+					file.Write([]byte("handle-"))
+					file.Close()
+				})
+
+				It("does not restore it", func() {
+					err := linuxBackend.Start()
+					Expect(err).ToNot(HaveOccurred())
+
+					Expect(fakeContainerPool.RestoredSnapshots).To(HaveLen(2))
+				})
+
+				It("removes it", func() {
+					err := linuxBackend.Start()
+					Expect(err).ToNot(HaveOccurred())
+
+					_, err = os.Stat(path.Join(snapshotsPath, ".some-id-123"))
+					Expect(os.IsNotExist(err)).To(BeTrue())
+				})
+			})
+
+			Context("when there are several snapshots of a container", func() {
+				BeforeEach(func() {
+					Expect(ioutil.WriteFile(path.Join(snapshotsPath, "dup-old"), []byte(`{"ID":"dup","Handle":"old-handle","Epoch":1}`), 0644)).To(Succeed())
+					Expect(ioutil.WriteFile(path.Join(snapshotsPath, "dup"), []byte(`{"ID":"dup","Handle":"new-handle","Epoch":2}`), 0644)).To(Succeed())
+				})
+
+				It("restores only the one with the highest epoch", func() {
+					Expect(linuxBackend.Start()).To(Succeed())
+
+					Expect(fakeContainerPool.RestoredSnapshots).To(HaveLen(3))
+
+					_, err := linuxBackend.Lookup("new-handle")
+					Expect(err).ToNot(HaveOccurred())
+
+					_, err = linuxBackend.Lookup("old-handle")
+					Expect(err).To(HaveOccurred())
+				})
+
+				It("removes the stale ones", func() {
+					Expect(linuxBackend.Start()).To(Succeed())
+
+					_, err := os.Stat(path.Join(snapshotsPath, "dup-old"))
+					Expect(os.IsNotExist(err)).To(BeTrue())
+				})
+
+				Context("and the journal has a newer one", func() {
+					BeforeEach(func() {
+						containerRepo = &fakeJournal{
+							ContainerRepository: container_repository.New(),
+							epochs:              map[string]uint64{"dup": 3},
+						}
+					})
+
+					It("leaves restoring the container to the journal", func() {
+						Expect(linuxBackend.Start()).To(Succeed())
+
+						_, err := linuxBackend.Lookup("new-handle")
+						Expect(err).To(HaveOccurred())
+					})
+
+					It("keeps the snapshots", func() {
+						Expect(linuxBackend.Start()).To(Succeed())
+
+						_, err := os.Stat(path.Join(snapshotsPath, "dup"))
+						Expect(err).ToNot(HaveOccurred())
+					})
+				})
+			})
+
 			Context("when restoring the container fails", func() {
 				disaster := errors.New("failed to restore")
 
 				BeforeEach(func() {
 					fakeContainerPool.RestoreError = disaster
+
+					Expect(ioutil.WriteFile(path.Join(snapshotsPath, "some-id-3"), []byte(`{"ID":"some-id-3","Handle":"handle-c"}`), 0644)).To(Succeed())
 				})
 
 				It("successfully starts anyway", func() {
 					err := linuxBackend.Start()
 					Expect(err).ToNot(HaveOccurred())
 				})
+
+				It("keeps the snapshots, to retry restoring them on the next start", func() {
+					Expect(linuxBackend.Start()).To(Succeed())
+
+					for _, name := range []string{"some-id", "some-other-id", "some-id-3"} {
+						_, err := os.Stat(path.Join(snapshotsPath, name))
+						Expect(err).ToNot(HaveOccurred())
+					}
+				})
+
+				It("keeps the containers when pruning the container pool", func() {
+					Expect(linuxBackend.Start()).To(Succeed())
+
+					Expect(fakeContainerPool.KeptContainers).To(Equal(map[string]bool{
+						"some-id-3": true,
+					}))
+				})
 			})
 		})
 
@@ -187,6 +287,53 @@ var _ = Describe("LinuxBackend", func() {
 			Expect(fakeContainerPool.KeptContainers).To(Equal(map[string]bool{}))
 		})
 
+		Context("when the container repository is a journal", func() {
+			var journal *fakeJournal
+
+			BeforeEach(func() {
+				journal = &fakeJournal{
+					ContainerRepository: container_repository.New(),
+					handles:             []string{"journaled-handle"},
+				}
+
+				containerRepo = journal
+			})
+
+			It("replays it via the container pool", func() {
+				err := linuxBackend.Start()
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(fakeContainerPool.RestoredSnapshots).To(HaveLen(1))
+
+				_, err = linuxBackend.Lookup("journaled-handle")
+				Expect(err).ToNot(HaveOccurred())
+			})
+
+			It("keeps the replayed containers when pruning the container pool", func() {
+				err := linuxBackend.Start()
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(fakeContainerPool.KeptContainers).To(Equal(map[string]bool{
+					"journaled-handle": true,
+				}))
+			})
+
+			Context("when replaying the journal fails", func() {
+				disaster := errors.New("failed to replay")
+
+				BeforeEach(func() {
+					journal.replayError = disaster
+				})
+
+				It("returns the error without pruning", func() {
+					err := linuxBackend.Start()
+					Expect(err).To(Equal(disaster))
+
+					Expect(fakeContainerPool.Pruned).To(BeFalse())
+				})
+			})
+		})
+
 		Context("when pruning the container pool fails", func() {
 			disaster := errors.New("failed to prune")
 
@@ -224,13 +371,11 @@ var _ = Describe("LinuxBackend", func() {
 			containerRepo.Add(container2)
 		})
 
-		Context("when no snapshot directory is passed", func() {
-			It("stops succesfully without saving snapshots", func() {
-				Expect(func() { linuxBackend.Stop() }).ToNot(Panic())
+		It("has each container save its snapshot", func() {
+			linuxBackend.Stop()
 
-				Expect(container1.SavedSnapshots).To(HaveLen(0))
-				Expect(container2.SavedSnapshots).To(HaveLen(0))
-			})
+			Expect(container1.SnapshotsSaved).To(Equal(1))
+			Expect(container2.SnapshotsSaved).To(Equal(1))
 		})
 
 		Context("when the snapshot directory is passed", func() {
@@ -246,13 +391,6 @@ var _ = Describe("LinuxBackend", func() {
 				Expect(err).ToNot(HaveOccurred())
 			})
 
-			It("takes a snapshot of each container", func() {
-				linuxBackend.Stop()
-
-				Expect(container1.SavedSnapshots).To(HaveLen(1))
-				Expect(container2.SavedSnapshots).To(HaveLen(1))
-			})
-
 			It("cleans up each container", func() {
 				linuxBackend.Stop()
 
@@ -333,6 +471,17 @@ var _ = Describe("LinuxBackend", func() {
 			})
 		})
 
+		It("emits a created event", func() {
+			_, err := linuxBackend.Create(garden.ContainerSpec{Handle: "some-handle"})
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(fakeEventEmitter.EmitCallCount()).To(Equal(1))
+
+			event := fakeEventEmitter.EmitArgsForCall(0)
+			Expect(event.Type).To(Equal(linux_backend.EventCreated))
+			Expect(event.Handle).To(Equal("some-handle"))
+		})
+
 		It("registers the container", func() {
 			container, err := linuxBackend.Create(garden.ContainerSpec{})
 			Expect(err).ToNot(HaveOccurred())
@@ -343,6 +492,35 @@ var _ = Describe("LinuxBackend", func() {
 			Expect(foundContainer).To(Equal(container))
 		})
 
+		Context("when the snapshot directory is passed", func() {
+			BeforeEach(func() {
+				tmpdir, err := ioutil.TempDir(os.TempDir(), "garden-server-test")
+				Expect(err).ToNot(HaveOccurred())
+
+				snapshotsPath = tmpdir
+			})
+
+			It("has the container save its snapshot", func() {
+				container, err := linuxBackend.Create(garden.ContainerSpec{Handle: "some-handle"})
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(container.(*fake_container_pool.FakeContainer).SnapshotsSaved).To(Equal(1))
+			})
+
+			Context("when saving the snapshot fails", func() {
+				BeforeEach(func() {
+					fakeContainerPool.ContainerSetup = func(c *fake_container_pool.FakeContainer) {
+						c.SnapshotError = errors.New("oh no!")
+					}
+				})
+
+				It("creates the container anyway", func() {
+					_, err := linuxBackend.Create(garden.ContainerSpec{})
+					Expect(err).ToNot(HaveOccurred())
+				})
+			})
+		})
+
 		Context("when creating the container fails", func() {
 			disaster := errors.New("failed to create")
 
@@ -386,6 +564,12 @@ var _ = Describe("LinuxBackend", func() {
 				Expect(container).To(BeNil())
 			})
 
+			It("does not emit a created event", func() {
+				linuxBackend.Create(garden.ContainerSpec{})
+
+				Expect(fakeEventEmitter.EmitCallCount()).To(Equal(0))
+			})
+
 			It("does not register the container", func() {
 				_, err := linuxBackend.Create(garden.ContainerSpec{})
 				Expect(err).To(HaveOccurred())
@@ -415,6 +599,17 @@ var _ = Describe("LinuxBackend", func() {
 			Expect(fakeContainerPool.DestroyedContainers).To(ContainElement(container))
 		})
 
+		It("emits a destroyed event", func() {
+			err := linuxBackend.Destroy("some-handle")
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(fakeEventEmitter.EmitCallCount()).To(Equal(1))
+
+			event := fakeEventEmitter.EmitArgsForCall(0)
+			Expect(event.Type).To(Equal(linux_backend.EventDestroyed))
+			Expect(event.Handle).To(Equal("some-handle"))
+		})
+
 		It("unregisters the container", func() {
 			err := linuxBackend.Destroy("some-handle")
 			Expect(err).ToNot(HaveOccurred())
@@ -424,6 +619,26 @@ var _ = Describe("LinuxBackend", func() {
 			Expect(err).To(MatchError(garden.ContainerNotFoundError{"some-handle"}))
 		})
 
+		Context("when the snapshot directory is passed", func() {
+			BeforeEach(func() {
+				tmpdir, err := ioutil.TempDir(os.TempDir(), "garden-server-test")
+				Expect(err).ToNot(HaveOccurred())
+
+				snapshotsPath = tmpdir
+
+				err = ioutil.WriteFile(path.Join(snapshotsPath, "some-handle"), []byte("snapshot"), 0644)
+				Expect(err).ToNot(HaveOccurred())
+			})
+
+			It("removes the container's snapshot", func() {
+				err := linuxBackend.Destroy("some-handle")
+				Expect(err).ToNot(HaveOccurred())
+
+				_, err = os.Stat(path.Join(snapshotsPath, "some-handle"))
+				Expect(os.IsNotExist(err)).To(BeTrue())
+			})
+		})
+
 		Context("when the container does not exist", func() {
 			It("returns ContainerNotFoundError", func() {
 				err := linuxBackend.Destroy("bogus-handle")
@@ -456,6 +671,276 @@ var _ = Describe("LinuxBackend", func() {
 		})
 	})
 
+	Describe("checkpointing", func() {
+		var container *fakes.FakeContainer
+
+		BeforeEach(func() {
+			container = &fakes.FakeContainer{}
+			container.HandleReturns("some-handle")
+
+			containerRepo.Add(container)
+		})
+
+		Describe("Checkpoint", func() {
+			It("checkpoints the container into the directory", func() {
+				Expect(linuxBackend.Checkpoint("some-handle", "/some/images/dir")).To(Succeed())
+
+				Expect(container.CheckpointCallCount()).To(Equal(1))
+				Expect(container.CheckpointArgsForCall(0)).To(Equal("/some/images/dir"))
+			})
+
+			Context("when checkpointing fails", func() {
+				disaster := errors.New("oh no!")
+
+				BeforeEach(func() {
+					container.CheckpointReturns(disaster)
+				})
+
+				It("returns the error", func() {
+					Expect(linuxBackend.Checkpoint("some-handle", "/some/images/dir")).To(Equal(disaster))
+				})
+			})
+
+			Context("when the container does not exist", func() {
+				It("returns a ContainerNotFoundError", func() {
+					err := linuxBackend.Checkpoint("bogus-handle", "/some/images/dir")
+					Expect(err).To(BeAssignableToTypeOf(garden.ContainerNotFoundError{}))
+				})
+			})
+		})
+
+		Describe("RestoreCheckpoint", func() {
+			It("restores the container from its checkpoint", func() {
+				Expect(linuxBackend.RestoreCheckpoint("some-handle")).To(Succeed())
+
+				Expect(container.RestoreCheckpointCallCount()).To(Equal(1))
+			})
+
+			Context("when restoring the checkpoint fails", func() {
+				disaster := errors.New("oh no!")
+
+				BeforeEach(func() {
+					container.RestoreCheckpointReturns(disaster)
+				})
+
+				It("returns the error", func() {
+					Expect(linuxBackend.RestoreCheckpoint("some-handle")).To(Equal(disaster))
+				})
+			})
+
+			Context("when the container does not exist", func() {
+				It("returns a ContainerNotFoundError", func() {
+					err := linuxBackend.RestoreCheckpoint("bogus-handle")
+					Expect(err).To(BeAssignableToTypeOf(garden.ContainerNotFoundError{}))
+				})
+			})
+		})
+	})
+
+	Describe("Traffic policies", func() {
+		var source, destination *fakes.FakeContainer
+		var policy linux_backend.TrafficPolicy
+
+		newContainer := func(handle, ip string) *fakes.FakeContainer {
+			fakeContainer := &fakes.FakeContainer{}
+			fakeContainer.IDReturns(handle)
+			fakeContainer.HandleReturns(handle)
+			fakeContainer.InfoReturns(garden.ContainerInfo{ContainerIP: ip}, nil)
+			return fakeContainer
+		}
+
+		BeforeEach(func() {
+			source = newContainer("source-handle", "10.2.0.2")
+			destination = newContainer("destination-handle", "10.2.0.6")
+
+			containerRepo.Add(source)
+			containerRepo.Add(destination)
+
+			policy = linux_backend.TrafficPolicy{
+				SourceHandle:      "source-handle",
+				DestinationHandle: "destination-handle",
+				Protocol:          garden.ProtocolTCP,
+				Port:              8080,
+			}
+		})
+
+		Describe("AllowTraffic", func() {
+			It("allows the source to reach the current IP of the destination", func() {
+				Expect(linuxBackend.AllowTraffic(policy)).To(Succeed())
+
+				Expect(source.AllowTrafficCallCount()).To(Equal(1))
+
+				allowed, ips := source.AllowTrafficArgsForCall(0)
+				Expect(allowed).To(Equal(policy))
+				Expect(ips).To(Equal([]net.IP{net.ParseIP("10.2.0.6")}))
+			})
+
+			Context("when the destination is dual-stack", func() {
+				BeforeEach(func() {
+					destination.InfoReturns(garden.ContainerInfo{
+						ContainerIP: "10.2.0.6",
+						Properties: garden.Properties{
+							"garden.network.container-ipv6": "fd00::6",
+						},
+					}, nil)
+				})
+
+				It("allows the source to reach both of its IPs", func() {
+					Expect(linuxBackend.AllowTraffic(policy)).To(Succeed())
+
+					_, ips := source.AllowTrafficArgsForCall(0)
+					Expect(ips).To(Equal([]net.IP{net.ParseIP("10.2.0.6"), net.ParseIP("fd00::6")}))
+				})
+			})
+
+			It("allows policies without a port for ICMP and all protocols", func() {
+				policy.Port = 0
+
+				policy.Protocol = garden.ProtocolICMP
+				Expect(linuxBackend.AllowTraffic(policy)).To(Succeed())
+
+				policy.Protocol = garden.ProtocolAll
+				Expect(linuxBackend.AllowTraffic(policy)).To(Succeed())
+
+				Expect(source.AllowTrafficCallCount()).To(Equal(2))
+			})
+
+			Context("when the policy is invalid", func() {
+				itRejects := func(protocol garden.Protocol, port uint16) {
+					policy.Protocol = protocol
+					policy.Port = port
+
+					err := linuxBackend.AllowTraffic(policy)
+					Expect(err).To(BeAssignableToTypeOf(linux_backend.InvalidTrafficPolicyError{}))
+
+					Expect(source.AllowTrafficCallCount()).To(Equal(0))
+				}
+
+				It("rejects TCP and UDP policies without a port", func() {
+					itRejects(garden.ProtocolTCP, 0)
+					itRejects(garden.ProtocolUDP, 0)
+				})
+
+				It("rejects ICMP and all protocol policies with a port", func() {
+					itRejects(garden.ProtocolICMP, 8080)
+					itRejects(garden.ProtocolAll, 8080)
+				})
+
+				It("rejects unknown protocols", func() {
+					itRejects(garden.Protocol(42), 8080)
+				})
+			})
+
+			Context("when the destination does not exist", func() {
+				It("returns ContainerNotFoundError", func() {
+					policy.DestinationHandle = "bogus-handle"
+
+					err := linuxBackend.AllowTraffic(policy)
+					Expect(err).To(Equal(garden.ContainerNotFoundError{"bogus-handle"}))
+
+					Expect(source.AllowTrafficCallCount()).To(Equal(0))
+				})
+			})
+
+			Context("when the source does not exist", func() {
+				It("returns ContainerNotFoundError", func() {
+					policy.SourceHandle = "bogus-handle"
+
+					err := linuxBackend.AllowTraffic(policy)
+					Expect(err).To(Equal(garden.ContainerNotFoundError{"bogus-handle"}))
+				})
+			})
+		})
+
+		Describe("DenyTraffic", func() {
+			It("denies the traffic in the source", func() {
+				Expect(linuxBackend.DenyTraffic(policy)).To(Succeed())
+
+				Expect(source.DenyTrafficCallCount()).To(Equal(1))
+				Expect(source.DenyTrafficArgsForCall(0)).To(Equal(policy))
+			})
+
+			Context("when the source fails to deny the traffic", func() {
+				disaster := errors.New("oh no!")
+
+				BeforeEach(func() {
+					source.DenyTrafficReturns(disaster)
+				})
+
+				It("returns the error", func() {
+					Expect(linuxBackend.DenyTraffic(policy)).To(Equal(disaster))
+				})
+			})
+		})
+
+		Describe("TrafficPolicies", func() {
+			It("returns the policies which the container is the source or destination of", func() {
+				unrelated := linux_backend.TrafficPolicy{
+					SourceHandle:      "destination-handle",
+					DestinationHandle: "some-other-handle",
+				}
+
+				source.TrafficPoliciesReturns([]linux_backend.TrafficPolicy{policy})
+				destination.TrafficPoliciesReturns([]linux_backend.TrafficPolicy{unrelated})
+
+				Expect(linuxBackend.TrafficPolicies("source-handle")).To(Equal([]linux_backend.TrafficPolicy{policy}))
+				Expect(linuxBackend.TrafficPolicies("destination-handle")).To(ConsistOf(policy, unrelated))
+			})
+
+			Context("when the container does not exist", func() {
+				It("returns ContainerNotFoundError", func() {
+					_, err := linuxBackend.TrafficPolicies("bogus-handle")
+					Expect(err).To(Equal(garden.ContainerNotFoundError{"bogus-handle"}))
+				})
+			})
+		})
+
+		Context("when the destination is destroyed", func() {
+			BeforeEach(func() {
+				source.TrafficPoliciesReturns([]linux_backend.TrafficPolicy{policy})
+			})
+
+			It("denies the traffic to it", func() {
+				Expect(linuxBackend.Destroy("destination-handle")).To(Succeed())
+
+				Expect(source.DenyTrafficCallCount()).To(Equal(1))
+				Expect(source.DenyTrafficArgsForCall(0)).To(Equal(policy))
+			})
+		})
+
+		Context("when the backend starts with restored policies", func() {
+			BeforeEach(func() {
+				source.TrafficPoliciesReturns([]linux_backend.TrafficPolicy{policy})
+			})
+
+			It("re-applies them for the current IPs of their destinations", func() {
+				destination.InfoReturns(garden.ContainerInfo{ContainerIP: "10.2.0.10"}, nil)
+
+				Expect(linuxBackend.Start()).To(Succeed())
+
+				Expect(source.AllowTrafficCallCount()).To(Equal(1))
+
+				allowed, ips := source.AllowTrafficArgsForCall(0)
+				Expect(allowed).To(Equal(policy))
+				Expect(ips).To(Equal([]net.IP{net.ParseIP("10.2.0.10")}))
+			})
+
+			Context("when the destination was not restored", func() {
+				BeforeEach(func() {
+					containerRepo.Delete(destination)
+				})
+
+				It("denies the traffic instead", func() {
+					Expect(linuxBackend.Start()).To(Succeed())
+
+					Expect(source.AllowTrafficCallCount()).To(Equal(0))
+					Expect(source.DenyTrafficCallCount()).To(Equal(1))
+					Expect(source.DenyTrafficArgsForCall(0)).To(Equal(policy))
+				})
+			})
+		})
+	})
+
 	Describe("BulkInfo", func() {
 		newContainer := func(handle string) *fakes.FakeContainer {
 			fakeContainer := &fakes.FakeContainer{}
@@ -630,6 +1115,71 @@ var _ = Describe("LinuxBackend", func() {
 		})
 	})
 
+	Describe("BulkNetworkMetrics", func() {
+		var container1, container2 *fakes.FakeContainer
+
+		newContainer := func(n uint64) *fakes.FakeContainer {
+			fakeContainer := &fakes.FakeContainer{}
+			fakeContainer.HandleReturns(fmt.Sprintf("handle%d", n))
+			fakeContainer.NetworkMetricsReturns(
+				linux_backend.NetworkMetrics{
+					Interface: network.InterfaceStats{
+						TxBytes: n,
+					},
+				},
+				nil,
+			)
+			return fakeContainer
+		}
+
+		BeforeEach(func() {
+			container1 = newContainer(1)
+			container2 = newContainer(2)
+
+			containerRepo.Add(container1)
+			containerRepo.Add(container2)
+		})
+
+		It("returns the network metrics of the specified containers", func() {
+			bulkMetrics, err := linuxBackend.BulkNetworkMetrics([]string{"handle2"})
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(bulkMetrics).To(Equal(map[string]linux_backend.ContainerNetworkMetricsEntry{
+				"handle2": linux_backend.ContainerNetworkMetricsEntry{
+					Metrics: linux_backend.NetworkMetrics{
+						Interface: network.InterfaceStats{
+							TxBytes: 2,
+						},
+					},
+				},
+			}))
+		})
+
+		Context("when getting the network metrics of a container fails", func() {
+			BeforeEach(func() {
+				container2.NetworkMetricsReturns(linux_backend.NetworkMetrics{}, errors.New("Oh no!"))
+			})
+
+			It("returns the err for the failed container", func() {
+				bulkMetrics, err := linuxBackend.BulkNetworkMetrics([]string{"handle1", "handle2"})
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(bulkMetrics).To(Equal(map[string]linux_backend.ContainerNetworkMetricsEntry{
+					"handle1": linux_backend.ContainerNetworkMetricsEntry{
+						Metrics: linux_backend.NetworkMetrics{
+							Interface: network.InterfaceStats{
+								TxBytes: 1,
+							},
+						},
+					},
+					"handle2": linux_backend.ContainerNetworkMetricsEntry{
+						Err: garden.NewError("Oh no!"),
+					},
+				}))
+			})
+		})
+	})
+
 	Describe("Lookup", func() {
 		It("returns the container", func() {
 			container, err := linuxBackend.Create(garden.ContainerSpec{})
@@ -707,3 +1257,33 @@ var _ = Describe("LinuxBackend", func() {
 		})
 	})
 })
+
+type fakeJournal struct {
+	linux_backend.ContainerRepository
+
+	handles     []string
+	replayError error
+
+	epochs map[string]uint64
+}
+
+func (j *fakeJournal) Epochs() (map[string]uint64, error) {
+	return j.epochs, nil
+}
+
+func (j *fakeJournal) Replay(restore func(io.Reader) (linux_backend.Container, error)) error {
+	if j.replayError != nil {
+		return j.replayError
+	}
+
+	for _, handle := range j.handles {
+		container, err := restore(strings.NewReader(handle))
+		if err != nil {
+			return err
+		}
+
+		j.Add(container)
+	}
+
+	return nil
+}
@@ -26,9 +26,27 @@ func (n *NamespacedSignaller) Signal(signal os.Signal) error {
 		return err
 	}
 
-	return n.Runner.Run(exec.Command(filepath.Join(n.ContainerPath, "bin/wsh"),
+	return n.kill(signal, fmt.Sprintf("%d", pid))
+}
+
+// SignalGroup sends the signal to every process in the process group led by
+// the process, i.e. the process and any children it has not moved out of it.
+func (n *NamespacedSignaller) SignalGroup(signal os.Signal) error {
+	pid, err := pidFromFile(n.PidFilePath)
+	if err != nil {
+		return err
+	}
+
+	return n.kill(signal, "--", fmt.Sprintf("-%d", pid))
+}
+
+func (n *NamespacedSignaller) kill(signal os.Signal, target ...string) error {
+	args := []string{
 		"--socket", filepath.Join(n.ContainerPath, "run/wshd.sock"),
-		"kill", fmt.Sprintf("-%d", signal), fmt.Sprintf("%d", pid)))
+		"kill", fmt.Sprintf("-%d", signal),
+	}
+
+	return n.Runner.Run(exec.Command(filepath.Join(n.ContainerPath, "bin/wsh"), append(args, target...)...))
 }
 
 func pidFromFile(pidFilePath string) (int, error) {
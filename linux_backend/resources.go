@@ -4,24 +4,40 @@ import (
 	"encoding/json"
 	"net"
 	"sync"
+
+	"github.com/cloudfoundry-incubator/garden-linux/network"
 )
 
 type Network struct {
 	Subnet *net.IPNet
 	IP     net.IP
+
+	// IPv6Subnet and IPv6 are only set for dual-stack containers.
+	IPv6Subnet *net.IPNet
+	IPv6       net.IP
 }
 
 func (n *Network) MarshalJSON() ([]byte, error) {
-	return json.Marshal(map[string]string{
+	m := map[string]string{
 		"IP":     n.IP.String(),
 		"Subnet": n.Subnet.String(),
-	})
+	}
+
+	if n.IPv6Subnet != nil {
+		m["IPv6"] = n.IPv6.String()
+		m["IPv6Subnet"] = n.IPv6Subnet.String()
+	}
+
+	return json.Marshal(m)
 }
 
 func (n *Network) UnmarshalJSON(b []byte) error {
 	var u = struct {
 		IP     string
 		Subnet string
+
+		IPv6       string
+		IPv6Subnet string
 	}{}
 
 	if err := json.Unmarshal(b, &u); err != nil {
@@ -31,6 +47,15 @@ func (n *Network) UnmarshalJSON(b []byte) error {
 	var err error
 	n.IP = net.ParseIP(u.IP)
 	_, n.Subnet, err = net.ParseCIDR(u.Subnet)
+	if err != nil {
+		return err
+	}
+
+	if u.IPv6Subnet != "" {
+		n.IPv6 = net.ParseIP(u.IPv6)
+		_, n.IPv6Subnet, err = net.ParseCIDR(u.IPv6Subnet)
+	}
+
 	return err
 }
 
@@ -42,6 +67,13 @@ type Resources struct {
 	Ports      []uint32
 	ExternalIP net.IP
 
+	// AdditionalExternalIPs are the other IPs of the host which ports may be
+	// mapped on. They are configured for the host, so are not snapshotted.
+	AdditionalExternalIPs []net.IP
+
+	// DNS is only set for containers whose spec configures name resolution.
+	DNS *network.DNSConfig
+
 	portsLock *sync.Mutex
 }
 
@@ -71,3 +103,30 @@ func (r *Resources) AddPort(port uint32) {
 
 	r.Ports = append(r.Ports, port)
 }
+
+// PortsCopy returns a copy of the ports, which is safe to read while ports
+// are being added or removed.
+func (r *Resources) PortsCopy() []uint32 {
+	r.portsLock.Lock()
+	defer r.portsLock.Unlock()
+
+	ports := make([]uint32, len(r.Ports))
+	copy(ports, r.Ports)
+
+	return ports
+}
+
+// RemovePort removes the port from the resources, and reports whether it was one of them.
+func (r *Resources) RemovePort(port uint32) bool {
+	r.portsLock.Lock()
+	defer r.portsLock.Unlock()
+
+	for i, p := range r.Ports {
+		if p == port {
+			r.Ports = append(r.Ports[:i], r.Ports[i+1:]...)
+			return true
+		}
+	}
+
+	return false
+}
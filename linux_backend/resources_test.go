@@ -0,0 +1,91 @@
+package linux_backend_test
+
+import (
+	"encoding/json"
+	"net"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudfoundry-incubator/garden-linux/linux_backend"
+)
+
+var _ = Describe("Network", func() {
+	var network *linux_backend.Network
+
+	BeforeEach(func() {
+		network = &linux_backend.Network{}
+		network.IP, network.Subnet, _ = net.ParseCIDR("10.2.0.1/30")
+	})
+
+	roundTrip := func() *linux_backend.Network {
+		encoded, err := json.Marshal(network)
+		Expect(err).ToNot(HaveOccurred())
+
+		decoded := &linux_backend.Network{}
+		Expect(json.Unmarshal(encoded, decoded)).To(Succeed())
+
+		return decoded
+	}
+
+	It("survives a round trip through JSON", func() {
+		decoded := roundTrip()
+
+		Expect(decoded.IP.String()).To(Equal("10.2.0.1"))
+		Expect(decoded.Subnet.String()).To(Equal("10.2.0.0/30"))
+		Expect(decoded.IPv6Subnet).To(BeNil())
+	})
+
+	It("does not encode IPv6 fields for a network which is not dual-stack", func() {
+		encoded, err := json.Marshal(network)
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(string(encoded)).ToNot(ContainSubstring("IPv6"))
+	})
+
+	Context("when the network is dual-stack", func() {
+		BeforeEach(func() {
+			network.IPv6, network.IPv6Subnet, _ = net.ParseCIDR("fd00::1/126")
+		})
+
+		It("survives a round trip through JSON", func() {
+			decoded := roundTrip()
+
+			Expect(decoded.IP.String()).To(Equal("10.2.0.1"))
+			Expect(decoded.IPv6.String()).To(Equal("fd00::1"))
+			Expect(decoded.IPv6Subnet.String()).To(Equal("fd00::/126"))
+		})
+	})
+})
+
+var _ = Describe("Resources", func() {
+	Describe("PortsCopy", func() {
+		It("returns the ports, without sharing them", func() {
+			resources := linux_backend.NewResources(0, 0, nil, "", []uint32{1, 2, 3}, nil)
+
+			ports := resources.PortsCopy()
+			Expect(ports).To(Equal([]uint32{1, 2, 3}))
+
+			Expect(resources.RemovePort(1)).To(BeTrue())
+			Expect(ports).To(Equal([]uint32{1, 2, 3}))
+		})
+	})
+
+	Describe("RemovePort", func() {
+		var resources *linux_backend.Resources
+
+		BeforeEach(func() {
+			resources = linux_backend.NewResources(0, 0, nil, "", []uint32{1, 2, 3}, nil)
+		})
+
+		It("removes the port and reports that it was there", func() {
+			Expect(resources.RemovePort(2)).To(BeTrue())
+			Expect(resources.Ports).To(Equal([]uint32{1, 3}))
+		})
+
+		It("reports a port which was not there", func() {
+			Expect(resources.RemovePort(4)).To(BeFalse())
+			Expect(resources.Ports).To(Equal([]uint32{1, 2, 3}))
+		})
+	})
+})
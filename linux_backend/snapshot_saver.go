@@ -0,0 +1,105 @@
+package linux_backend
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+)
+
+// SnapshotSaver atomically rewrites container snapshots in a directory. Each
+// snapshot is written to a temporary file which is then renamed into place,
+// so that dying part-way through never leaves a torn snapshot behind.
+type SnapshotSaver struct {
+	snapshotsPath string
+}
+
+func NewSnapshotSaver(snapshotsPath string) *SnapshotSaver {
+	return &SnapshotSaver{
+		snapshotsPath: snapshotsPath,
+	}
+}
+
+func (s *SnapshotSaver) Save(container Container) error {
+	if s.snapshotsPath == "" {
+		return nil
+	}
+
+	tmp, err := ioutil.TempFile(s.snapshotsPath, "."+container.ID()+"-")
+	if err != nil {
+		return &FailedToSnapshotError{err}
+	}
+
+	err = container.Snapshot(tmp)
+	if err == nil {
+		err = tmp.Sync()
+	}
+
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err == nil {
+		err = os.Rename(tmp.Name(), path.Join(s.snapshotsPath, container.ID()))
+	}
+
+	if err != nil {
+		os.Remove(tmp.Name())
+		return &FailedToSnapshotError{err}
+	}
+
+	return nil
+}
+
+func (s *SnapshotSaver) Remove(container Container) error {
+	if s.snapshotsPath == "" {
+		return nil
+	}
+
+	err := os.Remove(path.Join(s.snapshotsPath, container.ID()))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
+
+// SnapshotSavers saves each snapshot with every one of the savers in turn,
+// e.g. to both the snapshots directory and the container journal, returning
+// the first error.
+type SnapshotSavers []interface {
+	Save(Container) error
+}
+
+func (savers SnapshotSavers) Save(container Container) error {
+	var firstErr error
+	for _, saver := range savers {
+		if err := saver.Save(container); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	return firstErr
+}
+
+// A SnapshotHeader holds the fields of a container snapshot which identify the
+// container and tell how recent the snapshot is, so that snapshots can be
+// compared without restoring them.
+type SnapshotHeader struct {
+	ID     string
+	Handle string
+	Epoch  uint64
+}
+
+func ReadSnapshotHeader(snapshot []byte) (SnapshotHeader, error) {
+	var header SnapshotHeader
+	err := json.Unmarshal(snapshot, &header)
+	return header, err
+}
+
+// isTemporarySnapshot reports whether a snapshot file is one left behind by
+// a Save that did not complete.
+func isTemporarySnapshot(name string) bool {
+	return strings.HasPrefix(name, ".")
+}
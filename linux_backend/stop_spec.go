@@ -0,0 +1,39 @@
+package linux_backend
+
+import (
+	"time"
+
+	"github.com/cloudfoundry-incubator/garden"
+)
+
+// StopSpec describes how to gracefully stop the processes in a container.
+type StopSpec struct {
+	// Signal is sent to every process first.
+	Signal garden.Signal
+
+	// GracePeriod is how long the processes have to exit after Signal.
+	GracePeriod time.Duration
+
+	// EscalationSignal is sent to the processes still running once the
+	// grace period is over. It defaults to garden.SignalKill.
+	EscalationSignal *garden.Signal
+
+	// EscalationTimeout is how long the escalated processes have to exit
+	// before they are given up on. It defaults to DefaultEscalationTimeout.
+	EscalationTimeout time.Duration
+}
+
+const DefaultEscalationTimeout = 10 * time.Second
+
+// ProcessStopResult reports how a process in the container stopped.
+type ProcessStopResult struct {
+	ProcessID  uint32
+	ExitStatus int
+
+	// Escalated is true if the process outlived the grace period and had to
+	// be sent the escalation signal.
+	Escalated bool
+
+	// Err is set if the process could not be signalled or waited for.
+	Err error
+}
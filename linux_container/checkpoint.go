@@ -0,0 +1,126 @@
+package linux_container
+
+import (
+	"os"
+	"os/exec"
+	"path"
+	"strconv"
+
+	"github.com/cloudfoundry-incubator/garden-linux/old/logging"
+	"github.com/pivotal-golang/lager"
+)
+
+// flags shared by criu dump and criu restore; the container's processes hold
+// open sockets and file locks, and live in their own cgroups
+var criuFlags = []string{
+	"--manage-cgroups",
+	"--tcp-established",
+	"--ext-unix-sk",
+	"--file-locks",
+}
+
+// Checkpoint dumps every process in the container, wshd included, into dir
+// using CRIU. The processes exit once dumped and the container is left
+// checkpointed; they are brought back by RestoreCheckpoint when the
+// container is next restored from its snapshot.
+func (c *LinuxContainer) Checkpoint(dir string) error {
+	cLog := c.logger.Session("checkpoint", lager.Data{
+		"dir": dir,
+	})
+
+	cLog.Debug("checkpointing")
+
+	pid, err := c.wshdPid()
+	if err != nil {
+		cLog.Error("failed-to-read-wshd-pid", err)
+		return err
+	}
+
+	err = os.MkdirAll(dir, 0700)
+	if err != nil {
+		cLog.Error("failed-to-create-images-dir", err)
+		return err
+	}
+
+	cRunner := logging.Runner{
+		CommandRunner: c.runner,
+		Logger:        cLog,
+	}
+
+	dump := exec.Command("criu", append([]string{
+		"dump",
+		"--tree", strconv.Itoa(pid),
+		"--images-dir", dir,
+	}, criuFlags...)...)
+
+	err = cRunner.Run(dump)
+	if err != nil {
+		cLog.Error("failed-to-dump", err)
+		return err
+	}
+
+	c.checkpointMutex.Lock()
+	c.checkpoint = dir
+	c.checkpointMutex.Unlock()
+
+	c.setState(StateCheckpointed)
+
+	c.saveSnapshot()
+
+	cLog.Info("checkpointed")
+
+	return nil
+}
+
+// RestoreCheckpoint brings the container's processes back from the
+// checkpoint it was restored with, if any, and makes the container active
+// again.
+func (c *LinuxContainer) RestoreCheckpoint() error {
+	dir := c.checkpointDir()
+	if dir == "" {
+		return nil
+	}
+
+	cLog := c.logger.Session("restore-checkpoint", lager.Data{
+		"dir": dir,
+	})
+
+	cLog.Debug("restoring")
+
+	cRunner := logging.Runner{
+		CommandRunner: c.runner,
+		Logger:        cLog,
+	}
+
+	restore := exec.Command("criu", append([]string{
+		"restore",
+		"--images-dir", dir,
+		"--restore-detached",
+		"--pidfile", path.Join(c.path, "run", "wshd.pid"),
+	}, criuFlags...)...)
+
+	err := cRunner.Run(restore)
+	if err != nil {
+		cLog.Error("failed-to-restore", err)
+		return err
+	}
+
+	c.checkpointMutex.Lock()
+	c.checkpoint = ""
+	c.checkpointMutex.Unlock()
+
+	c.setState(StateActive)
+
+	c.saveSnapshot()
+
+	cLog.Info("restored")
+
+	return nil
+}
+
+func (c *LinuxContainer) checkpointDir() string {
+	c.checkpointMutex.RLock()
+	defer c.checkpointMutex.RUnlock()
+
+	return c.checkpoint
+}
@@ -0,0 +1,86 @@
+package linux_container
+
+import (
+	"fmt"
+	"time"
+)
+
+// An Event is something notable that happened to the container, such as it
+// running out of memory. Repeats of an event are folded into one, counting
+// the occurrences and keeping the time of the latest.
+type Event struct {
+	Type      string
+	Timestamp time.Time
+	Details   string `json:",omitempty"`
+	Count     int
+}
+
+// String renders the event the way events were reported before they were
+// structured.
+func (e Event) String() string {
+	if e.Details == "" {
+		return e.Type
+	}
+
+	return fmt.Sprintf("%s: %s", e.Type, e.Details)
+}
+
+// Events returns the container's events in their legacy string form, as
+// reported through Info(). Each occurrence of an event is listed, as it was
+// before events were folded together, though repeats are now listed next to
+// the first occurrence rather than where they happened.
+func (c *LinuxContainer) Events() []string {
+	c.eventsMutex.RLock()
+	defer c.eventsMutex.RUnlock()
+
+	events := []string{}
+
+	for _, event := range c.events {
+		for i := 0; i < event.Count; i++ {
+			events = append(events, event.String())
+		}
+	}
+
+	return events
+}
+
+// EventLog returns the container's events, oldest first.
+func (c *LinuxContainer) EventLog() []Event {
+	c.eventsMutex.RLock()
+	defer c.eventsMutex.RUnlock()
+
+	events := make([]Event, len(c.events))
+
+	copy(events, c.events)
+
+	return events
+}
+
+func (c *LinuxContainer) registerEvent(eventType, details string) {
+	c.eventsMutex.Lock()
+	defer c.eventsMutex.Unlock()
+
+	now := time.Now()
+
+	for i, event := range c.events {
+		if event.Type == eventType && event.Details == details {
+			c.events[i].Count++
+			c.events[i].Timestamp = now
+			return
+		}
+	}
+
+	c.events = append(c.events, Event{
+		Type:      eventType,
+		Timestamp: now,
+		Details:   details,
+		Count:     1,
+	})
+}
+
+func (c *LinuxContainer) restoreEvents(events []Event) {
+	c.eventsMutex.Lock()
+	defer c.eventsMutex.Unlock()
+
+	c.events = append(c.events, events...)
+}
@@ -0,0 +1,54 @@
+// This file was generated by counterfeiter
+package fakes
+
+import (
+	"sync"
+
+	"github.com/cloudfoundry-incubator/garden-linux/linux_backend"
+	"github.com/cloudfoundry-incubator/garden-linux/linux_container"
+)
+
+type FakeSnapshotSaver struct {
+	SaveStub        func(arg1 linux_backend.Container) error
+	saveMutex       sync.RWMutex
+	saveArgsForCall []struct {
+		arg1 linux_backend.Container
+	}
+	saveReturns struct {
+		result1 error
+	}
+}
+
+func (fake *FakeSnapshotSaver) Save(arg1 linux_backend.Container) error {
+	fake.saveMutex.Lock()
+	fake.saveArgsForCall = append(fake.saveArgsForCall, struct {
+		arg1 linux_backend.Container
+	}{arg1})
+	fake.saveMutex.Unlock()
+	if fake.SaveStub != nil {
+		return fake.SaveStub(arg1)
+	} else {
+		return fake.saveReturns.result1
+	}
+}
+
+func (fake *FakeSnapshotSaver) SaveCallCount() int {
+	fake.saveMutex.RLock()
+	defer fake.saveMutex.RUnlock()
+	return len(fake.saveArgsForCall)
+}
+
+func (fake *FakeSnapshotSaver) SaveArgsForCall(i int) linux_backend.Container {
+	fake.saveMutex.RLock()
+	defer fake.saveMutex.RUnlock()
+	return fake.saveArgsForCall[i].arg1
+}
+
+func (fake *FakeSnapshotSaver) SaveReturns(result1 error) {
+	fake.SaveStub = nil
+	fake.saveReturns = struct {
+		result1 error
+	}{result1}
+}
+
+var _ linux_container.SnapshotSaver = new(FakeSnapshotSaver)
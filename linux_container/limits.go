@@ -7,9 +7,27 @@ import (
 	"strconv"
 
 	"github.com/cloudfoundry-incubator/garden"
+	"github.com/cloudfoundry-incubator/garden-linux/linux_backend"
+	"github.com/cloudfoundry-incubator/garden-linux/network/tc"
+	"github.com/cloudfoundry-incubator/garden-linux/old/bandwidth_manager"
 )
 
 func (c *LinuxContainer) LimitBandwidth(limits garden.BandwidthLimits) error {
+	err := c.limitBandwidth(limits)
+	if err != nil {
+		return err
+	}
+
+	c.saveSnapshot()
+
+	c.emit(linux_backend.EventLimitChanged, map[string]string{
+		"limit": "bandwidth",
+	})
+
+	return nil
+}
+
+func (c *LinuxContainer) limitBandwidth(limits garden.BandwidthLimits) error {
 	cLog := c.logger.Session("limit-bandwidth")
 
 	err := c.bandwidthManager.SetLimits(cLog, limits)
@@ -21,10 +39,14 @@ func (c *LinuxContainer) LimitBandwidth(limits garden.BandwidthLimits) error {
 	defer c.bandwidthMutex.Unlock()
 
 	c.currentBandwidthLimits = &limits
+	c.currentDirectionalBandwidthLimits = nil
 
 	return nil
 }
 
+// CurrentBandwidthLimits returns the limits set by LimitBandwidth. Directional
+// limits cannot be expressed as garden.BandwidthLimits, so while they are set
+// the zero value is returned.
 func (c *LinuxContainer) CurrentBandwidthLimits() (garden.BandwidthLimits, error) {
 	c.bandwidthMutex.RLock()
 	defer c.bandwidthMutex.RUnlock()
@@ -36,7 +58,79 @@ func (c *LinuxContainer) CurrentBandwidthLimits() (garden.BandwidthLimits, error
 	return *c.currentBandwidthLimits, nil
 }
 
+// LimitDirectionalBandwidth limits the traffic into the container and the
+// traffic out of it independently, replacing the limits of LimitBandwidth.
+func (c *LinuxContainer) LimitDirectionalBandwidth(limits bandwidth_manager.Limits) error {
+	err := c.limitDirectionalBandwidth(limits)
+	if err != nil {
+		return err
+	}
+
+	c.saveSnapshot()
+
+	c.emit(linux_backend.EventLimitChanged, map[string]string{
+		"limit": "directional-bandwidth",
+	})
+
+	return nil
+}
+
+func (c *LinuxContainer) limitDirectionalBandwidth(limits bandwidth_manager.Limits) error {
+	cLog := c.logger.Session("limit-directional-bandwidth")
+
+	err := c.bandwidthManager.SetDirectionalLimits(cLog, limits)
+	if err != nil {
+		return err
+	}
+
+	c.bandwidthMutex.Lock()
+	defer c.bandwidthMutex.Unlock()
+
+	c.currentDirectionalBandwidthLimits = &limits
+	c.currentBandwidthLimits = nil
+
+	return nil
+}
+
+// CurrentDirectionalBandwidthLimits returns the limits of the traffic into
+// the container and out of it, whichever of LimitBandwidth and
+// LimitDirectionalBandwidth set them.
+func (c *LinuxContainer) CurrentDirectionalBandwidthLimits() (bandwidth_manager.Limits, error) {
+	c.bandwidthMutex.RLock()
+	defer c.bandwidthMutex.RUnlock()
+
+	if c.currentDirectionalBandwidthLimits != nil {
+		return *c.currentDirectionalBandwidthLimits, nil
+	}
+
+	if c.currentBandwidthLimits != nil {
+		bucket := tc.TokenBucket{
+			Rate:  c.currentBandwidthLimits.RateInBytesPerSecond,
+			Burst: c.currentBandwidthLimits.BurstRateInBytesPerSecond,
+		}
+
+		return bandwidth_manager.Limits{Ingress: bucket, Egress: bucket}, nil
+	}
+
+	return bandwidth_manager.Limits{}, nil
+}
+
 func (c *LinuxContainer) LimitDisk(limits garden.DiskLimits) error {
+	err := c.limitDisk(limits)
+	if err != nil {
+		return err
+	}
+
+	c.saveSnapshot()
+
+	c.emit(linux_backend.EventLimitChanged, map[string]string{
+		"limit": "disk",
+	})
+
+	return nil
+}
+
+func (c *LinuxContainer) limitDisk(limits garden.DiskLimits) error {
 	cLog := c.logger.Session("limit-disk")
 
 	err := c.quotaManager.SetLimits(cLog, c.resources.UserUID, limits)
@@ -58,6 +152,21 @@ func (c *LinuxContainer) CurrentDiskLimits() (garden.DiskLimits, error) {
 }
 
 func (c *LinuxContainer) LimitMemory(limits garden.MemoryLimits) error {
+	err := c.limitMemory(limits)
+	if err != nil {
+		return err
+	}
+
+	c.saveSnapshot()
+
+	c.emit(linux_backend.EventLimitChanged, map[string]string{
+		"limit": "memory",
+	})
+
+	return nil
+}
+
+func (c *LinuxContainer) limitMemory(limits garden.MemoryLimits) error {
 	err := c.startOomNotifier()
 	if err != nil {
 		return err
@@ -102,6 +211,21 @@ func (c *LinuxContainer) CurrentMemoryLimits() (garden.MemoryLimits, error) {
 }
 
 func (c *LinuxContainer) LimitCPU(limits garden.CPULimits) error {
+	err := c.limitCPU(limits)
+	if err != nil {
+		return err
+	}
+
+	c.saveSnapshot()
+
+	c.emit(linux_backend.EventLimitChanged, map[string]string{
+		"limit": "cpu",
+	})
+
+	return nil
+}
+
+func (c *LinuxContainer) limitCPU(limits garden.CPULimits) error {
 	limit := fmt.Sprintf("%d", limits.LimitInShares)
 
 	err := c.cgroupsManager.Set("cpu", "cpu.shares", limit)
@@ -165,7 +289,8 @@ func (c *LinuxContainer) stopOomNotifier() {
 func (c *LinuxContainer) watchForOom(oom *exec.Cmd) {
 	err := c.runner.Wait(oom)
 	if err == nil {
-		c.registerEvent("out of memory")
+		c.registerEvent("out of memory", "")
+		c.emit(linux_backend.EventOOM, nil)
 		c.Stop(false)
 	}
 
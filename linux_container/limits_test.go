@@ -14,8 +14,13 @@ import (
 
 	"github.com/cloudfoundry-incubator/garden"
 	"github.com/cloudfoundry-incubator/garden-linux/linux_backend"
+	backendFakes "github.com/cloudfoundry-incubator/garden-linux/linux_backend/fakes"
 	"github.com/cloudfoundry-incubator/garden-linux/linux_container"
+	containerFakes "github.com/cloudfoundry-incubator/garden-linux/linux_container/fakes"
 	networkFakes "github.com/cloudfoundry-incubator/garden-linux/network/fakes"
+	iptablesFakes "github.com/cloudfoundry-incubator/garden-linux/network/iptables/fakes"
+	"github.com/cloudfoundry-incubator/garden-linux/network/tc"
+	"github.com/cloudfoundry-incubator/garden-linux/old/bandwidth_manager"
 	"github.com/cloudfoundry-incubator/garden-linux/old/bandwidth_manager/fake_bandwidth_manager"
 	"github.com/cloudfoundry-incubator/garden-linux/old/cgroups_manager/fake_cgroups_manager"
 	"github.com/cloudfoundry-incubator/garden-linux/old/port_pool/fake_port_pool"
@@ -78,6 +83,10 @@ var _ = Describe("Linux containers", func() {
 			new(fake_process_tracker.FakeProcessTracker),
 			process.Env{"env1": "env1Value", "env2": "env2Value"},
 			new(networkFakes.FakeFilter),
+			new(iptablesFakes.FakeInstanceChain),
+			new(networkFakes.FakeInterfaceStatter),
+			new(containerFakes.FakeSnapshotSaver),
+			new(backendFakes.FakeEventEmitter),
 		)
 	})
 
@@ -149,6 +158,74 @@ var _ = Describe("Linux containers", func() {
 		})
 	})
 
+	Describe("Limiting bandwidth in each direction", func() {
+		limits := bandwidth_manager.Limits{
+			Ingress: tc.TokenBucket{Rate: 128, Burst: 256},
+			Egress:  tc.TokenBucket{Rate: 512, Burst: 1024},
+		}
+
+		It("sets the limits via the bandwidth manager", func() {
+			Expect(container.LimitDirectionalBandwidth(limits)).To(Succeed())
+
+			Expect(fakeBandwidthManager.EnforcedDirectionalLimits).To(ContainElement(limits))
+		})
+
+		It("reports them as the current directional limits", func() {
+			Expect(container.LimitDirectionalBandwidth(limits)).To(Succeed())
+
+			current, err := container.CurrentDirectionalBandwidthLimits()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(current).To(Equal(limits))
+		})
+
+		It("replaces the limits set by LimitBandwidth", func() {
+			Expect(container.LimitBandwidth(garden.BandwidthLimits{
+				RateInBytesPerSecond:      1,
+				BurstRateInBytesPerSecond: 2,
+			})).To(Succeed())
+
+			Expect(container.LimitDirectionalBandwidth(limits)).To(Succeed())
+
+			current, err := container.CurrentBandwidthLimits()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(current).To(BeZero())
+		})
+
+		Context("when limited by LimitBandwidth", func() {
+			It("reports the same limits in each direction", func() {
+				Expect(container.LimitDirectionalBandwidth(limits)).To(Succeed())
+
+				Expect(container.LimitBandwidth(garden.BandwidthLimits{
+					RateInBytesPerSecond:      1,
+					BurstRateInBytesPerSecond: 2,
+				})).To(Succeed())
+
+				current, err := container.CurrentDirectionalBandwidthLimits()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(current).To(Equal(bandwidth_manager.Limits{
+					Ingress: tc.TokenBucket{Rate: 1, Burst: 2},
+					Egress:  tc.TokenBucket{Rate: 1, Burst: 2},
+				}))
+			})
+		})
+
+		Context("when setting the limits fails", func() {
+			disaster := errors.New("oh no!")
+
+			BeforeEach(func() {
+				fakeBandwidthManager.SetDirectionalLimitsError = disaster
+			})
+
+			It("returns the error, and does not update the current limits", func() {
+				Expect(container.LimitDirectionalBandwidth(limits)).To(Equal(disaster))
+
+				current, err := container.CurrentDirectionalBandwidthLimits()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(current).To(BeZero())
+			})
+		})
+	})
+
 	Describe("Limiting memory", func() {
 		It("starts the oom notifier", func() {
 			limits := garden.MemoryLimits{
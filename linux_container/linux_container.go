@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"net"
 	"os"
 	"os/exec"
 	"path"
@@ -11,11 +12,13 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/cloudfoundry-incubator/garden"
 	"github.com/cloudfoundry-incubator/garden-linux/linux_backend"
 	"github.com/cloudfoundry-incubator/garden-linux/network"
+	"github.com/cloudfoundry-incubator/garden-linux/network/iptables"
 	"github.com/cloudfoundry-incubator/garden-linux/network/subnets"
 	"github.com/cloudfoundry-incubator/garden-linux/old/bandwidth_manager"
 	"github.com/cloudfoundry-incubator/garden-linux/old/cgroups_manager"
@@ -35,6 +38,65 @@ func (err UndefinedPropertyError) Error() string {
 	return fmt.Sprintf("property does not exist: %s", err.Key)
 }
 
+type NetInNotFoundError struct {
+	HostPort uint32
+}
+
+func (err NetInNotFoundError) Error() string {
+	return fmt.Sprintf("host port is not mapped: %d", err.HostPort)
+}
+
+type NetOutNotFoundError struct {
+	Rule garden.NetOutRule
+}
+
+func (err NetOutNotFoundError) Error() string {
+	return fmt.Sprintf("net out rule is not granted: %+v", err.Rule)
+}
+
+type UnsupportedNetInProtocolError struct {
+	Protocol garden.Protocol
+}
+
+func (err UnsupportedNetInProtocolError) Error() string {
+	return fmt.Sprintf("protocol cannot be mapped: %d", err.Protocol)
+}
+
+type InvalidStateError struct {
+	Op    string
+	State State
+}
+
+func (err InvalidStateError) Error() string {
+	return fmt.Sprintf("cannot %s a container which is %s", err.Op, err.State)
+}
+
+// NoFreezerCgroupError is returned by Pause for containers created before
+// containers were given a freezer cgroup.
+type NoFreezerCgroupError struct {
+	Handle string
+}
+
+func (err NoFreezerCgroupError) Error() string {
+	return fmt.Sprintf("container %s has no freezer cgroup, so cannot be paused; it was created before pausing was supported", err.Handle)
+}
+
+type ProcessesNotStoppedError struct {
+	ProcessIDs []uint32
+}
+
+func (err ProcessesNotStoppedError) Error() string {
+	return fmt.Sprintf("processes did not exit after escalation: %v", err.ProcessIDs)
+}
+
+type UnknownExternalIPError struct {
+	IP net.IP
+}
+
+func (err UnknownExternalIPError) Error() string {
+	return fmt.Sprintf("ports cannot be mapped on IP: %s", err.IP)
+}
+
 type LinuxContainer struct {
 	logger lager.Logger
 
@@ -50,7 +112,7 @@ type LinuxContainer struct {
 	state      State
 	stateMutex sync.RWMutex
 
-	events      []string
+	events      []Event
 	eventsMutex sync.RWMutex
 
 	resources *linux_backend.Resources
@@ -65,13 +127,17 @@ type LinuxContainer struct {
 
 	processTracker process_tracker.ProcessTracker
 
-	filter network.Filter
+	filter        network.Filter
+	instanceChain iptables.InstanceChain
+
+	interfaceStatter network.InterfaceStatter
 
 	oomMutex    sync.RWMutex
 	oomNotifier *exec.Cmd
 
-	currentBandwidthLimits *garden.BandwidthLimits
-	bandwidthMutex         sync.RWMutex
+	currentBandwidthLimits            *garden.BandwidthLimits
+	currentDirectionalBandwidthLimits *bandwidth_manager.Limits
+	bandwidthMutex                    sync.RWMutex
 
 	currentDiskLimits *garden.DiskLimits
 	diskMutex         sync.RWMutex
@@ -88,11 +154,24 @@ type LinuxContainer struct {
 	netOuts      []garden.NetOutRule
 	netOutsMutex sync.RWMutex
 
+	trafficPolicies      []trafficPolicy
+	trafficPoliciesMutex sync.RWMutex
+
 	mtu uint32
 
 	env process.Env
 
 	processIDPool *ProcessIDPool
+
+	snapshotSaver SnapshotSaver
+	snapshotMutex sync.Mutex
+
+	eventEmitter linux_backend.EventEmitter
+
+	epoch uint64
+
+	checkpoint      string
+	checkpointMutex sync.RWMutex
 }
 
 type ProcessIDPool struct {
@@ -120,6 +199,18 @@ func (p *ProcessIDPool) Restore(id uint32) {
 type NetInSpec struct {
 	HostPort      uint32
 	ContainerPort uint32
+	Protocol      garden.Protocol
+
+	// ExternalIP is only set for ports mapped on one of the additional
+	// external IPs of the host.
+	ExternalIP net.IP `json:",omitempty"`
+}
+
+// netInProtocols names the protocols a port can be mapped for.
+var netInProtocols = map[garden.Protocol]string{
+	garden.ProtocolTCP: "tcp",
+	garden.ProtocolUDP: "udp",
+	garden.ProtocolAll: "all",
 }
 
 type PortPool interface {
@@ -128,12 +219,33 @@ type PortPool interface {
 	Release(uint32)
 }
 
+//go:generate counterfeiter -o fakes/fake_snapshot_saver.go . SnapshotSaver
+type SnapshotSaver interface {
+	Save(linux_backend.Container) error
+}
+
+// Info reports the IPv6 addresses of dual-stack containers, the protocols of
+// mapped ports, and the structured events, as properties, as
+// garden.ContainerInfo has no fields for them. MappedPortsProperty lists
+// mappings as host:container/protocol, separated by commas, e.g.
+// "61001:8080/tcp,61002:53/udp". Mappings on an additional external IP are
+// prefixed with it, e.g. "10.0.0.5:61003:80/tcp". EventsProperty is the JSON
+// encoding of the EventLog.
+const (
+	ContainerIPv6Property = "garden.network.container-ipv6"
+	HostIPv6Property      = "garden.network.host-ipv6"
+	MappedPortsProperty   = "garden.network.mapped-ports"
+	EventsProperty        = "garden.events"
+)
+
 type State string
 
 const (
-	StateBorn    = State("born")
-	StateActive  = State("active")
-	StateStopped = State("stopped")
+	StateBorn         = State("born")
+	StateActive       = State("active")
+	StateStopped      = State("stopped")
+	StatePaused       = State("paused")
+	StateCheckpointed = State("checkpointed")
 )
 
 func NewLinuxContainer(
@@ -150,6 +262,10 @@ func NewLinuxContainer(
 	processTracker process_tracker.ProcessTracker,
 	env process.Env,
 	filter network.Filter,
+	instanceChain iptables.InstanceChain,
+	interfaceStatter network.InterfaceStatter,
+	snapshotSaver SnapshotSaver,
+	events linux_backend.EventEmitter,
 ) *LinuxContainer {
 	return &LinuxContainer{
 		logger: logger,
@@ -163,7 +279,7 @@ func NewLinuxContainer(
 		graceTime: graceTime,
 
 		state:  StateBorn,
-		events: []string{},
+		events: []Event{},
 
 		resources: resources,
 
@@ -177,10 +293,17 @@ func NewLinuxContainer(
 
 		processTracker: processTracker,
 
-		filter: filter,
+		filter:        filter,
+		instanceChain: instanceChain,
+
+		interfaceStatter: interfaceStatter,
 
 		env:           env,
 		processIDPool: &ProcessIDPool{},
+
+		snapshotSaver: snapshotSaver,
+
+		eventEmitter: events,
 	}
 }
 
@@ -203,17 +326,6 @@ func (c *LinuxContainer) State() State {
 	return c.state
 }
 
-func (c *LinuxContainer) Events() []string {
-	c.eventsMutex.RLock()
-	defer c.eventsMutex.RUnlock()
-
-	events := make([]string, len(c.events))
-
-	copy(events, c.events)
-
-	return events
-}
-
 func (c *LinuxContainer) Resources() *linux_backend.Resources {
 	return c.resources
 }
@@ -241,6 +353,9 @@ func (c *LinuxContainer) Snapshot(out io.Writer) error {
 	c.netOutsMutex.RLock()
 	defer c.netOutsMutex.RUnlock()
 
+	c.trafficPoliciesMutex.RLock()
+	defer c.trafficPoliciesMutex.RUnlock()
+
 	processSnapshots := []ProcessSnapshot{}
 
 	for _, p := range c.processTracker.ActiveProcesses() {
@@ -255,19 +370,24 @@ func (c *LinuxContainer) Snapshot(out io.Writer) error {
 	properties, _ := c.Properties()
 
 	snapshot := ContainerSnapshot{
+		Version: CurrentSnapshotVersion,
+
 		ID:     c.id,
 		Handle: c.handle,
 
+		Epoch: atomic.AddUint64(&c.epoch, 1),
+
 		GraceTime: c.graceTime,
 
 		State:  string(c.State()),
-		Events: c.Events(),
+		Events: c.EventLog(),
 
 		Limits: LimitsSnapshot{
-			Bandwidth: c.currentBandwidthLimits,
-			CPU:       c.currentCPULimits,
-			Disk:      c.currentDiskLimits,
-			Memory:    c.currentMemoryLimits,
+			Bandwidth:            c.currentBandwidthLimits,
+			DirectionalBandwidth: c.currentDirectionalBandwidthLimits,
+			CPU:                  c.currentCPULimits,
+			Disk:                 c.currentDiskLimits,
+			Memory:               c.currentMemoryLimits,
 		},
 
 		Resources: ResourcesSnapshot{
@@ -275,17 +395,22 @@ func (c *LinuxContainer) Snapshot(out io.Writer) error {
 			RootUID: c.resources.RootUID,
 			Network: c.resources.Network,
 			Bridge:  c.resources.Bridge,
-			Ports:   c.resources.Ports,
+			Ports:   c.resources.PortsCopy(),
+			DNS:     c.resources.DNS,
 		},
 
 		NetIns:  c.netIns,
 		NetOuts: c.netOuts,
 
+		TrafficPolicies: c.trafficPolicySpecs(),
+
 		Processes: processSnapshots,
 
 		Properties: properties,
 
 		EnvVars: c.env.Array(),
+
+		Checkpoint: c.checkpointDir(),
 	}
 
 	var err error
@@ -310,10 +435,7 @@ func (c *LinuxContainer) Restore(snapshot ContainerSnapshot) error {
 
 	cLog.Debug("restoring")
 
-	cRunner := logging.Runner{
-		CommandRunner: c.runner,
-		Logger:        cLog,
-	}
+	atomic.StoreUint64(&c.epoch, snapshot.Epoch)
 
 	c.setState(State(snapshot.State))
 
@@ -326,18 +448,63 @@ func (c *LinuxContainer) Restore(snapshot ContainerSnapshot) error {
 	}
 	c.env = snapshotEnv
 
-	for _, ev := range snapshot.Events {
-		c.registerEvent(ev)
+	c.restoreEvents(snapshot.Events)
+
+	c.checkpointMutex.Lock()
+	c.checkpoint = snapshot.Checkpoint
+	c.checkpointMutex.Unlock()
+
+	if c.State() == StatePaused {
+		// the freezer cgroup outlives the server, but make sure it agrees
+		err := c.cgroupsManager.Set("freezer", "freezer.state", "FROZEN")
+		if err != nil {
+			cLog.Error("failed-to-freeze", err)
+			return err
+		}
 	}
 
 	if snapshot.Limits.Memory != nil {
-		err := c.LimitMemory(*snapshot.Limits.Memory)
+		err := c.limitMemory(*snapshot.Limits.Memory)
 		if err != nil {
 			cLog.Error("failed-to-limit-memory", err)
 			return err
 		}
 	}
 
+	// the remaining limits are not worth failing the whole restore over, as
+	// the container can still run without them; surface them instead
+	if snapshot.Limits.CPU != nil {
+		err := c.limitCPU(*snapshot.Limits.CPU)
+		if err != nil {
+			cLog.Error("failed-to-limit-cpu", err)
+			c.registerEvent("failed to restore cpu limits", err.Error())
+		}
+	}
+
+	if snapshot.Limits.Bandwidth != nil {
+		err := c.limitBandwidth(*snapshot.Limits.Bandwidth)
+		if err != nil {
+			cLog.Error("failed-to-limit-bandwidth", err)
+			c.registerEvent("failed to restore bandwidth limits", err.Error())
+		}
+	}
+
+	if snapshot.Limits.DirectionalBandwidth != nil {
+		err := c.limitDirectionalBandwidth(*snapshot.Limits.DirectionalBandwidth)
+		if err != nil {
+			cLog.Error("failed-to-limit-directional-bandwidth", err)
+			c.registerEvent("failed to restore directional bandwidth limits", err.Error())
+		}
+	}
+
+	if snapshot.Limits.Disk != nil {
+		err := c.limitDisk(*snapshot.Limits.Disk)
+		if err != nil {
+			cLog.Error("failed-to-limit-disk", err)
+			c.registerEvent("failed to restore disk limits", err.Error())
+		}
+	}
+
 	for _, process := range snapshot.Processes {
 		cLog.Info("restoring-process", lager.Data{
 			"process": process,
@@ -356,16 +523,14 @@ func (c *LinuxContainer) Restore(snapshot ContainerSnapshot) error {
 		c.processTracker.Restore(process.ID, signaller)
 	}
 
-	net := exec.Command(path.Join(c.path, "net.sh"), "setup")
-
-	err = cRunner.Run(net)
+	err = c.instanceChain.Setup(c.instanceNetwork())
 	if err != nil {
 		cLog.Error("failed-to-reenforce-network-rules", err)
 		return err
 	}
 
 	for _, in := range snapshot.NetIns {
-		_, _, err = c.NetIn(in.HostPort, in.ContainerPort)
+		_, _, err = c.netIn(in.ExternalIP, in.HostPort, in.ContainerPort, in.Protocol)
 		if err != nil {
 			cLog.Error("failed-to-reenforce-port-mapping", err)
 			return err
@@ -373,12 +538,20 @@ func (c *LinuxContainer) Restore(snapshot ContainerSnapshot) error {
 	}
 
 	for _, out := range snapshot.NetOuts {
-		if err := c.NetOut(out); err != nil {
+		if err := c.netOut(out); err != nil {
 			cLog.Error("failed-to-reenforce-net-out", err)
 			return err
 		}
 	}
 
+	// the IPs of the destinations may have changed, so the backend re-applies
+	// the rules once every container has been restored
+	c.trafficPoliciesMutex.Lock()
+	for _, policy := range snapshot.TrafficPolicies {
+		c.trafficPolicies = append(c.trafficPolicies, trafficPolicy{TrafficPolicy: policy})
+	}
+	c.trafficPoliciesMutex.Unlock()
+
 	cLog.Info("restored")
 
 	return nil
@@ -389,6 +562,12 @@ func (c *LinuxContainer) Start() error {
 
 	cLog.Debug("starting")
 
+	err := c.instanceChain.Setup(c.instanceNetwork())
+	if err != nil {
+		cLog.Error("failed-to-enforce-network-rules", err)
+		return fmt.Errorf("container: start: %v", err)
+	}
+
 	start := exec.Command(path.Join(c.path, "start.sh"))
 	start.Env = []string{
 		"id=" + c.id,
@@ -400,7 +579,7 @@ func (c *LinuxContainer) Start() error {
 		Logger:        cLog,
 	}
 
-	err := cRunner.Run(start)
+	err = cRunner.Run(start)
 	if err != nil {
 		cLog.Error("failed-to-start", err)
 		return fmt.Errorf("container: start: %v", err)
@@ -408,11 +587,33 @@ func (c *LinuxContainer) Start() error {
 
 	c.setState(StateActive)
 
+	c.saveSnapshot()
+
+	c.emit(linux_backend.EventStarted, nil)
+
 	cLog.Info("started")
 
 	return nil
 }
 
+func (c *LinuxContainer) instanceNetwork() iptables.InstanceNetwork {
+	network := iptables.InstanceNetwork{
+		BridgeInterface: c.resources.Bridge,
+		ContainerIP:     c.resources.Network.IP,
+		Subnet:          c.resources.Network.Subnet,
+		ExternalIP:      c.resources.ExternalIP,
+
+		AdditionalExternalIPs: c.resources.AdditionalExternalIPs,
+	}
+
+	if c.resources.Network.IPv6Subnet != nil {
+		network.ContainerIPv6 = c.resources.Network.IPv6
+		network.IPv6Subnet = c.resources.Network.IPv6Subnet
+	}
+
+	return network
+}
+
 func (c *LinuxContainer) Cleanup() {
 	cLog := c.logger.Session("cleanup")
 
@@ -423,6 +624,14 @@ func (c *LinuxContainer) Cleanup() {
 }
 
 func (c *LinuxContainer) Stop(kill bool) error {
+	if c.State() == StatePaused {
+		// frozen processes cannot handle the signals sent to stop them
+		err := c.thaw()
+		if err != nil {
+			return err
+		}
+	}
+
 	stop := exec.Command(path.Join(c.path, "stop.sh"))
 
 	if kill {
@@ -438,6 +647,81 @@ func (c *LinuxContainer) Stop(kill bool) error {
 
 	c.setState(StateStopped)
 
+	c.saveSnapshot()
+
+	c.emit(linux_backend.EventStopped, nil)
+
+	return nil
+}
+
+// Pause freezes every process in the container via the freezer cgroup,
+// without killing them. Only active containers can be paused.
+//
+// The state is held locked from checking it to changing it, so that
+// concurrent pauses and resumes cannot leave it out of step with the
+// freezer.
+func (c *LinuxContainer) Pause() error {
+	cLog := c.logger.Session("pause")
+
+	c.stateMutex.Lock()
+
+	if c.state != StateActive {
+		c.stateMutex.Unlock()
+		return InvalidStateError{Op: "pause", State: c.state}
+	}
+
+	err := c.cgroupsManager.Set("freezer", "freezer.state", "FROZEN")
+	if err != nil {
+		c.stateMutex.Unlock()
+		cLog.Error("failed-to-freeze", err)
+
+		if os.IsNotExist(err) {
+			return NoFreezerCgroupError{Handle: c.handle}
+		}
+
+		return err
+	}
+
+	c.state = StatePaused
+
+	c.stateMutex.Unlock()
+
+	c.saveSnapshot()
+
+	return nil
+}
+
+// Resume thaws a container frozen by Pause.
+func (c *LinuxContainer) Resume() error {
+	c.stateMutex.Lock()
+
+	if c.state != StatePaused {
+		c.stateMutex.Unlock()
+		return InvalidStateError{Op: "resume", State: c.state}
+	}
+
+	err := c.thaw()
+	if err != nil {
+		c.stateMutex.Unlock()
+		return err
+	}
+
+	c.state = StateActive
+
+	c.stateMutex.Unlock()
+
+	c.saveSnapshot()
+
+	return nil
+}
+
+func (c *LinuxContainer) thaw() error {
+	err := c.cgroupsManager.Set("freezer", "freezer.state", "THAWED")
+	if err != nil {
+		c.logger.Session("thaw").Error("failed-to-thaw", err)
+		return err
+	}
+
 	return nil
 }
 
@@ -462,7 +746,6 @@ func (c *LinuxContainer) Property(key string) (string, error) {
 
 func (c *LinuxContainer) SetProperty(key string, value string) error {
 	c.propertiesMutex.Lock()
-	defer c.propertiesMutex.Unlock()
 
 	props := garden.Properties{}
 	for k, v := range c.properties {
@@ -473,19 +756,27 @@ func (c *LinuxContainer) SetProperty(key string, value string) error {
 
 	c.properties = props
 
+	c.propertiesMutex.Unlock()
+
+	c.saveSnapshot()
+
 	return nil
 }
 
 func (c *LinuxContainer) RemoveProperty(key string) error {
 	c.propertiesMutex.Lock()
-	defer c.propertiesMutex.Unlock()
 
 	if _, found := c.properties[key]; !found {
+		c.propertiesMutex.Unlock()
 		return UndefinedPropertyError{key}
 	}
 
 	delete(c.properties, key)
 
+	c.propertiesMutex.Unlock()
+
+	c.saveSnapshot()
+
 	return nil
 }
 
@@ -503,25 +794,48 @@ func (c *LinuxContainer) HasProperties(properties garden.Properties) bool {
 }
 
 func (c *LinuxContainer) Info() (garden.ContainerInfo, error) {
-	mappedPorts := []garden.PortMapping{}
-
-	c.netInsMutex.RLock()
+	netIns := c.NetIns()
 
-	for _, spec := range c.netIns {
+	mappedPorts := []garden.PortMapping{}
+	mappedPortsProperty := []string{}
+	for _, in := range netIns {
 		mappedPorts = append(mappedPorts, garden.PortMapping{
-			HostPort:      spec.HostPort,
-			ContainerPort: spec.ContainerPort,
+			HostPort:      in.HostPort,
+			ContainerPort: in.ContainerPort,
 		})
-	}
 
-	c.netInsMutex.RUnlock()
+		mapping := fmt.Sprintf("%d:%d/%s", in.HostPort, in.ContainerPort, netInProtocols[in.Protocol])
+		if in.ExternalIP != nil {
+			mapping = in.ExternalIP.String() + ":" + mapping
+		}
+
+		mappedPortsProperty = append(mappedPortsProperty, mapping)
+	}
 
 	processIDs := []uint32{}
 	for _, process := range c.processTracker.ActiveProcesses() {
 		processIDs = append(processIDs, process.ID())
 	}
 
-	properties, _ := c.Properties()
+	properties := c.copyProperties()
+
+	if network := c.resources.Network; network.IPv6Subnet != nil {
+		properties[ContainerIPv6Property] = network.IPv6.String()
+		properties[HostIPv6Property] = subnets.GatewayIP(network.IPv6Subnet).String()
+	}
+
+	if len(mappedPortsProperty) > 0 {
+		properties[MappedPortsProperty] = strings.Join(mappedPortsProperty, ",")
+	}
+
+	if eventLog := c.EventLog(); len(eventLog) > 0 {
+		encoded, err := json.Marshal(eventLog)
+		if err != nil {
+			return garden.ContainerInfo{}, err
+		}
+
+		properties[EventsProperty] = string(encoded)
+	}
 
 	info := garden.ContainerInfo{
 		State:         string(c.State()),
@@ -541,15 +855,8 @@ func (c *LinuxContainer) Info() (garden.ContainerInfo, error) {
 
 func (c *LinuxContainer) StreamIn(dstPath string, tarStream io.Reader) error {
 	nsTarPath := path.Join(c.path, "bin", "nstar")
-	pidPath := path.Join(c.path, "run", "wshd.pid")
 
-	pidFile, err := os.Open(pidPath)
-	if err != nil {
-		return err
-	}
-
-	var pid int
-	_, err = fmt.Fscanf(pidFile, "%d", &pid)
+	pid, err := c.wshdPid()
 	if err != nil {
 		return err
 	}
@@ -582,15 +889,8 @@ func (c *LinuxContainer) StreamOut(srcPath string) (io.ReadCloser, error) {
 	}
 
 	nsTarPath := path.Join(c.path, "bin", "nstar")
-	pidPath := path.Join(c.path, "run", "wshd.pid")
-
-	pidFile, err := os.Open(pidPath)
-	if err != nil {
-		return nil, err
-	}
 
-	var pid int
-	_, err = fmt.Fscanf(pidFile, "%d", &pid)
+	pid, err := c.wshdPid()
 	if err != nil {
 		return nil, err
 	}
@@ -623,7 +923,67 @@ func (c *LinuxContainer) StreamOut(srcPath string) (io.ReadCloser, error) {
 	return tarRead, nil
 }
 
+// NetIn forwards TCP traffic to the host port to the container port.
 func (c *LinuxContainer) NetIn(hostPort uint32, containerPort uint32) (uint32, uint32, error) {
+	return c.NetInWithProtocol(hostPort, containerPort, garden.ProtocolTCP)
+}
+
+// NetInWithProtocol forwards traffic of the given protocol to the host port
+// to the container port. garden.ProtocolAll forwards both TCP and UDP.
+func (c *LinuxContainer) NetInWithProtocol(hostPort, containerPort uint32, protocol garden.Protocol) (uint32, uint32, error) {
+	return c.NetInOnExternalIP(nil, hostPort, containerPort, protocol)
+}
+
+// NetInOnExternalIP forwards traffic to the host port on one of the external
+// IPs of the host, rather than on the default one. The IP must be the default
+// external IP or one of the additional ones; nil means the default.
+func (c *LinuxContainer) NetInOnExternalIP(externalIP net.IP, hostPort, containerPort uint32, protocol garden.Protocol) (uint32, uint32, error) {
+	if externalIP.Equal(c.resources.ExternalIP) {
+		externalIP = nil
+	}
+
+	if externalIP != nil && !c.isAdditionalExternalIP(externalIP) {
+		return 0, 0, UnknownExternalIPError{IP: externalIP}
+	}
+
+	hostPort, containerPort, err := c.netIn(externalIP, hostPort, containerPort, protocol)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	c.saveSnapshot()
+
+	data := map[string]string{
+		"rule":           "net-in",
+		"host-port":      fmt.Sprintf("%d", hostPort),
+		"container-port": fmt.Sprintf("%d", containerPort),
+		"protocol":       netInProtocols[protocol],
+	}
+
+	if externalIP != nil {
+		data["external-ip"] = externalIP.String()
+	}
+
+	c.emit(linux_backend.EventNetRuleAdded, data)
+
+	return hostPort, containerPort, nil
+}
+
+func (c *LinuxContainer) isAdditionalExternalIP(ip net.IP) bool {
+	for _, additional := range c.resources.AdditionalExternalIPs {
+		if ip.Equal(additional) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (c *LinuxContainer) netIn(externalIP net.IP, hostPort, containerPort uint32, protocol garden.Protocol) (uint32, uint32, error) {
+	if _, ok := netInProtocols[protocol]; !ok {
+		return 0, 0, UnsupportedNetInProtocolError{Protocol: protocol}
+	}
+
 	if hostPort == 0 {
 		randomPort, err := c.portPool.Acquire()
 		if err != nil {
@@ -639,14 +999,14 @@ func (c *LinuxContainer) NetIn(hostPort uint32, containerPort uint32) (uint32, u
 		containerPort = hostPort
 	}
 
-	net := exec.Command(path.Join(c.path, "net.sh"), "in")
-	net.Env = []string{
-		fmt.Sprintf("HOST_PORT=%d", hostPort),
-		fmt.Sprintf("CONTAINER_PORT=%d", containerPort),
-		"PATH=" + os.Getenv("PATH"),
+	spec := NetInSpec{
+		HostPort:      hostPort,
+		ContainerPort: containerPort,
+		Protocol:      protocol,
+		ExternalIP:    externalIP,
 	}
 
-	err := c.runner.Run(net)
+	err := c.instanceChain.AppendPortForward(c.portForwardSpec(spec))
 	if err != nil {
 		return 0, 0, err
 	}
@@ -654,12 +1014,116 @@ func (c *LinuxContainer) NetIn(hostPort uint32, containerPort uint32) (uint32, u
 	c.netInsMutex.Lock()
 	defer c.netInsMutex.Unlock()
 
-	c.netIns = append(c.netIns, NetInSpec{hostPort, containerPort})
+	c.netIns = append(c.netIns, spec)
 
 	return hostPort, containerPort, nil
 }
 
+// NetInRemove stops forwarding the host port to the container, on whichever
+// external IPs it is mapped on. If the port was acquired from the pool when it
+// was mapped, it is released back to it.
+func (c *LinuxContainer) NetInRemove(hostPort uint32) error {
+	cLog := c.logger.Session("net-in-remove", lager.Data{
+		"host-port": hostPort,
+	})
+
+	c.netInsMutex.Lock()
+
+	found := false
+	remaining := []NetInSpec{}
+
+	var err error
+	for _, spec := range c.netIns {
+		if spec.HostPort != hostPort || err != nil {
+			remaining = append(remaining, spec)
+			continue
+		}
+
+		found = true
+
+		err = c.instanceChain.DeletePortForward(c.portForwardSpec(spec))
+		if err != nil {
+			cLog.Error("failed-to-delete-port-forward", err)
+			remaining = append(remaining, spec)
+		}
+	}
+
+	c.netIns = remaining
+
+	c.netInsMutex.Unlock()
+
+	if !found {
+		return NetInNotFoundError{HostPort: hostPort}
+	}
+
+	if err == nil && c.resources.RemovePort(hostPort) {
+		c.portPool.Release(hostPort)
+	}
+
+	c.saveSnapshot()
+
+	if err != nil {
+		return err
+	}
+
+	c.emit(linux_backend.EventNetRuleRemoved, map[string]string{
+		"rule":      "net-in",
+		"host-port": fmt.Sprintf("%d", hostPort),
+	})
+
+	return nil
+}
+
+// NetIns returns the ports which are forwarded to the container.
+func (c *LinuxContainer) NetIns() []linux_backend.PortMapping {
+	c.netInsMutex.RLock()
+	defer c.netInsMutex.RUnlock()
+
+	mappings := []linux_backend.PortMapping{}
+	for _, spec := range c.netIns {
+		mappings = append(mappings, linux_backend.PortMapping{
+			HostPort:      spec.HostPort,
+			ContainerPort: spec.ContainerPort,
+			Protocol:      spec.Protocol,
+			ExternalIP:    spec.ExternalIP,
+		})
+	}
+
+	return mappings
+}
+
+func (c *LinuxContainer) portForwardSpec(spec NetInSpec) iptables.PortForwardSpec {
+	externalIP := c.resources.ExternalIP
+	if spec.ExternalIP != nil {
+		externalIP = spec.ExternalIP
+	}
+
+	return iptables.PortForwardSpec{
+		ExternalIP:    externalIP,
+		HostPort:      spec.HostPort,
+		ContainerIP:   c.resources.Network.IP,
+		ContainerPort: spec.ContainerPort,
+		Protocol:      spec.Protocol,
+	}
+}
+
 func (c *LinuxContainer) NetOut(r garden.NetOutRule) error {
+	err := c.netOut(r)
+	if err != nil {
+		return err
+	}
+
+	c.saveSnapshot()
+
+	c.emit(linux_backend.EventNetRuleAdded, map[string]string{
+		"rule":     "net-out",
+		"protocol": fmt.Sprintf("%d", r.Protocol),
+	})
+
+	return nil
+}
+
+func (c *LinuxContainer) netOut(r garden.NetOutRule) error {
 	err := c.filter.NetOut(r)
 	if err != nil {
 		return err
@@ -673,10 +1137,144 @@ func (c *LinuxContainer) NetOut(r garden.NetOutRule) error {
 	return nil
 }
 
+// BulkNetOut grants all of the rules, or none of them.
+func (c *LinuxContainer) BulkNetOut(rules []garden.NetOutRule) error {
+	err := c.filter.BulkNetOut(rules)
+	if err != nil {
+		return err
+	}
+
+	c.netOutsMutex.Lock()
+	c.netOuts = append(c.netOuts, rules...)
+	c.netOutsMutex.Unlock()
+
+	c.saveSnapshot()
+
+	for _, r := range rules {
+		c.emit(linux_backend.EventNetRuleAdded, map[string]string{
+			"rule":     "net-out",
+			"protocol": fmt.Sprintf("%d", r.Protocol),
+		})
+	}
+
+	return nil
+}
+
+// NetOutRemove revokes a rule granted by NetOut. If the same rule was granted
+// more than once, one grant of it is revoked.
+func (c *LinuxContainer) NetOutRemove(r garden.NetOutRule) error {
+	cLog := c.logger.Session("net-out-remove", lager.Data{
+		"rule": r,
+	})
+
+	c.netOutsMutex.Lock()
+
+	i := -1
+	for j, out := range c.netOuts {
+		if sameNetOutRule(out, r) {
+			i = j
+			break
+		}
+	}
+
+	if i == -1 {
+		c.netOutsMutex.Unlock()
+		return NetOutNotFoundError{Rule: r}
+	}
+
+	err := c.filter.NetOutRemove(r)
+	if err != nil {
+		c.netOutsMutex.Unlock()
+		cLog.Error("failed-to-delete-rule", err)
+		return err
+	}
+
+	c.netOuts = append(c.netOuts[:i:i], c.netOuts[i+1:]...)
+
+	c.netOutsMutex.Unlock()
+
+	c.saveSnapshot()
+
+	c.emit(linux_backend.EventNetRuleRemoved, map[string]string{
+		"rule":     "net-out",
+		"protocol": fmt.Sprintf("%d", r.Protocol),
+	})
+
+	return nil
+}
+
+// sameNetOutRule reports whether two rules grant the same thing. The rules
+// restored from a snapshot have 16 byte IPs and empty rather than nil lists, so
+// they are compared field by field rather than deeply.
+func sameNetOutRule(a, b garden.NetOutRule) bool {
+	if a.Protocol != b.Protocol || a.Log != b.Log {
+		return false
+	}
+
+	if len(a.Networks) != len(b.Networks) || len(a.Ports) != len(b.Ports) {
+		return false
+	}
+
+	for i := range a.Networks {
+		if !a.Networks[i].Start.Equal(b.Networks[i].Start) || !a.Networks[i].End.Equal(b.Networks[i].End) {
+			return false
+		}
+	}
+
+	for i := range a.Ports {
+		if a.Ports[i] != b.Ports[i] {
+			return false
+		}
+	}
+
+	if a.ICMPs == nil || b.ICMPs == nil {
+		return a.ICMPs == b.ICMPs
+	}
+
+	if a.ICMPs.Type != b.ICMPs.Type {
+		return false
+	}
+
+	if a.ICMPs.Code == nil || b.ICMPs.Code == nil {
+		return a.ICMPs.Code == b.ICMPs.Code
+	}
+
+	return *a.ICMPs.Code == *b.ICMPs.Code
+}
+
+// NetOutRules returns the rules granted by NetOut, in the order they were
+// granted.
+func (c *LinuxContainer) NetOutRules() []garden.NetOutRule {
+	c.netOutsMutex.RLock()
+	defer c.netOutsMutex.RUnlock()
+
+	rules := make([]garden.NetOutRule, len(c.netOuts))
+	copy(rules, c.netOuts)
+
+	return rules
+}
+
 func (c *LinuxContainer) CurrentEnvVars() process.Env {
 	return c.env
 }
 
+func (c *LinuxContainer) wshdPid() (int, error) {
+	pidFile, err := os.Open(path.Join(c.path, "run", "wshd.pid"))
+	if err != nil {
+		return 0, err
+	}
+
+	defer pidFile.Close()
+
+	var pid int
+	_, err = fmt.Fscanf(pidFile, "%d", &pid)
+	if err != nil {
+		return 0, err
+	}
+
+	return pid, nil
+}
+
 func (c *LinuxContainer) setState(state State) {
 	c.stateMutex.Lock()
 	defer c.stateMutex.Unlock()
@@ -684,9 +1282,47 @@ func (c *LinuxContainer) setState(state State) {
 	c.state = state
 }
 
-func (c *LinuxContainer) registerEvent(event string) {
-	c.eventsMutex.Lock()
-	defer c.eventsMutex.Unlock()
+// SaveSnapshot rewrites the container's snapshot with its snapshot saver,
+// serialised with the saves it makes as its state changes so that an older
+// snapshot never replaces a newer one.
+//
+// It must not be called with any of the container's state mutexes held.
+func (c *LinuxContainer) SaveSnapshot() error {
+	c.snapshotMutex.Lock()
+	defer c.snapshotMutex.Unlock()
+
+	return c.snapshotSaver.Save(c)
+}
+
+// saveSnapshot rewrites the container's snapshot after its state has changed,
+// so that the change survives the server dying without a clean shutdown.
+func (c *LinuxContainer) saveSnapshot() {
+	err := c.SaveSnapshot()
+	if err != nil {
+		c.logger.Error("failed-to-save-snapshot", err)
+	}
+}
+
+// emit publishes a lifecycle event for the container.
+func (c *LinuxContainer) emit(eventType linux_backend.EventType, data map[string]string) {
+	c.eventEmitter.Emit(linux_backend.ContainerEvent{
+		Type: eventType,
+
+		Handle:     c.handle,
+		Properties: c.copyProperties(),
+
+		Data: data,
+	})
+}
+
+func (c *LinuxContainer) copyProperties() garden.Properties {
+	c.propertiesMutex.RLock()
+	defer c.propertiesMutex.RUnlock()
+
+	properties := make(garden.Properties, len(c.properties))
+	for key, val := range c.properties {
+		properties[key] = val
+	}
 
-	c.events = append(c.events, event)
+	return properties
 }
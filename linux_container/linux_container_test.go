@@ -9,6 +9,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"syscall"
 	"time"
 
 	. "github.com/onsi/ginkgo"
@@ -17,8 +18,13 @@ import (
 
 	"github.com/cloudfoundry-incubator/garden"
 	"github.com/cloudfoundry-incubator/garden-linux/linux_backend"
+	backendFakes "github.com/cloudfoundry-incubator/garden-linux/linux_backend/fakes"
 	"github.com/cloudfoundry-incubator/garden-linux/linux_container"
+	containerFakes "github.com/cloudfoundry-incubator/garden-linux/linux_container/fakes"
 	networkFakes "github.com/cloudfoundry-incubator/garden-linux/network/fakes"
+	"github.com/cloudfoundry-incubator/garden-linux/network/iptables"
+	iptablesFakes "github.com/cloudfoundry-incubator/garden-linux/network/iptables/fakes"
+	"github.com/cloudfoundry-incubator/garden-linux/old/bandwidth_manager"
 	"github.com/cloudfoundry-incubator/garden-linux/old/bandwidth_manager/fake_bandwidth_manager"
 	"github.com/cloudfoundry-incubator/garden-linux/old/cgroups_manager/fake_cgroups_manager"
 	"github.com/cloudfoundry-incubator/garden-linux/old/port_pool/fake_port_pool"
@@ -40,6 +46,10 @@ var _ = Describe("Linux containers", func() {
 	var fakePortPool *fake_port_pool.FakePortPool
 	var fakeProcessTracker *fake_process_tracker.FakeProcessTracker
 	var fakeFilter *networkFakes.FakeFilter
+	var fakeInstanceChain *iptablesFakes.FakeInstanceChain
+	var fakeInterfaceStatter *networkFakes.FakeInterfaceStatter
+	var fakeSnapshotSaver *containerFakes.FakeSnapshotSaver
+	var fakeEventEmitter *backendFakes.FakeEventEmitter
 	var containerDir string
 	var containerProps map[string]string
 	var mtu uint32
@@ -52,7 +62,12 @@ var _ = Describe("Linux containers", func() {
 		fakeQuotaManager = fake_quota_manager.New()
 		fakeBandwidthManager = fake_bandwidth_manager.New()
 		fakeProcessTracker = new(fake_process_tracker.FakeProcessTracker)
+		fakeProcessTracker.RunReturns(new(wfakes.FakeProcess), nil)
 		fakeFilter = new(networkFakes.FakeFilter)
+		fakeInstanceChain = new(iptablesFakes.FakeInstanceChain)
+		fakeInterfaceStatter = new(networkFakes.FakeInterfaceStatter)
+		fakeSnapshotSaver = new(containerFakes.FakeSnapshotSaver)
+		fakeEventEmitter = new(backendFakes.FakeEventEmitter)
 
 		fakePortPool = fake_port_pool.New(1000)
 
@@ -75,7 +90,7 @@ var _ = Describe("Linux containers", func() {
 			},
 			"some-bridge",
 			[]uint32{},
-			nil,
+			net.ParseIP("5.6.7.8"),
 		)
 
 		mtu = 1500
@@ -102,6 +117,10 @@ var _ = Describe("Linux containers", func() {
 			fakeProcessTracker,
 			process.Env{"env1": "env1Value", "env2": "env2Value"},
 			fakeFilter,
+			fakeInstanceChain,
+			fakeInterfaceStatter,
+			fakeSnapshotSaver,
+			fakeEventEmitter,
 		)
 	})
 
@@ -146,6 +165,79 @@ var _ = Describe("Linux containers", func() {
 			Expect(container.State()).To(Equal(linux_container.StateActive))
 		})
 
+		It("sets up the instance chains for the container's network before running start.sh", func() {
+			fakeRunner.WhenRunning(
+				fake_command_runner.CommandSpec{
+					Path: containerDir + "/start.sh",
+				}, func(*exec.Cmd) error {
+					Expect(fakeInstanceChain.SetupCallCount()).To(Equal(1))
+					return nil
+				},
+			)
+
+			err := container.Start()
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(fakeInstanceChain.SetupCallCount()).To(Equal(1))
+
+			network := fakeInstanceChain.SetupArgsForCall(0)
+			Expect(network.BridgeInterface).To(Equal("some-bridge"))
+			Expect(network.ContainerIP.String()).To(Equal("1.2.3.4"))
+			Expect(network.Subnet.String()).To(Equal("2.3.4.0/30"))
+			Expect(network.ExternalIP.String()).To(Equal("5.6.7.8"))
+			Expect(network.IPv6Subnet).To(BeNil())
+		})
+
+		Context("when the container is dual-stack", func() {
+			BeforeEach(func() {
+				containerResources.Network.IPv6, containerResources.Network.IPv6Subnet, _ = net.ParseCIDR("fd00::2/126")
+			})
+
+			It("sets up the instance chains for IPv6 as well", func() {
+				err := container.Start()
+				Expect(err).ToNot(HaveOccurred())
+
+				network := fakeInstanceChain.SetupArgsForCall(0)
+				Expect(network.ContainerIPv6.String()).To(Equal("fd00::2"))
+				Expect(network.IPv6Subnet.String()).To(Equal("fd00::/126"))
+			})
+		})
+
+		Context("when ports may be mapped on additional external IPs", func() {
+			BeforeEach(func() {
+				containerResources.AdditionalExternalIPs = []net.IP{net.ParseIP("5.6.7.9")}
+			})
+
+			It("sets up the instance chains with them", func() {
+				err := container.Start()
+				Expect(err).ToNot(HaveOccurred())
+
+				network := fakeInstanceChain.SetupArgsForCall(0)
+				Expect(network.AdditionalExternalIPs).To(Equal([]net.IP{net.ParseIP("5.6.7.9")}))
+			})
+		})
+
+		Context("when setting up the instance chains fails", func() {
+			JustBeforeEach(func() {
+				fakeInstanceChain.SetupReturns(errors.New("oh no!"))
+			})
+
+			It("returns a wrapped error", func() {
+				err := container.Start()
+				Expect(err).To(MatchError("container: start: oh no!"))
+			})
+
+			It("does not run start.sh", func() {
+				container.Start()
+
+				Expect(fakeRunner).ToNot(HaveExecutedSerially(
+					fake_command_runner.CommandSpec{
+						Path: containerDir + "/start.sh",
+					},
+				))
+			})
+		})
+
 		Context("when start.sh fails", func() {
 			nastyError := errors.New("oh no!")
 
@@ -261,155 +353,186 @@ var _ = Describe("Linux containers", func() {
 		})
 	})
 
-	Describe("Cleaning up", func() {
-		Context("when the container has an oom notifier running", func() {
-			JustBeforeEach(func() {
-				err := container.LimitMemory(garden.MemoryLimits{
-					LimitInBytes: 42,
-				})
+	Describe("Stopping gracefully", func() {
+		var stopSpec linux_backend.StopSpec
 
-				Expect(err).ToNot(HaveOccurred())
-			})
+		// newProcess returns a process which exits upon receiving one of the
+		// given signals
+		newProcess := func(id uint32, exitsOn ...garden.Signal) *wfakes.FakeProcess {
+			exited := make(chan struct{})
 
-			It("stops it", func() {
-				container.Cleanup()
+			process := new(wfakes.FakeProcess)
+			process.IDReturns(id)
 
-				Expect(fakeRunner).To(HaveKilled(fake_command_runner.CommandSpec{
-					Path: containerDir + "/bin/oom",
-				}))
+			process.SignalStub = func(signal garden.Signal) error {
+				for _, s := range exitsOn {
+					if s == signal {
+						close(exited)
+					}
+				}
 
-			})
+				return nil
+			}
+
+			process.WaitStub = func() (int, error) {
+				<-exited
+				return int(id), nil
+			}
+
+			return process
+		}
+
+		BeforeEach(func() {
+			stopSpec = linux_backend.StopSpec{
+				Signal:      garden.SignalTerminate,
+				GracePeriod: 100 * time.Millisecond,
+			}
 		})
-	})
 
-	Describe("Streaming data in", func() {
-		It("streams the input to tar xf in the container", func() {
-			fakeRunner.WhenRunning(
-				fake_command_runner.CommandSpec{
-					Path: containerDir + "/bin/nstar",
-					Args: []string{
-						"12345",
-						"vcap",
-						"/some/directory/dst",
-					},
-				},
-				func(cmd *exec.Cmd) error {
-					bytes, err := ioutil.ReadAll(cmd.Stdin)
-					Expect(err).ToNot(HaveOccurred())
+		It("sends the initial signal to every process", func() {
+			p1 := newProcess(1, garden.SignalTerminate)
+			p2 := newProcess(2, garden.SignalTerminate)
 
-					Expect(string(bytes)).To(Equal("the-tar-content"))
+			fakeProcessTracker.ActiveProcessesReturns([]garden.Process{p1, p2})
 
-					return nil
-				},
-			)
+			_, err := container.GracefulStop(stopSpec)
+			Expect(err).ToNot(HaveOccurred())
 
-			err := container.StreamIn("/some/directory/dst", bytes.NewBufferString("the-tar-content"))
+			Expect(p1.SignalCallCount()).To(Equal(1))
+			Expect(p1.SignalArgsForCall(0)).To(Equal(garden.SignalTerminate))
+			Expect(p2.SignalCallCount()).To(Equal(1))
+			Expect(p2.SignalArgsForCall(0)).To(Equal(garden.SignalTerminate))
+		})
+
+		It("escalates only the processes which outlive the grace period", func() {
+			p1 := newProcess(1, garden.SignalTerminate)
+			p2 := newProcess(2, garden.SignalKill)
+			p3 := newProcess(3, garden.SignalTerminate)
+
+			fakeProcessTracker.ActiveProcessesReturns([]garden.Process{p1, p2, p3})
+
+			results, err := container.GracefulStop(stopSpec)
 			Expect(err).ToNot(HaveOccurred())
+
+			Expect(p2.SignalCallCount()).To(Equal(2))
+			Expect(p2.SignalArgsForCall(1)).To(Equal(garden.SignalKill))
+
+			Expect(p1.SignalCallCount()).To(Equal(1))
+			Expect(p3.SignalCallCount()).To(Equal(1))
+
+			Expect(results).To(Equal([]linux_backend.ProcessStopResult{
+				{ProcessID: 1, ExitStatus: 1},
+				{ProcessID: 2, ExitStatus: 2, Escalated: true},
+				{ProcessID: 3, ExitStatus: 3},
+			}))
 		})
 
-		Context("when tar fails", func() {
-			disaster := errors.New("oh no!")
+		It("escalates with the given signal", func() {
+			quit := garden.SignalTerminate
+			stopSpec.Signal = garden.SignalKill
+			stopSpec.EscalationSignal = &quit
 
-			JustBeforeEach(func() {
-				fakeRunner.WhenRunning(
-					fake_command_runner.CommandSpec{
-						Path: containerDir + "/bin/nstar",
-					},
-					func(cmd *exec.Cmd) error {
-						return disaster
-					},
-				)
+			p1 := newProcess(1, garden.SignalTerminate)
+
+			fakeProcessTracker.ActiveProcessesReturns([]garden.Process{p1})
+
+			results, err := container.GracefulStop(stopSpec)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(p1.SignalCallCount()).To(Equal(2))
+			Expect(p1.SignalArgsForCall(1)).To(Equal(garden.SignalTerminate))
+			Expect(results[0].Escalated).To(BeTrue())
+		})
+
+		Context("when escalated processes do not exit before the escalation timeout", func() {
+			var p1, p2 *wfakes.FakeProcess
+
+			BeforeEach(func() {
+				stopSpec.EscalationTimeout = 100 * time.Millisecond
+
+				p1 = newProcess(1, garden.SignalTerminate)
+				p2 = newProcess(2)
+
+				fakeProcessTracker.ActiveProcessesReturns([]garden.Process{p1, p2})
 			})
 
-			It("returns the error", func() {
-				err := container.StreamIn("/some/directory/dst", nil)
-				Expect(err).To(Equal(disaster))
+			It("returns an error naming the processes still running", func() {
+				results, err := container.GracefulStop(stopSpec)
+				Expect(err).To(Equal(linux_container.ProcessesNotStoppedError{
+					ProcessIDs: []uint32{2},
+				}))
+
+				Expect(results).To(Equal([]linux_backend.ProcessStopResult{
+					{ProcessID: 1, ExitStatus: 1},
+					{ProcessID: 2, Escalated: true},
+				}))
+			})
+
+			It("gives up after the escalation timeout", func() {
+				started := time.Now()
+
+				container.GracefulStop(stopSpec)
+
+				Expect(time.Since(started)).To(BeNumerically("<", time.Second))
+			})
+
+			It("does not set the container's state to stopped", func() {
+				container.GracefulStop(stopSpec)
+
+				Expect(container.State()).ToNot(Equal(linux_container.StateStopped))
 			})
 		})
-	})
 
-	Describe("Streaming out", func() {
-		It("streams the output of tar cf to the destination", func() {
-			fakeRunner.WhenRunning(
-				fake_command_runner.CommandSpec{
-					Path: containerDir + "/bin/nstar",
-					Args: []string{
-						"12345",
-						"vcap",
-						"/some/directory",
-						"dst",
-					},
-				},
-				func(cmd *exec.Cmd) error {
-					_, err := cmd.Stdout.Write([]byte("the-compressed-content"))
-					Expect(err).ToNot(HaveOccurred())
+		It("waits for the grace period before escalating", func() {
+			fakeProcessTracker.ActiveProcessesReturns([]garden.Process{
+				newProcess(1, garden.SignalKill),
+			})
 
-					return nil
-				},
-			)
+			started := time.Now()
 
-			reader, err := container.StreamOut("/some/directory/dst")
+			_, err := container.GracefulStop(stopSpec)
 			Expect(err).ToNot(HaveOccurred())
 
-			bytes, err := ioutil.ReadAll(reader)
-			Expect(err).ToNot(HaveOccurred())
-			Expect(string(bytes)).To(Equal("the-compressed-content"))
+			Expect(time.Since(started)).To(BeNumerically(">=", stopSpec.GracePeriod))
 		})
 
-		It("closes the server-side dupe of of the pipe's write end", func() {
-			var outPipe io.Writer
-
-			fakeRunner.WhenRunning(
-				fake_command_runner.CommandSpec{
-					Path: containerDir + "/bin/nstar",
-					Args: []string{
-						"12345",
-						"vcap",
-						"/some/directory",
-						"dst",
-					},
-				},
-				func(cmd *exec.Cmd) error {
-					outPipe = cmd.Stdout
-					return nil
-				},
-			)
+		It("records an event for each escalated process", func() {
+			fakeProcessTracker.ActiveProcessesReturns([]garden.Process{
+				newProcess(42, garden.SignalKill),
+			})
 
-			_, err := container.StreamOut("/some/directory/dst")
+			_, err := container.GracefulStop(stopSpec)
 			Expect(err).ToNot(HaveOccurred())
 
-			Expect(outPipe).ToNot(BeNil())
+			Expect(container.Events()).To(ContainElement("process required escalation signal: 42"))
+		})
 
-			_, err = outPipe.Write([]byte("sup"))
-			Expect(err).To(HaveOccurred())
+		It("sets the container's state to stopped", func() {
+			_, err := container.GracefulStop(stopSpec)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(container.State()).To(Equal(linux_container.StateStopped))
 		})
 
-		Context("when there's a trailing slash", func() {
-			It("compresses the directory's contents", func() {
-				_, err := container.StreamOut("/some/directory/dst/")
-				Expect(err).ToNot(HaveOccurred())
+		It("kills whatever is left in the container with stop.sh", func() {
+			_, err := container.GracefulStop(stopSpec)
+			Expect(err).ToNot(HaveOccurred())
 
-				Expect(fakeRunner).To(HaveBackgrounded(
-					fake_command_runner.CommandSpec{
-						Path: containerDir + "/bin/nstar",
-						Args: []string{
-							"12345",
-							"vcap",
-							"/some/directory/dst/",
-							".",
-						},
-					},
-				))
-			})
+			Expect(fakeRunner).To(HaveExecutedSerially(
+				fake_command_runner.CommandSpec{
+					Path: containerDir + "/stop.sh",
+					Args: []string{"-w", "0"},
+				},
+			))
 		})
 
-		Context("when executing the command fails", func() {
+		Context("when stop.sh fails", func() {
 			disaster := errors.New("oh no!")
 
-			JustBeforeEach(func() {
+			BeforeEach(func() {
 				fakeRunner.WhenRunning(
 					fake_command_runner.CommandSpec{
-						Path: containerDir + "/bin/nstar",
+						Path: containerDir + "/stop.sh",
 					}, func(*exec.Cmd) error {
 						return disaster
 					},
@@ -417,47 +540,438 @@ var _ = Describe("Linux containers", func() {
 			})
 
 			It("returns the error", func() {
-				_, err := container.StreamOut("/some/dst")
+				_, err := container.GracefulStop(stopSpec)
 				Expect(err).To(Equal(disaster))
 			})
+
+			It("does not set the container's state to stopped", func() {
+				container.GracefulStop(stopSpec)
+
+				Expect(container.State()).ToNot(Equal(linux_container.StateStopped))
+			})
+		})
+
+		Context("when escalated processes do not exit", func() {
+			BeforeEach(func() {
+				stopSpec.EscalationTimeout = 100 * time.Millisecond
+
+				fakeProcessTracker.ActiveProcessesReturns([]garden.Process{newProcess(1)})
+			})
+
+			It("does not run stop.sh", func() {
+				container.GracefulStop(stopSpec)
+
+				Expect(fakeRunner).ToNot(HaveExecutedSerially(
+					fake_command_runner.CommandSpec{
+						Path: containerDir + "/stop.sh",
+					},
+				))
+			})
 		})
 	})
 
-	Describe("Net in", func() {
-		It("executes net.sh in with HOST_PORT and CONTAINER_PORT", func() {
-			hostPort, containerPort, err := container.NetIn(123, 456)
+	Describe("Pausing", func() {
+		JustBeforeEach(func() {
+			Expect(container.Start()).To(Succeed())
+		})
+
+		It("freezes the container's freezer cgroup", func() {
+			err := container.Pause()
 			Expect(err).ToNot(HaveOccurred())
 
-			Expect(fakeRunner).To(HaveExecutedSerially(
-				fake_command_runner.CommandSpec{
-					Path: containerDir + "/net.sh",
-					Args: []string{"in"},
-					Env: []string{
-						"HOST_PORT=123",
-						"CONTAINER_PORT=456",
-						"PATH=" + os.Getenv("PATH"),
-					},
+			Expect(fakeCgroups.SetValues()).To(ContainElement(
+				fake_cgroups_manager.SetValue{
+					Subsystem: "freezer",
+					Name:      "freezer.state",
+					Value:     "FROZEN",
 				},
 			))
+		})
 
-			Expect(hostPort).To(Equal(uint32(123)))
-			Expect(containerPort).To(Equal(uint32(456)))
+		It("sets the container's state to paused", func() {
+			err := container.Pause()
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(container.State()).To(Equal(linux_container.StatePaused))
 		})
 
-		Context("when a host port is not provided", func() {
-			It("acquires one from the port pool", func() {
-				hostPort, containerPort, err := container.NetIn(0, 456)
-				Expect(err).ToNot(HaveOccurred())
+		It("reports the paused state through Info", func() {
+			err := container.Pause()
+			Expect(err).ToNot(HaveOccurred())
 
-				Expect(hostPort).To(Equal(uint32(1000)))
-				Expect(containerPort).To(Equal(uint32(456)))
+			info, err := container.Info()
+			Expect(err).ToNot(HaveOccurred())
 
-				secondHostPort, _, err := container.NetIn(0, 456)
-				Expect(err).ToNot(HaveOccurred())
+			Expect(info.State).To(Equal("paused"))
+		})
 
-				Expect(secondHostPort).ToNot(Equal(hostPort))
+		Context("when freezing fails", func() {
+			disaster := errors.New("oh no!")
 
-				Expect(container.Resources().Ports).To(ContainElement(hostPort))
+			JustBeforeEach(func() {
+				fakeCgroups.WhenSetting("freezer", "freezer.state", func() error {
+					return disaster
+				})
+			})
+
+			It("returns the error", func() {
+				err := container.Pause()
+				Expect(err).To(Equal(disaster))
+			})
+
+			It("does not change the container's state", func() {
+				container.Pause()
+
+				Expect(container.State()).To(Equal(linux_container.StateActive))
+			})
+		})
+
+		Context("when the container has no freezer cgroup", func() {
+			JustBeforeEach(func() {
+				fakeCgroups.WhenSetting("freezer", "freezer.state", func() error {
+					return &os.PathError{Op: "open", Path: "/cgroups/freezer/instance-some-id/freezer.state", Err: syscall.ENOENT}
+				})
+			})
+
+			It("returns a NoFreezerCgroupError", func() {
+				err := container.Pause()
+				Expect(err).To(Equal(linux_container.NoFreezerCgroupError{Handle: "some-handle"}))
+			})
+
+			It("does not change the container's state", func() {
+				container.Pause()
+
+				Expect(container.State()).To(Equal(linux_container.StateActive))
+			})
+		})
+
+		Context("when paused concurrently", func() {
+			JustBeforeEach(func() {
+				fakeCgroups.WhenSetting("freezer", "freezer.state", func() error {
+					time.Sleep(10 * time.Millisecond)
+					return nil
+				})
+			})
+
+			It("freezes the container only once", func() {
+				errs := make(chan error, 2)
+				for i := 0; i < 2; i++ {
+					go func() {
+						errs <- container.Pause()
+					}()
+				}
+
+				failed := 0
+				for i := 0; i < 2; i++ {
+					if err := <-errs; err != nil {
+						Expect(err).To(Equal(linux_container.InvalidStateError{Op: "pause", State: linux_container.StatePaused}))
+						failed++
+					}
+				}
+
+				Expect(failed).To(Equal(1))
+			})
+		})
+
+		Context("when the container is not active", func() {
+			JustBeforeEach(func() {
+				Expect(container.Stop(false)).To(Succeed())
+			})
+
+			It("returns an InvalidStateError without freezing it", func() {
+				err := container.Pause()
+				Expect(err).To(Equal(linux_container.InvalidStateError{Op: "pause", State: linux_container.StateStopped}))
+				Expect(err).To(MatchError("cannot pause a container which is stopped"))
+
+				Expect(container.State()).To(Equal(linux_container.StateStopped))
+				Expect(fakeCgroups.SetValues()).ToNot(ContainElement(
+					fake_cgroups_manager.SetValue{
+						Subsystem: "freezer",
+						Name:      "freezer.state",
+						Value:     "FROZEN",
+					},
+				))
+			})
+		})
+	})
+
+	Describe("Resuming a container which is not paused", func() {
+		It("returns an InvalidStateError without changing its state", func() {
+			err := container.Resume()
+			Expect(err).To(Equal(linux_container.InvalidStateError{Op: "resume", State: linux_container.StateBorn}))
+
+			Expect(container.State()).To(Equal(linux_container.StateBorn))
+		})
+
+		Context("when the container is active", func() {
+			It("returns an InvalidStateError without thawing it", func() {
+				Expect(container.Start()).To(Succeed())
+
+				err := container.Resume()
+				Expect(err).To(Equal(linux_container.InvalidStateError{Op: "resume", State: linux_container.StateActive}))
+
+				Expect(fakeCgroups.SetValues()).To(BeEmpty())
+			})
+		})
+	})
+
+	Describe("Resuming", func() {
+		JustBeforeEach(func() {
+			Expect(container.Start()).To(Succeed())
+
+			err := container.Pause()
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("thaws the container's freezer cgroup", func() {
+			err := container.Resume()
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(fakeCgroups.SetValues()).To(ContainElement(
+				fake_cgroups_manager.SetValue{
+					Subsystem: "freezer",
+					Name:      "freezer.state",
+					Value:     "THAWED",
+				},
+			))
+		})
+
+		It("sets the container's state to active", func() {
+			err := container.Resume()
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(container.State()).To(Equal(linux_container.StateActive))
+		})
+
+		Context("when thawing fails", func() {
+			disaster := errors.New("oh no!")
+
+			JustBeforeEach(func() {
+				fakeCgroups.WhenSetting("freezer", "freezer.state", func() error {
+					return disaster
+				})
+			})
+
+			It("returns the error and stays paused", func() {
+				err := container.Resume()
+				Expect(err).To(Equal(disaster))
+
+				Expect(container.State()).To(Equal(linux_container.StatePaused))
+			})
+		})
+
+		Context("when the container is stopped while paused", func() {
+			It("thaws it first, so that its processes can be signalled", func() {
+				err := container.Stop(false)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(fakeCgroups.SetValues()).To(ContainElement(
+					fake_cgroups_manager.SetValue{
+						Subsystem: "freezer",
+						Name:      "freezer.state",
+						Value:     "THAWED",
+					},
+				))
+
+				Expect(container.State()).To(Equal(linux_container.StateStopped))
+			})
+		})
+	})
+
+	Describe("Cleaning up", func() {
+		Context("when the container has an oom notifier running", func() {
+			JustBeforeEach(func() {
+				err := container.LimitMemory(garden.MemoryLimits{
+					LimitInBytes: 42,
+				})
+
+				Expect(err).ToNot(HaveOccurred())
+			})
+
+			It("stops it", func() {
+				container.Cleanup()
+
+				Expect(fakeRunner).To(HaveKilled(fake_command_runner.CommandSpec{
+					Path: containerDir + "/bin/oom",
+				}))
+
+			})
+		})
+	})
+
+	Describe("Streaming data in", func() {
+		It("streams the input to tar xf in the container", func() {
+			fakeRunner.WhenRunning(
+				fake_command_runner.CommandSpec{
+					Path: containerDir + "/bin/nstar",
+					Args: []string{
+						"12345",
+						"vcap",
+						"/some/directory/dst",
+					},
+				},
+				func(cmd *exec.Cmd) error {
+					bytes, err := ioutil.ReadAll(cmd.Stdin)
+					Expect(err).ToNot(HaveOccurred())
+
+					Expect(string(bytes)).To(Equal("the-tar-content"))
+
+					return nil
+				},
+			)
+
+			err := container.StreamIn("/some/directory/dst", bytes.NewBufferString("the-tar-content"))
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		Context("when tar fails", func() {
+			disaster := errors.New("oh no!")
+
+			JustBeforeEach(func() {
+				fakeRunner.WhenRunning(
+					fake_command_runner.CommandSpec{
+						Path: containerDir + "/bin/nstar",
+					},
+					func(cmd *exec.Cmd) error {
+						return disaster
+					},
+				)
+			})
+
+			It("returns the error", func() {
+				err := container.StreamIn("/some/directory/dst", nil)
+				Expect(err).To(Equal(disaster))
+			})
+		})
+	})
+
+	Describe("Streaming out", func() {
+		It("streams the output of tar cf to the destination", func() {
+			fakeRunner.WhenRunning(
+				fake_command_runner.CommandSpec{
+					Path: containerDir + "/bin/nstar",
+					Args: []string{
+						"12345",
+						"vcap",
+						"/some/directory",
+						"dst",
+					},
+				},
+				func(cmd *exec.Cmd) error {
+					_, err := cmd.Stdout.Write([]byte("the-compressed-content"))
+					Expect(err).ToNot(HaveOccurred())
+
+					return nil
+				},
+			)
+
+			reader, err := container.StreamOut("/some/directory/dst")
+			Expect(err).ToNot(HaveOccurred())
+
+			bytes, err := ioutil.ReadAll(reader)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(bytes)).To(Equal("the-compressed-content"))
+		})
+
+		It("closes the server-side dupe of of the pipe's write end", func() {
+			var outPipe io.Writer
+
+			fakeRunner.WhenRunning(
+				fake_command_runner.CommandSpec{
+					Path: containerDir + "/bin/nstar",
+					Args: []string{
+						"12345",
+						"vcap",
+						"/some/directory",
+						"dst",
+					},
+				},
+				func(cmd *exec.Cmd) error {
+					outPipe = cmd.Stdout
+					return nil
+				},
+			)
+
+			_, err := container.StreamOut("/some/directory/dst")
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(outPipe).ToNot(BeNil())
+
+			_, err = outPipe.Write([]byte("sup"))
+			Expect(err).To(HaveOccurred())
+		})
+
+		Context("when there's a trailing slash", func() {
+			It("compresses the directory's contents", func() {
+				_, err := container.StreamOut("/some/directory/dst/")
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(fakeRunner).To(HaveBackgrounded(
+					fake_command_runner.CommandSpec{
+						Path: containerDir + "/bin/nstar",
+						Args: []string{
+							"12345",
+							"vcap",
+							"/some/directory/dst/",
+							".",
+						},
+					},
+				))
+			})
+		})
+
+		Context("when executing the command fails", func() {
+			disaster := errors.New("oh no!")
+
+			JustBeforeEach(func() {
+				fakeRunner.WhenRunning(
+					fake_command_runner.CommandSpec{
+						Path: containerDir + "/bin/nstar",
+					}, func(*exec.Cmd) error {
+						return disaster
+					},
+				)
+			})
+
+			It("returns the error", func() {
+				_, err := container.StreamOut("/some/dst")
+				Expect(err).To(Equal(disaster))
+			})
+		})
+	})
+
+	Describe("Net in", func() {
+		It("forwards the host port on the external IP to the container port", func() {
+			hostPort, containerPort, err := container.NetIn(123, 456)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(fakeInstanceChain.AppendPortForwardCallCount()).To(Equal(1))
+			Expect(fakeInstanceChain.AppendPortForwardArgsForCall(0)).To(Equal(iptables.PortForwardSpec{
+				ExternalIP:    net.ParseIP("5.6.7.8"),
+				HostPort:      123,
+				ContainerIP:   net.ParseIP("1.2.3.4"),
+				ContainerPort: 456,
+				Protocol:      garden.ProtocolTCP,
+			}))
+
+			Expect(hostPort).To(Equal(uint32(123)))
+			Expect(containerPort).To(Equal(uint32(456)))
+		})
+
+		Context("when a host port is not provided", func() {
+			It("acquires one from the port pool", func() {
+				hostPort, containerPort, err := container.NetIn(0, 456)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(hostPort).To(Equal(uint32(1000)))
+				Expect(containerPort).To(Equal(uint32(456)))
+
+				secondHostPort, _, err := container.NetIn(0, 456)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(secondHostPort).ToNot(Equal(hostPort))
+
+				Expect(container.Resources().Ports).To(ContainElement(hostPort))
 			})
 
 			Context("and acquiring a port from the pool fails", func() {
@@ -479,17 +993,9 @@ var _ = Describe("Linux containers", func() {
 				hostPort, containerPort, err := container.NetIn(123, 0)
 				Expect(err).ToNot(HaveOccurred())
 
-				Expect(fakeRunner).To(HaveExecutedSerially(
-					fake_command_runner.CommandSpec{
-						Path: containerDir + "/net.sh",
-						Args: []string{"in"},
-						Env: []string{
-							"HOST_PORT=123",
-							"CONTAINER_PORT=123",
-							"PATH=" + os.Getenv("PATH"),
-						},
-					},
-				))
+				spec := fakeInstanceChain.AppendPortForwardArgsForCall(0)
+				Expect(spec.HostPort).To(Equal(uint32(123)))
+				Expect(spec.ContainerPort).To(Equal(uint32(123)))
 
 				Expect(hostPort).To(Equal(uint32(123)))
 				Expect(containerPort).To(Equal(uint32(123)))
@@ -500,17 +1006,9 @@ var _ = Describe("Linux containers", func() {
 					hostPort, containerPort, err := container.NetIn(0, 0)
 					Expect(err).ToNot(HaveOccurred())
 
-					Expect(fakeRunner).To(HaveExecutedSerially(
-						fake_command_runner.CommandSpec{
-							Path: containerDir + "/net.sh",
-							Args: []string{"in"},
-							Env: []string{
-								"HOST_PORT=1000",
-								"CONTAINER_PORT=1000",
-								"PATH=" + os.Getenv("PATH"),
-							},
-						},
-					))
+					spec := fakeInstanceChain.AppendPortForwardArgsForCall(0)
+					Expect(spec.HostPort).To(Equal(uint32(1000)))
+					Expect(spec.ContainerPort).To(Equal(uint32(1000)))
 
 					Expect(hostPort).To(Equal(uint32(1000)))
 					Expect(containerPort).To(Equal(uint32(1000)))
@@ -518,17 +1016,11 @@ var _ = Describe("Linux containers", func() {
 			})
 		})
 
-		Context("when net.sh fails", func() {
+		Context("when forwarding the port fails", func() {
 			disaster := errors.New("oh no!")
 
 			JustBeforeEach(func() {
-				fakeRunner.WhenRunning(
-					fake_command_runner.CommandSpec{
-						Path: containerDir + "/net.sh",
-					}, func(*exec.Cmd) error {
-						return disaster
-					},
-				)
+				fakeInstanceChain.AppendPortForwardReturns(disaster)
 			})
 
 			It("returns the error", func() {
@@ -536,6 +1028,196 @@ var _ = Describe("Linux containers", func() {
 				Expect(err).To(Equal(disaster))
 			})
 		})
+
+		Context("with a protocol", func() {
+			It("forwards the port for that protocol", func() {
+				_, _, err := container.NetInWithProtocol(123, 456, garden.ProtocolUDP)
+				Expect(err).ToNot(HaveOccurred())
+
+				spec := fakeInstanceChain.AppendPortForwardArgsForCall(0)
+				Expect(spec.Protocol).To(Equal(garden.ProtocolUDP))
+
+				Expect(container.NetIns()).To(Equal([]linux_backend.PortMapping{
+					{HostPort: 123, ContainerPort: 456, Protocol: garden.ProtocolUDP},
+				}))
+			})
+
+			Context("when the protocol cannot be mapped", func() {
+				It("returns an UnsupportedNetInProtocolError without acquiring a port", func() {
+					_, _, err := container.NetInWithProtocol(0, 456, garden.ProtocolICMP)
+					Expect(err).To(Equal(linux_container.UnsupportedNetInProtocolError{Protocol: garden.ProtocolICMP}))
+
+					Expect(container.Resources().Ports).To(BeEmpty())
+					Expect(fakeInstanceChain.AppendPortForwardCallCount()).To(Equal(0))
+				})
+			})
+		})
+
+		Context("on an external IP", func() {
+			BeforeEach(func() {
+				containerResources.AdditionalExternalIPs = []net.IP{net.ParseIP("5.6.7.9")}
+			})
+
+			It("forwards the port on that IP", func() {
+				_, _, err := container.NetInOnExternalIP(net.ParseIP("5.6.7.9"), 123, 456, garden.ProtocolTCP)
+				Expect(err).ToNot(HaveOccurred())
+
+				spec := fakeInstanceChain.AppendPortForwardArgsForCall(0)
+				Expect(spec.ExternalIP).To(Equal(net.ParseIP("5.6.7.9")))
+
+				Expect(container.NetIns()).To(Equal([]linux_backend.PortMapping{
+					{HostPort: 123, ContainerPort: 456, Protocol: garden.ProtocolTCP, ExternalIP: net.ParseIP("5.6.7.9")},
+				}))
+			})
+
+			It("removes the mapping from the IP it was made on", func() {
+				_, _, err := container.NetInOnExternalIP(net.ParseIP("5.6.7.9"), 123, 456, garden.ProtocolTCP)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(container.NetInRemove(123)).To(Succeed())
+				Expect(fakeInstanceChain.DeletePortForwardArgsForCall(0).ExternalIP).To(Equal(net.ParseIP("5.6.7.9")))
+			})
+
+			Context("when the IP is the default external IP", func() {
+				It("maps the port as if no IP was given", func() {
+					_, _, err := container.NetInOnExternalIP(net.ParseIP("5.6.7.8"), 123, 456, garden.ProtocolTCP)
+					Expect(err).ToNot(HaveOccurred())
+
+					Expect(fakeInstanceChain.AppendPortForwardArgsForCall(0).ExternalIP).To(Equal(net.ParseIP("5.6.7.8")))
+					Expect(container.NetIns()).To(Equal([]linux_backend.PortMapping{
+						{HostPort: 123, ContainerPort: 456, Protocol: garden.ProtocolTCP},
+					}))
+				})
+			})
+
+			Context("when ports cannot be mapped on the IP", func() {
+				It("returns an UnknownExternalIPError without acquiring a port", func() {
+					_, _, err := container.NetInOnExternalIP(net.ParseIP("9.9.9.9"), 0, 456, garden.ProtocolTCP)
+					Expect(err).To(Equal(linux_container.UnknownExternalIPError{IP: net.ParseIP("9.9.9.9")}))
+					Expect(err).To(MatchError("ports cannot be mapped on IP: 9.9.9.9"))
+
+					Expect(container.Resources().Ports).To(BeEmpty())
+					Expect(fakeInstanceChain.AppendPortForwardCallCount()).To(Equal(0))
+				})
+			})
+		})
+	})
+
+	Describe("Listing net ins", func() {
+		It("returns the mapped ports", func() {
+			Expect(container.NetIns()).To(BeEmpty())
+
+			_, _, err := container.NetIn(123, 456)
+			Expect(err).ToNot(HaveOccurred())
+
+			_, _, err = container.NetIn(0, 789)
+			Expect(err).ToNot(HaveOccurred())
+
+			_, _, err = container.NetInWithProtocol(53, 53, garden.ProtocolAll)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(container.NetIns()).To(Equal([]linux_backend.PortMapping{
+				{HostPort: 123, ContainerPort: 456, Protocol: garden.ProtocolTCP},
+				{HostPort: 1000, ContainerPort: 789, Protocol: garden.ProtocolTCP},
+				{HostPort: 53, ContainerPort: 53, Protocol: garden.ProtocolAll},
+			}))
+		})
+	})
+
+	Describe("Removing a net in", func() {
+		It("deletes the port forward", func() {
+			_, _, err := container.NetIn(123, 456)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(container.NetInRemove(123)).To(Succeed())
+
+			Expect(fakeInstanceChain.DeletePortForwardCallCount()).To(Equal(1))
+			Expect(fakeInstanceChain.DeletePortForwardArgsForCall(0)).To(Equal(iptables.PortForwardSpec{
+				ExternalIP:    net.ParseIP("5.6.7.8"),
+				HostPort:      123,
+				ContainerIP:   net.ParseIP("1.2.3.4"),
+				ContainerPort: 456,
+				Protocol:      garden.ProtocolTCP,
+			}))
+		})
+
+		It("removes only the mappings of the host port", func() {
+			_, _, err := container.NetIn(123, 456)
+			Expect(err).ToNot(HaveOccurred())
+
+			_, _, err = container.NetIn(124, 456)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(container.NetInRemove(123)).To(Succeed())
+
+			Expect(container.NetIns()).To(Equal([]linux_backend.PortMapping{
+				{HostPort: 124, ContainerPort: 456, Protocol: garden.ProtocolTCP},
+			}))
+		})
+
+		It("saves the snapshot", func() {
+			_, _, err := container.NetIn(123, 456)
+			Expect(err).ToNot(HaveOccurred())
+
+			saves := fakeSnapshotSaver.SaveCallCount()
+
+			Expect(container.NetInRemove(123)).To(Succeed())
+			Expect(fakeSnapshotSaver.SaveCallCount()).To(Equal(saves + 1))
+		})
+
+		It("does not release a port which was not acquired from the pool", func() {
+			_, _, err := container.NetIn(123, 456)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(container.NetInRemove(123)).To(Succeed())
+
+			Expect(fakePortPool.Released).To(BeEmpty())
+		})
+
+		Context("when the host port was acquired from the pool", func() {
+			It("releases it back to the pool", func() {
+				hostPort, _, err := container.NetIn(0, 456)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(container.NetInRemove(hostPort)).To(Succeed())
+
+				Expect(fakePortPool.Released).To(Equal([]uint32{hostPort}))
+				Expect(container.Resources().Ports).ToNot(ContainElement(hostPort))
+			})
+		})
+
+		Context("when the host port is not mapped", func() {
+			It("returns a NetInNotFoundError", func() {
+				err := container.NetInRemove(123)
+				Expect(err).To(Equal(linux_container.NetInNotFoundError{HostPort: 123}))
+				Expect(err).To(MatchError("host port is not mapped: 123"))
+			})
+		})
+
+		Context("when deleting the port forward fails", func() {
+			disaster := errors.New("oh no!")
+
+			JustBeforeEach(func() {
+				fakeInstanceChain.DeletePortForwardReturns(disaster)
+			})
+
+			It("returns the error", func() {
+				hostPort, _, err := container.NetIn(0, 456)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(container.NetInRemove(hostPort)).To(Equal(disaster))
+			})
+
+			It("keeps the mapping and the port", func() {
+				hostPort, _, err := container.NetIn(0, 456)
+				Expect(err).ToNot(HaveOccurred())
+
+				container.NetInRemove(hostPort)
+
+				Expect(container.NetIns()).To(HaveLen(1))
+				Expect(fakePortPool.Released).To(BeEmpty())
+			})
+		})
 	})
 
 	Describe("Net out", func() {
@@ -563,6 +1245,350 @@ var _ = Describe("Linux containers", func() {
 		})
 	})
 
+	Describe("Bulk net out", func() {
+		rules := []garden.NetOutRule{
+			{Protocol: garden.ProtocolTCP},
+			{Protocol: garden.ProtocolUDP},
+		}
+
+		It("delegates all of the rules to the filter at once", func() {
+			Expect(container.BulkNetOut(rules)).To(Succeed())
+
+			Expect(fakeFilter.BulkNetOutCallCount()).To(Equal(1))
+			Expect(fakeFilter.BulkNetOutArgsForCall(0)).To(Equal(rules))
+			Expect(fakeFilter.NetOutCallCount()).To(Equal(0))
+		})
+
+		It("lists the rules, and saves them in one snapshot", func() {
+			saves := fakeSnapshotSaver.SaveCallCount()
+
+			Expect(container.BulkNetOut(rules)).To(Succeed())
+
+			Expect(container.NetOutRules()).To(Equal(rules))
+			Expect(fakeSnapshotSaver.SaveCallCount()).To(Equal(saves + 1))
+		})
+
+		Context("when the filter fails", func() {
+			disaster := errors.New("oh no!")
+
+			BeforeEach(func() {
+				fakeFilter.BulkNetOutReturns(disaster)
+			})
+
+			It("returns the error without granting any of the rules", func() {
+				saves := fakeSnapshotSaver.SaveCallCount()
+
+				Expect(container.BulkNetOut(rules)).To(Equal(disaster))
+
+				Expect(container.NetOutRules()).To(BeEmpty())
+				Expect(fakeSnapshotSaver.SaveCallCount()).To(Equal(saves))
+			})
+		})
+	})
+
+	Describe("Listing net outs", func() {
+		It("returns the granted rules, in order", func() {
+			Expect(container.NetOutRules()).To(BeEmpty())
+
+			tcp := garden.NetOutRule{Protocol: garden.ProtocolTCP}
+			udp := garden.NetOutRule{Protocol: garden.ProtocolUDP}
+
+			Expect(container.NetOut(tcp)).To(Succeed())
+			Expect(container.NetOut(udp)).To(Succeed())
+
+			Expect(container.NetOutRules()).To(Equal([]garden.NetOutRule{tcp, udp}))
+		})
+	})
+
+	Describe("Removing a net out", func() {
+		var rule garden.NetOutRule
+
+		BeforeEach(func() {
+			rule = garden.NetOutRule{
+				Protocol: garden.ProtocolTCP,
+				Networks: []garden.IPRange{{Start: net.ParseIP("1.2.3.4")}},
+				Ports:    []garden.PortRange{{Start: 80, End: 80}},
+			}
+		})
+
+		It("deletes the rule with the filter", func() {
+			Expect(container.NetOut(rule)).To(Succeed())
+			Expect(container.NetOutRemove(rule)).To(Succeed())
+
+			Expect(fakeFilter.NetOutRemoveCallCount()).To(Equal(1))
+			Expect(fakeFilter.NetOutRemoveArgsForCall(0)).To(Equal(rule))
+		})
+
+		It("stops listing the rule", func() {
+			other := garden.NetOutRule{Protocol: garden.ProtocolUDP}
+
+			Expect(container.NetOut(rule)).To(Succeed())
+			Expect(container.NetOut(other)).To(Succeed())
+			Expect(container.NetOutRemove(rule)).To(Succeed())
+
+			Expect(container.NetOutRules()).To(Equal([]garden.NetOutRule{other}))
+		})
+
+		It("revokes one grant of a rule granted more than once", func() {
+			Expect(container.NetOut(rule)).To(Succeed())
+			Expect(container.NetOut(rule)).To(Succeed())
+			Expect(container.NetOutRemove(rule)).To(Succeed())
+
+			Expect(container.NetOutRules()).To(Equal([]garden.NetOutRule{rule}))
+		})
+
+		It("matches a rule restored from a snapshot, with 16 byte IPs and empty lists", func() {
+			code := garden.ICMPCode(0)
+			granted := garden.NetOutRule{
+				Protocol: garden.ProtocolICMP,
+				Networks: []garden.IPRange{{Start: net.ParseIP("1.2.3.4").To4()}},
+				ICMPs:    &garden.ICMPControl{Type: 8, Code: &code},
+			}
+
+			Expect(container.NetOut(granted)).To(Succeed())
+
+			otherCode := garden.ICMPCode(0)
+			Expect(container.NetOutRemove(garden.NetOutRule{
+				Protocol: garden.ProtocolICMP,
+				Networks: []garden.IPRange{{Start: net.ParseIP("1.2.3.4")}},
+				Ports:    []garden.PortRange{},
+				ICMPs:    &garden.ICMPControl{Type: 8, Code: &otherCode},
+			})).To(Succeed())
+
+			Expect(container.NetOutRules()).To(BeEmpty())
+		})
+
+		It("does not match a rule which differs", func() {
+			Expect(container.NetOut(rule)).To(Succeed())
+
+			other := rule
+			other.Networks = []garden.IPRange{{Start: net.ParseIP("1.2.3.5")}}
+
+			Expect(container.NetOutRemove(other)).To(Equal(linux_container.NetOutNotFoundError{Rule: other}))
+		})
+
+		It("saves the snapshot", func() {
+			Expect(container.NetOut(rule)).To(Succeed())
+
+			saves := fakeSnapshotSaver.SaveCallCount()
+
+			Expect(container.NetOutRemove(rule)).To(Succeed())
+			Expect(fakeSnapshotSaver.SaveCallCount()).To(Equal(saves + 1))
+		})
+
+		Context("when the rule was not granted", func() {
+			It("returns a NetOutNotFoundError without touching the filter", func() {
+				err := container.NetOutRemove(rule)
+				Expect(err).To(Equal(linux_container.NetOutNotFoundError{Rule: rule}))
+
+				Expect(fakeFilter.NetOutRemoveCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when the filter fails", func() {
+			disaster := errors.New("oh no!")
+
+			BeforeEach(func() {
+				fakeFilter.NetOutRemoveReturns(disaster)
+			})
+
+			It("returns the error and keeps the rule", func() {
+				Expect(container.NetOut(rule)).To(Succeed())
+
+				Expect(container.NetOutRemove(rule)).To(Equal(disaster))
+				Expect(container.NetOutRules()).To(Equal([]garden.NetOutRule{rule}))
+			})
+		})
+	})
+
+	Describe("Traffic policies", func() {
+		var policy linux_backend.TrafficPolicy
+
+		BeforeEach(func() {
+			policy = linux_backend.TrafficPolicy{
+				SourceHandle:      "some-handle",
+				DestinationHandle: "other-handle",
+				Protocol:          garden.ProtocolTCP,
+				Port:              8080,
+			}
+		})
+
+		ips := func(addresses ...string) []net.IP {
+			parsed := []net.IP{}
+			for _, address := range addresses {
+				parsed = append(parsed, net.ParseIP(address))
+			}
+
+			return parsed
+		}
+
+		ruleFor := func(addresses ...string) garden.NetOutRule {
+			rule := garden.NetOutRule{
+				Protocol: garden.ProtocolTCP,
+				Ports:    []garden.PortRange{{Start: 8080, End: 8080}},
+			}
+
+			for _, ip := range ips(addresses...) {
+				rule.Networks = append(rule.Networks, garden.IPRange{Start: ip})
+			}
+
+			return rule
+		}
+
+		Describe("allowing traffic", func() {
+			It("applies a rule for the port of the destination IP with the filter", func() {
+				Expect(container.AllowTraffic(policy, ips("10.2.0.6"))).To(Succeed())
+
+				Expect(fakeFilter.NetOutCallCount()).To(Equal(1))
+				Expect(fakeFilter.NetOutArgsForCall(0)).To(Equal(ruleFor("10.2.0.6")))
+			})
+
+			It("applies a single rule for all of the destination's IPs", func() {
+				Expect(container.AllowTraffic(policy, ips("10.2.0.6", "fd00::6"))).To(Succeed())
+
+				Expect(fakeFilter.NetOutCallCount()).To(Equal(1))
+				Expect(fakeFilter.NetOutArgsForCall(0)).To(Equal(ruleFor("10.2.0.6", "fd00::6")))
+			})
+
+			It("applies a rule for every port for a policy without a port", func() {
+				policy.Protocol = garden.ProtocolICMP
+				policy.Port = 0
+
+				Expect(container.AllowTraffic(policy, ips("10.2.0.6"))).To(Succeed())
+
+				Expect(fakeFilter.NetOutCallCount()).To(Equal(1))
+				Expect(fakeFilter.NetOutArgsForCall(0)).To(Equal(garden.NetOutRule{
+					Protocol: garden.ProtocolICMP,
+					Networks: []garden.IPRange{{Start: net.ParseIP("10.2.0.6")}},
+				}))
+			})
+
+			Context("when the container is not the source of the policy", func() {
+				BeforeEach(func() {
+					policy.SourceHandle = "other-handle"
+				})
+
+				It("returns a TrafficPolicySourceMismatchError without touching the filter", func() {
+					err := container.AllowTraffic(policy, ips("10.2.0.6"))
+					Expect(err).To(Equal(linux_container.TrafficPolicySourceMismatchError{
+						Policy: policy,
+						Handle: "some-handle",
+					}))
+
+					Expect(fakeFilter.NetOutCallCount()).To(Equal(0))
+					Expect(container.TrafficPolicies()).To(BeEmpty())
+				})
+			})
+
+			It("lists the policy, but not its rule as a net out", func() {
+				Expect(container.AllowTraffic(policy, ips("10.2.0.6"))).To(Succeed())
+
+				Expect(container.TrafficPolicies()).To(Equal([]linux_backend.TrafficPolicy{policy}))
+				Expect(container.NetOutRules()).To(BeEmpty())
+			})
+
+			It("saves the snapshot", func() {
+				saves := fakeSnapshotSaver.SaveCallCount()
+
+				Expect(container.AllowTraffic(policy, ips("10.2.0.6"))).To(Succeed())
+				Expect(fakeSnapshotSaver.SaveCallCount()).To(Equal(saves + 1))
+			})
+
+			It("does nothing if the policy is already allowed for the IP", func() {
+				Expect(container.AllowTraffic(policy, ips("10.2.0.6"))).To(Succeed())
+				Expect(container.AllowTraffic(policy, ips("10.2.0.6"))).To(Succeed())
+
+				Expect(fakeFilter.NetOutCallCount()).To(Equal(1))
+				Expect(container.TrafficPolicies()).To(HaveLen(1))
+			})
+
+			Context("when the policy is already allowed for another IP", func() {
+				It("replaces the rule for the old IP", func() {
+					Expect(container.AllowTraffic(policy, ips("10.2.0.6"))).To(Succeed())
+					Expect(container.AllowTraffic(policy, ips("10.2.0.10"))).To(Succeed())
+
+					Expect(fakeFilter.NetOutCallCount()).To(Equal(2))
+					Expect(fakeFilter.NetOutArgsForCall(1)).To(Equal(ruleFor("10.2.0.10")))
+
+					Expect(fakeFilter.NetOutRemoveCallCount()).To(Equal(1))
+					Expect(fakeFilter.NetOutRemoveArgsForCall(0)).To(Equal(ruleFor("10.2.0.6")))
+
+					Expect(container.TrafficPolicies()).To(HaveLen(1))
+				})
+			})
+
+			Context("when the destination has gained an IPv6 address", func() {
+				It("replaces the rule for its IPv4 address alone", func() {
+					Expect(container.AllowTraffic(policy, ips("10.2.0.6"))).To(Succeed())
+					Expect(container.AllowTraffic(policy, ips("10.2.0.6", "fd00::6"))).To(Succeed())
+
+					Expect(fakeFilter.NetOutCallCount()).To(Equal(2))
+					Expect(fakeFilter.NetOutArgsForCall(1)).To(Equal(ruleFor("10.2.0.6", "fd00::6")))
+
+					Expect(fakeFilter.NetOutRemoveCallCount()).To(Equal(1))
+					Expect(fakeFilter.NetOutRemoveArgsForCall(0)).To(Equal(ruleFor("10.2.0.6")))
+				})
+			})
+
+			Context("when the filter fails", func() {
+				disaster := errors.New("oh no!")
+
+				BeforeEach(func() {
+					fakeFilter.NetOutReturns(disaster)
+				})
+
+				It("returns the error without listing the policy", func() {
+					Expect(container.AllowTraffic(policy, ips("10.2.0.6"))).To(Equal(disaster))
+					Expect(container.TrafficPolicies()).To(BeEmpty())
+				})
+			})
+		})
+
+		Describe("denying traffic", func() {
+			It("deletes the rule with the filter and stops listing the policy", func() {
+				Expect(container.AllowTraffic(policy, ips("10.2.0.6"))).To(Succeed())
+				Expect(container.DenyTraffic(policy)).To(Succeed())
+
+				Expect(fakeFilter.NetOutRemoveCallCount()).To(Equal(1))
+				Expect(fakeFilter.NetOutRemoveArgsForCall(0)).To(Equal(ruleFor("10.2.0.6")))
+
+				Expect(container.TrafficPolicies()).To(BeEmpty())
+			})
+
+			It("deletes the rule for all of the destination's IPs", func() {
+				Expect(container.AllowTraffic(policy, ips("10.2.0.6", "fd00::6"))).To(Succeed())
+				Expect(container.DenyTraffic(policy)).To(Succeed())
+
+				Expect(fakeFilter.NetOutRemoveCallCount()).To(Equal(1))
+				Expect(fakeFilter.NetOutRemoveArgsForCall(0)).To(Equal(ruleFor("10.2.0.6", "fd00::6")))
+			})
+
+			Context("when the policy was not allowed", func() {
+				It("returns a TrafficPolicyNotFoundError without touching the filter", func() {
+					err := container.DenyTraffic(policy)
+					Expect(err).To(Equal(linux_container.TrafficPolicyNotFoundError{Policy: policy}))
+
+					Expect(fakeFilter.NetOutRemoveCallCount()).To(Equal(0))
+				})
+			})
+
+			Context("when the filter fails", func() {
+				disaster := errors.New("oh no!")
+
+				BeforeEach(func() {
+					fakeFilter.NetOutRemoveReturns(disaster)
+				})
+
+				It("returns the error and keeps the policy", func() {
+					Expect(container.AllowTraffic(policy, ips("10.2.0.6"))).To(Succeed())
+
+					Expect(container.DenyTraffic(policy)).To(Equal(disaster))
+					Expect(container.TrafficPolicies()).To(Equal([]linux_backend.TrafficPolicy{policy}))
+				})
+			})
+		})
+	})
+
 	Describe("Properties", func() {
 		Describe("CRUD", func() {
 			It("can get a property", func() {
@@ -682,6 +1708,414 @@ var _ = Describe("Linux containers", func() {
 		})
 	})
 
+	Describe("Emitting events", func() {
+		emittedTypes := func() []linux_backend.EventType {
+			types := []linux_backend.EventType{}
+			for i := 0; i < fakeEventEmitter.EmitCallCount(); i++ {
+				types = append(types, fakeEventEmitter.EmitArgsForCall(i).Type)
+			}
+
+			return types
+		}
+
+		It("emits a started event when started", func() {
+			Expect(container.Start()).To(Succeed())
+
+			Expect(emittedTypes()).To(Equal([]linux_backend.EventType{linux_backend.EventStarted}))
+
+			event := fakeEventEmitter.EmitArgsForCall(0)
+			Expect(event.Handle).To(Equal("some-handle"))
+			Expect(event.Properties).To(Equal(garden.Properties{"property-name": "property-value"}))
+		})
+
+		It("emits a stopped event when stopped", func() {
+			Expect(container.Stop(false)).To(Succeed())
+
+			Expect(emittedTypes()).To(Equal([]linux_backend.EventType{linux_backend.EventStopped}))
+		})
+
+		It("emits a limit-changed event when a limit is set", func() {
+			Expect(container.LimitCPU(garden.CPULimits{LimitInShares: 1})).To(Succeed())
+
+			Expect(emittedTypes()).To(Equal([]linux_backend.EventType{linux_backend.EventLimitChanged}))
+			Expect(fakeEventEmitter.EmitArgsForCall(0).Data).To(Equal(map[string]string{"limit": "cpu"}))
+		})
+
+		It("emits a net-rule-added event when a port is mapped", func() {
+			_, _, err := container.NetIn(123, 456)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(emittedTypes()).To(Equal([]linux_backend.EventType{linux_backend.EventNetRuleAdded}))
+			Expect(fakeEventEmitter.EmitArgsForCall(0).Data).To(Equal(map[string]string{
+				"rule":           "net-in",
+				"host-port":      "123",
+				"container-port": "456",
+				"protocol":       "tcp",
+			}))
+		})
+
+		It("reports the external IP of a port mapped on an additional one", func() {
+			containerResources.AdditionalExternalIPs = []net.IP{net.ParseIP("5.6.7.9")}
+
+			_, _, err := container.NetInOnExternalIP(net.ParseIP("5.6.7.9"), 123, 456, garden.ProtocolTCP)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(fakeEventEmitter.EmitArgsForCall(0).Data).To(HaveKeyWithValue("external-ip", "5.6.7.9"))
+		})
+
+		It("emits a net-rule-removed event when a port is unmapped", func() {
+			_, _, err := container.NetIn(123, 456)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(container.NetInRemove(123)).To(Succeed())
+
+			Expect(emittedTypes()).To(Equal([]linux_backend.EventType{
+				linux_backend.EventNetRuleAdded,
+				linux_backend.EventNetRuleRemoved,
+			}))
+			Expect(fakeEventEmitter.EmitArgsForCall(1).Data).To(Equal(map[string]string{
+				"rule":      "net-in",
+				"host-port": "123",
+			}))
+		})
+
+		It("emits a net-rule-added event when outbound traffic is allowed", func() {
+			Expect(container.NetOut(garden.NetOutRule{Protocol: garden.ProtocolTCP})).To(Succeed())
+
+			Expect(emittedTypes()).To(Equal([]linux_backend.EventType{linux_backend.EventNetRuleAdded}))
+			Expect(fakeEventEmitter.EmitArgsForCall(0).Data).To(HaveKeyWithValue("rule", "net-out"))
+		})
+
+		It("emits a process-exited event when a process exits", func() {
+			process := new(wfakes.FakeProcess)
+			process.IDReturns(42)
+			process.WaitReturns(3, nil)
+
+			fakeProcessTracker.RunReturns(process, nil)
+
+			_, err := container.Run(garden.ProcessSpec{Path: "/some/script"}, garden.ProcessIO{})
+			Expect(err).ToNot(HaveOccurred())
+
+			Eventually(emittedTypes).Should(Equal([]linux_backend.EventType{linux_backend.EventProcessExited}))
+			Expect(fakeEventEmitter.EmitArgsForCall(0).Data).To(Equal(map[string]string{
+				"process-id":  "42",
+				"exit-status": "3",
+			}))
+		})
+
+		It("emits a net-rule-removed event when a net out is revoked", func() {
+			Expect(container.NetOut(garden.NetOutRule{Protocol: garden.ProtocolTCP})).To(Succeed())
+			Expect(container.NetOutRemove(garden.NetOutRule{Protocol: garden.ProtocolTCP})).To(Succeed())
+
+			Expect(emittedTypes()).To(Equal([]linux_backend.EventType{
+				linux_backend.EventNetRuleAdded,
+				linux_backend.EventNetRuleRemoved,
+			}))
+			Expect(fakeEventEmitter.EmitArgsForCall(1).Data).To(Equal(map[string]string{
+				"rule":     "net-out",
+				"protocol": "1",
+			}))
+		})
+
+		It("emits an oom event when the container runs out of memory", func() {
+			Expect(container.LimitMemory(garden.MemoryLimits{LimitInBytes: 42})).To(Succeed())
+
+			// oom will exit immediately as the command runner is faked out
+			Eventually(emittedTypes).Should(ContainElement(linux_backend.EventOOM))
+		})
+
+		Context("when the operation fails", func() {
+			BeforeEach(func() {
+				fakeFilter.NetOutReturns(errors.New("oh no!"))
+			})
+
+			It("emits nothing", func() {
+				Expect(container.NetOut(garden.NetOutRule{})).ToNot(Succeed())
+
+				Expect(fakeEventEmitter.EmitCallCount()).To(Equal(0))
+			})
+		})
+	})
+
+	Describe("Checkpointing", func() {
+		var imagesDir string
+
+		BeforeEach(func() {
+			imagesDir = filepath.Join(containerDir, "checkpoint")
+		})
+
+		It("dumps the processes under wshd with criu", func() {
+			err := container.Checkpoint(imagesDir)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(fakeRunner).To(HaveExecutedSerially(
+				fake_command_runner.CommandSpec{
+					Path: "criu",
+					Args: []string{
+						"dump",
+						"--tree", "12345",
+						"--images-dir", imagesDir,
+						"--manage-cgroups",
+						"--tcp-established",
+						"--ext-unix-sk",
+						"--file-locks",
+					},
+				},
+			))
+		})
+
+		It("creates the images directory", func() {
+			err := container.Checkpoint(imagesDir)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(imagesDir).To(BeADirectory())
+		})
+
+		It("transitions to the checkpointed state", func() {
+			err := container.Checkpoint(imagesDir)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(container.State()).To(Equal(linux_container.StateCheckpointed))
+		})
+
+		It("records the checkpoint in the container's snapshot", func() {
+			err := container.Checkpoint(imagesDir)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(fakeSnapshotSaver.SaveCallCount()).To(Equal(1))
+
+			out := new(bytes.Buffer)
+			Expect(container.Snapshot(out)).To(Succeed())
+
+			snapshot, err := linux_container.DecodeSnapshot(out)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(snapshot.Checkpoint).To(Equal(imagesDir))
+		})
+
+		Context("when dumping fails", func() {
+			disaster := errors.New("oh no!")
+
+			BeforeEach(func() {
+				fakeRunner.WhenRunning(
+					fake_command_runner.CommandSpec{
+						Path: "criu",
+					}, func(*exec.Cmd) error {
+						return disaster
+					},
+				)
+			})
+
+			It("returns the error", func() {
+				err := container.Checkpoint(imagesDir)
+				Expect(err).To(Equal(disaster))
+			})
+
+			It("does not transition to the checkpointed state", func() {
+				container.Checkpoint(imagesDir)
+
+				Expect(container.State()).To(Equal(linux_container.StateBorn))
+			})
+		})
+
+		Context("when wshd is not running", func() {
+			BeforeEach(func() {
+				err := os.Remove(filepath.Join(containerDir, "run", "wshd.pid"))
+				Expect(err).ToNot(HaveOccurred())
+			})
+
+			It("returns an error without running criu", func() {
+				err := container.Checkpoint(imagesDir)
+				Expect(err).To(HaveOccurred())
+
+				Expect(fakeRunner).ToNot(HaveExecutedSerially(
+					fake_command_runner.CommandSpec{
+						Path: "criu",
+					},
+				))
+			})
+		})
+	})
+
+	Describe("Restoring a checkpoint", func() {
+		Context("when the container was restored with a checkpoint", func() {
+			JustBeforeEach(func() {
+				err := container.Restore(linux_container.ContainerSnapshot{
+					State:      string(linux_container.StateCheckpointed),
+					Checkpoint: "/some/images/dir",
+				})
+				Expect(err).ToNot(HaveOccurred())
+			})
+
+			It("restores the processes with criu, rewriting wshd's pidfile", func() {
+				err := container.RestoreCheckpoint()
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(fakeRunner).To(HaveExecutedSerially(
+					fake_command_runner.CommandSpec{
+						Path: "criu",
+						Args: []string{
+							"restore",
+							"--images-dir", "/some/images/dir",
+							"--restore-detached",
+							"--pidfile", filepath.Join(containerDir, "run", "wshd.pid"),
+							"--manage-cgroups",
+							"--tcp-established",
+							"--ext-unix-sk",
+							"--file-locks",
+						},
+					},
+				))
+			})
+
+			It("transitions to the active state", func() {
+				err := container.RestoreCheckpoint()
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(container.State()).To(Equal(linux_container.StateActive))
+			})
+
+			It("forgets the checkpoint", func() {
+				err := container.RestoreCheckpoint()
+				Expect(err).ToNot(HaveOccurred())
+
+				out := new(bytes.Buffer)
+				Expect(container.Snapshot(out)).To(Succeed())
+
+				snapshot, err := linux_container.DecodeSnapshot(out)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(snapshot.Checkpoint).To(BeEmpty())
+			})
+
+			Context("when restoring fails", func() {
+				disaster := errors.New("oh no!")
+
+				BeforeEach(func() {
+					fakeRunner.WhenRunning(
+						fake_command_runner.CommandSpec{
+							Path: "criu",
+						}, func(*exec.Cmd) error {
+							return disaster
+						},
+					)
+				})
+
+				It("returns the error and stays checkpointed", func() {
+					err := container.RestoreCheckpoint()
+					Expect(err).To(Equal(disaster))
+
+					Expect(container.State()).To(Equal(linux_container.StateCheckpointed))
+				})
+			})
+		})
+
+		Context("when the container has no checkpoint", func() {
+			It("does nothing", func() {
+				err := container.RestoreCheckpoint()
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(fakeRunner).ToNot(HaveExecutedSerially(
+					fake_command_runner.CommandSpec{
+						Path: "criu",
+					},
+				))
+			})
+		})
+	})
+
+	Describe("Saving snapshots", func() {
+		mutations := map[string]func(*linux_container.LinuxContainer) error{
+			"starting": func(c *linux_container.LinuxContainer) error {
+				return c.Start()
+			},
+			"stopping": func(c *linux_container.LinuxContainer) error {
+				return c.Stop(false)
+			},
+			"pausing": func(c *linux_container.LinuxContainer) error {
+				if err := c.Start(); err != nil {
+					return err
+				}
+
+				return c.Pause()
+			},
+			"resuming": func(c *linux_container.LinuxContainer) error {
+				if err := c.Start(); err != nil {
+					return err
+				}
+
+				if err := c.Pause(); err != nil {
+					return err
+				}
+
+				return c.Resume()
+			},
+			"setting a property": func(c *linux_container.LinuxContainer) error {
+				return c.SetProperty("some-property", "some-value")
+			},
+			"removing a property": func(c *linux_container.LinuxContainer) error {
+				return c.RemoveProperty("property-name")
+			},
+			"mapping a port": func(c *linux_container.LinuxContainer) error {
+				_, _, err := c.NetIn(123, 456)
+				return err
+			},
+			"allowing outbound traffic": func(c *linux_container.LinuxContainer) error {
+				return c.NetOut(garden.NetOutRule{})
+			},
+			"limiting memory": func(c *linux_container.LinuxContainer) error {
+				return c.LimitMemory(garden.MemoryLimits{LimitInBytes: 42})
+			},
+			"limiting cpu": func(c *linux_container.LinuxContainer) error {
+				return c.LimitCPU(garden.CPULimits{LimitInShares: 42})
+			},
+			"limiting bandwidth": func(c *linux_container.LinuxContainer) error {
+				return c.LimitBandwidth(garden.BandwidthLimits{RateInBytesPerSecond: 42})
+			},
+			"limiting bandwidth in each direction": func(c *linux_container.LinuxContainer) error {
+				return c.LimitDirectionalBandwidth(bandwidth_manager.Limits{})
+			},
+			"limiting disk": func(c *linux_container.LinuxContainer) error {
+				return c.LimitDisk(garden.DiskLimits{ByteHard: 42})
+			},
+			"running a process": func(c *linux_container.LinuxContainer) error {
+				_, err := c.Run(garden.ProcessSpec{Path: "/some/script"}, garden.ProcessIO{})
+				return err
+			},
+		}
+
+		for description, mutation := range mutations {
+			mutate := mutation
+
+			It("saves the container's snapshot after "+description, func() {
+				Expect(mutate(container)).To(Succeed())
+
+				Expect(fakeSnapshotSaver.SaveCallCount()).To(BeNumerically(">=", 1))
+				Expect(fakeSnapshotSaver.SaveArgsForCall(0)).To(Equal(container))
+			})
+		}
+
+		Context("when the mutation fails", func() {
+			BeforeEach(func() {
+				fakeFilter.NetOutReturns(errors.New("oh no!"))
+			})
+
+			It("does not save the container's snapshot", func() {
+				Expect(container.NetOut(garden.NetOutRule{})).ToNot(Succeed())
+
+				Expect(fakeSnapshotSaver.SaveCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when saving the snapshot fails", func() {
+			BeforeEach(func() {
+				fakeSnapshotSaver.SaveReturns(errors.New("oh no!"))
+			})
+
+			It("does not fail the mutation", func() {
+				Expect(container.SetProperty("some-property", "some-value")).To(Succeed())
+			})
+		})
+	})
+
 	Describe("Info", func() {
 		It("returns the container's state", func() {
 			info, err := container.Info()
@@ -697,6 +2131,13 @@ var _ = Describe("Linux containers", func() {
 			Expect(info.Events).To(Equal([]string{}))
 		})
 
+		It("does not report the structured events while there are none", func() {
+			info, err := container.Info()
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(info.Properties).ToNot(HaveKey(linux_container.EventsProperty))
+		})
+
 		It("returns the container's properties", func() {
 			info, err := container.Info()
 			Expect(err).ToNot(HaveOccurred())
@@ -714,6 +2155,36 @@ var _ = Describe("Linux containers", func() {
 			Expect(info.ContainerIP).To(Equal("1.2.3.4"))
 		})
 
+		It("does not report IPv6 addresses for a container which is not dual-stack", func() {
+			info, err := container.Info()
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(info.Properties).ToNot(HaveKey(linux_container.ContainerIPv6Property))
+			Expect(info.Properties).ToNot(HaveKey(linux_container.HostIPv6Property))
+		})
+
+		Context("when the container is dual-stack", func() {
+			BeforeEach(func() {
+				containerResources.Network.IPv6, containerResources.Network.IPv6Subnet, _ = net.ParseCIDR("fd00::1/126")
+			})
+
+			It("reports the IPv6 addresses as properties", func() {
+				info, err := container.Info()
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(info.Properties).To(HaveKeyWithValue(linux_container.ContainerIPv6Property, "fd00::1"))
+				Expect(info.Properties).To(HaveKeyWithValue(linux_container.HostIPv6Property, "fd00::2"))
+			})
+
+			It("does not add them to the container's properties", func() {
+				_, err := container.Info()
+				Expect(err).ToNot(HaveOccurred())
+
+				_, err = container.Property(linux_container.ContainerIPv6Property)
+				Expect(err).To(HaveOccurred())
+			})
+		})
+
 		It("returns the container's path", func() {
 			info, err := container.Info()
 			Expect(err).ToNot(HaveOccurred())
@@ -736,6 +2207,47 @@ var _ = Describe("Linux containers", func() {
 
 		})
 
+		It("reports the protocols of the mapped ports as a property", func() {
+			_, _, err := container.NetIn(1234, 5678)
+			Expect(err).ToNot(HaveOccurred())
+
+			_, _, err = container.NetInWithProtocol(1235, 53, garden.ProtocolUDP)
+			Expect(err).ToNot(HaveOccurred())
+
+			_, _, err = container.NetInWithProtocol(1236, 54, garden.ProtocolAll)
+			Expect(err).ToNot(HaveOccurred())
+
+			info, err := container.Info()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(info.Properties[linux_container.MappedPortsProperty]).To(Equal("1234:5678/tcp,1235:53/udp,1236:54/all"))
+		})
+
+		Context("when a port is mapped on an additional external IP", func() {
+			BeforeEach(func() {
+				containerResources.AdditionalExternalIPs = []net.IP{net.ParseIP("5.6.7.9")}
+			})
+
+			It("prefixes its mapping with the IP", func() {
+				_, _, err := container.NetIn(1234, 5678)
+				Expect(err).ToNot(HaveOccurred())
+
+				_, _, err = container.NetInOnExternalIP(net.ParseIP("5.6.7.9"), 1234, 5678, garden.ProtocolTCP)
+				Expect(err).ToNot(HaveOccurred())
+
+				info, err := container.Info()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(info.Properties[linux_container.MappedPortsProperty]).To(Equal("1234:5678/tcp,5.6.7.9:1234:5678/tcp"))
+			})
+		})
+
+		Context("when no ports are mapped", func() {
+			It("does not report the mapped ports property", func() {
+				info, err := container.Info()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(info.Properties).ToNot(HaveKey(linux_container.MappedPortsProperty))
+			})
+		})
+
 		Context("with running processes", func() {
 			JustBeforeEach(func() {
 				p1 := new(wfakes.FakeProcess)
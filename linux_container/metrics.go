@@ -2,10 +2,14 @@ package linux_container
 
 import (
 	"bufio"
+	"path"
 	"strconv"
 	"strings"
 
 	"github.com/cloudfoundry-incubator/garden"
+	"github.com/cloudfoundry-incubator/garden-linux/linux_backend"
+	"github.com/cloudfoundry-incubator/garden-linux/network"
+	"github.com/cloudfoundry-incubator/garden-linux/process"
 )
 
 func (c *LinuxContainer) Metrics() (garden.Metrics, error) {
@@ -31,10 +35,63 @@ func (c *LinuxContainer) Metrics() (garden.Metrics, error) {
 		return garden.Metrics{}, err
 	}
 
+	interfaceStats, err := c.interfaceStats()
+	if err != nil {
+		return garden.Metrics{}, err
+	}
+
 	return garden.Metrics{
-		MemoryStat: parseMemoryStat(memoryStat),
-		CPUStat:    parseCPUStat(cpuUsage, cpuStat),
-		DiskStat:   diskStat,
+		MemoryStat:  parseMemoryStat(memoryStat),
+		CPUStat:     parseCPUStat(cpuUsage, cpuStat),
+		DiskStat:    diskStat,
+		NetworkStat: garden.ContainerNetworkStat(interfaceStats),
+	}, nil
+}
+
+// NetworkMetrics returns the counters of the container's network interface,
+// which Metrics also returns, along with those of the rules of its filter
+// chain.
+func (c *LinuxContainer) NetworkMetrics() (linux_backend.NetworkMetrics, error) {
+	interfaceStats, err := c.interfaceStats()
+	if err != nil {
+		return linux_backend.NetworkMetrics{}, err
+	}
+
+	netOutRules, err := c.filter.NetOutCounters()
+	if err != nil {
+		return linux_backend.NetworkMetrics{}, err
+	}
+
+	return linux_backend.NetworkMetrics{
+		Interface:   interfaceStats,
+		NetOutRules: netOutRules,
+	}, nil
+}
+
+// interfaceStats reads the counters of the host side of the container's veth
+// pair, whose name the setup script records in the container's config. What
+// the host side receives the container sent, so the counters are swapped.
+func (c *LinuxContainer) interfaceStats() (network.InterfaceStats, error) {
+	config, err := process.EnvFromFile(path.Join(c.path, "etc", "config"))
+	if err != nil {
+		return network.InterfaceStats{}, err
+	}
+
+	hostStats, err := c.interfaceStatter.Stats(config["network_host_iface"])
+	if err != nil {
+		return network.InterfaceStats{}, err
+	}
+
+	return network.InterfaceStats{
+		RxBytes:   hostStats.TxBytes,
+		RxPackets: hostStats.TxPackets,
+		RxDropped: hostStats.TxDropped,
+		RxErrors:  hostStats.TxErrors,
+
+		TxBytes:   hostStats.RxBytes,
+		TxPackets: hostStats.RxPackets,
+		TxDropped: hostStats.RxDropped,
+		TxErrors:  hostStats.RxErrors,
 	}, nil
 }
 
@@ -2,7 +2,10 @@ package linux_container_test
 
 import (
 	"errors"
+	"io/ioutil"
 	"net"
+	"os"
+	"path/filepath"
 	"time"
 
 	. "github.com/onsi/ginkgo"
@@ -11,8 +14,13 @@ import (
 
 	"github.com/cloudfoundry-incubator/garden"
 	"github.com/cloudfoundry-incubator/garden-linux/linux_backend"
+	backendFakes "github.com/cloudfoundry-incubator/garden-linux/linux_backend/fakes"
 	"github.com/cloudfoundry-incubator/garden-linux/linux_container"
+	containerFakes "github.com/cloudfoundry-incubator/garden-linux/linux_container/fakes"
+	"github.com/cloudfoundry-incubator/garden-linux/network"
 	networkFakes "github.com/cloudfoundry-incubator/garden-linux/network/fakes"
+	"github.com/cloudfoundry-incubator/garden-linux/network/iptables"
+	iptablesFakes "github.com/cloudfoundry-incubator/garden-linux/network/iptables/fakes"
 	"github.com/cloudfoundry-incubator/garden-linux/old/bandwidth_manager/fake_bandwidth_manager"
 	"github.com/cloudfoundry-incubator/garden-linux/old/cgroups_manager/fake_cgroups_manager"
 	"github.com/cloudfoundry-incubator/garden-linux/old/port_pool/fake_port_pool"
@@ -25,6 +33,8 @@ import (
 var _ = Describe("Linux containers", func() {
 	var fakeCgroups *fake_cgroups_manager.FakeCgroupsManager
 	var fakeQuotaManager *fake_quota_manager.FakeQuotaManager
+	var fakeFilter *networkFakes.FakeFilter
+	var fakeInterfaceStatter *networkFakes.FakeInterfaceStatter
 	var container *linux_container.LinuxContainer
 	var containerDir string
 
@@ -32,6 +42,35 @@ var _ = Describe("Linux containers", func() {
 		fakeCgroups = fake_cgroups_manager.New("/cgroups", "some-id")
 
 		fakeQuotaManager = fake_quota_manager.New()
+
+		fakeFilter = new(networkFakes.FakeFilter)
+		fakeInterfaceStatter = new(networkFakes.FakeInterfaceStatter)
+
+		var err error
+		containerDir, err = ioutil.TempDir("", "depot")
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(os.Mkdir(filepath.Join(containerDir, "etc"), 0755)).To(Succeed())
+		Expect(ioutil.WriteFile(
+			filepath.Join(containerDir, "etc", "config"),
+			[]byte("id=some-id\nnetwork_host_iface=w1some-id-0\nnetwork_container_iface=w1some-id-1\n"),
+			0644,
+		)).To(Succeed())
+
+		fakeInterfaceStatter.StatsReturns(network.InterfaceStats{
+			RxBytes:   1000,
+			RxPackets: 10,
+			RxDropped: 1,
+			RxErrors:  2,
+			TxBytes:   5000,
+			TxPackets: 50,
+			TxDropped: 3,
+			TxErrors:  4,
+		}, nil)
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(containerDir)
 	})
 
 	JustBeforeEach(func() {
@@ -62,7 +101,11 @@ var _ = Describe("Linux containers", func() {
 			fake_bandwidth_manager.New(),
 			new(fake_process_tracker.FakeProcessTracker),
 			process.Env{"env1": "env1Value", "env2": "env2Value"},
-			new(networkFakes.FakeFilter),
+			fakeFilter,
+			new(iptablesFakes.FakeInstanceChain),
+			fakeInterfaceStatter,
+			new(containerFakes.FakeSnapshotSaver),
+			new(backendFakes.FakeEventEmitter),
 		)
 	})
 
@@ -240,5 +283,119 @@ system 2
 				})
 			})
 		})
+
+		Describe("network info", func() {
+			It("is read from the host side of the container's veth pair", func() {
+				_, err := container.Metrics()
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(fakeInterfaceStatter.StatsCallCount()).To(Equal(1))
+				Expect(fakeInterfaceStatter.StatsArgsForCall(0)).To(Equal("w1some-id-0"))
+			})
+
+			It("is returned in the response from the container's point of view", func() {
+				metrics, err := container.Metrics()
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(metrics.NetworkStat).To(Equal(garden.ContainerNetworkStat{
+					RxBytes:   5000,
+					RxPackets: 50,
+					RxDropped: 3,
+					RxErrors:  4,
+					TxBytes:   1000,
+					TxPackets: 10,
+					TxDropped: 1,
+					TxErrors:  2,
+				}))
+			})
+
+			Context("when reading the interface counters fails", func() {
+				disaster := errors.New("oh no!")
+
+				BeforeEach(func() {
+					fakeInterfaceStatter.StatsReturns(network.InterfaceStats{}, disaster)
+				})
+
+				It("returns the error", func() {
+					_, err := container.Metrics()
+					Expect(err).To(Equal(disaster))
+				})
+			})
+		})
+	})
+
+	Describe("NetworkMetrics", func() {
+		It("reads the counters of the host side of the container's veth pair", func() {
+			_, err := container.NetworkMetrics()
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(fakeInterfaceStatter.StatsCallCount()).To(Equal(1))
+			Expect(fakeInterfaceStatter.StatsArgsForCall(0)).To(Equal("w1some-id-0"))
+		})
+
+		It("returns the counters from the container's point of view", func() {
+			metrics, err := container.NetworkMetrics()
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(metrics.Interface).To(Equal(network.InterfaceStats{
+				RxBytes:   5000,
+				RxPackets: 50,
+				RxDropped: 3,
+				RxErrors:  4,
+				TxBytes:   1000,
+				TxPackets: 10,
+				TxDropped: 1,
+				TxErrors:  2,
+			}))
+		})
+
+		It("returns the counters of the rules of the container's filter chain", func() {
+			counters := []iptables.RuleCounter{
+				{Rule: "-d 1.2.3.4/32 -p tcp -j RETURN", Packets: 12, Bytes: 720},
+			}
+			fakeFilter.NetOutCountersReturns(counters, nil)
+
+			metrics, err := container.NetworkMetrics()
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(metrics.NetOutRules).To(Equal(counters))
+		})
+
+		Context("when the container's config cannot be read", func() {
+			BeforeEach(func() {
+				Expect(os.Remove(filepath.Join(containerDir, "etc", "config"))).To(Succeed())
+			})
+
+			It("returns an error", func() {
+				_, err := container.NetworkMetrics()
+				Expect(err).To(HaveOccurred())
+			})
+		})
+
+		Context("when reading the interface counters fails", func() {
+			disaster := errors.New("oh no!")
+
+			BeforeEach(func() {
+				fakeInterfaceStatter.StatsReturns(network.InterfaceStats{}, disaster)
+			})
+
+			It("returns the error", func() {
+				_, err := container.NetworkMetrics()
+				Expect(err).To(Equal(disaster))
+			})
+		})
+
+		Context("when reading the rule counters fails", func() {
+			disaster := errors.New("oh no!")
+
+			BeforeEach(func() {
+				fakeFilter.NetOutCountersReturns(nil, disaster)
+			})
+
+			It("returns the error", func() {
+				_, err := container.NetworkMetrics()
+				Expect(err).To(Equal(disaster))
+			})
+		})
 	})
 })
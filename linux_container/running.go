@@ -63,7 +63,28 @@ func (c *LinuxContainer) Run(spec garden.ProcessSpec, processIO garden.ProcessIO
 
 	setRLimitsEnv(wsh, spec.Limits)
 
-	return c.processTracker.Run(processID, wsh, processIO, spec.TTY, signaller)
+	p, err := c.processTracker.Run(processID, wsh, processIO, spec.TTY, signaller)
+	if err != nil {
+		return nil, err
+	}
+
+	c.saveSnapshot()
+
+	go c.emitWhenExited(p)
+
+	return p, nil
+}
+
+func (c *LinuxContainer) emitWhenExited(p garden.Process) {
+	exitStatus, err := p.Wait()
+	if err != nil {
+		return
+	}
+
+	c.emit(linux_backend.EventProcessExited, map[string]string{
+		"process-id":  fmt.Sprintf("%d", p.ID()),
+		"exit-status": fmt.Sprintf("%d", exitStatus),
+	})
 }
 
 func (c *LinuxContainer) Attach(processID uint32, processIO garden.ProcessIO) (garden.Process, error) {
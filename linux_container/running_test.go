@@ -16,8 +16,11 @@ import (
 
 	"github.com/cloudfoundry-incubator/garden"
 	"github.com/cloudfoundry-incubator/garden-linux/linux_backend"
+	backendFakes "github.com/cloudfoundry-incubator/garden-linux/linux_backend/fakes"
 	"github.com/cloudfoundry-incubator/garden-linux/linux_container"
+	containerFakes "github.com/cloudfoundry-incubator/garden-linux/linux_container/fakes"
 	networkFakes "github.com/cloudfoundry-incubator/garden-linux/network/fakes"
+	iptablesFakes "github.com/cloudfoundry-incubator/garden-linux/network/iptables/fakes"
 	"github.com/cloudfoundry-incubator/garden-linux/old/bandwidth_manager/fake_bandwidth_manager"
 	"github.com/cloudfoundry-incubator/garden-linux/old/cgroups_manager/fake_cgroups_manager"
 	"github.com/cloudfoundry-incubator/garden-linux/old/port_pool/fake_port_pool"
@@ -40,6 +43,7 @@ var _ = Describe("Linux containers", func() {
 		fakeRunner = fake_command_runner.New()
 
 		fakeProcessTracker = new(fake_process_tracker.FakeProcessTracker)
+		fakeProcessTracker.RunReturns(new(wfakes.FakeProcess), nil)
 
 		var err error
 		containerDir, err = ioutil.TempDir("", "depot")
@@ -76,6 +80,10 @@ var _ = Describe("Linux containers", func() {
 			fakeProcessTracker,
 			process.Env{"env1": "env1Value", "env2": "env2Value"},
 			new(networkFakes.FakeFilter),
+			new(iptablesFakes.FakeInstanceChain),
+			new(networkFakes.FakeInterfaceStatter),
+			new(containerFakes.FakeSnapshotSaver),
+			new(backendFakes.FakeEventEmitter),
 		)
 	})
 
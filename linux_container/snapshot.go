@@ -1,20 +1,39 @@
 package linux_container
 
 import (
+	"encoding/json"
+	"fmt"
+	"io"
 	"time"
 
 	"github.com/cloudfoundry-incubator/garden"
 	"github.com/cloudfoundry-incubator/garden-linux/linux_backend"
+	"github.com/cloudfoundry-incubator/garden-linux/network"
+	"github.com/cloudfoundry-incubator/garden-linux/old/bandwidth_manager"
 )
 
+// CurrentSnapshotVersion is the version of the ContainerSnapshot schema
+// written by this release. Bump it, and register a migration from the
+// previous version in snapshotMigrations, whenever a field is added, renamed
+// or changes meaning.
+const CurrentSnapshotVersion = 2
+
 type ContainerSnapshot struct {
+	// Version is the schema version the snapshot was written with. Snapshots
+	// written before versioning was introduced have no version, i.e. 0.
+	Version int
+
 	ID     string
 	Handle string
 
+	// Epoch increases every time the container is snapshotted, so that a
+	// stale snapshot can be told apart from a newer one of the same container.
+	Epoch uint64
+
 	GraceTime time.Duration
 
 	State  string
-	Events []string
+	Events []Event
 
 	Limits LimitsSnapshot
 
@@ -25,9 +44,17 @@ type ContainerSnapshot struct {
 	NetIns  []NetInSpec
 	NetOuts []garden.NetOutRule
 
+	// TrafficPolicies are the policies of which the container is the source.
+	// Their rules are not snapshotted, as the destination IPs may change.
+	TrafficPolicies []linux_backend.TrafficPolicy `json:",omitempty"`
+
 	Properties garden.Properties
 
 	EnvVars []string
+
+	// Checkpoint is the directory holding the CRIU images of the container's
+	// processes, if it has been checkpointed.
+	Checkpoint string `json:",omitempty"`
 }
 
 type LimitsSnapshot struct {
@@ -35,6 +62,10 @@ type LimitsSnapshot struct {
 	Disk      *garden.DiskLimits
 	Bandwidth *garden.BandwidthLimits
 	CPU       *garden.CPULimits
+
+	// DirectionalBandwidth is set instead of Bandwidth if the container's
+	// bandwidth was limited in each direction independently.
+	DirectionalBandwidth *bandwidth_manager.Limits `json:",omitempty"`
 }
 
 type ResourcesSnapshot struct {
@@ -43,9 +74,199 @@ type ResourcesSnapshot struct {
 	Network *linux_backend.Network
 	Bridge  string
 	Ports   []uint32
+	DNS     *network.DNSConfig `json:",omitempty"`
 }
 
 type ProcessSnapshot struct {
 	ID  uint32
 	TTY bool
 }
+
+type UnknownSnapshotVersionError struct {
+	Version int
+}
+
+func (e UnknownSnapshotVersionError) Error() string {
+	return fmt.Sprintf("unknown snapshot version: %d", e.Version)
+}
+
+type FutureSnapshotVersionError struct {
+	Version        int
+	CurrentVersion int
+}
+
+func (e FutureSnapshotVersionError) Error() string {
+	return fmt.Sprintf(
+		"snapshot version %d is newer than the supported version %d",
+		e.Version,
+		e.CurrentVersion,
+	)
+}
+
+// A SnapshotMigration upgrades a raw snapshot document in place from the
+// version it is registered under to the next version.
+type SnapshotMigration func(snapshot map[string]interface{}) error
+
+// snapshotMigrations maps each version to the migration which upgrades a
+// snapshot from it to the version after it.
+var snapshotMigrations = map[int]SnapshotMigration{
+	0: func(map[string]interface{}) error {
+		// version 1 only introduced the Version field itself
+		return nil
+	},
+	1: migrateToVersion2,
+}
+
+// migrateToVersion2 converts the events and port mappings of version 1. The
+// other fields version 2 introduced, the Checkpoint, the DNS resources, the
+// TrafficPolicies, the ExternalIP of NetIns, the DirectionalBandwidth limits
+// and the IPv6 addresses of the Network resources, are all optional, so need
+// no migration.
+func migrateToVersion2(snapshot map[string]interface{}) error {
+	if err := migrateStringEvents(snapshot); err != nil {
+		return err
+	}
+
+	return migrateNetInProtocols(snapshot)
+}
+
+// migrateStringEvents converts the free-form event strings of version 1 into
+// structured events, folding repeats together. When they
+// happened was never recorded, so their timestamps are left zero.
+func migrateStringEvents(snapshot map[string]interface{}) error {
+	legacy, found := snapshot["Events"]
+	if !found || legacy == nil {
+		return nil
+	}
+
+	legacyEvents, ok := legacy.([]interface{})
+	if !ok {
+		return fmt.Errorf("events are not a list: %v", legacy)
+	}
+
+	events := []interface{}{}
+	counts := map[string]map[string]interface{}{}
+
+	for _, e := range legacyEvents {
+		eventType, ok := e.(string)
+		if !ok {
+			return fmt.Errorf("event is not a string: %v", e)
+		}
+
+		if event, found := counts[eventType]; found {
+			event["Count"] = event["Count"].(int) + 1
+			continue
+		}
+
+		event := map[string]interface{}{
+			"Type":  eventType,
+			"Count": 1,
+		}
+
+		counts[eventType] = event
+		events = append(events, event)
+	}
+
+	snapshot["Events"] = events
+
+	return nil
+}
+
+// migrateNetInProtocols marks the port mappings of version 1, which could
+// only be TCP, as TCP. A missing protocol would otherwise decode as
+// garden.ProtocolAll.
+func migrateNetInProtocols(snapshot map[string]interface{}) error {
+	legacy, found := snapshot["NetIns"]
+	if !found || legacy == nil {
+		return nil
+	}
+
+	netIns, ok := legacy.([]interface{})
+	if !ok {
+		return fmt.Errorf("net ins are not a list: %v", legacy)
+	}
+
+	for _, in := range netIns {
+		netIn, ok := in.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("net in is not an object: %v", in)
+		}
+
+		netIn["Protocol"] = garden.ProtocolTCP
+	}
+
+	return nil
+}
+
+// DecodeSnapshot reads a snapshot of any supported version, migrating it up
+// to CurrentSnapshotVersion.
+func DecodeSnapshot(in io.Reader) (ContainerSnapshot, error) {
+	var raw map[string]interface{}
+
+	decoder := json.NewDecoder(in)
+	decoder.UseNumber()
+
+	err := decoder.Decode(&raw)
+	if err != nil {
+		return ContainerSnapshot{}, err
+	}
+
+	version, err := snapshotVersion(raw)
+	if err != nil {
+		return ContainerSnapshot{}, err
+	}
+
+	if version > CurrentSnapshotVersion {
+		return ContainerSnapshot{}, FutureSnapshotVersionError{
+			Version:        version,
+			CurrentVersion: CurrentSnapshotVersion,
+		}
+	}
+
+	for ; version < CurrentSnapshotVersion; version++ {
+		migrate, found := snapshotMigrations[version]
+		if !found {
+			return ContainerSnapshot{}, UnknownSnapshotVersionError{version}
+		}
+
+		err := migrate(raw)
+		if err != nil {
+			return ContainerSnapshot{}, fmt.Errorf("linux_container: migrating snapshot from version %d: %s", version, err)
+		}
+	}
+
+	raw["Version"] = CurrentSnapshotVersion
+
+	migrated, err := json.Marshal(raw)
+	if err != nil {
+		return ContainerSnapshot{}, err
+	}
+
+	var snapshot ContainerSnapshot
+
+	err = json.Unmarshal(migrated, &snapshot)
+	if err != nil {
+		return ContainerSnapshot{}, err
+	}
+
+	return snapshot, nil
+}
+
+func snapshotVersion(raw map[string]interface{}) (int, error) {
+	field, found := raw["Version"]
+	if !found {
+		return 0, nil
+	}
+
+	number, ok := field.(json.Number)
+	if !ok {
+		return 0, fmt.Errorf("linux_container: invalid snapshot version: %v", field)
+	}
+
+	version, err := number.Int64()
+	if err != nil || version < 0 {
+		return 0, fmt.Errorf("linux_container: invalid snapshot version: %v", field)
+	}
+
+	return int(version), nil
+}
@@ -0,0 +1,189 @@
+package linux_container_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/cloudfoundry-incubator/garden"
+	"github.com/cloudfoundry-incubator/garden-linux/linux_container"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("DecodeSnapshot", func() {
+	It("decodes a snapshot of the current version", func() {
+		written := linux_container.ContainerSnapshot{
+			Version: linux_container.CurrentSnapshotVersion,
+
+			ID:     "some-id",
+			Handle: "some-handle",
+
+			Epoch: 1 << 60,
+
+			State: "active",
+
+			Limits: linux_container.LimitsSnapshot{
+				Memory: &garden.MemoryLimits{LimitInBytes: 1024},
+			},
+
+			Properties: garden.Properties{"some": "property"},
+		}
+
+		encoded, err := json.Marshal(written)
+		Expect(err).ToNot(HaveOccurred())
+
+		snapshot, err := linux_container.DecodeSnapshot(bytes.NewReader(encoded))
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(snapshot).To(Equal(written))
+	})
+
+	Context("when the snapshot predates versioning", func() {
+		It("migrates it to the current version", func() {
+			snapshot, err := linux_container.DecodeSnapshot(strings.NewReader(
+				`{"ID":"some-id","Handle":"some-handle","State":"active"}`,
+			))
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(snapshot.Version).To(Equal(linux_container.CurrentSnapshotVersion))
+			Expect(snapshot.ID).To(Equal("some-id"))
+			Expect(snapshot.Handle).To(Equal("some-handle"))
+			Expect(snapshot.State).To(Equal("active"))
+		})
+	})
+
+	Context("when the snapshot has events as plain strings", func() {
+		It("migrates them to structured events, folding repeats", func() {
+			snapshot, err := linux_container.DecodeSnapshot(strings.NewReader(
+				`{"Version":1,"Events":["out of memory","foo","out of memory"]}`,
+			))
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(snapshot.Events).To(Equal([]linux_container.Event{
+				{Type: "out of memory", Count: 2},
+				{Type: "foo", Count: 1},
+			}))
+		})
+
+		Context("when an event is not a string", func() {
+			It("returns an error", func() {
+				_, err := linux_container.DecodeSnapshot(strings.NewReader(
+					`{"Version":1,"Events":[42]}`,
+				))
+				Expect(err).To(HaveOccurred())
+			})
+		})
+	})
+
+	Context("when the snapshot has net ins without a protocol", func() {
+		It("migrates them to TCP net ins", func() {
+			snapshot, err := linux_container.DecodeSnapshot(strings.NewReader(
+				`{"Version":1,"NetIns":[{"HostPort":1234,"ContainerPort":5678}]}`,
+			))
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(snapshot.NetIns).To(Equal([]linux_container.NetInSpec{
+				{HostPort: 1234, ContainerPort: 5678, Protocol: garden.ProtocolTCP},
+			}))
+		})
+	})
+
+	Context("when the snapshot has both plain string events and net ins without a protocol", func() {
+		It("migrates both", func() {
+			snapshot, err := linux_container.DecodeSnapshot(strings.NewReader(
+				`{"Version":1,"Events":["foo"],"NetIns":[{"HostPort":1234,"ContainerPort":5678}]}`,
+			))
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(snapshot.Events).To(Equal([]linux_container.Event{{Type: "foo", Count: 1}}))
+			Expect(snapshot.NetIns).To(Equal([]linux_container.NetInSpec{
+				{HostPort: 1234, ContainerPort: 5678, Protocol: garden.ProtocolTCP},
+			}))
+		})
+	})
+
+	Context("when the snapshot has net ins on an external IP", func() {
+		It("decodes the IP", func() {
+			snapshot, err := linux_container.DecodeSnapshot(strings.NewReader(
+				`{"Version":2,"NetIns":[{"HostPort":1234,"ContainerPort":5678,"Protocol":1,"ExternalIP":"5.6.7.9"},{"HostPort":1235,"ContainerPort":5679,"Protocol":1}]}`,
+			))
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(snapshot.NetIns).To(Equal([]linux_container.NetInSpec{
+				{HostPort: 1234, ContainerPort: 5678, Protocol: garden.ProtocolTCP, ExternalIP: net.ParseIP("5.6.7.9")},
+				{HostPort: 1235, ContainerPort: 5679, Protocol: garden.ProtocolTCP},
+			}))
+		})
+	})
+
+	Context("when the snapshot predates directional bandwidth limits", func() {
+		It("migrates it without any", func() {
+			snapshot, err := linux_container.DecodeSnapshot(strings.NewReader(
+				`{"Version":1,"Limits":{"Bandwidth":{"rate":128}}}`,
+			))
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(snapshot.Version).To(Equal(linux_container.CurrentSnapshotVersion))
+			Expect(snapshot.Limits.Bandwidth.RateInBytesPerSecond).To(Equal(uint64(128)))
+			Expect(snapshot.Limits.DirectionalBandwidth).To(BeNil())
+		})
+	})
+
+	Context("when the snapshot predates dual-stack networks", func() {
+		It("migrates it to an IPv4-only network", func() {
+			snapshot, err := linux_container.DecodeSnapshot(strings.NewReader(
+				`{"Version":1,"Resources":{"Network":{"IP":"10.2.0.2","Subnet":"10.2.0.0/30"}}}`,
+			))
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(snapshot.Version).To(Equal(linux_container.CurrentSnapshotVersion))
+			Expect(snapshot.Resources.Network.IP.String()).To(Equal("10.2.0.2"))
+			Expect(snapshot.Resources.Network.IPv6).To(BeNil())
+			Expect(snapshot.Resources.Network.IPv6Subnet).To(BeNil())
+		})
+	})
+
+	Context("when the snapshot has a dual-stack network", func() {
+		It("decodes its IPv6 addresses", func() {
+			snapshot, err := linux_container.DecodeSnapshot(strings.NewReader(
+				`{"Version":2,"Resources":{"Network":{"IP":"10.2.0.2","Subnet":"10.2.0.0/30","IPv6":"fd00::2","IPv6Subnet":"fd00::/126"}}}`,
+			))
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(snapshot.Resources.Network.IPv6.String()).To(Equal("fd00::2"))
+			Expect(snapshot.Resources.Network.IPv6Subnet.String()).To(Equal("fd00::/126"))
+		})
+	})
+
+	Context("when the snapshot is from a newer version", func() {
+		It("returns a FutureSnapshotVersionError", func() {
+			_, err := linux_container.DecodeSnapshot(strings.NewReader(
+				fmt.Sprintf(`{"Version":%d,"ID":"some-id"}`, linux_container.CurrentSnapshotVersion+1),
+			))
+			Expect(err).To(Equal(linux_container.FutureSnapshotVersionError{
+				Version:        linux_container.CurrentSnapshotVersion + 1,
+				CurrentVersion: linux_container.CurrentSnapshotVersion,
+			}))
+		})
+	})
+
+	Context("when the snapshot version is not a valid version", func() {
+		It("returns an error", func() {
+			_, err := linux_container.DecodeSnapshot(strings.NewReader(`{"Version":-1}`))
+			Expect(err).To(HaveOccurred())
+
+			_, err = linux_container.DecodeSnapshot(strings.NewReader(`{"Version":"one"}`))
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("when the snapshot is not valid JSON", func() {
+		It("returns an error", func() {
+			_, err := linux_container.DecodeSnapshot(strings.NewReader(`{"ID":`))
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
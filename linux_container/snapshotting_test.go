@@ -6,7 +6,6 @@ import (
 	"errors"
 	"io/ioutil"
 	"net"
-	"os/exec"
 	"time"
 
 	. "github.com/onsi/ginkgo"
@@ -15,8 +14,13 @@ import (
 
 	"github.com/cloudfoundry-incubator/garden"
 	"github.com/cloudfoundry-incubator/garden-linux/linux_backend"
+	backendFakes "github.com/cloudfoundry-incubator/garden-linux/linux_backend/fakes"
 	"github.com/cloudfoundry-incubator/garden-linux/linux_container"
+	containerFakes "github.com/cloudfoundry-incubator/garden-linux/linux_container/fakes"
 	networkFakes "github.com/cloudfoundry-incubator/garden-linux/network/fakes"
+	iptablesFakes "github.com/cloudfoundry-incubator/garden-linux/network/iptables/fakes"
+	"github.com/cloudfoundry-incubator/garden-linux/network/tc"
+	"github.com/cloudfoundry-incubator/garden-linux/old/bandwidth_manager"
 	"github.com/cloudfoundry-incubator/garden-linux/old/bandwidth_manager/fake_bandwidth_manager"
 	"github.com/cloudfoundry-incubator/garden-linux/old/cgroups_manager/fake_cgroups_manager"
 	"github.com/cloudfoundry-incubator/garden-linux/old/port_pool/fake_port_pool"
@@ -25,7 +29,6 @@ import (
 	"github.com/cloudfoundry-incubator/garden-linux/process_tracker/fake_process_tracker"
 	wfakes "github.com/cloudfoundry-incubator/garden/fakes"
 	"github.com/cloudfoundry/gunk/command_runner/fake_command_runner"
-	. "github.com/cloudfoundry/gunk/command_runner/fake_command_runner/matchers"
 )
 
 var _ = Describe("Linux containers", func() {
@@ -38,6 +41,10 @@ var _ = Describe("Linux containers", func() {
 	var fakePortPool *fake_port_pool.FakePortPool
 	var fakeProcessTracker *fake_process_tracker.FakeProcessTracker
 	var fakeFilter *networkFakes.FakeFilter
+	var fakeInstanceChain *iptablesFakes.FakeInstanceChain
+	var fakeInterfaceStatter *networkFakes.FakeInterfaceStatter
+	var fakeSnapshotSaver *containerFakes.FakeSnapshotSaver
+	var fakeEventEmitter *backendFakes.FakeEventEmitter
 	var containerDir string
 	var containerProps map[string]string
 
@@ -65,7 +72,12 @@ var _ = Describe("Linux containers", func() {
 		fakeQuotaManager = fake_quota_manager.New()
 		fakeBandwidthManager = fake_bandwidth_manager.New()
 		fakeProcessTracker = new(fake_process_tracker.FakeProcessTracker)
+		fakeProcessTracker.RunReturns(new(wfakes.FakeProcess), nil)
 		fakeFilter = new(networkFakes.FakeFilter)
+		fakeInstanceChain = new(iptablesFakes.FakeInstanceChain)
+		fakeInterfaceStatter = new(networkFakes.FakeInterfaceStatter)
+		fakeSnapshotSaver = new(containerFakes.FakeSnapshotSaver)
+		fakeEventEmitter = new(backendFakes.FakeEventEmitter)
 
 		fakePortPool = fake_port_pool.New(1000)
 
@@ -108,9 +120,34 @@ var _ = Describe("Linux containers", func() {
 			fakeProcessTracker,
 			process.Env{"env1": "env1Value", "env2": "env2Value"},
 			fakeFilter,
+			fakeInstanceChain,
+			fakeInterfaceStatter,
+			fakeSnapshotSaver,
+			fakeEventEmitter,
 		)
 	})
 
+	Describe("Saving the snapshot", func() {
+		It("saves the container with its snapshot saver", func() {
+			Expect(container.SaveSnapshot()).To(Succeed())
+
+			Expect(fakeSnapshotSaver.SaveCallCount()).To(Equal(1))
+			Expect(fakeSnapshotSaver.SaveArgsForCall(0)).To(Equal(container))
+		})
+
+		Context("when saving fails", func() {
+			disaster := errors.New("oh no!")
+
+			BeforeEach(func() {
+				fakeSnapshotSaver.SaveReturns(disaster)
+			})
+
+			It("returns the error", func() {
+				Expect(container.SaveSnapshot()).To(Equal(disaster))
+			})
+		})
+	})
+
 	Describe("Snapshotting", func() {
 		memoryLimits := garden.MemoryLimits{
 			LimitInBytes: 1,
@@ -174,6 +211,8 @@ var _ = Describe("Linux containers", func() {
 			err = json.NewDecoder(out).Decode(&snapshot)
 			Expect(err).ToNot(HaveOccurred())
 
+			Expect(snapshot.Version).To(Equal(linux_container.CurrentSnapshotVersion))
+
 			Expect(snapshot.ID).To(Equal("some-id"))
 			Expect(snapshot.Handle).To(Equal("some-handle"))
 
@@ -200,10 +239,12 @@ var _ = Describe("Linux containers", func() {
 					{
 						HostPort:      1,
 						ContainerPort: 2,
+						Protocol:      garden.ProtocolTCP,
 					},
 					{
 						HostPort:      3,
 						ContainerPort: 4,
+						Protocol:      garden.ProtocolTCP,
 					},
 				},
 			))
@@ -237,6 +278,40 @@ var _ = Describe("Linux containers", func() {
 			Expect(snapshot.EnvVars).To(Equal([]string{"env1=env1Value", "env2=env2Value"}))
 		})
 
+		It("increments the snapshot's epoch every time", func() {
+			var first, second linux_container.ContainerSnapshot
+
+			out := new(bytes.Buffer)
+			Expect(container.Snapshot(out)).To(Succeed())
+			Expect(json.NewDecoder(out).Decode(&first)).To(Succeed())
+
+			out = new(bytes.Buffer)
+			Expect(container.Snapshot(out)).To(Succeed())
+			Expect(json.NewDecoder(out).Decode(&second)).To(Succeed())
+
+			Expect(second.Epoch).To(BeNumerically(">", first.Epoch))
+		})
+
+		It("includes the traffic policies, but not the IPs of their destinations", func() {
+			policy := linux_backend.TrafficPolicy{
+				SourceHandle:      "some-handle",
+				DestinationHandle: "other-handle",
+				Protocol:          garden.ProtocolTCP,
+				Port:              8080,
+			}
+
+			Expect(container.AllowTraffic(policy, []net.IP{net.ParseIP("10.2.0.6")})).To(Succeed())
+
+			out := new(bytes.Buffer)
+			Expect(container.Snapshot(out)).To(Succeed())
+			Expect(out.String()).ToNot(ContainSubstring("10.2.0.6"))
+
+			var snapshot linux_container.ContainerSnapshot
+			Expect(json.NewDecoder(out).Decode(&snapshot)).To(Succeed())
+
+			Expect(snapshot.TrafficPolicies).To(Equal([]linux_backend.TrafficPolicy{policy}))
+		})
+
 		Context("with limits set", func() {
 			JustBeforeEach(func() {
 				err := container.LimitMemory(memoryLimits)
@@ -269,7 +344,10 @@ var _ = Describe("Linux containers", func() {
 				Expect(err).ToNot(HaveOccurred())
 
 				Expect(snapshot.State).To(Equal("stopped"))
-				Expect(snapshot.Events).To(Equal([]string{"out of memory"}))
+				Expect(snapshot.Events).To(HaveLen(1))
+				Expect(snapshot.Events[0].Type).To(Equal("out of memory"))
+				Expect(snapshot.Events[0].Count).To(Equal(1))
+				Expect(snapshot.Events[0].Timestamp).To(BeTemporally("~", time.Now(), time.Minute))
 
 				Expect(snapshot.Limits).To(Equal(
 					linux_container.LimitsSnapshot{
@@ -282,6 +360,27 @@ var _ = Describe("Linux containers", func() {
 			})
 		})
 
+		Context("with directional bandwidth limits set", func() {
+			It("saves them instead of the bandwidth limits", func() {
+				limits := bandwidth_manager.Limits{
+					Ingress: tc.TokenBucket{Rate: 128, Burst: 256},
+					Egress:  tc.TokenBucket{Rate: 512, Burst: 1024},
+				}
+
+				Expect(container.LimitBandwidth(bandwidthLimits)).To(Succeed())
+				Expect(container.LimitDirectionalBandwidth(limits)).To(Succeed())
+
+				out := new(bytes.Buffer)
+				Expect(container.Snapshot(out)).To(Succeed())
+
+				var snapshot linux_container.ContainerSnapshot
+				Expect(json.NewDecoder(out).Decode(&snapshot)).To(Succeed())
+
+				Expect(snapshot.Limits.Bandwidth).To(BeNil())
+				Expect(snapshot.Limits.DirectionalBandwidth).To(Equal(&limits))
+			})
+		})
+
 		Context("with no limits set", func() {
 			It("saves them as nil, not zero values", func() {
 				out := new(bytes.Buffer)
@@ -309,24 +408,108 @@ var _ = Describe("Linux containers", func() {
 
 	Describe("Restoring", func() {
 		It("sets the container's state and events", func() {
+			oom := linux_container.Event{
+				Type:      "out of memory",
+				Timestamp: time.Unix(123, 0),
+				Count:     2,
+			}
+
+			foo := linux_container.Event{
+				Type:      "foo",
+				Timestamp: time.Unix(456, 0),
+				Details:   "bar",
+				Count:     1,
+			}
+
 			err := container.Restore(linux_container.ContainerSnapshot{
 				State:  "active",
-				Events: []string{"out of memory", "foo"},
+				Events: []linux_container.Event{oom, foo},
 			})
 			Expect(err).ToNot(HaveOccurred())
 
 			Expect(container.State()).To(Equal(linux_container.State("active")))
 			Expect(container.Events()).To(Equal([]string{
 				"out of memory",
-				"foo",
+				"out of memory",
+				"foo: bar",
 			}))
 
+			Expect(container.EventLog()).To(Equal([]linux_container.Event{oom, foo}))
+
+			info, err := container.Info()
+			Expect(err).ToNot(HaveOccurred())
+
+			var reported []linux_container.Event
+			Expect(json.Unmarshal([]byte(info.Properties[linux_container.EventsProperty]), &reported)).To(Succeed())
+			Expect(reported).To(HaveLen(2))
+			Expect(reported[0].Type).To(Equal("out of memory"))
+			Expect(reported[0].Count).To(Equal(2))
+			Expect(reported[0].Timestamp.Equal(oom.Timestamp)).To(BeTrue())
+			Expect(reported[1].Details).To(Equal("bar"))
+		})
+
+		Context("when the container was paused", func() {
+			It("keeps its freezer cgroup frozen", func() {
+				err := container.Restore(linux_container.ContainerSnapshot{
+					State:  "paused",
+					Events: []linux_container.Event{},
+				})
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(container.State()).To(Equal(linux_container.StatePaused))
+
+				Expect(fakeCgroups.SetValues()).To(ContainElement(
+					fake_cgroups_manager.SetValue{
+						Subsystem: "freezer",
+						Name:      "freezer.state",
+						Value:     "FROZEN",
+					},
+				))
+			})
+		})
+
+		It("continues the epoch from the snapshot", func() {
+			Expect(container.Restore(linux_container.ContainerSnapshot{
+				State: "active",
+				Epoch: 42,
+			})).To(Succeed())
+
+			out := new(bytes.Buffer)
+			Expect(container.Snapshot(out)).To(Succeed())
+
+			var snapshot linux_container.ContainerSnapshot
+			Expect(json.NewDecoder(out).Decode(&snapshot)).To(Succeed())
+
+			Expect(snapshot.Epoch).To(Equal(uint64(43)))
+		})
+
+		It("does not save snapshots of the partially restored container", func() {
+			Expect(container.Restore(linux_container.ContainerSnapshot{
+				State: "active",
+
+				Limits: linux_container.LimitsSnapshot{
+					Memory: &garden.MemoryLimits{
+						LimitInBytes: 1024,
+					},
+				},
+
+				NetIns: []linux_container.NetInSpec{
+					{
+						HostPort:      1234,
+						ContainerPort: 5678,
+					},
+				},
+
+				NetOuts: []garden.NetOutRule{netOutRule1},
+			})).To(Succeed())
+
+			Expect(fakeSnapshotSaver.SaveCallCount()).To(Equal(0))
 		})
 
 		It("restores process state", func() {
 			err := container.Restore(linux_container.ContainerSnapshot{
 				State:  "active",
-				Events: []string{},
+				Events: []linux_container.Event{},
 
 				Processes: []linux_container.ProcessSnapshot{
 					{
@@ -351,7 +534,7 @@ var _ = Describe("Linux containers", func() {
 		It("makes the next process ID be higher than the highest restored ID", func() {
 			err := container.Restore(linux_container.ContainerSnapshot{
 				State:  "active",
-				Events: []string{},
+				Events: []linux_container.Event{},
 
 				Processes: []linux_container.ProcessSnapshot{
 					{
@@ -379,7 +562,7 @@ var _ = Describe("Linux containers", func() {
 		It("configures a signaller with the correct pidfile for the process", func() {
 			Expect(container.Restore(linux_container.ContainerSnapshot{
 				State:  "active",
-				Events: []string{},
+				Events: []linux_container.Event{},
 
 				Processes: []linux_container.ProcessSnapshot{
 					{
@@ -416,6 +599,22 @@ var _ = Describe("Linux containers", func() {
 			Expect(fakeFilter.NetOutArgsForCall(1)).To(Equal(netOutRule2))
 		})
 
+		It("restores traffic policies without applying their rules", func() {
+			policy := linux_backend.TrafficPolicy{
+				SourceHandle:      "some-handle",
+				DestinationHandle: "other-handle",
+				Protocol:          garden.ProtocolTCP,
+				Port:              8080,
+			}
+
+			Expect(container.Restore(linux_container.ContainerSnapshot{
+				TrafficPolicies: []linux_backend.TrafficPolicy{policy},
+			})).To(Succeed())
+
+			Expect(container.TrafficPolicies()).To(Equal([]linux_backend.TrafficPolicy{policy}))
+			Expect(fakeFilter.NetOutCallCount()).To(Equal(0))
+		})
+
 		Context("when applying a netout rule fails", func() {
 			It("returns an error", func() {
 				fakeFilter.NetOutReturns(errors.New("didn't work"))
@@ -430,81 +629,97 @@ var _ = Describe("Linux containers", func() {
 		It("redoes network setup and net-ins", func() {
 			err := container.Restore(linux_container.ContainerSnapshot{
 				State:  "active",
-				Events: []string{},
+				Events: []linux_container.Event{},
 
 				NetIns: []linux_container.NetInSpec{
 					{
 						HostPort:      1234,
 						ContainerPort: 5678,
+						Protocol:      garden.ProtocolTCP,
 					},
 					{
 						HostPort:      1235,
 						ContainerPort: 5679,
+						Protocol:      garden.ProtocolUDP,
+					},
+					{
+						HostPort:      1236,
+						ContainerPort: 5680,
+						Protocol:      garden.ProtocolTCP,
+						ExternalIP:    net.ParseIP("5.6.7.9"),
 					},
 				},
 			})
 			Expect(err).ToNot(HaveOccurred())
 
-			Expect(fakeRunner).To(HaveExecutedSerially(
-				fake_command_runner.CommandSpec{
-					Path: containerDir + "/net.sh",
-					Args: []string{"setup"},
-				},
-				fake_command_runner.CommandSpec{
-					Path: containerDir + "/net.sh",
-					Args: []string{"in"},
-				},
-				fake_command_runner.CommandSpec{
-					Path: containerDir + "/net.sh",
-					Args: []string{"in"},
-				},
-			))
-		})
+			Expect(fakeInstanceChain.SetupCallCount()).To(Equal(1))
+			Expect(fakeInstanceChain.SetupArgsForCall(0).BridgeInterface).To(Equal("some-bridge"))
 
-		for _, cmd := range []string{"setup", "in"} {
-			command := cmd
+			Expect(fakeInstanceChain.AppendPortForwardCallCount()).To(Equal(3))
+			Expect(fakeInstanceChain.AppendPortForwardArgsForCall(0).HostPort).To(Equal(uint32(1234)))
+			Expect(fakeInstanceChain.AppendPortForwardArgsForCall(1).HostPort).To(Equal(uint32(1235)))
+			Expect(fakeInstanceChain.AppendPortForwardArgsForCall(2).HostPort).To(Equal(uint32(1236)))
 
-			Context("when net.sh "+cmd+" fails", func() {
-				disaster := errors.New("oh no!")
+			Expect(fakeInstanceChain.AppendPortForwardArgsForCall(0).Protocol).To(Equal(garden.ProtocolTCP))
+			Expect(fakeInstanceChain.AppendPortForwardArgsForCall(1).Protocol).To(Equal(garden.ProtocolUDP))
 
-				JustBeforeEach(func() {
-					fakeRunner.WhenRunning(
-						fake_command_runner.CommandSpec{
-							Path: containerDir + "/net.sh",
-							Args: []string{command},
-						}, func(*exec.Cmd) error {
-							return disaster
-						},
-					)
-				})
+			Expect(fakeInstanceChain.AppendPortForwardArgsForCall(0).ExternalIP).To(Equal(containerResources.ExternalIP))
+			Expect(fakeInstanceChain.AppendPortForwardArgsForCall(2).ExternalIP).To(Equal(net.ParseIP("5.6.7.9")))
+		})
 
-				It("returns the error", func() {
-					err := container.Restore(linux_container.ContainerSnapshot{
-						State:  "active",
-						Events: []string{},
-
-						NetIns: []linux_container.NetInSpec{
-							{
-								HostPort:      1234,
-								ContainerPort: 5678,
-							},
-							{
-								HostPort:      1235,
-								ContainerPort: 5679,
-							},
-						},
+		restoreNetIns := func() error {
+			return container.Restore(linux_container.ContainerSnapshot{
+				State:  "active",
+				Events: []linux_container.Event{},
 
-						NetOuts: []garden.NetOutRule{},
-					})
-					Expect(err).To(Equal(disaster))
-				})
+				NetIns: []linux_container.NetInSpec{
+					{
+						HostPort:      1234,
+						ContainerPort: 5678,
+					},
+					{
+						HostPort:      1235,
+						ContainerPort: 5679,
+					},
+				},
+
+				NetOuts: []garden.NetOutRule{},
 			})
 		}
 
+		Context("when setting up the instance chains fails", func() {
+			disaster := errors.New("oh no!")
+
+			JustBeforeEach(func() {
+				fakeInstanceChain.SetupReturns(disaster)
+			})
+
+			It("returns the error", func() {
+				Expect(restoreNetIns()).To(Equal(disaster))
+			})
+
+			It("does not redo the net-ins", func() {
+				restoreNetIns()
+				Expect(fakeInstanceChain.AppendPortForwardCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when forwarding a port fails", func() {
+			disaster := errors.New("oh no!")
+
+			JustBeforeEach(func() {
+				fakeInstanceChain.AppendPortForwardReturns(disaster)
+			})
+
+			It("returns the error", func() {
+				Expect(restoreNetIns()).To(Equal(disaster))
+			})
+		})
+
 		It("re-enforces the memory limit", func() {
 			err := container.Restore(linux_container.ContainerSnapshot{
 				State:  "active",
-				Events: []string{},
+				Events: []linux_container.Event{},
 
 				Limits: linux_container.LimitsSnapshot{
 					Memory: &garden.MemoryLimits{
@@ -538,7 +753,7 @@ var _ = Describe("Linux containers", func() {
 			It("does not set a limit", func() {
 				err := container.Restore(linux_container.ContainerSnapshot{
 					State:  "active",
-					Events: []string{},
+					Events: []linux_container.Event{},
 				})
 				Expect(err).ToNot(HaveOccurred())
 
@@ -558,7 +773,7 @@ var _ = Describe("Linux containers", func() {
 			It("returns the error", func() {
 				err := container.Restore(linux_container.ContainerSnapshot{
 					State:  "active",
-					Events: []string{},
+					Events: []linux_container.Event{},
 
 					Limits: linux_container.LimitsSnapshot{
 						Memory: &garden.MemoryLimits{
@@ -569,5 +784,142 @@ var _ = Describe("Linux containers", func() {
 				Expect(err).To(Equal(disaster))
 			})
 		})
+
+		Describe("restoring the other limits", func() {
+			var snapshot linux_container.ContainerSnapshot
+
+			BeforeEach(func() {
+				snapshot = linux_container.ContainerSnapshot{
+					State:  "active",
+					Events: []linux_container.Event{},
+
+					Limits: linux_container.LimitsSnapshot{
+						CPU: &garden.CPULimits{
+							LimitInShares: 512,
+						},
+						Bandwidth: &garden.BandwidthLimits{
+							RateInBytesPerSecond:      128,
+							BurstRateInBytesPerSecond: 256,
+						},
+						Disk: &garden.DiskLimits{
+							ByteHard: 4096,
+						},
+					},
+				}
+			})
+
+			It("re-enforces the cpu limit", func() {
+				Expect(container.Restore(snapshot)).To(Succeed())
+
+				Expect(fakeCgroups.SetValues()).To(ContainElement(
+					fake_cgroups_manager.SetValue{
+						Subsystem: "cpu",
+						Name:      "cpu.shares",
+						Value:     "512",
+					},
+				))
+			})
+
+			It("re-enforces the bandwidth limit", func() {
+				Expect(container.Restore(snapshot)).To(Succeed())
+
+				Expect(fakeBandwidthManager.EnforcedLimits).To(ContainElement(*snapshot.Limits.Bandwidth))
+
+				limits, err := container.CurrentBandwidthLimits()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(limits).To(Equal(*snapshot.Limits.Bandwidth))
+			})
+
+			Context("when the bandwidth was limited in each direction", func() {
+				limits := bandwidth_manager.Limits{
+					Ingress: tc.TokenBucket{Rate: 128, Burst: 256},
+					Egress:  tc.TokenBucket{Rate: 512, Burst: 1024},
+				}
+
+				BeforeEach(func() {
+					snapshot.Limits.Bandwidth = nil
+					snapshot.Limits.DirectionalBandwidth = &limits
+				})
+
+				It("re-enforces the directional bandwidth limits", func() {
+					Expect(container.Restore(snapshot)).To(Succeed())
+
+					Expect(fakeBandwidthManager.EnforcedDirectionalLimits).To(ContainElement(limits))
+
+					current, err := container.CurrentDirectionalBandwidthLimits()
+					Expect(err).ToNot(HaveOccurred())
+					Expect(current).To(Equal(limits))
+				})
+
+				Context("when re-enforcing them fails", func() {
+					BeforeEach(func() {
+						fakeBandwidthManager.SetDirectionalLimitsError = errors.New("bandwidth disaster")
+					})
+
+					It("restores the container anyway, and registers an event", func() {
+						Expect(container.Restore(snapshot)).To(Succeed())
+
+						Expect(container.Events()).To(ContainElement("failed to restore directional bandwidth limits: bandwidth disaster"))
+					})
+				})
+			})
+
+			It("re-enforces the disk limit", func() {
+				Expect(container.Restore(snapshot)).To(Succeed())
+
+				Expect(fakeQuotaManager.Limited).To(HaveKeyWithValue(1234, *snapshot.Limits.Disk))
+			})
+
+			It("includes the limits in the next snapshot", func() {
+				Expect(container.Restore(snapshot)).To(Succeed())
+
+				out := new(bytes.Buffer)
+				Expect(container.Snapshot(out)).To(Succeed())
+
+				var next linux_container.ContainerSnapshot
+				Expect(json.NewDecoder(out).Decode(&next)).To(Succeed())
+
+				Expect(next.Limits).To(Equal(snapshot.Limits))
+			})
+
+			Context("when re-enforcing them fails", func() {
+				BeforeEach(func() {
+					fakeCgroups.WhenSetting("cpu", "cpu.shares", func() error {
+						return errors.New("cpu disaster")
+					})
+
+					fakeBandwidthManager.SetLimitsError = errors.New("bandwidth disaster")
+					fakeQuotaManager.SetLimitsError = errors.New("disk disaster")
+				})
+
+				It("restores the container anyway", func() {
+					Expect(container.Restore(snapshot)).To(Succeed())
+				})
+
+				It("registers an event for each failure", func() {
+					Expect(container.Restore(snapshot)).To(Succeed())
+
+					Expect(container.Events()).To(ContainElement("failed to restore cpu limits: cpu disaster"))
+					Expect(container.Events()).To(ContainElement("failed to restore bandwidth limits: bandwidth disaster"))
+					Expect(container.Events()).To(ContainElement("failed to restore disk limits: disk disaster"))
+				})
+
+				It("folds repeated failures into one event", func() {
+					Expect(container.Restore(snapshot)).To(Succeed())
+					Expect(container.Restore(snapshot)).To(Succeed())
+
+					cpuEvents := []linux_container.Event{}
+					for _, event := range container.EventLog() {
+						if event.Type == "failed to restore cpu limits" {
+							cpuEvents = append(cpuEvents, event)
+						}
+					}
+
+					Expect(cpuEvents).To(HaveLen(1))
+					Expect(cpuEvents[0].Details).To(Equal("cpu disaster"))
+					Expect(cpuEvents[0].Count).To(Equal(2))
+				})
+			})
+		})
 	})
 })
@@ -0,0 +1,163 @@
+package linux_container
+
+import (
+	"fmt"
+	"os/exec"
+	"path"
+	"time"
+
+	"github.com/cloudfoundry-incubator/garden"
+	"github.com/cloudfoundry-incubator/garden-linux/linux_backend"
+	"github.com/pivotal-golang/lager"
+)
+
+// GracefulStop signals every process in the container, waits for them to
+// exit for the spec's grace period, and sends the escalation signal to any
+// that are still running. Unlike Stop, it reports how each process went.
+// Once they have all exited, whatever is left in the container, wshd and
+// processes which were not tracked included, is killed with stop.sh.
+//
+// If any escalated process is still running once the spec's escalation
+// timeout is over, the container is left as it is and a
+// ProcessesNotStoppedError naming those processes is returned along with the
+// results of the processes which did exit.
+func (c *LinuxContainer) GracefulStop(spec linux_backend.StopSpec) ([]linux_backend.ProcessStopResult, error) {
+	cLog := c.logger.Session("graceful-stop", lager.Data{
+		"grace-period": spec.GracePeriod.String(),
+	})
+
+	cLog.Debug("stopping")
+
+	if c.State() == StatePaused {
+		// frozen processes cannot handle the signals sent to stop them
+		err := c.thaw()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	escalationSignal := garden.SignalKill
+	if spec.EscalationSignal != nil {
+		escalationSignal = *spec.EscalationSignal
+	}
+
+	escalationTimeout := spec.EscalationTimeout
+	if escalationTimeout == 0 {
+		escalationTimeout = linux_backend.DefaultEscalationTimeout
+	}
+
+	processes := c.processTracker.ActiveProcesses()
+
+	results := make([]linux_backend.ProcessStopResult, len(processes))
+	exited := make([]chan struct{}, len(processes))
+
+	for i, process := range processes {
+		results[i].ProcessID = process.ID()
+		exited[i] = make(chan struct{})
+
+		err := process.Signal(spec.Signal)
+		if err != nil {
+			cLog.Error("failed-to-signal", err, lager.Data{
+				"process": process.ID(),
+			})
+		}
+
+		go func(result *linux_backend.ProcessStopResult, process garden.Process, exited chan<- struct{}) {
+			result.ExitStatus, result.Err = process.Wait()
+			close(exited)
+		}(&results[i], process, exited[i])
+	}
+
+	grace := time.NewTimer(spec.GracePeriod)
+	defer grace.Stop()
+
+	graceExpired := false
+
+	for i, process := range processes {
+		if !graceExpired {
+			select {
+			case <-exited[i]:
+				continue
+			case <-grace.C:
+				graceExpired = true
+			}
+		}
+
+		select {
+		case <-exited[i]:
+			continue
+		default:
+		}
+
+		results[i].Escalated = true
+
+		err := process.Signal(escalationSignal)
+		if err != nil {
+			cLog.Error("failed-to-escalate", err, lager.Data{
+				"process": process.ID(),
+			})
+		}
+
+		c.registerEvent("process required escalation signal", fmt.Sprintf("%d", process.ID()))
+	}
+
+	escalation := time.NewTimer(escalationTimeout)
+	defer escalation.Stop()
+
+	escalationExpired := false
+
+	for _, e := range exited {
+		select {
+		case <-e:
+			continue
+		case <-escalation.C:
+			escalationExpired = true
+		}
+
+		break
+	}
+
+	if escalationExpired {
+		// the results of the processes which are still running are being
+		// written to by their waiters, so only those which exited are copied
+		stopped := make([]linux_backend.ProcessStopResult, len(processes))
+		notStopped := ProcessesNotStoppedError{}
+
+		for i := range processes {
+			select {
+			case <-exited[i]:
+				stopped[i] = results[i]
+			default:
+				stopped[i] = linux_backend.ProcessStopResult{
+					ProcessID: results[i].ProcessID,
+					Escalated: results[i].Escalated,
+				}
+
+				notStopped.ProcessIDs = append(notStopped.ProcessIDs, results[i].ProcessID)
+			}
+		}
+
+		if len(notStopped.ProcessIDs) > 0 {
+			cLog.Error("processes-not-stopped", notStopped)
+			return stopped, notStopped
+		}
+	}
+
+	err := c.runner.Run(exec.Command(path.Join(c.path, "stop.sh"), "-w", "0"))
+	if err != nil {
+		cLog.Error("failed-to-kill-remaining-processes", err)
+		return results, err
+	}
+
+	c.stopOomNotifier()
+
+	c.setState(StateStopped)
+
+	c.saveSnapshot()
+
+	c.emit(linux_backend.EventStopped, nil)
+
+	cLog.Info("stopped")
+
+	return results, nil
+}
@@ -0,0 +1,198 @@
+package linux_container
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/cloudfoundry-incubator/garden"
+	"github.com/cloudfoundry-incubator/garden-linux/linux_backend"
+	"github.com/pivotal-golang/lager"
+)
+
+type TrafficPolicyNotFoundError struct {
+	Policy linux_backend.TrafficPolicy
+}
+
+func (err TrafficPolicyNotFoundError) Error() string {
+	return fmt.Sprintf("traffic policy is not allowed: %+v", err.Policy)
+}
+
+type TrafficPolicySourceMismatchError struct {
+	Policy linux_backend.TrafficPolicy
+	Handle string
+}
+
+func (err TrafficPolicySourceMismatchError) Error() string {
+	return fmt.Sprintf("traffic policy is not for container %s: %+v", err.Handle, err.Policy)
+}
+
+// trafficPolicy is a policy of which this container is the source, along
+// with the IPs of the destination its rule was applied for. The destinations
+// are nil while the rule is not applied, e.g. after a restore until the
+// backend has looked up the destination container again.
+type trafficPolicy struct {
+	linux_backend.TrafficPolicy
+	destinations []net.IP
+}
+
+// AllowTraffic applies the rule for the policy to the container's filter
+// chain, to reach the destination container at the given IPs, e.g. both the
+// IPv4 and IPv6 addresses of a dual-stack container. If the policy was
+// already allowed for other IPs, the rule for those IPs is replaced.
+func (c *LinuxContainer) AllowTraffic(policy linux_backend.TrafficPolicy, destinations []net.IP) error {
+	cLog := c.logger.Session("allow-traffic", lager.Data{
+		"policy":       policy,
+		"destinations": destinations,
+	})
+
+	if policy.SourceHandle != c.Handle() {
+		return TrafficPolicySourceMismatchError{Policy: policy, Handle: c.Handle()}
+	}
+
+	c.trafficPoliciesMutex.Lock()
+
+	i := c.findTrafficPolicy(policy)
+	if i != -1 && sameIPs(c.trafficPolicies[i].destinations, destinations) {
+		c.trafficPoliciesMutex.Unlock()
+		return nil
+	}
+
+	err := c.filter.NetOut(trafficPolicyRule(policy, destinations))
+	if err != nil {
+		c.trafficPoliciesMutex.Unlock()
+		cLog.Error("failed-to-apply-rule", err)
+		return err
+	}
+
+	if i == -1 {
+		c.trafficPolicies = append(c.trafficPolicies, trafficPolicy{policy, destinations})
+	} else {
+		stale := c.trafficPolicies[i].destinations
+		if stale != nil {
+			err := c.filter.NetOutRemove(trafficPolicyRule(policy, stale))
+			if err != nil {
+				cLog.Error("failed-to-delete-stale-rule", err, lager.Data{
+					"stale": stale,
+				})
+			}
+		}
+
+		c.trafficPolicies[i].destinations = destinations
+	}
+
+	c.trafficPoliciesMutex.Unlock()
+
+	c.saveSnapshot()
+
+	c.emit(linux_backend.EventNetRuleAdded, map[string]string{
+		"rule":        "traffic-policy",
+		"destination": policy.DestinationHandle,
+		"protocol":    fmt.Sprintf("%d", policy.Protocol),
+		"port":        fmt.Sprintf("%d", policy.Port),
+	})
+
+	return nil
+}
+
+// DenyTraffic removes a policy allowed by AllowTraffic, along with its rule.
+func (c *LinuxContainer) DenyTraffic(policy linux_backend.TrafficPolicy) error {
+	cLog := c.logger.Session("deny-traffic", lager.Data{
+		"policy": policy,
+	})
+
+	c.trafficPoliciesMutex.Lock()
+
+	i := c.findTrafficPolicy(policy)
+	if i == -1 {
+		c.trafficPoliciesMutex.Unlock()
+		return TrafficPolicyNotFoundError{Policy: policy}
+	}
+
+	if destinations := c.trafficPolicies[i].destinations; destinations != nil {
+		err := c.filter.NetOutRemove(trafficPolicyRule(policy, destinations))
+		if err != nil {
+			c.trafficPoliciesMutex.Unlock()
+			cLog.Error("failed-to-delete-rule", err)
+			return err
+		}
+	}
+
+	c.trafficPolicies = append(c.trafficPolicies[:i:i], c.trafficPolicies[i+1:]...)
+
+	c.trafficPoliciesMutex.Unlock()
+
+	c.saveSnapshot()
+
+	c.emit(linux_backend.EventNetRuleRemoved, map[string]string{
+		"rule":        "traffic-policy",
+		"destination": policy.DestinationHandle,
+		"protocol":    fmt.Sprintf("%d", policy.Protocol),
+		"port":        fmt.Sprintf("%d", policy.Port),
+	})
+
+	return nil
+}
+
+// TrafficPolicies returns the policies of which the container is the source,
+// in the order they were allowed.
+func (c *LinuxContainer) TrafficPolicies() []linux_backend.TrafficPolicy {
+	c.trafficPoliciesMutex.RLock()
+	defer c.trafficPoliciesMutex.RUnlock()
+
+	return c.trafficPolicySpecs()
+}
+
+// trafficPolicySpecs must be called with trafficPoliciesMutex held.
+func (c *LinuxContainer) trafficPolicySpecs() []linux_backend.TrafficPolicy {
+	policies := make([]linux_backend.TrafficPolicy, len(c.trafficPolicies))
+	for i, p := range c.trafficPolicies {
+		policies[i] = p.TrafficPolicy
+	}
+
+	return policies
+}
+
+func (c *LinuxContainer) findTrafficPolicy(policy linux_backend.TrafficPolicy) int {
+	for i, p := range c.trafficPolicies {
+		if p.TrafficPolicy == policy {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// sameIPs reports whether a and b hold the same IPs in the same order.
+func sameIPs(a, b []net.IP) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if !a[i].Equal(b[i]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// trafficPolicyRule returns the rule for the policy, with a network for each
+// of the destinations. The filter applies each network with the iptables
+// binary of its family.
+func trafficPolicyRule(policy linux_backend.TrafficPolicy, destinations []net.IP) garden.NetOutRule {
+	rule := garden.NetOutRule{
+		Protocol: policy.Protocol,
+	}
+
+	for _, destination := range destinations {
+		rule.Networks = append(rule.Networks, garden.IPRange{Start: destination})
+	}
+
+	// ICMP and ProtocolAll policies have no port, and cover every port
+	if policy.Port != 0 {
+		rule.Ports = []garden.PortRange{{Start: policy.Port, End: policy.Port}}
+	}
+
+	return rule
+}
@@ -44,6 +44,12 @@ type FakeBridgeManager struct {
 	pruneReturns     struct {
 		result1 error
 	}
+	InventoryStub        func() bridgemgr.Inventory
+	inventoryMutex       sync.RWMutex
+	inventoryArgsForCall []struct{}
+	inventoryReturns     struct {
+		result1 bridgemgr.Inventory
+	}
 }
 
 func (fake *FakeBridgeManager) Reserve(subnet *net.IPNet, containerId string) (string, error) {
@@ -171,4 +177,28 @@ func (fake *FakeBridgeManager) PruneReturns(result1 error) {
 	}{result1}
 }
 
+func (fake *FakeBridgeManager) Inventory() bridgemgr.Inventory {
+	fake.inventoryMutex.Lock()
+	fake.inventoryArgsForCall = append(fake.inventoryArgsForCall, struct{}{})
+	fake.inventoryMutex.Unlock()
+	if fake.InventoryStub != nil {
+		return fake.InventoryStub()
+	} else {
+		return fake.inventoryReturns.result1
+	}
+}
+
+func (fake *FakeBridgeManager) InventoryCallCount() int {
+	fake.inventoryMutex.RLock()
+	defer fake.inventoryMutex.RUnlock()
+	return len(fake.inventoryArgsForCall)
+}
+
+func (fake *FakeBridgeManager) InventoryReturns(result1 bridgemgr.Inventory) {
+	fake.InventoryStub = nil
+	fake.inventoryReturns = struct {
+		result1 bridgemgr.Inventory
+	}{result1}
+}
+
 var _ bridgemgr.BridgeManager = new(FakeBridgeManager)
@@ -34,6 +34,19 @@ type BridgeManager interface {
 
 	// Prune deletes all bridges starting with prefix, that are unknown.
 	Prune() error
+
+	// Inventory returns a copy of the reservations of every bridge, for diagnostics.
+	Inventory() Inventory
+}
+
+// Inventory maps the name of each reserved bridge to its reservation.
+type Inventory struct {
+	Bridges map[string]Reservation
+}
+
+type Reservation struct {
+	Subnet       string
+	ContainerIDs []string
 }
 
 type mgr struct {
@@ -140,6 +153,21 @@ func (m *mgr) Prune() error {
 	return nil
 }
 
+func (m *mgr) Inventory() Inventory {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	inventory := Inventory{Bridges: make(map[string]Reservation, len(m.bridgeSubnet))}
+	for name, subnet := range m.bridgeSubnet {
+		owners := make([]string, len(m.owners[name]))
+		copy(owners, m.owners[name])
+
+		inventory.Bridges[name] = Reservation{Subnet: subnet, ContainerIDs: owners}
+	}
+
+	return inventory
+}
+
 func (m *mgr) isReserved(r string) bool {
 	_, ok := m.bridgeSubnet[r]
 	return ok
@@ -238,6 +238,34 @@ var _ = Describe("BridgeNamePool", func() {
 			})
 		})
 	})
+
+	Describe("inventory", func() {
+		It("lists the subnet and containers of each reserved bridge", func() {
+			name1, err := mgr.Reserve(subnet1, "container1")
+			Expect(err).ToNot(HaveOccurred())
+
+			_, err = mgr.Reserve(subnet1, "container2")
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(mgr.Rereserve("pr-234", subnet2, "container3")).To(Succeed())
+
+			Expect(mgr.Inventory()).To(Equal(bridgemgr.Inventory{
+				Bridges: map[string]bridgemgr.Reservation{
+					name1:    {Subnet: "1.2.3.4/30", ContainerIDs: []string{"container1", "container2"}},
+					"pr-234": {Subnet: "1.2.3.0/29", ContainerIDs: []string{"container3"}},
+				},
+			}))
+		})
+
+		It("stops listing a bridge once it has been released", func() {
+			name, err := mgr.Reserve(subnet1, "container1")
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(mgr.Release(name, "container1")).To(Succeed())
+
+			Expect(mgr.Inventory().Bridges).To(BeEmpty())
+		})
+	})
 })
 
 type builder struct {
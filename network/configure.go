@@ -50,6 +50,10 @@ type HostConfig struct {
 	ContainerPid  int
 	Subnet        *net.IPNet
 	Mtu           int
+
+	// BridgeIPv6 and IPv6Subnet are only set for dual-stack containers.
+	BridgeIPv6 net.IP
+	IPv6Subnet *net.IPNet
 }
 
 func (c *NetworkConfigurer) ConfigureHost(config *HostConfig) error {
@@ -76,6 +80,14 @@ func (c *NetworkConfigurer) ConfigureHost(config *HostConfig) error {
 		return err
 	}
 
+	if config.IPv6Subnet != nil {
+		// the bridge is shared by the IPv4 subnet, but each container has its own IPv6 subnet
+		if err = c.Link.AddIP(bridge, config.BridgeIPv6, config.IPv6Subnet); err != nil {
+			cLog.Error("add-bridge-ipv6", err)
+			return &ConfigureLinkError{err, "bridge", bridge, config.BridgeIPv6, config.IPv6Subnet}
+		}
+	}
+
 	if host, container, err = c.configureVethPair(cLog, config.HostIntf, config.ContainerIntf); err != nil {
 		return err
 	}
@@ -157,10 +169,15 @@ type ContainerConfig struct {
 	GatewayIP     net.IP
 	Subnet        *net.IPNet
 	Mtu           int
+
+	// ContainerIPv6, GatewayIPv6 and IPv6Subnet are only set for dual-stack containers.
+	ContainerIPv6 net.IP
+	GatewayIPv6   net.IP
+	IPv6Subnet    *net.IPNet
 }
 
 func (c *NetworkConfigurer) ConfigureContainer(config *ContainerConfig) error {
-	if err := c.configureLoopbackIntf(); err != nil {
+	if err := c.configureLoopbackIntf(config.IPv6Subnet != nil); err != nil {
 		return err
 	}
 
@@ -174,6 +191,17 @@ func (c *NetworkConfigurer) ConfigureContainer(config *ContainerConfig) error {
 		return err
 	}
 
+	if config.IPv6Subnet != nil {
+		if err := c.configureContainerIPv6(
+			config.ContainerIntf,
+			config.ContainerIPv6,
+			config.GatewayIPv6,
+			config.IPv6Subnet,
+		); err != nil {
+			return err
+		}
+	}
+
 	return c.Hostname.SetHostname(config.Hostname)
 }
 
@@ -203,7 +231,27 @@ func (c *NetworkConfigurer) configureContainerIntf(name string, ip, gatewayIP ne
 	return nil
 }
 
-func (c *NetworkConfigurer) configureLoopbackIntf() (err error) {
+// configureContainerIPv6 adds an IPv6 address and default route to the container
+// interface, which configureContainerIntf has already brought up.
+func (c *NetworkConfigurer) configureContainerIPv6(name string, ip, gatewayIP net.IP, subnet *net.IPNet) (err error) {
+	var found bool
+	var intf *net.Interface
+	if intf, found, err = c.Link.InterfaceByName(name); !found || err != nil {
+		return &FindLinkError{err, "container", name}
+	}
+
+	if err := c.Link.AddIP(intf, ip, subnet); err != nil {
+		return &ConfigureLinkError{err, "container", intf, ip, subnet}
+	}
+
+	if err := c.Link.AddDefaultGW(intf, gatewayIP); err != nil {
+		return &ConfigureDefaultGWError{err, intf, gatewayIP}
+	}
+
+	return nil
+}
+
+func (c *NetworkConfigurer) configureLoopbackIntf(ipv6 bool) (err error) {
 	var found bool
 	var lo *net.Interface
 	if lo, found, err = c.Link.InterfaceByName("lo"); !found || err != nil {
@@ -219,6 +267,17 @@ func (c *NetworkConfigurer) configureLoopbackIntf() (err error) {
 		return &ConfigureLinkError{err, "loopback", lo, ip, subnet}
 	}
 
+	if ipv6 {
+		ip, subnet, err := net.ParseCIDR("::1/128")
+		if err != nil {
+			panic("can't parse ::1/128 as a CIDR") // cant happen
+		}
+
+		if err := c.Link.AddIP(lo, ip, subnet); err != nil {
+			return &ConfigureLinkError{err, "loopback", lo, ip, subnet}
+		}
+	}
+
 	if err := c.Link.SetUp(lo); err != nil {
 		return &LinkUpError{err, lo, "loopback"}
 	}
@@ -157,6 +157,38 @@ var _ = Describe("Configure", func() {
 				})
 			})
 
+			Context("when the container is dual-stack", func() {
+				BeforeEach(func() {
+					config.BridgeName = "bridge"
+					config.BridgeIPv6 = net.ParseIP("fd00::2")
+					_, config.IPv6Subnet, _ = net.ParseCIDR("fd00::/126")
+				})
+
+				It("adds the IPv6 gateway address to the bridge", func() {
+					Expect(configurer.ConfigureHost(config)).To(Succeed())
+					Expect(linkConfigurer.AddIPCalledWith).To(ContainElement(fakedevices.InterfaceIPAndSubnet{
+						existingBridge,
+						config.BridgeIPv6,
+						config.IPv6Subnet,
+					}))
+				})
+
+				Context("when adding the address fails", func() {
+					It("returns a wrapped error", func() {
+						linkConfigurer.AddIPReturns["bridge"] = errors.New("o no")
+
+						err := configurer.ConfigureHost(config)
+						Expect(err).To(MatchError(&network.ConfigureLinkError{
+							errors.New("o no"),
+							"bridge",
+							existingBridge,
+							config.BridgeIPv6,
+							config.IPv6Subnet,
+						}))
+					})
+				})
+			})
+
 		})
 	})
 
@@ -379,6 +411,50 @@ var _ = Describe("Configure", func() {
 					Expect(err).To(MatchError(&network.ConfigureDefaultGWError{linkConfigurer.AddDefaultGWReturns, &net.Interface{Name: "foo"}, net.ParseIP("2.3.4.5")}))
 				})
 			})
+
+			Context("when the container is dual-stack", func() {
+				BeforeEach(func() {
+					config.ContainerIntf = "foo"
+					config.ContainerIP, config.Subnet, _ = net.ParseCIDR("2.3.4.5/30")
+					config.GatewayIP = net.ParseIP("2.3.4.6")
+					config.ContainerIPv6, config.IPv6Subnet, _ = net.ParseCIDR("fd00::1/126")
+					config.GatewayIPv6 = net.ParseIP("fd00::2")
+				})
+
+				It("adds ::1/128 to the loopback device", func() {
+					ip, subnet, _ := net.ParseCIDR("::1/128")
+					Expect(configurer.ConfigureContainer(config)).To(Succeed())
+					Expect(linkConfigurer.AddIPCalledWith).To(ContainElement(fakedevices.InterfaceIPAndSubnet{&net.Interface{Name: "lo"}, ip, subnet}))
+				})
+
+				It("adds the requested IPv6 address as well as the IPv4 one", func() {
+					Expect(configurer.ConfigureContainer(config)).To(Succeed())
+					Expect(linkConfigurer.AddIPCalledWith).To(ContainElement(fakedevices.InterfaceIPAndSubnet{
+						&net.Interface{Name: "foo"},
+						config.ContainerIP,
+						config.Subnet,
+					}))
+					Expect(linkConfigurer.AddIPCalledWith).To(ContainElement(fakedevices.InterfaceIPAndSubnet{
+						&net.Interface{Name: "foo"},
+						config.ContainerIPv6,
+						config.IPv6Subnet,
+					}))
+				})
+
+				It("adds a default IPv6 gateway", func() {
+					Expect(configurer.ConfigureContainer(config)).To(Succeed())
+					Expect(linkConfigurer.AddDefaultGWCalledWith.Interface).To(Equal(&net.Interface{Name: "foo"}))
+					Expect(linkConfigurer.AddDefaultGWCalledWith.IP).To(Equal(net.ParseIP("fd00::2")))
+				})
+			})
+
+			Context("when the container is not dual-stack", func() {
+				It("does not add ::1/128 to the loopback device", func() {
+					ip, subnet, _ := net.ParseCIDR("::1/128")
+					Expect(configurer.ConfigureContainer(config)).To(Succeed())
+					Expect(linkConfigurer.AddIPCalledWith).ToNot(ContainElement(fakedevices.InterfaceIPAndSubnet{&net.Interface{Name: "lo"}, ip, subnet}))
+				})
+			})
 		})
 	})
 })
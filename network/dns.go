@@ -0,0 +1,250 @@
+package network
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/cloudfoundry-incubator/garden"
+)
+
+// Containers are given DNS configuration through properties of their spec, as
+// garden.ContainerSpec has no fields for it. Each property is a comma separated
+// list; extra hosts are given as hostname:ip, e.g. "db:10.0.0.5,cache:fd00::5".
+const (
+	DNSServersProperty       = "garden.network.dns-servers"
+	DNSSearchDomainsProperty = "garden.network.dns-search-domains"
+	ExtraHostsProperty       = "garden.network.extra-hosts"
+)
+
+// DNSConfig is the name resolution configuration of a container, which is
+// written into its rootfs when it is created.
+type DNSConfig struct {
+	Servers       []net.IP    `json:",omitempty"`
+	SearchDomains []string    `json:",omitempty"`
+	ExtraHosts    []HostEntry `json:",omitempty"`
+}
+
+type HostEntry struct {
+	Hostname string
+	IP       net.IP
+}
+
+// ParseDNSConfig reads the DNS configuration from the properties of a
+// container's spec. It returns nil if none of the properties are set.
+func ParseDNSConfig(properties garden.Properties) (*DNSConfig, error) {
+	config := &DNSConfig{}
+
+	for _, server := range splitProperty(properties, DNSServersProperty) {
+		ip := net.ParseIP(server)
+		if ip == nil {
+			return nil, InvalidDNSPropertyError{DNSServersProperty, server}
+		}
+
+		config.Servers = append(config.Servers, ip)
+	}
+
+	for _, domain := range splitProperty(properties, DNSSearchDomainsProperty) {
+		if !isDNSName(domain) {
+			return nil, InvalidDNSPropertyError{DNSSearchDomainsProperty, domain}
+		}
+
+		config.SearchDomains = append(config.SearchDomains, domain)
+	}
+
+	for _, entry := range splitProperty(properties, ExtraHostsProperty) {
+		parts := strings.SplitN(entry, ":", 2)
+		if len(parts) != 2 || !isDNSName(parts[0]) {
+			return nil, InvalidDNSPropertyError{ExtraHostsProperty, entry}
+		}
+
+		ip := net.ParseIP(parts[1])
+		if ip == nil {
+			return nil, InvalidDNSPropertyError{ExtraHostsProperty, entry}
+		}
+
+		config.ExtraHosts = append(config.ExtraHosts, HostEntry{Hostname: parts[0], IP: ip})
+	}
+
+	if len(config.Servers) == 0 && len(config.SearchDomains) == 0 && len(config.ExtraHosts) == 0 {
+		return nil, nil
+	}
+
+	return config, nil
+}
+
+// isDNSName reports whether the name is a valid host or domain name: dot
+// separated labels of up to 63 letters, digits and hyphens, which neither
+// start nor end with a hyphen, with an optional trailing dot. Anything else,
+// such as whitespace or control characters, could be written into
+// resolv.conf or hosts as more than the one name.
+func isDNSName(name string) bool {
+	name = strings.TrimSuffix(name, ".")
+	if name == "" || len(name) > 253 {
+		return false
+	}
+
+	for _, label := range strings.Split(name, ".") {
+		if label == "" || len(label) > 63 {
+			return false
+		}
+
+		if label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+
+		for _, c := range label {
+			switch {
+			case c >= 'a' && c <= 'z':
+			case c >= 'A' && c <= 'Z':
+			case c >= '0' && c <= '9':
+			case c == '-':
+			default:
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+func splitProperty(properties garden.Properties, key string) []string {
+	values := []string{}
+	for _, value := range strings.Split(properties[key], ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+
+	return values
+}
+
+// WriteTo writes the configuration into the /etc/resolv.conf and /etc/hosts of
+// the rootfs. Lines of the files which it does not override are kept, so the
+// container still resolves its own hostname and, unless servers are
+// configured, uses the nameservers it inherited from the host.
+func (c *DNSConfig) WriteTo(rootfsPath string) error {
+	resolvConf := filepath.Join(rootfsPath, "etc", "resolv.conf")
+
+	lines, err := readLines(resolvConf)
+	if err != nil {
+		return DNSConfigError{err, resolvConf}
+	}
+
+	if len(c.Servers) > 0 {
+		lines = withoutKeywords(lines, "nameserver")
+		for _, server := range c.Servers {
+			lines = append(lines, "nameserver "+server.String())
+		}
+	}
+
+	if len(c.SearchDomains) > 0 {
+		lines = withoutKeywords(lines, "search", "domain")
+		lines = append(lines, "search "+strings.Join(c.SearchDomains, " "))
+	}
+
+	if err := replaceFile(resolvConf, lines); err != nil {
+		return DNSConfigError{err, resolvConf}
+	}
+
+	hosts := filepath.Join(rootfsPath, "etc", "hosts")
+
+	lines, err = readLines(hosts)
+	if err != nil {
+		return DNSConfigError{err, hosts}
+	}
+
+	for _, entry := range c.ExtraHosts {
+		lines = append(lines, entry.IP.String()+" "+entry.Hostname)
+	}
+
+	if err := replaceFile(hosts, lines); err != nil {
+		return DNSConfigError{err, hosts}
+	}
+
+	return nil
+}
+
+// readLines reads the lines of a file of the rootfs. Anything other than a
+// regular file, such as a symlink to a file of the host, reads as empty.
+func readLines(path string) ([]string, error) {
+	info, err := os.Lstat(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if !info.Mode().IsRegular() {
+		return nil, nil
+	}
+
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	lines := []string{}
+
+	scanner := bufio.NewScanner(bytes.NewReader(contents))
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+
+	return lines, scanner.Err()
+}
+
+func withoutKeywords(lines []string, keywords ...string) []string {
+	kept := []string{}
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) > 0 && contains(keywords, fields[0]) {
+			continue
+		}
+
+		kept = append(kept, line)
+	}
+
+	return kept
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+// replaceFile writes the file by renaming a new file over it, so that a
+// symlink in the rootfs is replaced rather than followed out of it.
+func replaceFile(path string, lines []string) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path))
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(tmp.Name())
+
+	_, err = fmt.Fprintln(tmp, strings.Join(lines, "\n"))
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
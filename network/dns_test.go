@@ -0,0 +1,216 @@
+package network_test
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/cloudfoundry-incubator/garden"
+	"github.com/cloudfoundry-incubator/garden-linux/network"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("DNSConfig", func() {
+	Describe("ParseDNSConfig", func() {
+		It("parses the servers, search domains and extra hosts", func() {
+			config, err := network.ParseDNSConfig(garden.Properties{
+				network.DNSServersProperty:       "8.8.8.8, fd00::53",
+				network.DNSSearchDomainsProperty: "example.com,svc.local",
+				network.ExtraHostsProperty:       "db:10.0.0.5,cache:fd00::5",
+			})
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(config).To(Equal(&network.DNSConfig{
+				Servers:       []net.IP{net.ParseIP("8.8.8.8"), net.ParseIP("fd00::53")},
+				SearchDomains: []string{"example.com", "svc.local"},
+				ExtraHosts: []network.HostEntry{
+					{Hostname: "db", IP: net.ParseIP("10.0.0.5")},
+					{Hostname: "cache", IP: net.ParseIP("fd00::5")},
+				},
+			}))
+		})
+
+		Context("when none of the properties are set", func() {
+			It("returns nil", func() {
+				config, err := network.ParseDNSConfig(garden.Properties{"foo": "bar"})
+				Expect(err).ToNot(HaveOccurred())
+				Expect(config).To(BeNil())
+			})
+		})
+
+		Context("when a server is not an IP", func() {
+			It("returns an InvalidDNSPropertyError", func() {
+				_, err := network.ParseDNSConfig(garden.Properties{
+					network.DNSServersProperty: "8.8.8.8,dns.example.com",
+				})
+				Expect(err).To(Equal(network.InvalidDNSPropertyError{network.DNSServersProperty, "dns.example.com"}))
+			})
+		})
+
+		Context("when an extra host has no IP", func() {
+			It("returns an InvalidDNSPropertyError", func() {
+				_, err := network.ParseDNSConfig(garden.Properties{
+					network.ExtraHostsProperty: "db",
+				})
+				Expect(err).To(MatchError("network: invalid value for property garden.network.extra-hosts: 'db'"))
+			})
+		})
+
+		Context("when a search domain is not a DNS name", func() {
+			It("returns an InvalidDNSPropertyError", func() {
+				for _, domain := range []string{
+					"example.com\nnameserver 6.6.6.6",
+					"exa mple.com",
+					"example..com",
+					"-example.com",
+					"example-.com",
+					"ex@mple.com",
+					strings.Repeat("a", 64) + ".com",
+				} {
+					_, err := network.ParseDNSConfig(garden.Properties{
+						network.DNSSearchDomainsProperty: domain,
+					})
+					Expect(err).To(Equal(network.InvalidDNSPropertyError{network.DNSSearchDomainsProperty, domain}))
+				}
+			})
+		})
+
+		It("accepts fully qualified search domains", func() {
+			config, err := network.ParseDNSConfig(garden.Properties{
+				network.DNSSearchDomainsProperty: "example.com.",
+			})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(config.SearchDomains).To(Equal([]string{"example.com."}))
+		})
+
+		Context("when an extra host's name is not a DNS name", func() {
+			It("returns an InvalidDNSPropertyError", func() {
+				for _, entry := range []string{
+					":10.0.0.5",
+					"db\x00:10.0.0.5",
+					"db cache:10.0.0.5",
+					"db/1:10.0.0.5",
+				} {
+					_, err := network.ParseDNSConfig(garden.Properties{
+						network.ExtraHostsProperty: entry,
+					})
+					Expect(err).To(Equal(network.InvalidDNSPropertyError{network.ExtraHostsProperty, entry}))
+				}
+			})
+		})
+
+		Context("when an extra host has an invalid IP", func() {
+			It("returns an InvalidDNSPropertyError", func() {
+				_, err := network.ParseDNSConfig(garden.Properties{
+					network.ExtraHostsProperty: "db:10.0.0",
+				})
+				Expect(err).To(BeAssignableToTypeOf(network.InvalidDNSPropertyError{}))
+			})
+		})
+	})
+
+	Describe("WriteTo", func() {
+		var rootfsPath string
+
+		BeforeEach(func() {
+			var err error
+			rootfsPath, err = ioutil.TempDir("", "rootfs")
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(os.Mkdir(filepath.Join(rootfsPath, "etc"), 0755)).To(Succeed())
+
+			Expect(ioutil.WriteFile(
+				filepath.Join(rootfsPath, "etc", "resolv.conf"),
+				[]byte("nameserver 10.254.0.1\nsearch host.local\noptions ndots:2\n"),
+				0644,
+			)).To(Succeed())
+
+			Expect(ioutil.WriteFile(
+				filepath.Join(rootfsPath, "etc", "hosts"),
+				[]byte("127.0.0.1 localhost\n10.254.0.2 some-id\n"),
+				0644,
+			)).To(Succeed())
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(rootfsPath)
+		})
+
+		readFile := func(name string) string {
+			contents, err := ioutil.ReadFile(filepath.Join(rootfsPath, "etc", name))
+			Expect(err).ToNot(HaveOccurred())
+			return string(contents)
+		}
+
+		It("replaces the nameservers and search domains, keeping other options", func() {
+			config := &network.DNSConfig{
+				Servers:       []net.IP{net.ParseIP("8.8.8.8"), net.ParseIP("8.8.4.4")},
+				SearchDomains: []string{"example.com", "svc.local"},
+			}
+			Expect(config.WriteTo(rootfsPath)).To(Succeed())
+
+			Expect(readFile("resolv.conf")).To(Equal(
+				"options ndots:2\nnameserver 8.8.8.8\nnameserver 8.8.4.4\nsearch example.com svc.local\n",
+			))
+		})
+
+		It("keeps the inherited nameservers if no servers are configured", func() {
+			config := &network.DNSConfig{SearchDomains: []string{"example.com"}}
+			Expect(config.WriteTo(rootfsPath)).To(Succeed())
+
+			Expect(readFile("resolv.conf")).To(Equal(
+				"nameserver 10.254.0.1\noptions ndots:2\nsearch example.com\n",
+			))
+		})
+
+		It("appends the extra hosts to /etc/hosts", func() {
+			config := &network.DNSConfig{
+				ExtraHosts: []network.HostEntry{
+					{Hostname: "db", IP: net.ParseIP("10.0.0.5")},
+					{Hostname: "cache", IP: net.ParseIP("fd00::5")},
+				},
+			}
+			Expect(config.WriteTo(rootfsPath)).To(Succeed())
+
+			Expect(readFile("hosts")).To(Equal(
+				"127.0.0.1 localhost\n10.254.0.2 some-id\n10.0.0.5 db\nfd00::5 cache\n",
+			))
+		})
+
+		Context("when a file is a symlink", func() {
+			var hostFile string
+
+			BeforeEach(func() {
+				hostFile = filepath.Join(rootfsPath, "host-file")
+				Expect(ioutil.WriteFile(hostFile, []byte("secret\n"), 0600)).To(Succeed())
+
+				resolvConf := filepath.Join(rootfsPath, "etc", "resolv.conf")
+				Expect(os.Remove(resolvConf)).To(Succeed())
+				Expect(os.Symlink(hostFile, resolvConf)).To(Succeed())
+			})
+
+			It("replaces the symlink without reading or writing its target", func() {
+				config := &network.DNSConfig{SearchDomains: []string{"example.com"}}
+				Expect(config.WriteTo(rootfsPath)).To(Succeed())
+
+				Expect(readFile("resolv.conf")).To(Equal("search example.com\n"))
+
+				contents, err := ioutil.ReadFile(hostFile)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(string(contents)).To(Equal("secret\n"))
+			})
+		})
+
+		Context("when the rootfs has no /etc", func() {
+			It("returns a DNSConfigError", func() {
+				Expect(os.RemoveAll(filepath.Join(rootfsPath, "etc"))).To(Succeed())
+
+				config := &network.DNSConfig{SearchDomains: []string{"example.com"}}
+				Expect(config.WriteTo(rootfsPath)).To(BeAssignableToTypeOf(network.DNSConfigError{}))
+			})
+		})
+	})
+})
@@ -115,6 +115,36 @@ func (err DeleteLinkError) Error() string {
 	return fmtErr("failed to delete %s link named %s: %v", err.Role, err.Name, err.Cause)
 }
 
+// InvalidDNSPropertyError is returned if a DNS property of a container spec cannot be parsed
+type InvalidDNSPropertyError struct {
+	Property string
+	Value    string
+}
+
+func (err InvalidDNSPropertyError) Error() string {
+	return fmtErr("invalid value for property %s: '%s'", err.Property, err.Value)
+}
+
+// DNSConfigError is returned if the DNS configuration cannot be written into a rootfs
+type DNSConfigError struct {
+	Cause error
+	Path  string
+}
+
+func (err DNSConfigError) Error() string {
+	return fmtErr("failed to write DNS configuration to %s: %v", err.Path, err.Cause)
+}
+
+// InterfaceStatsError is returned if the counters of an interface cannot be read
+type InterfaceStatsError struct {
+	Cause     error
+	Interface string
+}
+
+func (err InterfaceStatsError) Error() string {
+	return fmtErr("failed to read statistics of interface %s: %v", err.Interface, err.Cause)
+}
+
 func fmtErr(msg string, args ...interface{}) string {
 	return fmt.Sprintf("network: "+msg, args...)
 }
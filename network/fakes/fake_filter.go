@@ -6,6 +6,7 @@ import (
 
 	"github.com/cloudfoundry-incubator/garden"
 	"github.com/cloudfoundry-incubator/garden-linux/network"
+	"github.com/cloudfoundry-incubator/garden-linux/network/iptables"
 )
 
 type FakeFilter struct {
@@ -28,6 +29,29 @@ type FakeFilter struct {
 	netOutReturns struct {
 		result1 error
 	}
+	BulkNetOutStub        func(arg1 []garden.NetOutRule) error
+	bulkNetOutMutex       sync.RWMutex
+	bulkNetOutArgsForCall []struct {
+		arg1 []garden.NetOutRule
+	}
+	bulkNetOutReturns struct {
+		result1 error
+	}
+	NetOutRemoveStub        func(arg1 garden.NetOutRule) error
+	netOutRemoveMutex       sync.RWMutex
+	netOutRemoveArgsForCall []struct {
+		arg1 garden.NetOutRule
+	}
+	netOutRemoveReturns struct {
+		result1 error
+	}
+	NetOutCountersStub        func() ([]iptables.RuleCounter, error)
+	netOutCountersMutex       sync.RWMutex
+	netOutCountersArgsForCall []struct{}
+	netOutCountersReturns     struct {
+		result1 []iptables.RuleCounter
+		result2 error
+	}
 }
 
 func (fake *FakeFilter) Setup(logPrefix string) error {
@@ -109,4 +133,93 @@ func (fake *FakeFilter) NetOutReturns(result1 error) {
 	}{result1}
 }
 
+func (fake *FakeFilter) BulkNetOut(arg1 []garden.NetOutRule) error {
+	fake.bulkNetOutMutex.Lock()
+	fake.bulkNetOutArgsForCall = append(fake.bulkNetOutArgsForCall, struct {
+		arg1 []garden.NetOutRule
+	}{arg1})
+	fake.bulkNetOutMutex.Unlock()
+	if fake.BulkNetOutStub != nil {
+		return fake.BulkNetOutStub(arg1)
+	} else {
+		return fake.bulkNetOutReturns.result1
+	}
+}
+
+func (fake *FakeFilter) BulkNetOutCallCount() int {
+	fake.bulkNetOutMutex.RLock()
+	defer fake.bulkNetOutMutex.RUnlock()
+	return len(fake.bulkNetOutArgsForCall)
+}
+
+func (fake *FakeFilter) BulkNetOutArgsForCall(i int) []garden.NetOutRule {
+	fake.bulkNetOutMutex.RLock()
+	defer fake.bulkNetOutMutex.RUnlock()
+	return fake.bulkNetOutArgsForCall[i].arg1
+}
+
+func (fake *FakeFilter) BulkNetOutReturns(result1 error) {
+	fake.BulkNetOutStub = nil
+	fake.bulkNetOutReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeFilter) NetOutRemove(arg1 garden.NetOutRule) error {
+	fake.netOutRemoveMutex.Lock()
+	fake.netOutRemoveArgsForCall = append(fake.netOutRemoveArgsForCall, struct {
+		arg1 garden.NetOutRule
+	}{arg1})
+	fake.netOutRemoveMutex.Unlock()
+	if fake.NetOutRemoveStub != nil {
+		return fake.NetOutRemoveStub(arg1)
+	} else {
+		return fake.netOutRemoveReturns.result1
+	}
+}
+
+func (fake *FakeFilter) NetOutRemoveCallCount() int {
+	fake.netOutRemoveMutex.RLock()
+	defer fake.netOutRemoveMutex.RUnlock()
+	return len(fake.netOutRemoveArgsForCall)
+}
+
+func (fake *FakeFilter) NetOutRemoveArgsForCall(i int) garden.NetOutRule {
+	fake.netOutRemoveMutex.RLock()
+	defer fake.netOutRemoveMutex.RUnlock()
+	return fake.netOutRemoveArgsForCall[i].arg1
+}
+
+func (fake *FakeFilter) NetOutRemoveReturns(result1 error) {
+	fake.NetOutRemoveStub = nil
+	fake.netOutRemoveReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeFilter) NetOutCounters() ([]iptables.RuleCounter, error) {
+	fake.netOutCountersMutex.Lock()
+	fake.netOutCountersArgsForCall = append(fake.netOutCountersArgsForCall, struct{}{})
+	fake.netOutCountersMutex.Unlock()
+	if fake.NetOutCountersStub != nil {
+		return fake.NetOutCountersStub()
+	} else {
+		return fake.netOutCountersReturns.result1, fake.netOutCountersReturns.result2
+	}
+}
+
+func (fake *FakeFilter) NetOutCountersCallCount() int {
+	fake.netOutCountersMutex.RLock()
+	defer fake.netOutCountersMutex.RUnlock()
+	return len(fake.netOutCountersArgsForCall)
+}
+
+func (fake *FakeFilter) NetOutCountersReturns(result1 []iptables.RuleCounter, result2 error) {
+	fake.NetOutCountersStub = nil
+	fake.netOutCountersReturns = struct {
+		result1 []iptables.RuleCounter
+		result2 error
+	}{result1, result2}
+}
+
 var _ network.Filter = new(FakeFilter)
@@ -0,0 +1,55 @@
+// This file was generated by counterfeiter
+package fakes
+
+import (
+	"sync"
+
+	"github.com/cloudfoundry-incubator/garden-linux/network"
+)
+
+type FakeInterfaceStatter struct {
+	StatsStub        func(iface string) (network.InterfaceStats, error)
+	statsMutex       sync.RWMutex
+	statsArgsForCall []struct {
+		iface string
+	}
+	statsReturns struct {
+		result1 network.InterfaceStats
+		result2 error
+	}
+}
+
+func (fake *FakeInterfaceStatter) Stats(iface string) (network.InterfaceStats, error) {
+	fake.statsMutex.Lock()
+	fake.statsArgsForCall = append(fake.statsArgsForCall, struct {
+		iface string
+	}{iface})
+	fake.statsMutex.Unlock()
+	if fake.StatsStub != nil {
+		return fake.StatsStub(iface)
+	} else {
+		return fake.statsReturns.result1, fake.statsReturns.result2
+	}
+}
+
+func (fake *FakeInterfaceStatter) StatsCallCount() int {
+	fake.statsMutex.RLock()
+	defer fake.statsMutex.RUnlock()
+	return len(fake.statsArgsForCall)
+}
+
+func (fake *FakeInterfaceStatter) StatsArgsForCall(i int) string {
+	fake.statsMutex.RLock()
+	defer fake.statsMutex.RUnlock()
+	return fake.statsArgsForCall[i].iface
+}
+
+func (fake *FakeInterfaceStatter) StatsReturns(result1 network.InterfaceStats, result2 error) {
+	fake.StatsStub = nil
+	fake.statsReturns = struct {
+		result1 network.InterfaceStats
+		result2 error
+	}{result1, result2}
+}
+
+var _ network.InterfaceStatter = new(FakeInterfaceStatter)
@@ -13,6 +13,12 @@ type Filter interface {
 	Setup(logPrefix string) error
 	TearDown()
 	NetOut(garden.NetOutRule) error
+	BulkNetOut([]garden.NetOutRule) error
+	NetOutRemove(garden.NetOutRule) error
+
+	// NetOutCounters returns how many packets and bytes have matched each rule
+	// of the container's filter chain.
+	NetOutCounters() ([]iptables.RuleCounter, error)
 }
 
 type filter struct {
@@ -37,3 +43,15 @@ func (fltr *filter) TearDown() {
 func (fltr *filter) NetOut(r garden.NetOutRule) error {
 	return fltr.chain.PrependFilterRule(r)
 }
+
+func (fltr *filter) BulkNetOut(rs []garden.NetOutRule) error {
+	return fltr.chain.PrependFilterRules(rs)
+}
+
+func (fltr *filter) NetOutRemove(r garden.NetOutRule) error {
+	return fltr.chain.DeleteFilterRule(r)
+}
+
+func (fltr *filter) NetOutCounters() ([]iptables.RuleCounter, error) {
+	return fltr.chain.Counters()
+}
@@ -5,6 +5,7 @@ import (
 
 	"github.com/cloudfoundry-incubator/garden"
 	"github.com/cloudfoundry-incubator/garden-linux/network"
+	"github.com/cloudfoundry-incubator/garden-linux/network/iptables"
 	"github.com/cloudfoundry-incubator/garden-linux/network/iptables/fakes"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -61,4 +62,55 @@ var _ = Describe("Filter", func() {
 			Expect(filter.NetOut(garden.NetOutRule{})).To(MatchError("iptables says no"))
 		})
 	})
+
+	Context("BulkNetOut", func() {
+		It("prepends the rules to the chain at once", func() {
+			rules := []garden.NetOutRule{
+				{Protocol: garden.ProtocolTCP},
+				{Protocol: garden.ProtocolUDP},
+			}
+			Expect(filter.BulkNetOut(rules)).To(Succeed())
+
+			Expect(fakeChain.PrependFilterRulesCallCount()).To(Equal(1))
+			Expect(fakeChain.PrependFilterRulesArgsForCall(0)).To(Equal(rules))
+		})
+
+		It("returns an error if one occurs", func() {
+			fakeChain.PrependFilterRulesReturns(errors.New("iptables says no"))
+			Expect(filter.BulkNetOut([]garden.NetOutRule{{}})).To(MatchError("iptables says no"))
+		})
+	})
+
+	Context("NetOutRemove", func() {
+		It("deletes the rule from the chain", func() {
+			rule := garden.NetOutRule{Protocol: garden.ProtocolUDP}
+			Expect(filter.NetOutRemove(rule)).To(Succeed())
+
+			Expect(fakeChain.DeleteFilterRuleCallCount()).To(Equal(1))
+			Expect(fakeChain.DeleteFilterRuleArgsForCall(0)).To(Equal(rule))
+		})
+
+		It("returns an error if one occurs", func() {
+			fakeChain.DeleteFilterRuleReturns(errors.New("iptables says no"))
+			Expect(filter.NetOutRemove(garden.NetOutRule{})).To(MatchError("iptables says no"))
+		})
+	})
+
+	Context("NetOutCounters", func() {
+		It("returns the counters of the chain", func() {
+			counters := []iptables.RuleCounter{
+				{Rule: "-p tcp -j RETURN", Packets: 3, Bytes: 180},
+			}
+			fakeChain.CountersReturns(counters, nil)
+
+			Expect(filter.NetOutCounters()).To(Equal(counters))
+		})
+
+		It("returns an error if one occurs", func() {
+			fakeChain.CountersReturns(nil, errors.New("iptables says no"))
+
+			_, err := filter.NetOutCounters()
+			Expect(err).To(MatchError("iptables says no"))
+		})
+	})
 })
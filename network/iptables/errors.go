@@ -0,0 +1,49 @@
+package iptables
+
+import "fmt"
+
+// InstanceChainSetupError is returned if the rules of a container's instance chain cannot be set up
+type InstanceChainSetupError struct {
+	Cause error
+	Table string
+	Chain string
+}
+
+func (err InstanceChainSetupError) Error() string {
+	return fmtErr("failed to set up %s instance chain %s: %v", err.Table, err.Chain, err.Cause)
+}
+
+// InstanceChainTearDownError is returned if a jump to a container's instance chain cannot be removed
+type InstanceChainTearDownError struct {
+	Cause error
+	Table string
+	Chain string
+}
+
+func (err InstanceChainTearDownError) Error() string {
+	return fmtErr("failed to tear down %s instance chain %s: %v", err.Table, err.Chain, err.Cause)
+}
+
+// PortForwardError is returned if a port of the host cannot be forwarded to the container
+type PortForwardError struct {
+	Cause error
+	Spec  PortForwardSpec
+}
+
+func (err PortForwardError) Error() string {
+	return fmtErr("failed to forward %s:%d to %s:%d: %v", err.Spec.ExternalIP, err.Spec.HostPort, err.Spec.ContainerIP, err.Spec.ContainerPort, err.Cause)
+}
+
+// DeletePortForwardError is returned if a port of the host cannot stop being forwarded to the container
+type DeletePortForwardError struct {
+	Cause error
+	Spec  PortForwardSpec
+}
+
+func (err DeletePortForwardError) Error() string {
+	return fmtErr("failed to stop forwarding %s:%d to %s:%d: %v", err.Spec.ExternalIP, err.Spec.HostPort, err.Spec.ContainerIP, err.Spec.ContainerPort, err.Cause)
+}
+
+func fmtErr(msg string, args ...interface{}) string {
+	return fmt.Sprintf("iptables: "+msg, args...)
+}
@@ -74,6 +74,29 @@ type FakeChain struct {
 	prependFilterRuleReturns struct {
 		result1 error
 	}
+	PrependFilterRulesStub        func(rules []garden.NetOutRule) error
+	prependFilterRulesMutex       sync.RWMutex
+	prependFilterRulesArgsForCall []struct {
+		rules []garden.NetOutRule
+	}
+	prependFilterRulesReturns struct {
+		result1 error
+	}
+	DeleteFilterRuleStub        func(rule garden.NetOutRule) error
+	deleteFilterRuleMutex       sync.RWMutex
+	deleteFilterRuleArgsForCall []struct {
+		rule garden.NetOutRule
+	}
+	deleteFilterRuleReturns struct {
+		result1 error
+	}
+	CountersStub        func() ([]iptables.RuleCounter, error)
+	countersMutex       sync.RWMutex
+	countersArgsForCall []struct{}
+	countersReturns     struct {
+		result1 []iptables.RuleCounter
+		result2 error
+	}
 }
 
 func (fake *FakeChain) Setup(logPrefix string) error {
@@ -302,4 +325,93 @@ func (fake *FakeChain) PrependFilterRuleReturns(result1 error) {
 	}{result1}
 }
 
+func (fake *FakeChain) PrependFilterRules(rules []garden.NetOutRule) error {
+	fake.prependFilterRulesMutex.Lock()
+	fake.prependFilterRulesArgsForCall = append(fake.prependFilterRulesArgsForCall, struct {
+		rules []garden.NetOutRule
+	}{rules})
+	fake.prependFilterRulesMutex.Unlock()
+	if fake.PrependFilterRulesStub != nil {
+		return fake.PrependFilterRulesStub(rules)
+	} else {
+		return fake.prependFilterRulesReturns.result1
+	}
+}
+
+func (fake *FakeChain) PrependFilterRulesCallCount() int {
+	fake.prependFilterRulesMutex.RLock()
+	defer fake.prependFilterRulesMutex.RUnlock()
+	return len(fake.prependFilterRulesArgsForCall)
+}
+
+func (fake *FakeChain) PrependFilterRulesArgsForCall(i int) []garden.NetOutRule {
+	fake.prependFilterRulesMutex.RLock()
+	defer fake.prependFilterRulesMutex.RUnlock()
+	return fake.prependFilterRulesArgsForCall[i].rules
+}
+
+func (fake *FakeChain) PrependFilterRulesReturns(result1 error) {
+	fake.PrependFilterRulesStub = nil
+	fake.prependFilterRulesReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeChain) DeleteFilterRule(rule garden.NetOutRule) error {
+	fake.deleteFilterRuleMutex.Lock()
+	fake.deleteFilterRuleArgsForCall = append(fake.deleteFilterRuleArgsForCall, struct {
+		rule garden.NetOutRule
+	}{rule})
+	fake.deleteFilterRuleMutex.Unlock()
+	if fake.DeleteFilterRuleStub != nil {
+		return fake.DeleteFilterRuleStub(rule)
+	} else {
+		return fake.deleteFilterRuleReturns.result1
+	}
+}
+
+func (fake *FakeChain) DeleteFilterRuleCallCount() int {
+	fake.deleteFilterRuleMutex.RLock()
+	defer fake.deleteFilterRuleMutex.RUnlock()
+	return len(fake.deleteFilterRuleArgsForCall)
+}
+
+func (fake *FakeChain) DeleteFilterRuleArgsForCall(i int) garden.NetOutRule {
+	fake.deleteFilterRuleMutex.RLock()
+	defer fake.deleteFilterRuleMutex.RUnlock()
+	return fake.deleteFilterRuleArgsForCall[i].rule
+}
+
+func (fake *FakeChain) DeleteFilterRuleReturns(result1 error) {
+	fake.DeleteFilterRuleStub = nil
+	fake.deleteFilterRuleReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeChain) Counters() ([]iptables.RuleCounter, error) {
+	fake.countersMutex.Lock()
+	fake.countersArgsForCall = append(fake.countersArgsForCall, struct{}{})
+	fake.countersMutex.Unlock()
+	if fake.CountersStub != nil {
+		return fake.CountersStub()
+	} else {
+		return fake.countersReturns.result1, fake.countersReturns.result2
+	}
+}
+
+func (fake *FakeChain) CountersCallCount() int {
+	fake.countersMutex.RLock()
+	defer fake.countersMutex.RUnlock()
+	return len(fake.countersArgsForCall)
+}
+
+func (fake *FakeChain) CountersReturns(result1 []iptables.RuleCounter, result2 error) {
+	fake.CountersStub = nil
+	fake.countersReturns = struct {
+		result1 []iptables.RuleCounter
+		result2 error
+	}{result1, result2}
+}
+
 var _ iptables.Chain = new(FakeChain)
@@ -0,0 +1,163 @@
+// This file was generated by counterfeiter
+package fakes
+
+import (
+	"sync"
+
+	"github.com/cloudfoundry-incubator/garden-linux/network/iptables"
+)
+
+type FakeInstanceChain struct {
+	SetupStub        func(network iptables.InstanceNetwork) error
+	setupMutex       sync.RWMutex
+	setupArgsForCall []struct {
+		network iptables.InstanceNetwork
+	}
+	setupReturns struct {
+		result1 error
+	}
+	TearDownStub        func() error
+	tearDownMutex       sync.RWMutex
+	tearDownArgsForCall []struct{}
+	tearDownReturns     struct {
+		result1 error
+	}
+	AppendPortForwardStub        func(spec iptables.PortForwardSpec) error
+	appendPortForwardMutex       sync.RWMutex
+	appendPortForwardArgsForCall []struct {
+		spec iptables.PortForwardSpec
+	}
+	appendPortForwardReturns struct {
+		result1 error
+	}
+	DeletePortForwardStub        func(spec iptables.PortForwardSpec) error
+	deletePortForwardMutex       sync.RWMutex
+	deletePortForwardArgsForCall []struct {
+		spec iptables.PortForwardSpec
+	}
+	deletePortForwardReturns struct {
+		result1 error
+	}
+}
+
+func (fake *FakeInstanceChain) Setup(network iptables.InstanceNetwork) error {
+	fake.setupMutex.Lock()
+	fake.setupArgsForCall = append(fake.setupArgsForCall, struct {
+		network iptables.InstanceNetwork
+	}{network})
+	fake.setupMutex.Unlock()
+	if fake.SetupStub != nil {
+		return fake.SetupStub(network)
+	} else {
+		return fake.setupReturns.result1
+	}
+}
+
+func (fake *FakeInstanceChain) SetupCallCount() int {
+	fake.setupMutex.RLock()
+	defer fake.setupMutex.RUnlock()
+	return len(fake.setupArgsForCall)
+}
+
+func (fake *FakeInstanceChain) SetupArgsForCall(i int) iptables.InstanceNetwork {
+	fake.setupMutex.RLock()
+	defer fake.setupMutex.RUnlock()
+	return fake.setupArgsForCall[i].network
+}
+
+func (fake *FakeInstanceChain) SetupReturns(result1 error) {
+	fake.SetupStub = nil
+	fake.setupReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeInstanceChain) TearDown() error {
+	fake.tearDownMutex.Lock()
+	fake.tearDownArgsForCall = append(fake.tearDownArgsForCall, struct{}{})
+	fake.tearDownMutex.Unlock()
+	if fake.TearDownStub != nil {
+		return fake.TearDownStub()
+	} else {
+		return fake.tearDownReturns.result1
+	}
+}
+
+func (fake *FakeInstanceChain) TearDownCallCount() int {
+	fake.tearDownMutex.RLock()
+	defer fake.tearDownMutex.RUnlock()
+	return len(fake.tearDownArgsForCall)
+}
+
+func (fake *FakeInstanceChain) TearDownReturns(result1 error) {
+	fake.TearDownStub = nil
+	fake.tearDownReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeInstanceChain) AppendPortForward(spec iptables.PortForwardSpec) error {
+	fake.appendPortForwardMutex.Lock()
+	fake.appendPortForwardArgsForCall = append(fake.appendPortForwardArgsForCall, struct {
+		spec iptables.PortForwardSpec
+	}{spec})
+	fake.appendPortForwardMutex.Unlock()
+	if fake.AppendPortForwardStub != nil {
+		return fake.AppendPortForwardStub(spec)
+	} else {
+		return fake.appendPortForwardReturns.result1
+	}
+}
+
+func (fake *FakeInstanceChain) AppendPortForwardCallCount() int {
+	fake.appendPortForwardMutex.RLock()
+	defer fake.appendPortForwardMutex.RUnlock()
+	return len(fake.appendPortForwardArgsForCall)
+}
+
+func (fake *FakeInstanceChain) AppendPortForwardArgsForCall(i int) iptables.PortForwardSpec {
+	fake.appendPortForwardMutex.RLock()
+	defer fake.appendPortForwardMutex.RUnlock()
+	return fake.appendPortForwardArgsForCall[i].spec
+}
+
+func (fake *FakeInstanceChain) AppendPortForwardReturns(result1 error) {
+	fake.AppendPortForwardStub = nil
+	fake.appendPortForwardReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeInstanceChain) DeletePortForward(spec iptables.PortForwardSpec) error {
+	fake.deletePortForwardMutex.Lock()
+	fake.deletePortForwardArgsForCall = append(fake.deletePortForwardArgsForCall, struct {
+		spec iptables.PortForwardSpec
+	}{spec})
+	fake.deletePortForwardMutex.Unlock()
+	if fake.DeletePortForwardStub != nil {
+		return fake.DeletePortForwardStub(spec)
+	} else {
+		return fake.deletePortForwardReturns.result1
+	}
+}
+
+func (fake *FakeInstanceChain) DeletePortForwardCallCount() int {
+	fake.deletePortForwardMutex.RLock()
+	defer fake.deletePortForwardMutex.RUnlock()
+	return len(fake.deletePortForwardArgsForCall)
+}
+
+func (fake *FakeInstanceChain) DeletePortForwardArgsForCall(i int) iptables.PortForwardSpec {
+	fake.deletePortForwardMutex.RLock()
+	defer fake.deletePortForwardMutex.RUnlock()
+	return fake.deletePortForwardArgsForCall[i].spec
+}
+
+func (fake *FakeInstanceChain) DeletePortForwardReturns(result1 error) {
+	fake.DeletePortForwardStub = nil
+	fake.deletePortForwardReturns = struct {
+		result1 error
+	}{result1}
+}
+
+var _ iptables.InstanceChain = new(FakeInstanceChain)
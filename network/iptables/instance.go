@@ -0,0 +1,281 @@
+package iptables
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"os/exec"
+	"strings"
+
+	"github.com/cloudfoundry-incubator/garden"
+	"github.com/cloudfoundry/gunk/command_runner"
+	"github.com/pivotal-golang/lager"
+)
+
+// GlobalChains names the chains which instance chains are bound to. They are
+// created when the host is set up, by bin/net.sh.
+type GlobalChains struct {
+	// ForwardChain and DefaultChain are in the filter table.
+	ForwardChain string
+	DefaultChain string
+
+	// PreroutingChain and PostroutingChain are in the nat table.
+	PreroutingChain  string
+	PostroutingChain string
+}
+
+// InstanceNetwork is the network of the container an instance chain is for.
+type InstanceNetwork struct {
+	BridgeInterface string
+	ContainerIP     net.IP
+	Subnet          *net.IPNet
+	ExternalIP      net.IP
+
+	// AdditionalExternalIPs are the other IPs of the host which ports of the
+	// container may be mapped on.
+	AdditionalExternalIPs []net.IP
+
+	// ContainerIPv6 and IPv6Subnet are only set for dual-stack containers.
+	ContainerIPv6 net.IP
+	IPv6Subnet    *net.IPNet
+}
+
+// PortForwardSpec describes traffic to a port of the host which is to be
+// forwarded to a port of the container.
+type PortForwardSpec struct {
+	ExternalIP    net.IP
+	HostPort      uint32
+	ContainerIP   net.IP
+	ContainerPort uint32
+
+	// Protocol is TCP, UDP, or garden.ProtocolAll for both.
+	Protocol garden.Protocol
+}
+
+var portForwardProtocols = map[garden.Protocol][]string{
+	garden.ProtocolTCP: {"tcp"},
+	garden.ProtocolUDP: {"udp"},
+	garden.ProtocolAll: {"tcp", "udp"},
+}
+
+//go:generate counterfeiter . InstanceChain
+type InstanceChain interface {
+	// Create the container's filter and nat chains, and bind them to the global chains.
+	// Any rules left in the chains from before are removed.
+	Setup(network InstanceNetwork) error
+
+	// Unbind and destroy the container's filter and nat chains.
+	TearDown() error
+
+	AppendPortForward(spec PortForwardSpec) error
+	DeletePortForward(spec PortForwardSpec) error
+}
+
+// NewInstanceChain creates the instance chains of a container. The filter
+// chain and the nat chain have the same name.
+func NewInstanceChain(name string, globals GlobalChains, runner command_runner.CommandRunner, logger lager.Logger) InstanceChain {
+	return &instanceChain{name: name, globals: globals, runner: runner, logger: logger}
+}
+
+// NewDualStackInstanceChain creates the instance chains of a container on a
+// host with IPv6 networking, whose filter chain may also exist in ip6tables.
+func NewDualStackInstanceChain(name string, globals GlobalChains, runner command_runner.CommandRunner, logger lager.Logger) InstanceChain {
+	return &instanceChain{name: name, globals: globals, ipv6: true, runner: runner, logger: logger}
+}
+
+type instanceChain struct {
+	name    string
+	globals GlobalChains
+	ipv6    bool
+	runner  command_runner.CommandRunner
+	logger  lager.Logger
+}
+
+func (ch *instanceChain) Setup(network InstanceNetwork) error {
+	externalIPs := append([]net.IP{network.ExternalIP}, network.AdditionalExternalIPs...)
+	if err := ch.setupFilter(iptablesBin, network.BridgeInterface, network.ContainerIP, network.Subnet, externalIPs); err != nil {
+		return err
+	}
+
+	if err := ch.setupNat(network); err != nil {
+		return err
+	}
+
+	if network.IPv6Subnet != nil {
+		if err := ch.setupFilter(ip6tablesBin, network.BridgeInterface, network.ContainerIPv6, network.IPv6Subnet, nil); err != nil {
+			return err
+		}
+	}
+
+	ch.logger.Debug("instance-chain-setup-finished", lager.Data{"name": ch.name})
+
+	return nil
+}
+
+// setupFilter creates the filter chain. Connections to the external IPs which
+// were forwarded to a container are accepted, so that the container can reach
+// the mapped ports of its siblings (and its own) through the host.
+func (ch *instanceChain) setupFilter(bin string, bridge string, containerIP net.IP, subnet *net.IPNet, externalIPs []net.IP) error {
+	if err := ch.pruneJumps(bin, "filter", ch.globals.ForwardChain, "-g"); err != nil {
+		return InstanceChainSetupError{Cause: err, Table: "filter", Chain: ch.name}
+	}
+
+	b := newBatch("filter")
+	b.declareChain(ch.name)
+
+	// allow intra-subnet traffic (linux ethernet bridging goes through the ip stack)
+	b.add("-A", ch.name, "--source", subnet.String(), "--destination", subnet.String(), "--jump", "ACCEPT")
+
+	for _, ip := range externalIPs {
+		b.add("-A", ch.name, "--match", "conntrack", "--ctstate", "DNAT", "--ctorigdst", ip.String(), "--jump", "ACCEPT")
+	}
+
+	b.add("-A", ch.name, "--goto", ch.globals.DefaultChain)
+
+	b.add("-I", ch.globals.ForwardChain, "2", "--in-interface", bridge, "--source", containerIP.String(), "--goto", ch.name)
+
+	if err := b.apply(ch.runner, bin); err != nil {
+		return InstanceChainSetupError{Cause: err, Table: "filter", Chain: ch.name}
+	}
+
+	return nil
+}
+
+func (ch *instanceChain) setupNat(network InstanceNetwork) error {
+	if err := ch.pruneJumps(iptablesBin, "nat", ch.globals.PreroutingChain, "-j"); err != nil {
+		return InstanceChainSetupError{Cause: err, Table: "nat", Chain: ch.name}
+	}
+
+	b := newBatch("nat")
+	b.declareChain(ch.name)
+	b.add("-A", ch.globals.PreroutingChain, "--jump", ch.name)
+
+	// traffic from the containers of a subnet is masqueraded once, however
+	// many containers the subnet has
+	snat := []string{ch.globals.PostroutingChain, "--source", network.Subnet.String(), "--jump", "SNAT", "--to", network.ExternalIP.String()}
+	if err := run(ch.runner, exec.Command(iptablesBin, append([]string{"-w", "-t", "nat", "-C"}, snat...)...)); err != nil {
+		b.add(append([]string{"-A"}, snat...)...)
+	}
+
+	// connections from the subnet which were forwarded back into it, i.e. a
+	// container reaching a mapped port of its own or of a sibling through an
+	// external IP, are masqueraded as the gateway, so that replies go back
+	// through the host to be translated, rather than straight over the bridge
+	hairpin := []string{ch.globals.PostroutingChain, "--source", network.Subnet.String(), "--destination", network.Subnet.String(), "--match", "conntrack", "--ctstate", "DNAT", "--jump", "MASQUERADE"}
+	if err := run(ch.runner, exec.Command(iptablesBin, append([]string{"-w", "-t", "nat", "-C"}, hairpin...)...)); err != nil {
+		b.add(append([]string{"-I", hairpin[0], "1"}, hairpin[1:]...)...)
+	}
+
+	if err := b.apply(ch.runner, iptablesBin); err != nil {
+		return InstanceChainSetupError{Cause: err, Table: "nat", Chain: ch.name}
+	}
+
+	return nil
+}
+
+func (ch *instanceChain) TearDown() error {
+	if err := ch.tearDown(iptablesBin, "filter", ch.globals.ForwardChain, "-g"); err != nil {
+		return err
+	}
+
+	if err := ch.tearDown(iptablesBin, "nat", ch.globals.PreroutingChain, "-j"); err != nil {
+		return err
+	}
+
+	if ch.ipv6 {
+		if err := ch.tearDown(ip6tablesBin, "filter", ch.globals.ForwardChain, "-g"); err != nil {
+			return err
+		}
+	}
+
+	ch.logger.Debug("instance-chain-teardown-finished", lager.Data{"name": ch.name})
+
+	return nil
+}
+
+func (ch *instanceChain) tearDown(bin, table, parent, jumpFlag string) error {
+	if err := ch.pruneJumps(bin, table, parent, jumpFlag); err != nil {
+		return InstanceChainTearDownError{Cause: err, Table: table, Chain: ch.name}
+	}
+
+	// the chain may never have been set up
+	run(ch.runner, exec.Command(bin, "-w", "-t", table, "-F", ch.name))
+	run(ch.runner, exec.Command(bin, "-w", "-t", table, "-X", ch.name))
+
+	return nil
+}
+
+// pruneJumps removes every rule of the parent chain which jumps (or goes) to
+// the instance chain.
+func (ch *instanceChain) pruneJumps(bin, table, parent, jumpFlag string) error {
+	var stdout bytes.Buffer
+	list := exec.Command(bin, "-w", "-t", table, "-S", parent)
+	list.Stdout = &stdout
+
+	if err := run(ch.runner, list); err != nil {
+		// the parent chain does not exist, so nothing jumps to the instance chain
+		ch.logger.Error("list-chain-failed", err, lager.Data{"bin": bin, "table": table, "chain": parent})
+		return nil
+	}
+
+	b := newBatch(table)
+	for _, line := range strings.Split(stdout.String(), "\n") {
+		rule := strings.Fields(line)
+		if len(rule) == 0 || rule[0] != "-A" || !jumpsTo(rule, jumpFlag, ch.name) {
+			continue
+		}
+
+		rule[0] = "-D"
+		b.add(rule...)
+	}
+
+	return b.apply(ch.runner, bin)
+}
+
+func jumpsTo(rule []string, jumpFlag, chain string) bool {
+	for i := 0; i < len(rule)-1; i++ {
+		if rule[i] == jumpFlag && rule[i+1] == chain {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (ch *instanceChain) AppendPortForward(spec PortForwardSpec) error {
+	if err := ch.runPortForward("-A", spec); err != nil {
+		return PortForwardError{Cause: err, Spec: spec}
+	}
+
+	return nil
+}
+
+func (ch *instanceChain) DeletePortForward(spec PortForwardSpec) error {
+	if err := ch.runPortForward("-D", spec); err != nil {
+		return DeletePortForwardError{Cause: err, Spec: spec}
+	}
+
+	return nil
+}
+
+// runPortForward appends or deletes the rules of every protocol of the spec
+// at once.
+func (ch *instanceChain) runPortForward(action string, spec PortForwardSpec) error {
+	protocols, ok := portForwardProtocols[spec.Protocol]
+	if !ok {
+		return fmt.Errorf("invalid protocol: %d", spec.Protocol)
+	}
+
+	b := newBatch("nat")
+	for _, protocol := range protocols {
+		b.add(action, ch.name,
+			"--protocol", protocol,
+			"--destination", spec.ExternalIP.String(),
+			"--destination-port", fmt.Sprintf("%d", spec.HostPort),
+			"--jump", "DNAT",
+			"--to-destination", fmt.Sprintf("%s:%d", spec.ContainerIP, spec.ContainerPort),
+		)
+	}
+
+	return b.apply(ch.runner, iptablesBin)
+}
@@ -0,0 +1,495 @@
+package iptables_test
+
+import (
+	"errors"
+	"net"
+	"os/exec"
+
+	"github.com/cloudfoundry-incubator/garden"
+
+	. "github.com/cloudfoundry-incubator/garden-linux/network/iptables"
+	"github.com/cloudfoundry/gunk/command_runner/fake_command_runner"
+	. "github.com/cloudfoundry/gunk/command_runner/fake_command_runner/matchers"
+	"github.com/pivotal-golang/lager/lagertest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("InstanceChain", func() {
+	var fakeRunner *fake_command_runner.FakeCommandRunner
+	var subject InstanceChain
+	var network InstanceNetwork
+
+	globals := GlobalChains{
+		ForwardChain:     "w--forward",
+		DefaultChain:     "w--default",
+		PreroutingChain:  "w--prerouting",
+		PostroutingChain: "w--postrouting",
+	}
+
+	BeforeEach(func() {
+		fakeRunner = fake_command_runner.New()
+		subject = NewInstanceChain("w--instance-abc", globals, fakeRunner, lagertest.NewTestLogger("test"))
+
+		_, subnet, err := net.ParseCIDR("10.2.0.0/30")
+		Expect(err).ToNot(HaveOccurred())
+
+		network = InstanceNetwork{
+			BridgeInterface: "w-br",
+			ContainerIP:     net.ParseIP("10.2.0.2"),
+			Subnet:          subnet,
+			ExternalIP:      net.ParseIP("1.2.3.4"),
+		}
+	})
+
+	// listing makes the fake runner print the rules of a chain when it is listed with -S
+	listing := func(path, table, chain string, rules ...string) {
+		fakeRunner.WhenRunning(
+			fake_command_runner.CommandSpec{
+				Path: path,
+				Args: []string{"-w", "-t", table, "-S", chain},
+			},
+			func(cmd *exec.Cmd) error {
+				for _, rule := range rules {
+					cmd.Stdout.Write([]byte(rule + "\n"))
+				}
+
+				return nil
+			},
+		)
+	}
+
+	Describe("Setup", func() {
+		It("creates the filter instance chain and binds it to the forward chain", func() {
+			Expect(subject.Setup(network)).To(Succeed())
+
+			Expect(restored(fakeRunner, "/sbin/iptables-restore")).To(ContainElement(
+				"*filter\n" +
+					":w--instance-abc - [0:0]\n" +
+					"-A w--instance-abc --source 10.2.0.0/30 --destination 10.2.0.0/30 --jump ACCEPT\n" +
+					"-A w--instance-abc --match conntrack --ctstate DNAT --ctorigdst 1.2.3.4 --jump ACCEPT\n" +
+					"-A w--instance-abc --goto w--default\n" +
+					"-I w--forward 2 --in-interface w-br --source 10.2.0.2 --goto w--instance-abc\n" +
+					"COMMIT\n",
+			))
+		})
+
+		Context("when ports may be mapped on additional external IPs", func() {
+			BeforeEach(func() {
+				network.AdditionalExternalIPs = []net.IP{net.ParseIP("1.2.3.5"), net.ParseIP("1.2.3.6")}
+			})
+
+			It("accepts forwarded connections to each of them", func() {
+				Expect(subject.Setup(network)).To(Succeed())
+
+				Expect(restored(fakeRunner, "/sbin/iptables-restore")).To(ContainElement(
+					"*filter\n" +
+						":w--instance-abc - [0:0]\n" +
+						"-A w--instance-abc --source 10.2.0.0/30 --destination 10.2.0.0/30 --jump ACCEPT\n" +
+						"-A w--instance-abc --match conntrack --ctstate DNAT --ctorigdst 1.2.3.4 --jump ACCEPT\n" +
+						"-A w--instance-abc --match conntrack --ctstate DNAT --ctorigdst 1.2.3.5 --jump ACCEPT\n" +
+						"-A w--instance-abc --match conntrack --ctstate DNAT --ctorigdst 1.2.3.6 --jump ACCEPT\n" +
+						"-A w--instance-abc --goto w--default\n" +
+						"-I w--forward 2 --in-interface w-br --source 10.2.0.2 --goto w--instance-abc\n" +
+						"COMMIT\n",
+				))
+			})
+		})
+
+		It("creates the nat instance chain and binds it to the prerouting chain", func() {
+			Expect(subject.Setup(network)).To(Succeed())
+
+			Expect(restored(fakeRunner, "/sbin/iptables-restore")).To(ContainElement(
+				"*nat\n" +
+					":w--instance-abc - [0:0]\n" +
+					"-A w--prerouting --jump w--instance-abc\n" +
+					"COMMIT\n",
+			))
+		})
+
+		It("checks whether traffic from the subnet is already masqueraded", func() {
+			Expect(subject.Setup(network)).To(Succeed())
+
+			Expect(fakeRunner).To(HaveExecutedSerially(fake_command_runner.CommandSpec{
+				Path: "/sbin/iptables",
+				Args: []string{"-w", "-t", "nat", "-C", "w--postrouting", "--source", "10.2.0.0/30", "--jump", "SNAT", "--to", "1.2.3.4"},
+			}))
+		})
+
+		Context("when traffic from the subnet is not masqueraded yet", func() {
+			BeforeEach(func() {
+				fakeRunner.WhenRunning(
+					fake_command_runner.CommandSpec{
+						Path: "/sbin/iptables",
+						Args: []string{"-w", "-t", "nat", "-C", "w--postrouting", "--source", "10.2.0.0/30", "--jump", "SNAT", "--to", "1.2.3.4"},
+					},
+					func(*exec.Cmd) error {
+						return errors.New("exit status 1")
+					},
+				)
+			})
+
+			It("masquerades it as the external IP", func() {
+				Expect(subject.Setup(network)).To(Succeed())
+
+				Expect(restored(fakeRunner, "/sbin/iptables-restore")).To(ContainElement(
+					"*nat\n" +
+						":w--instance-abc - [0:0]\n" +
+						"-A w--prerouting --jump w--instance-abc\n" +
+						"-A w--postrouting --source 10.2.0.0/30 --jump SNAT --to 1.2.3.4\n" +
+						"COMMIT\n",
+				))
+			})
+		})
+
+		It("checks whether hairpin traffic of the subnet is already masqueraded", func() {
+			Expect(subject.Setup(network)).To(Succeed())
+
+			Expect(fakeRunner).To(HaveExecutedSerially(fake_command_runner.CommandSpec{
+				Path: "/sbin/iptables",
+				Args: []string{"-w", "-t", "nat", "-C", "w--postrouting", "--source", "10.2.0.0/30", "--destination", "10.2.0.0/30", "--match", "conntrack", "--ctstate", "DNAT", "--jump", "MASQUERADE"},
+			}))
+		})
+
+		Context("when hairpin traffic of the subnet is not masqueraded yet", func() {
+			BeforeEach(func() {
+				fakeRunner.WhenRunning(
+					fake_command_runner.CommandSpec{
+						Path: "/sbin/iptables",
+						Args: []string{"-w", "-t", "nat", "-C", "w--postrouting", "--source", "10.2.0.0/30", "--destination", "10.2.0.0/30", "--match", "conntrack", "--ctstate", "DNAT", "--jump", "MASQUERADE"},
+					},
+					func(*exec.Cmd) error {
+						return errors.New("exit status 1")
+					},
+				)
+			})
+
+			It("masquerades it as the gateway, ahead of the external IP", func() {
+				Expect(subject.Setup(network)).To(Succeed())
+
+				Expect(restored(fakeRunner, "/sbin/iptables-restore")).To(ContainElement(
+					"*nat\n" +
+						":w--instance-abc - [0:0]\n" +
+						"-A w--prerouting --jump w--instance-abc\n" +
+						"-I w--postrouting 1 --source 10.2.0.0/30 --destination 10.2.0.0/30 --match conntrack --ctstate DNAT --jump MASQUERADE\n" +
+						"COMMIT\n",
+				))
+			})
+		})
+
+		Context("when the chains were bound before", func() {
+			BeforeEach(func() {
+				listing("/sbin/iptables", "filter", "w--forward",
+					"-N w--forward",
+					"-A w--forward -i w-br -j ACCEPT",
+					"-A w--forward -s 10.2.0.2/32 -i w-br -g w--instance-abc",
+					"-A w--forward -s 10.2.0.6/32 -i w-br -g w--instance-abcd",
+				)
+
+				listing("/sbin/iptables", "nat", "w--prerouting",
+					"-N w--prerouting",
+					"-A w--prerouting -j w--instance-abc",
+				)
+			})
+
+			It("removes the old jumps first", func() {
+				Expect(subject.Setup(network)).To(Succeed())
+
+				inputs := restored(fakeRunner, "/sbin/iptables-restore")
+				Expect(inputs).To(HaveLen(4))
+				Expect(inputs[0]).To(Equal(filterBatch(
+					"-D w--forward -s 10.2.0.2/32 -i w-br -g w--instance-abc",
+				)))
+				Expect(inputs[2]).To(Equal("*nat\n-D w--prerouting -j w--instance-abc\nCOMMIT\n"))
+			})
+		})
+
+		Context("when the container is dual-stack", func() {
+			BeforeEach(func() {
+				network.ContainerIPv6, network.IPv6Subnet, _ = net.ParseCIDR("fd00::2/126")
+			})
+
+			It("creates the filter instance chain in ip6tables too", func() {
+				Expect(subject.Setup(network)).To(Succeed())
+
+				Expect(restored(fakeRunner, "/sbin/ip6tables-restore")).To(Equal([]string{
+					"*filter\n" +
+						":w--instance-abc - [0:0]\n" +
+						"-A w--instance-abc --source fd00::/126 --destination fd00::/126 --jump ACCEPT\n" +
+						"-A w--instance-abc --goto w--default\n" +
+						"-I w--forward 2 --in-interface w-br --source fd00::2 --goto w--instance-abc\n" +
+						"COMMIT\n",
+				}))
+			})
+		})
+
+		Context("when the filter chain cannot be set up", func() {
+			BeforeEach(func() {
+				fakeRunner.WhenRunning(
+					fake_command_runner.CommandSpec{
+						Path: "/sbin/iptables-restore",
+					},
+					func(*exec.Cmd) error {
+						return errors.New("oh no")
+					},
+				)
+			})
+
+			It("returns an InstanceChainSetupError", func() {
+				err := subject.Setup(network)
+				Expect(err).To(BeAssignableToTypeOf(InstanceChainSetupError{}))
+				Expect(err.(InstanceChainSetupError).Table).To(Equal("filter"))
+				Expect(err.(InstanceChainSetupError).Chain).To(Equal("w--instance-abc"))
+				Expect(err).To(MatchError("iptables: failed to set up filter instance chain w--instance-abc: iptables: oh no, "))
+			})
+		})
+	})
+
+	Describe("TearDown", func() {
+		BeforeEach(func() {
+			listing("/sbin/iptables", "filter", "w--forward",
+				"-A w--forward -s 10.2.0.2/32 -i w-br -g w--instance-abc",
+			)
+		})
+
+		It("removes the jumps to the chains, then flushes and deletes them", func() {
+			Expect(subject.TearDown()).To(Succeed())
+
+			Expect(fakeRunner).To(HaveExecutedSerially(
+				fake_command_runner.CommandSpec{
+					Path: "/sbin/iptables",
+					Args: []string{"-w", "-t", "filter", "-S", "w--forward"},
+				},
+				fake_command_runner.CommandSpec{
+					Path: "/sbin/iptables-restore",
+				},
+				fake_command_runner.CommandSpec{
+					Path: "/sbin/iptables",
+					Args: []string{"-w", "-t", "filter", "-F", "w--instance-abc"},
+				},
+				fake_command_runner.CommandSpec{
+					Path: "/sbin/iptables",
+					Args: []string{"-w", "-t", "filter", "-X", "w--instance-abc"},
+				},
+				fake_command_runner.CommandSpec{
+					Path: "/sbin/iptables",
+					Args: []string{"-w", "-t", "nat", "-S", "w--prerouting"},
+				},
+				fake_command_runner.CommandSpec{
+					Path: "/sbin/iptables",
+					Args: []string{"-w", "-t", "nat", "-F", "w--instance-abc"},
+				},
+				fake_command_runner.CommandSpec{
+					Path: "/sbin/iptables",
+					Args: []string{"-w", "-t", "nat", "-X", "w--instance-abc"},
+				},
+			))
+
+			Expect(restored(fakeRunner, "/sbin/iptables-restore")).To(Equal([]string{filterBatch(
+				"-D w--forward -s 10.2.0.2/32 -i w-br -g w--instance-abc",
+			)}))
+		})
+
+		It("ignores failures to flush and delete the chains", func() {
+			fakeRunner.WhenRunning(
+				fake_command_runner.CommandSpec{
+					Path: "/sbin/iptables",
+					Args: []string{"-w", "-t", "filter", "-X", "w--instance-abc"},
+				},
+				func(*exec.Cmd) error {
+					return errors.New("no such chain")
+				},
+			)
+
+			Expect(subject.TearDown()).To(Succeed())
+		})
+
+		It("returns an InstanceChainTearDownError when a jump cannot be removed", func() {
+			fakeRunner.WhenRunning(
+				fake_command_runner.CommandSpec{
+					Path: "/sbin/iptables-restore",
+				},
+				func(*exec.Cmd) error {
+					return errors.New("oh no")
+				},
+			)
+
+			err := subject.TearDown()
+			Expect(err).To(BeAssignableToTypeOf(InstanceChainTearDownError{}))
+			Expect(err).To(MatchError(ContainSubstring("failed to tear down filter instance chain w--instance-abc")))
+		})
+
+		Context("when the chain is dual-stack", func() {
+			BeforeEach(func() {
+				subject = NewDualStackInstanceChain("w--instance-abc", globals, fakeRunner, lagertest.NewTestLogger("test"))
+			})
+
+			It("tears down the filter chain in ip6tables too", func() {
+				Expect(subject.TearDown()).To(Succeed())
+
+				Expect(fakeRunner).To(HaveExecutedSerially(
+					fake_command_runner.CommandSpec{
+						Path: "/sbin/ip6tables",
+						Args: []string{"-w", "-t", "filter", "-S", "w--forward"},
+					},
+					fake_command_runner.CommandSpec{
+						Path: "/sbin/ip6tables",
+						Args: []string{"-w", "-t", "filter", "-X", "w--instance-abc"},
+					},
+				))
+			})
+		})
+	})
+
+	Describe("AppendPortForward", func() {
+		var spec PortForwardSpec
+
+		BeforeEach(func() {
+			spec = PortForwardSpec{
+				ExternalIP:    net.ParseIP("1.2.3.4"),
+				HostPort:      1234,
+				ContainerIP:   net.ParseIP("10.2.0.2"),
+				ContainerPort: 5678,
+				Protocol:      garden.ProtocolTCP,
+			}
+		})
+
+		It("appends a DNAT rule to the nat instance chain", func() {
+			Expect(subject.AppendPortForward(spec)).To(Succeed())
+
+			Expect(restored(fakeRunner, "/sbin/iptables-restore")).To(Equal([]string{
+				"*nat\n" +
+					"-A w--instance-abc --protocol tcp --destination 1.2.3.4 --destination-port 1234 --jump DNAT --to-destination 10.2.0.2:5678\n" +
+					"COMMIT\n",
+			}))
+		})
+
+		Context("when the protocol is UDP", func() {
+			BeforeEach(func() {
+				spec.Protocol = garden.ProtocolUDP
+			})
+
+			It("appends a UDP DNAT rule", func() {
+				Expect(subject.AppendPortForward(spec)).To(Succeed())
+
+				Expect(restored(fakeRunner, "/sbin/iptables-restore")).To(Equal([]string{
+					"*nat\n" +
+						"-A w--instance-abc --protocol udp --destination 1.2.3.4 --destination-port 1234 --jump DNAT --to-destination 10.2.0.2:5678\n" +
+						"COMMIT\n",
+				}))
+			})
+		})
+
+		Context("when the protocol is all", func() {
+			BeforeEach(func() {
+				spec.Protocol = garden.ProtocolAll
+			})
+
+			It("appends TCP and UDP DNAT rules in one transaction", func() {
+				Expect(subject.AppendPortForward(spec)).To(Succeed())
+
+				Expect(restored(fakeRunner, "/sbin/iptables-restore")).To(Equal([]string{
+					"*nat\n" +
+						"-A w--instance-abc --protocol tcp --destination 1.2.3.4 --destination-port 1234 --jump DNAT --to-destination 10.2.0.2:5678\n" +
+						"-A w--instance-abc --protocol udp --destination 1.2.3.4 --destination-port 1234 --jump DNAT --to-destination 10.2.0.2:5678\n" +
+						"COMMIT\n",
+				}))
+			})
+		})
+
+		Context("when the protocol cannot be forwarded", func() {
+			BeforeEach(func() {
+				spec.Protocol = garden.ProtocolICMP
+			})
+
+			It("returns a PortForwardError without running anything", func() {
+				err := subject.AppendPortForward(spec)
+				Expect(err).To(MatchError("iptables: failed to forward 1.2.3.4:1234 to 10.2.0.2:5678: invalid protocol: 3"))
+
+				Expect(fakeRunner.ExecutedCommands()).To(BeEmpty())
+			})
+		})
+
+		Context("when the rule cannot be appended", func() {
+			BeforeEach(func() {
+				fakeRunner.WhenRunning(
+					fake_command_runner.CommandSpec{
+						Path: "/sbin/iptables-restore",
+					},
+					func(cmd *exec.Cmd) error {
+						cmd.Stderr.Write([]byte("stderr contents"))
+						return errors.New("oh no")
+					},
+				)
+			})
+
+			It("returns a PortForwardError, including stderr", func() {
+				err := subject.AppendPortForward(spec)
+				Expect(err).To(Equal(PortForwardError{Cause: errors.New("iptables: oh no, stderr contents"), Spec: spec}))
+				Expect(err).To(MatchError("iptables: failed to forward 1.2.3.4:1234 to 10.2.0.2:5678: iptables: oh no, stderr contents"))
+			})
+		})
+	})
+
+	Describe("DeletePortForward", func() {
+		var spec PortForwardSpec
+
+		BeforeEach(func() {
+			spec = PortForwardSpec{
+				ExternalIP:    net.ParseIP("1.2.3.4"),
+				HostPort:      1234,
+				ContainerIP:   net.ParseIP("10.2.0.2"),
+				ContainerPort: 5678,
+				Protocol:      garden.ProtocolTCP,
+			}
+		})
+
+		It("deletes the DNAT rule from the nat instance chain", func() {
+			Expect(subject.DeletePortForward(spec)).To(Succeed())
+
+			Expect(restored(fakeRunner, "/sbin/iptables-restore")).To(Equal([]string{
+				"*nat\n" +
+					"-D w--instance-abc --protocol tcp --destination 1.2.3.4 --destination-port 1234 --jump DNAT --to-destination 10.2.0.2:5678\n" +
+					"COMMIT\n",
+			}))
+		})
+
+		Context("when the protocol is all", func() {
+			BeforeEach(func() {
+				spec.Protocol = garden.ProtocolAll
+			})
+
+			It("deletes the TCP and UDP DNAT rules", func() {
+				Expect(subject.DeletePortForward(spec)).To(Succeed())
+
+				Expect(restored(fakeRunner, "/sbin/iptables-restore")).To(Equal([]string{
+					"*nat\n" +
+						"-D w--instance-abc --protocol tcp --destination 1.2.3.4 --destination-port 1234 --jump DNAT --to-destination 10.2.0.2:5678\n" +
+						"-D w--instance-abc --protocol udp --destination 1.2.3.4 --destination-port 1234 --jump DNAT --to-destination 10.2.0.2:5678\n" +
+						"COMMIT\n",
+				}))
+			})
+		})
+
+		Context("when the rule cannot be deleted", func() {
+			BeforeEach(func() {
+				fakeRunner.WhenRunning(
+					fake_command_runner.CommandSpec{
+						Path: "/sbin/iptables-restore",
+					},
+					func(cmd *exec.Cmd) error {
+						cmd.Stderr.Write([]byte("bad rule"))
+						return errors.New("oh no")
+					},
+				)
+			})
+
+			It("returns a DeletePortForwardError", func() {
+				err := subject.DeletePortForward(spec)
+				Expect(err).To(BeAssignableToTypeOf(DeletePortForwardError{}))
+				Expect(err).To(MatchError("iptables: failed to stop forwarding 1.2.3.4:1234 to 10.2.0.2:5678: iptables: oh no, bad rule"))
+			})
+		})
+	})
+})
@@ -2,9 +2,11 @@ package iptables
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"net"
 	"os/exec"
+	"strconv"
 	"strings"
 
 	"sync"
@@ -21,8 +23,17 @@ var protocols = map[garden.Protocol]string{
 	garden.ProtocolUDP:  "udp",
 }
 
+const (
+	iptablesBin  = "/sbin/iptables"
+	ip6tablesBin = "/sbin/ip6tables"
+)
+
+// NFLogGroup is the NFLOG group log chains send packets to, unless they use
+// kernel logging.
+const NFLogGroup = 1
+
 // NewGlobalChain creates a chain without an associated log chain.
-// The chain is not created by this package (currently it is created in net.sh).
+// The chain is not created by this package; it is created when the host is set up, by bin/net.sh.
 // It is an error to attempt to call Setup on this chain.
 func NewGlobalChain(name string, runner command_runner.CommandRunner, log lager.Logger) Chain {
 	return &chain{name: name, logChainName: "", runner: runner, logger: log}
@@ -34,6 +45,12 @@ func NewLoggingChain(name string, useKernelLogging bool, runner command_runner.C
 	return &chain{name: name, logChainName: name + "-log", useKernelLogging: useKernelLogging, runner: runner, logger: logger}
 }
 
+// NewDualStackLoggingChain creates a logging chain which exists in ip6tables as well as
+// iptables. Filter rules which do not specify networks are applied to both.
+func NewDualStackLoggingChain(name string, useKernelLogging bool, runner command_runner.CommandRunner, logger lager.Logger) Chain {
+	return &chain{name: name, logChainName: name + "-log", useKernelLogging: useKernelLogging, ipv6: true, runner: runner, logger: logger}
+}
+
 //go:generate counterfeiter . Chain
 type Chain interface {
 	// Create the actual iptable chains in the underlying system.
@@ -49,7 +66,37 @@ type Chain interface {
 	AppendNatRule(source string, destination string, jump Action, to net.IP) error
 	DeleteNatRule(source string, destination string, jump Action, to net.IP) error
 
+	// PrependFilterRule prepends the rule as PrependFilterRules does, so a
+	// rule applied to both iptables and ip6tables is not atomic across them,
+	// but is deleted from the first again if applying it to the second fails.
 	PrependFilterRule(rule garden.NetOutRule) error
+
+	// PrependFilterRules prepends all of the rules, or none of them: every
+	// rule is validated before any is applied, and if applying fails the rules
+	// which were applied are deleted again.
+	PrependFilterRules(rules []garden.NetOutRule) error
+
+	// DeleteFilterRule deletes the rules a PrependFilterRule of the same rule
+	// inserted. If deleting them from ip6tables fails after they were deleted
+	// from iptables, they are prepended to the iptables chain again.
+	DeleteFilterRule(rule garden.NetOutRule) error
+
+	// Counters returns the packet and byte counters of the rules of the chain
+	// in the filter table, including those of ip6tables for dual-stack chains.
+	Counters() ([]RuleCounter, error)
+}
+
+// RuleCounter is the number of packets and bytes which have matched a rule.
+type RuleCounter struct {
+	// Rule is the rule's specification as listed by iptables -S, without the
+	// chain, e.g. "-d 1.2.3.4/32 -p tcp -m tcp --dport 80 -j RETURN".
+	Rule string
+
+	// IPv6 is set for the rules of ip6tables.
+	IPv6 bool
+
+	Packets uint64
+	Bytes   uint64
 }
 
 type chain struct {
@@ -57,6 +104,7 @@ type chain struct {
 	name             string
 	logChainName     string
 	useKernelLogging bool
+	ipv6             bool
 	runner           command_runner.CommandRunner
 	logger           lager.Logger
 }
@@ -66,28 +114,34 @@ func (ch *chain) Setup(logPrefix string) error {
 	defer ch.mu.Unlock()
 
 	if ch.logChainName == "" {
-		// we still use net.sh to set up global non-logging chains
+		// global chains are set up with the host, not by their users
 		panic("cannot set up chains without associated log chains")
 	}
 
-	ch.TearDown()
-
-	if err := ch.runner.Run(exec.Command("/sbin/iptables", "-w", "-N", ch.logChainName)); err != nil {
-		return fmt.Errorf("iptables: log chain setup: %v", err)
+	if err := ch.setupLogChain(iptablesBin, logPrefix); err != nil {
+		return err
 	}
-	ch.logger.Debug("log-chain-created")
 
-	logParams := ch.buildLogParams(logPrefix)
-	appendFlags := []string{"-w", "-A", ch.logChainName, "-m", "conntrack", "--ctstate", "NEW,UNTRACKED,INVALID", "--protocol", "tcp"}
-	if err := ch.runner.Run(exec.Command("/sbin/iptables", append(appendFlags, logParams...)...)); err != nil {
-		return fmt.Errorf("iptables: log chain setup: %v", err)
+	if ch.ipv6 {
+		if err := ch.setupLogChain(ip6tablesBin, logPrefix); err != nil {
+			return err
+		}
 	}
-	ch.logger.Debug("log-chain-conntrack-set-up")
 
-	if err := ch.runner.Run(exec.Command("/sbin/iptables", "-w", "-A", ch.logChainName, "--jump", "RETURN")); err != nil {
+	return nil
+}
+
+// setupLogChain creates the log chain, or replaces the rules of an existing one.
+func (ch *chain) setupLogChain(bin string, logPrefix string) error {
+	b := newBatch("filter")
+	b.declareChain(ch.logChainName)
+	b.add(append([]string{"-A", ch.logChainName, "-m", "conntrack", "--ctstate", "NEW,UNTRACKED,INVALID", "--protocol", "tcp"}, ch.buildLogParams(logPrefix)...)...)
+	b.add("-A", ch.logChainName, "--jump", "RETURN")
+
+	if err := b.apply(ch.runner, bin); err != nil {
 		return fmt.Errorf("iptables: log chain setup: %v", err)
 	}
-	ch.logger.Debug("log-chain-setup-finished")
+	ch.logger.Debug("log-chain-setup-finished", lager.Data{"bin": bin})
 
 	return nil
 }
@@ -96,18 +150,24 @@ func (ch *chain) buildLogParams(logPrefix string) []string {
 	if ch.useKernelLogging {
 		return []string{"--jump", "LOG", "--log-prefix", logPrefix}
 	} else {
-		return []string{"--jump", "NFLOG", "--nflog-prefix", logPrefix, "--nflog-group", "1"}
+		return []string{"--jump", "NFLOG", "--nflog-prefix", logPrefix, "--nflog-group", strconv.Itoa(NFLogGroup)}
 	}
 }
 
 func (ch *chain) TearDown() error {
 	if ch.logChainName == "" {
-		// we still use net.sh to tear down global non-logging chains
+		// global chains are torn down with the host, not by their users
 		panic("cannot tear down chains without associated log chains")
 	}
 
-	ch.runner.Run(exec.Command("/sbin/iptables", "-w", "-F", ch.logChainName))
-	ch.runner.Run(exec.Command("/sbin/iptables", "-w", "-X", ch.logChainName))
+	run(ch.runner, exec.Command(iptablesBin, "-w", "-F", ch.logChainName))
+	run(ch.runner, exec.Command(iptablesBin, "-w", "-X", ch.logChainName))
+
+	if ch.ipv6 {
+		run(ch.runner, exec.Command(ip6tablesBin, "-w", "-F", ch.logChainName))
+		run(ch.runner, exec.Command(ip6tablesBin, "-w", "-X", ch.logChainName))
+	}
+
 	return nil
 }
 
@@ -156,7 +216,213 @@ type singleRule struct {
 }
 
 func (ch *chain) PrependFilterRule(r garden.NetOutRule) error {
+	return ch.PrependFilterRules([]garden.NetOutRule{r})
+}
+
+func (ch *chain) PrependFilterRules(rules []garden.NetOutRule) error {
+	inserts := newFilterBatches()
+	deletes := newFilterBatches()
+
+	for _, r := range rules {
+		if err := ch.addFilterRule(inserts, r, []string{"-I", ch.name, "1"}); err != nil {
+			return err
+		}
+
+		ch.addFilterRule(deletes, r, []string{"-D", ch.name})
+	}
+
+	// the rules of each family are applied all at once, or not at all, but a
+	// family can fail after the other has been applied
+	applied := []string{}
+	for _, bin := range []string{iptablesBin, ip6tablesBin} {
+		ch.logger.Debug("prepend-filter-rules", lager.Data{"bin": bin, "rules": inserts[bin].rules})
+
+		if err := inserts[bin].apply(ch.runner, bin); err != nil {
+			ch.rollBack(deletes, applied)
+			return err
+		}
+
+		applied = append(applied, bin)
+	}
+
+	ch.logger.Debug("prepend-filter-rules-finished")
 
+	return nil
+}
+
+// rollBack applies the batches which undo the changes that were applied with
+// the given binaries.
+func (ch *chain) rollBack(undos map[string]*batch, applied []string) {
+	for _, bin := range applied {
+		if err := undos[bin].apply(ch.runner, bin); err != nil {
+			ch.logger.Error("roll-back-filter-rules-failed", err, lager.Data{"bin": bin})
+		}
+	}
+}
+
+func (ch *chain) DeleteFilterRule(r garden.NetOutRule) error {
+	deletes := newFilterBatches()
+	if err := ch.addFilterRule(deletes, r, []string{"-D", ch.name}); err != nil {
+		return err
+	}
+
+	bins := []string{}
+	for _, bin := range []string{iptablesBin, ip6tablesBin} {
+		if !deletes[bin].empty() {
+			bins = append(bins, bin)
+		}
+	}
+
+	// a rule which is not in the chain fails the whole batch, so nothing is
+	// deleted from a family unless all of the rule is there. A family which
+	// is followed by another is listed first, so that it can be put back as
+	// it was, with the deleted rules in their original positions, if the
+	// other fails.
+	restores := map[string]*batch{}
+	applied := []string{}
+	for i, bin := range bins {
+		if i < len(bins)-1 {
+			restore, err := ch.listFilterRules(bin)
+			if err != nil {
+				ch.rollBack(restores, applied)
+				return err
+			}
+
+			restores[bin] = restore
+		}
+
+		ch.logger.Debug("delete-filter-rules", lager.Data{"bin": bin, "rules": deletes[bin].rules})
+
+		if err := deletes[bin].apply(ch.runner, bin); err != nil {
+			ch.rollBack(restores, applied)
+			return err
+		}
+
+		applied = append(applied, bin)
+	}
+
+	ch.logger.Debug("delete-filter-rules-finished")
+
+	return nil
+}
+
+// listFilterRules returns a batch which replaces the rules of the chain with
+// those it has now, in the same order and with the same counters.
+func (ch *chain) listFilterRules(bin string) (*batch, error) {
+	var stdout bytes.Buffer
+	list := exec.Command(bin, "-w", "-t", "filter", "-S", ch.name, "-v")
+	list.Stdout = &stdout
+
+	if err := run(ch.runner, list); err != nil {
+		return nil, fmt.Errorf("iptables: list rules: %v", err)
+	}
+
+	b := newBatch("filter")
+	b.declareChain(ch.name)
+
+	for _, line := range strings.Split(stdout.String(), "\n") {
+		rule := strings.Fields(line)
+		if len(rule) < 2 || rule[0] != "-A" || rule[1] != ch.name {
+			continue
+		}
+
+		b.add(rule...)
+	}
+
+	return b, nil
+}
+
+func (ch *chain) Counters() ([]RuleCounter, error) {
+	counters, err := ch.listCounters(iptablesBin)
+	if err != nil {
+		return nil, err
+	}
+
+	if ch.ipv6 {
+		ipv6Counters, err := ch.listCounters(ip6tablesBin)
+		if err != nil {
+			return nil, err
+		}
+
+		counters = append(counters, ipv6Counters...)
+	}
+
+	return counters, nil
+}
+
+// listCounters parses the rules of the chain listed with -v, which gives each
+// rule its counters as "-c packets bytes".
+func (ch *chain) listCounters(bin string) ([]RuleCounter, error) {
+	var stdout bytes.Buffer
+	list := exec.Command(bin, "-w", "-t", "filter", "-S", ch.name, "-v")
+	list.Stdout = &stdout
+
+	if err := run(ch.runner, list); err != nil {
+		return nil, fmt.Errorf("iptables: list counters: %v", err)
+	}
+
+	counters := []RuleCounter{}
+	for _, line := range strings.Split(stdout.String(), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 || fields[0] != "-A" || fields[1] != ch.name {
+			continue
+		}
+
+		counter, err := parseRuleCounter(fields[2:])
+		if err != nil {
+			return nil, fmt.Errorf("iptables: list counters: %v: %s", err, line)
+		}
+
+		counter.IPv6 = bin == ip6tablesBin
+		counters = append(counters, counter)
+	}
+
+	return counters, nil
+}
+
+func parseRuleCounter(fields []string) (RuleCounter, error) {
+	for i := 0; i < len(fields); i++ {
+		if fields[i] != "-c" {
+			continue
+		}
+
+		if i+2 >= len(fields) {
+			break
+		}
+
+		packets, err := strconv.ParseUint(fields[i+1], 10, 64)
+		if err != nil {
+			return RuleCounter{}, err
+		}
+
+		byteCount, err := strconv.ParseUint(fields[i+2], 10, 64)
+		if err != nil {
+			return RuleCounter{}, err
+		}
+
+		spec := append(append([]string{}, fields[:i]...), fields[i+3:]...)
+
+		return RuleCounter{
+			Rule:    strings.Join(spec, " "),
+			Packets: packets,
+			Bytes:   byteCount,
+		}, nil
+	}
+
+	return RuleCounter{}, errors.New("no counters")
+}
+
+func newFilterBatches() map[string]*batch {
+	return map[string]*batch{
+		iptablesBin:  newBatch("filter"),
+		ip6tablesBin: newBatch("filter"),
+	}
+}
+
+// addFilterRule expands the rule into a single rule for each of its networks
+// and ports, and adds each, preceded by action, to the batch of the binary it
+// must be applied with. Nothing is added if the rule is invalid.
+func (ch *chain) addFilterRule(batches map[string]*batch, r garden.NetOutRule, action []string) error {
 	if len(r.Ports) > 0 && !allowsPort(r.Protocol) {
 		return fmt.Errorf("Ports cannot be specified for Protocol %s", strings.ToUpper(protocols[r.Protocol]))
 	}
@@ -167,6 +433,8 @@ func (ch *chain) PrependFilterRule(r garden.NetOutRule) error {
 		Log:      r.Log,
 	}
 
+	expanded := map[string][][]string{}
+
 	// It should still loop once even if there are no networks or ports.
 	for j := 0; j < len(r.Networks) || j == 0; j++ {
 		for i := 0; i < len(r.Ports) || i == 0; i++ {
@@ -181,9 +449,25 @@ func (ch *chain) PrependFilterRule(r garden.NetOutRule) error {
 				single.Networks = &r.Networks[j]
 			}
 
-			if err := ch.prependSingleRule(single); err != nil {
+			bins, err := ch.singleRuleBins(single)
+			if err != nil {
 				return err
 			}
+
+			for _, bin := range bins {
+				params, err := ch.singleRuleParams(single, bin)
+				if err != nil {
+					return err
+				}
+
+				expanded[bin] = append(expanded[bin], append(action, params...))
+			}
+		}
+	}
+
+	for bin, rules := range expanded {
+		for _, rule := range rules {
+			batches[bin].add(rule...)
 		}
 	}
 
@@ -194,13 +478,46 @@ func allowsPort(p garden.Protocol) bool {
 	return p == garden.ProtocolTCP || p == garden.ProtocolUDP
 }
 
-func (ch *chain) prependSingleRule(r singleRule) error {
-	params := []string{"-w", "-I", ch.name, "1"}
+// singleRuleBins returns the binaries a rule must be applied with: that of the
+// family of the rule's network, or if it has none then iptables and, for
+// dual-stack chains, ip6tables too. ICMP rules without a network are IPv4 only,
+// as ICMP types differ between the families.
+func (ch *chain) singleRuleBins(r singleRule) ([]string, error) {
+	network := r.Networks
+	if network == nil || (network.Start == nil && network.End == nil) {
+		if ch.ipv6 && r.Protocol != garden.ProtocolICMP {
+			return []string{iptablesBin, ip6tablesBin}, nil
+		}
+
+		return []string{iptablesBin}, nil
+	}
+
+	if network.Start != nil && network.End != nil && isIPv6(network.Start) != isIPv6(network.End) {
+		return nil, fmt.Errorf("iptables: network range mixes IPv4 and IPv6: %s-%s", network.Start, network.End)
+	}
+
+	if isIPv6(network.Start) || isIPv6(network.End) {
+		return []string{ip6tablesBin}, nil
+	}
+
+	return []string{iptablesBin}, nil
+}
+
+// singleRuleParams returns the iptables arguments which specify the rule,
+// without the action or the chain.
+func (ch *chain) singleRuleParams(r singleRule, bin string) ([]string, error) {
+	params := []string{}
 
 	protocolString, ok := protocols[r.Protocol]
 
 	if !ok {
-		return fmt.Errorf("invalid protocol: %d", r.Protocol)
+		return nil, fmt.Errorf("invalid protocol: %d", r.Protocol)
+	}
+
+	icmpTypeFlag := "--icmp-type"
+	if bin == ip6tablesBin && r.Protocol == garden.ProtocolICMP {
+		protocolString = "icmpv6"
+		icmpTypeFlag = "--icmpv6-type"
 	}
 
 	params = append(params, "--protocol", protocolString)
@@ -231,7 +548,7 @@ func (ch *chain) prependSingleRule(r singleRule) error {
 			icmpType = fmt.Sprintf("%d/%d", r.ICMPs.Type, *r.ICMPs.Code)
 		}
 
-		params = append(params, "--icmp-type", icmpType)
+		params = append(params, icmpTypeFlag, icmpType)
 	}
 
 	if r.Log {
@@ -240,17 +557,7 @@ func (ch *chain) prependSingleRule(r singleRule) error {
 		params = append(params, "--jump", "RETURN")
 	}
 
-	ch.logger.Debug("prepend-filter-rule", lager.Data{"parms": params})
-
-	var stderr bytes.Buffer
-	cmd := exec.Command("/sbin/iptables", params...)
-	cmd.Stderr = &stderr
-	if err := ch.runner.Run(cmd); err != nil {
-		return fmt.Errorf("iptables: %v, %v", err, stderr.String())
-	}
-	ch.logger.Debug("prependSingleRule-finished")
-
-	return nil
+	return params, nil
 }
 
 type rule struct {
@@ -262,11 +569,33 @@ type rule struct {
 }
 
 func (n *rule) create(chain string, runner command_runner.CommandRunner) error {
-	return runner.Run(exec.Command("/sbin/iptables", flags("-A", chain, n)...))
+	return run(runner, exec.Command(n.bin(), flags("-A", chain, n)...))
 }
 
 func (n *rule) destroy(chain string, runner command_runner.CommandRunner) error {
-	return runner.Run(exec.Command("/sbin/iptables", flags("-D", chain, n)...))
+	return run(runner, exec.Command(n.bin(), flags("-D", chain, n)...))
+}
+
+// bin returns ip6tables if the rule is for IPv6 addresses, and iptables otherwise.
+func (n *rule) bin() string {
+	if isIPv6Address(n.source) || isIPv6Address(n.destination) || isIPv6(n.to) {
+		return ip6tablesBin
+	}
+
+	return iptablesBin
+}
+
+func isIPv6(ip net.IP) bool {
+	return ip != nil && ip.To4() == nil
+}
+
+// isIPv6Address reports whether an address or CIDR is IPv6.
+func isIPv6Address(address string) bool {
+	if ip, _, err := net.ParseCIDR(address); err == nil {
+		return isIPv6(ip)
+	}
+
+	return isIPv6(net.ParseIP(address))
 }
 
 func flags(action, chain string, n *rule) []string {
@@ -1,12 +1,17 @@
 package iptables_test
 
 import (
+	"github.com/cloudfoundry-incubator/garden-linux/network/iptables"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
 	"testing"
 )
 
+var _ = BeforeEach(func() {
+	iptables.UseRestoreWait(true)
+})
+
 func TestIptables(t *testing.T) {
 	RegisterFailHandler(Fail)
 	RunSpecs(t, "Iptables Suite")
@@ -2,8 +2,10 @@ package iptables_test
 
 import (
 	"errors"
+	"io/ioutil"
 	"net"
 	"os/exec"
+	"strings"
 
 	"github.com/cloudfoundry-incubator/garden"
 	. "github.com/cloudfoundry-incubator/garden-linux/network/iptables"
@@ -28,29 +30,15 @@ var _ = Describe("Iptables", func() {
 
 		Describe("Setup", func() {
 			Context("when kernel logging is not enabled", func() {
-				It("creates the log chain using iptables", func() {
+				It("creates the log chain using iptables-restore", func() {
 					Expect(subject.Setup("logPrefix")).To(Succeed())
-					Expect(fakeRunner).To(HaveExecutedSerially(
-						fake_command_runner.CommandSpec{
-							Path: "/sbin/iptables",
-							Args: []string{"-w", "-F", "foo-bar-baz-log"},
-						},
-						fake_command_runner.CommandSpec{
-							Path: "/sbin/iptables",
-							Args: []string{"-w", "-X", "foo-bar-baz-log"},
-						},
-						fake_command_runner.CommandSpec{
-							Path: "/sbin/iptables",
-							Args: []string{"-w", "-N", "foo-bar-baz-log"},
-						},
-						fake_command_runner.CommandSpec{
-							Path: "/sbin/iptables",
-							Args: []string{"-w", "-A", "foo-bar-baz-log", "-m", "conntrack", "--ctstate", "NEW,UNTRACKED,INVALID", "--protocol", "tcp", "--jump", "NFLOG", "--nflog-prefix", "logPrefix", "--nflog-group", "1"},
-						},
-						fake_command_runner.CommandSpec{
-							Path: "/sbin/iptables",
-							Args: []string{"-w", "-A", "foo-bar-baz-log", "--jump", "RETURN"},
-						}))
+					Expect(restored(fakeRunner, "/sbin/iptables-restore")).To(Equal([]string{
+						"*filter\n" +
+							":foo-bar-baz-log - [0:0]\n" +
+							"-A foo-bar-baz-log -m conntrack --ctstate NEW,UNTRACKED,INVALID --protocol tcp --jump NFLOG --nflog-prefix logPrefix --nflog-group 1\n" +
+							"-A foo-bar-baz-log --jump RETURN\n" +
+							"COMMIT\n",
+					}))
 				})
 			})
 
@@ -59,101 +47,40 @@ var _ = Describe("Iptables", func() {
 					useKernelLogging = true
 				})
 
-				It("creates the log chain using iptables", func() {
+				It("creates the log chain using iptables-restore", func() {
 					Expect(subject.Setup("logPrefix")).To(Succeed())
-					Expect(fakeRunner).To(HaveExecutedSerially(
-						fake_command_runner.CommandSpec{
-							Path: "/sbin/iptables",
-							Args: []string{"-w", "-F", "foo-bar-baz-log"},
-						},
-						fake_command_runner.CommandSpec{
-							Path: "/sbin/iptables",
-							Args: []string{"-w", "-X", "foo-bar-baz-log"},
-						},
-						fake_command_runner.CommandSpec{
-							Path: "/sbin/iptables",
-							Args: []string{"-w", "-N", "foo-bar-baz-log"},
-						},
-						fake_command_runner.CommandSpec{
-							Path: "/sbin/iptables",
-							Args: []string{"-w", "-A", "foo-bar-baz-log", "-m", "conntrack", "--ctstate", "NEW,UNTRACKED,INVALID", "--protocol", "tcp",
-								"--jump", "LOG", "--log-prefix", "logPrefix"},
-						},
-						fake_command_runner.CommandSpec{
-							Path: "/sbin/iptables",
-							Args: []string{"-w", "-A", "foo-bar-baz-log", "--jump", "RETURN"},
-						}))
+					Expect(restored(fakeRunner, "/sbin/iptables-restore")).To(Equal([]string{
+						"*filter\n" +
+							":foo-bar-baz-log - [0:0]\n" +
+							"-A foo-bar-baz-log -m conntrack --ctstate NEW,UNTRACKED,INVALID --protocol tcp --jump LOG --log-prefix logPrefix\n" +
+							"-A foo-bar-baz-log --jump RETURN\n" +
+							"COMMIT\n",
+					}))
 				})
 			})
 
-			It("ignores failures to flush", func() {
-				someError := errors.New("y")
-				fakeRunner.WhenRunning(
-					fake_command_runner.CommandSpec{
-						Path: "/sbin/iptables",
-						Args: []string{"-w", "-F", "foo-bar-baz-log"},
-					},
-					func(cmd *exec.Cmd) error {
-						return someError
-					})
-
-				Expect(subject.Setup("logPrefix")).To(Succeed())
-			})
-
-			It("ignores failures to delete", func() {
-				someError := errors.New("y")
-				fakeRunner.WhenRunning(
-					fake_command_runner.CommandSpec{
-						Path: "/sbin/iptables",
-						Args: []string{"-w", "-X", "foo-bar-baz-log"},
-					},
-					func(cmd *exec.Cmd) error {
-						return someError
-					})
-
+			It("creates the whole log chain in a single process", func() {
 				Expect(subject.Setup("logPrefix")).To(Succeed())
+				Expect(fakeRunner.ExecutedCommands()).To(HaveLen(1))
 			})
 
-			It("returns any error returned when the table is created", func() {
-				someError := errors.New("y")
-				fakeRunner.WhenRunning(
-					fake_command_runner.CommandSpec{
-						Path: "/sbin/iptables",
-						Args: []string{"-w", "-N", "foo-bar-baz-log"},
-					},
-					func(cmd *exec.Cmd) error {
-						return someError
-					})
-
-				Expect(subject.Setup("logPrefix")).To(MatchError("iptables: log chain setup: y"))
-			})
-
-			It("returns any error returned when the logging rule is added", func() {
-				someError := errors.New("y")
-				fakeRunner.WhenRunning(
-					fake_command_runner.CommandSpec{
-						Path: "/sbin/iptables",
-						Args: []string{"-w", "-A", "foo-bar-baz-log", "-m", "conntrack", "--ctstate", "NEW,UNTRACKED,INVALID", "--protocol", "tcp", "--jump", "LOG", "--log-prefix", "logPrefix"},
-					},
-					func(cmd *exec.Cmd) error {
-						return someError
-					})
-
-				Expect(subject.Setup("logPrefix")).To(MatchError("iptables: log chain setup: y"))
+			It("quotes a log prefix containing spaces", func() {
+				Expect(subject.Setup("some prefix")).To(Succeed())
+				Expect(restored(fakeRunner, "/sbin/iptables-restore")[0]).To(ContainSubstring(`-prefix "some prefix"`))
 			})
 
-			It("returns any error returned when the RETURN rule is added", func() {
+			It("returns any error returned when the chain is restored", func() {
 				someError := errors.New("y")
 				fakeRunner.WhenRunning(
 					fake_command_runner.CommandSpec{
-						Path: "/sbin/iptables",
-						Args: []string{"-w", "-A", "foo-bar-baz-log", "--jump", "RETURN"},
+						Path: "/sbin/iptables-restore",
 					},
 					func(cmd *exec.Cmd) error {
+						cmd.Stderr.Write([]byte("z"))
 						return someError
 					})
 
-				Expect(subject.Setup("logPrefix")).To(MatchError("iptables: log chain setup: y"))
+				Expect(subject.Setup("logPrefix")).To(MatchError("iptables: log chain setup: iptables: y, z"))
 			})
 		})
 
@@ -348,10 +275,9 @@ var _ = Describe("Iptables", func() {
 				Context("when all parameters are defaulted", func() {
 					It("runs iptables with appropriate parameters", func() {
 						Expect(subject.PrependFilterRule(garden.NetOutRule{})).To(Succeed())
-						Expect(fakeRunner).To(HaveExecutedSerially(fake_command_runner.CommandSpec{
-							Path: "/sbin/iptables",
-							Args: []string{"-w", "-I", "foo-bar-baz", "1", "--protocol", "all", "--jump", "RETURN"},
-						}))
+						Expect(restored(fakeRunner, "/sbin/iptables-restore")).To(Equal([]string{filterBatch(
+							"-I foo-bar-baz 1 --protocol all --jump RETURN",
+						)}))
 					})
 				})
 
@@ -364,10 +290,9 @@ var _ = Describe("Iptables", func() {
 								},
 							})).To(Succeed())
 
-							Expect(fakeRunner).To(HaveExecutedSerially(fake_command_runner.CommandSpec{
-								Path: "/sbin/iptables",
-								Args: []string{"-w", "-I", "foo-bar-baz", "1", "--protocol", "all", "--jump", "RETURN"},
-							}))
+							Expect(restored(fakeRunner, "/sbin/iptables-restore")).To(Equal([]string{filterBatch(
+								"-I foo-bar-baz 1 --protocol all --jump RETURN",
+							)}))
 						})
 					})
 
@@ -381,10 +306,9 @@ var _ = Describe("Iptables", func() {
 								},
 							})).To(Succeed())
 
-							Expect(fakeRunner).To(HaveExecutedSerially(fake_command_runner.CommandSpec{
-								Path: "/sbin/iptables",
-								Args: []string{"-w", "-I", "foo-bar-baz", "1", "--protocol", "all", "--destination", "1.2.3.4", "--jump", "RETURN"},
-							}))
+							Expect(restored(fakeRunner, "/sbin/iptables-restore")).To(Equal([]string{filterBatch(
+								"-I foo-bar-baz 1 --protocol all --destination 1.2.3.4 --jump RETURN",
+							)}))
 						})
 					})
 
@@ -402,17 +326,11 @@ var _ = Describe("Iptables", func() {
 								},
 							})).To(Succeed())
 
-							Expect(fakeRunner.ExecutedCommands()).To(HaveLen(2))
-							Expect(fakeRunner).To(HaveExecutedSerially(
-								fake_command_runner.CommandSpec{
-									Path: "/sbin/iptables",
-									Args: []string{"-w", "-I", "foo-bar-baz", "1", "--protocol", "all", "--destination", "1.2.3.4", "--jump", "RETURN"},
-								},
-								fake_command_runner.CommandSpec{
-									Path: "/sbin/iptables",
-									Args: []string{"-w", "-I", "foo-bar-baz", "1", "--protocol", "all", "-m", "iprange", "--dst-range", "2.2.3.4-2.2.3.9", "--jump", "RETURN"},
-								},
-							))
+							Expect(fakeRunner.ExecutedCommands()).To(HaveLen(1))
+							Expect(restored(fakeRunner, "/sbin/iptables-restore")).To(Equal([]string{filterBatch(
+								"-I foo-bar-baz 1 --protocol all --destination 1.2.3.4 --jump RETURN",
+								"-I foo-bar-baz 1 --protocol all -m iprange --dst-range 2.2.3.4-2.2.3.9 --jump RETURN",
+							)}))
 						})
 					})
 
@@ -426,10 +344,9 @@ var _ = Describe("Iptables", func() {
 								},
 							})).To(Succeed())
 
-							Expect(fakeRunner).To(HaveExecutedSerially(fake_command_runner.CommandSpec{
-								Path: "/sbin/iptables",
-								Args: []string{"-w", "-I", "foo-bar-baz", "1", "--protocol", "all", "--destination", "1.2.3.4", "--jump", "RETURN"},
-							}))
+							Expect(restored(fakeRunner, "/sbin/iptables-restore")).To(Equal([]string{filterBatch(
+								"-I foo-bar-baz 1 --protocol all --destination 1.2.3.4 --jump RETURN",
+							)}))
 						})
 					})
 
@@ -443,10 +360,9 @@ var _ = Describe("Iptables", func() {
 								},
 							})).To(Succeed())
 
-							Expect(fakeRunner).To(HaveExecutedSerially(fake_command_runner.CommandSpec{
-								Path: "/sbin/iptables",
-								Args: []string{"-w", "-I", "foo-bar-baz", "1", "--protocol", "all", "-m", "iprange", "--dst-range", "1.2.3.4-2.3.4.5", "--jump", "RETURN"},
-							}))
+							Expect(restored(fakeRunner, "/sbin/iptables-restore")).To(Equal([]string{filterBatch(
+								"-I foo-bar-baz 1 --protocol all -m iprange --dst-range 1.2.3.4-2.3.4.5 --jump RETURN",
+							)}))
 						})
 					})
 				})
@@ -461,10 +377,9 @@ var _ = Describe("Iptables", func() {
 								},
 							})).To(Succeed())
 
-							Expect(fakeRunner).To(HaveExecutedSerially(fake_command_runner.CommandSpec{
-								Path: "/sbin/iptables",
-								Args: []string{"-w", "-I", "foo-bar-baz", "1", "--protocol", "tcp", "--destination-port", "22", "--jump", "RETURN"},
-							}))
+							Expect(restored(fakeRunner, "/sbin/iptables-restore")).To(Equal([]string{filterBatch(
+								"-I foo-bar-baz 1 --protocol tcp --destination-port 22 --jump RETURN",
+							)}))
 						})
 					})
 
@@ -477,10 +392,9 @@ var _ = Describe("Iptables", func() {
 								},
 							})).To(Succeed())
 
-							Expect(fakeRunner).To(HaveExecutedSerially(fake_command_runner.CommandSpec{
-								Path: "/sbin/iptables",
-								Args: []string{"-w", "-I", "foo-bar-baz", "1", "--protocol", "tcp", "--destination-port", "12:24", "--jump", "RETURN"},
-							}))
+							Expect(restored(fakeRunner, "/sbin/iptables-restore")).To(Equal([]string{filterBatch(
+								"-I foo-bar-baz 1 --protocol tcp --destination-port 12:24 --jump RETURN",
+							)}))
 						})
 					})
 
@@ -494,16 +408,10 @@ var _ = Describe("Iptables", func() {
 								},
 							})).To(Succeed())
 
-							Expect(fakeRunner).To(HaveExecutedSerially(
-								fake_command_runner.CommandSpec{
-									Path: "/sbin/iptables",
-									Args: []string{"-w", "-I", "foo-bar-baz", "1", "--protocol", "tcp", "--destination-port", "12:24", "--jump", "RETURN"},
-								},
-								fake_command_runner.CommandSpec{
-									Path: "/sbin/iptables",
-									Args: []string{"-w", "-I", "foo-bar-baz", "1", "--protocol", "tcp", "--destination-port", "64:942", "--jump", "RETURN"},
-								},
-							))
+							Expect(restored(fakeRunner, "/sbin/iptables-restore")).To(Equal([]string{filterBatch(
+								"-I foo-bar-baz 1 --protocol tcp --destination-port 12:24 --jump RETURN",
+								"-I foo-bar-baz 1 --protocol tcp --destination-port 64:942 --jump RETURN",
+							)}))
 						})
 					})
 				})
@@ -515,10 +423,9 @@ var _ = Describe("Iptables", func() {
 								Protocol: garden.ProtocolTCP,
 							})).To(Succeed())
 
-							Expect(fakeRunner).To(HaveExecutedSerially(fake_command_runner.CommandSpec{
-								Path: "/sbin/iptables",
-								Args: []string{"-w", "-I", "foo-bar-baz", "1", "--protocol", "tcp", "--jump", "RETURN"},
-							}))
+							Expect(restored(fakeRunner, "/sbin/iptables-restore")).To(Equal([]string{filterBatch(
+								"-I foo-bar-baz 1 --protocol tcp --jump RETURN",
+							)}))
 						})
 					})
 
@@ -528,10 +435,9 @@ var _ = Describe("Iptables", func() {
 								Protocol: garden.ProtocolUDP,
 							})).To(Succeed())
 
-							Expect(fakeRunner).To(HaveExecutedSerially(fake_command_runner.CommandSpec{
-								Path: "/sbin/iptables",
-								Args: []string{"-w", "-I", "foo-bar-baz", "1", "--protocol", "udp", "--jump", "RETURN"},
-							}))
+							Expect(restored(fakeRunner, "/sbin/iptables-restore")).To(Equal([]string{filterBatch(
+								"-I foo-bar-baz 1 --protocol udp --jump RETURN",
+							)}))
 						})
 					})
 
@@ -541,10 +447,9 @@ var _ = Describe("Iptables", func() {
 								Protocol: garden.ProtocolICMP,
 							})).To(Succeed())
 
-							Expect(fakeRunner).To(HaveExecutedSerially(fake_command_runner.CommandSpec{
-								Path: "/sbin/iptables",
-								Args: []string{"-w", "-I", "foo-bar-baz", "1", "--protocol", "icmp", "--jump", "RETURN"},
-							}))
+							Expect(restored(fakeRunner, "/sbin/iptables-restore")).To(Equal([]string{filterBatch(
+								"-I foo-bar-baz 1 --protocol icmp --jump RETURN",
+							)}))
 						})
 
 						Context("when icmp type is specified", func() {
@@ -556,10 +461,9 @@ var _ = Describe("Iptables", func() {
 									},
 								})).To(Succeed())
 
-								Expect(fakeRunner).To(HaveExecutedSerially(fake_command_runner.CommandSpec{
-									Path: "/sbin/iptables",
-									Args: []string{"-w", "-I", "foo-bar-baz", "1", "--protocol", "icmp", "--icmp-type", "99", "--jump", "RETURN"},
-								}))
+								Expect(restored(fakeRunner, "/sbin/iptables-restore")).To(Equal([]string{filterBatch(
+									"-I foo-bar-baz 1 --protocol icmp --icmp-type 99 --jump RETURN",
+								)}))
 							})
 						})
 
@@ -573,10 +477,9 @@ var _ = Describe("Iptables", func() {
 									},
 								})).To(Succeed())
 
-								Expect(fakeRunner).To(HaveExecutedSerially(fake_command_runner.CommandSpec{
-									Path: "/sbin/iptables",
-									Args: []string{"-w", "-I", "foo-bar-baz", "1", "--protocol", "icmp", "--icmp-type", "99/11", "--jump", "RETURN"},
-								}))
+								Expect(restored(fakeRunner, "/sbin/iptables-restore")).To(Equal([]string{filterBatch(
+									"-I foo-bar-baz 1 --protocol icmp --icmp-type 99/11 --jump RETURN",
+								)}))
 							})
 						})
 					})
@@ -588,10 +491,9 @@ var _ = Describe("Iptables", func() {
 							Log: true,
 						})).To(Succeed())
 
-						Expect(fakeRunner).To(HaveExecutedSerially(fake_command_runner.CommandSpec{
-							Path: "/sbin/iptables",
-							Args: []string{"-w", "-I", "foo-bar-baz", "1", "--protocol", "all", "--goto", "foo-bar-baz-log"},
-						}))
+						Expect(restored(fakeRunner, "/sbin/iptables-restore")).To(Equal([]string{filterBatch(
+							"-I foo-bar-baz 1 --protocol all --goto foo-bar-baz-log",
+						)}))
 					})
 				})
 
@@ -614,25 +516,13 @@ var _ = Describe("Iptables", func() {
 							},
 						})).To(Succeed())
 
-						Expect(fakeRunner.ExecutedCommands()).To(HaveLen(4))
-						Expect(fakeRunner).To(HaveExecutedSerially(
-							fake_command_runner.CommandSpec{
-								Path: "/sbin/iptables",
-								Args: []string{"-w", "-I", "foo-bar-baz", "1", "--protocol", "tcp", "--destination", "1.2.3.4", "--destination-port", "12:24", "--jump", "RETURN"},
-							},
-							fake_command_runner.CommandSpec{
-								Path: "/sbin/iptables",
-								Args: []string{"-w", "-I", "foo-bar-baz", "1", "--protocol", "tcp", "--destination", "1.2.3.4", "--destination-port", "64:942", "--jump", "RETURN"},
-							},
-							fake_command_runner.CommandSpec{
-								Path: "/sbin/iptables",
-								Args: []string{"-w", "-I", "foo-bar-baz", "1", "--protocol", "tcp", "-m", "iprange", "--dst-range", "2.2.3.4-2.2.3.9", "--destination-port", "12:24", "--jump", "RETURN"},
-							},
-							fake_command_runner.CommandSpec{
-								Path: "/sbin/iptables",
-								Args: []string{"-w", "-I", "foo-bar-baz", "1", "--protocol", "tcp", "-m", "iprange", "--dst-range", "2.2.3.4-2.2.3.9", "--destination-port", "64:942", "--jump", "RETURN"},
-							},
-						))
+						Expect(fakeRunner.ExecutedCommands()).To(HaveLen(1))
+						Expect(restored(fakeRunner, "/sbin/iptables-restore")).To(Equal([]string{filterBatch(
+							"-I foo-bar-baz 1 --protocol tcp --destination 1.2.3.4 --destination-port 12:24 --jump RETURN",
+							"-I foo-bar-baz 1 --protocol tcp --destination 1.2.3.4 --destination-port 64:942 --jump RETURN",
+							"-I foo-bar-baz 1 --protocol tcp -m iprange --dst-range 2.2.3.4-2.2.3.9 --destination-port 12:24 --jump RETURN",
+							"-I foo-bar-baz 1 --protocol tcp -m iprange --dst-range 2.2.3.4-2.2.3.9 --destination-port 64:942 --jump RETURN",
+						)}))
 					})
 				})
 
@@ -686,7 +576,7 @@ var _ = Describe("Iptables", func() {
 					It("returns a wrapped error, including stderr", func() {
 						someError := errors.New("badly laid iptable")
 						fakeRunner.WhenRunning(
-							fake_command_runner.CommandSpec{Path: "/sbin/iptables"},
+							fake_command_runner.CommandSpec{Path: "/sbin/iptables-restore"},
 							func(cmd *exec.Cmd) error {
 								cmd.Stderr.Write([]byte("stderr contents"))
 								return someError
@@ -697,6 +587,549 @@ var _ = Describe("Iptables", func() {
 					})
 				})
 			})
+
+			Describe("DeleteFilterRule", func() {
+				It("deletes each single rule the rule expands to, in one transaction", func() {
+					Expect(subject.DeleteFilterRule(garden.NetOutRule{
+						Protocol: garden.ProtocolTCP,
+						Networks: []garden.IPRange{
+							{Start: net.ParseIP("1.2.3.4")},
+							{Start: net.ParseIP("2.2.3.4"), End: net.ParseIP("2.2.3.9")},
+						},
+						Ports: []garden.PortRange{{12, 24}},
+						Log:   true,
+					})).To(Succeed())
+
+					Expect(fakeRunner.ExecutedCommands()).To(HaveLen(1))
+					Expect(restored(fakeRunner, "/sbin/iptables-restore")).To(Equal([]string{filterBatch(
+						"-D foo-bar-baz --protocol tcp --destination 1.2.3.4 --destination-port 12:24 --goto foo-bar-baz-log",
+						"-D foo-bar-baz --protocol tcp -m iprange --dst-range 2.2.3.4-2.2.3.9 --destination-port 12:24 --goto foo-bar-baz-log",
+					)}))
+				})
+
+				Context("when the rule is invalid", func() {
+					It("returns an error without running iptables", func() {
+						Expect(subject.DeleteFilterRule(garden.NetOutRule{
+							Protocol: garden.ProtocolAll,
+							Ports:    []garden.PortRange{{Start: 1, End: 5}},
+						})).To(MatchError("Ports cannot be specified for Protocol ALL"))
+
+						Expect(fakeRunner.ExecutedCommands()).To(HaveLen(0))
+					})
+				})
+
+				Context("when the rule is not in the chain", func() {
+					It("returns a wrapped error, including stderr", func() {
+						fakeRunner.WhenRunning(
+							fake_command_runner.CommandSpec{Path: "/sbin/iptables-restore"},
+							func(cmd *exec.Cmd) error {
+								cmd.Stderr.Write([]byte("Bad rule (does a matching rule exist in that chain?)"))
+								return errors.New("exit status 1")
+							},
+						)
+
+						Expect(subject.DeleteFilterRule(garden.NetOutRule{})).To(MatchError(
+							"iptables: exit status 1, Bad rule (does a matching rule exist in that chain?)",
+						))
+					})
+				})
+			})
+		})
+
+		Describe("Counters", func() {
+			var listing string
+			var listErr error
+
+			BeforeEach(func() {
+				listing = "-N foo-bar-baz\n" +
+					"-A foo-bar-baz -d 1.2.3.4/32 -p tcp -m tcp --dport 80 -c 12 720 -j RETURN\n" +
+					"-A foo-bar-baz -s 10.254.0.0/30 -d 10.254.0.0/30 -c 0 0 -j ACCEPT\n" +
+					"-A foo-bar-baz -c 3 180 -g w--default\n"
+				listErr = nil
+			})
+
+			JustBeforeEach(func() {
+				fakeRunner.WhenRunning(
+					fake_command_runner.CommandSpec{
+						Path: "/sbin/iptables",
+						Args: []string{"-w", "-t", "filter", "-S", "foo-bar-baz", "-v"},
+					},
+					func(cmd *exec.Cmd) error {
+						cmd.Stdout.Write([]byte(listing))
+						return listErr
+					},
+				)
+			})
+
+			It("returns the counters of each rule of the chain", func() {
+				Expect(subject.Counters()).To(Equal([]RuleCounter{
+					{Rule: "-d 1.2.3.4/32 -p tcp -m tcp --dport 80 -j RETURN", Packets: 12, Bytes: 720},
+					{Rule: "-s 10.254.0.0/30 -d 10.254.0.0/30 -j ACCEPT", Packets: 0, Bytes: 0},
+					{Rule: "-g w--default", Packets: 3, Bytes: 180},
+				}))
+			})
+
+			It("does not list ip6tables", func() {
+				_, err := subject.Counters()
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(fakeRunner.ExecutedCommands()).To(HaveLen(1))
+			})
+
+			Context("when a rule has no counters", func() {
+				BeforeEach(func() {
+					listing = "-A foo-bar-baz -j RETURN\n"
+				})
+
+				It("returns an error", func() {
+					_, err := subject.Counters()
+					Expect(err).To(MatchError("iptables: list counters: no counters: -A foo-bar-baz -j RETURN"))
+				})
+			})
+
+			Context("when listing the chain fails", func() {
+				BeforeEach(func() {
+					listErr = errors.New("no chain by that name")
+				})
+
+				It("returns a wrapped error", func() {
+					_, err := subject.Counters()
+					Expect(err).To(MatchError("iptables: list counters: no chain by that name"))
+				})
+			})
+		})
+
+		Context("when an address is IPv6", func() {
+			It("appends the rule using ip6tables", func() {
+				Expect(subject.AppendRule("", "fd00::/64", Return)).To(Succeed())
+
+				Expect(fakeRunner).To(HaveExecutedSerially(fake_command_runner.CommandSpec{
+					Path: "/sbin/ip6tables",
+					Args: []string{"-w", "-A", "foo-bar-baz", "--destination", "fd00::/64", "--jump", "RETURN"},
+				}))
+			})
+
+			It("prepends a filter rule for an IPv6 network using ip6tables", func() {
+				Expect(subject.PrependFilterRule(garden.NetOutRule{
+					Networks: []garden.IPRange{{Start: net.ParseIP("fd00::1")}},
+				})).To(Succeed())
+
+				Expect(restored(fakeRunner, "/sbin/ip6tables-restore")).To(Equal([]string{filterBatch(
+					"-I foo-bar-baz 1 --protocol all --destination fd00::1 --jump RETURN",
+				)}))
+			})
+
+			It("uses icmpv6 for ICMP rules", func() {
+				Expect(subject.PrependFilterRule(garden.NetOutRule{
+					Protocol: garden.ProtocolICMP,
+					Networks: []garden.IPRange{{Start: net.ParseIP("fd00::1")}},
+					ICMPs:    &garden.ICMPControl{Type: 128},
+				})).To(Succeed())
+
+				Expect(restored(fakeRunner, "/sbin/ip6tables-restore")).To(Equal([]string{filterBatch(
+					"-I foo-bar-baz 1 --protocol icmpv6 --destination fd00::1 --icmpv6-type 128 --jump RETURN",
+				)}))
+			})
+
+			It("rejects a range which mixes IPv4 and IPv6", func() {
+				Expect(subject.PrependFilterRule(garden.NetOutRule{
+					Networks: []garden.IPRange{{Start: net.ParseIP("1.2.3.4"), End: net.ParseIP("fd00::1")}},
+				})).To(MatchError(ContainSubstring("mixes IPv4 and IPv6")))
+
+				Expect(fakeRunner.ExecutedCommands()).To(HaveLen(0))
+			})
+		})
+	})
+
+	Describe("Dual-stack Chain", func() {
+		var fakeRunner *fake_command_runner.FakeCommandRunner
+		var subject Chain
+
+		BeforeEach(func() {
+			fakeRunner = fake_command_runner.New()
+			subject = NewDualStackLoggingChain("foo-bar-baz", false, fakeRunner, lagertest.NewTestLogger("test"))
+		})
+
+		It("sets up the log chain in ip6tables as well as iptables", func() {
+			Expect(subject.Setup("logPrefix")).To(Succeed())
+
+			Expect(fakeRunner).To(HaveExecutedSerially(
+				fake_command_runner.CommandSpec{
+					Path: "/sbin/iptables-restore",
+					Args: []string{"--noflush", "--wait"},
+				},
+				fake_command_runner.CommandSpec{
+					Path: "/sbin/ip6tables-restore",
+					Args: []string{"--noflush", "--wait"},
+				}))
+		})
+
+		It("tears down the log chain in ip6tables as well as iptables", func() {
+			Expect(subject.TearDown()).To(Succeed())
+
+			Expect(fakeRunner).To(HaveExecutedSerially(
+				fake_command_runner.CommandSpec{
+					Path: "/sbin/iptables",
+					Args: []string{"-w", "-X", "foo-bar-baz-log"},
+				},
+				fake_command_runner.CommandSpec{
+					Path: "/sbin/ip6tables",
+					Args: []string{"-w", "-X", "foo-bar-baz-log"},
+				}))
+		})
+
+		It("applies a filter rule without networks to both families", func() {
+			Expect(subject.PrependFilterRule(garden.NetOutRule{
+				Protocol: garden.ProtocolTCP,
+				Ports:    []garden.PortRange{{Start: 80, End: 80}},
+			})).To(Succeed())
+
+			Expect(restored(fakeRunner, "/sbin/iptables-restore")).To(Equal([]string{filterBatch(
+				"-I foo-bar-baz 1 --protocol tcp --destination-port 80 --jump RETURN",
+			)}))
+			Expect(restored(fakeRunner, "/sbin/ip6tables-restore")).To(Equal([]string{filterBatch(
+				"-I foo-bar-baz 1 --protocol tcp --destination-port 80 --jump RETURN",
+			)}))
+		})
+
+		It("deletes a filter rule without networks from both families", func() {
+			Expect(subject.DeleteFilterRule(garden.NetOutRule{
+				Protocol: garden.ProtocolUDP,
+			})).To(Succeed())
+
+			Expect(restored(fakeRunner, "/sbin/iptables-restore")).To(Equal([]string{filterBatch(
+				"-D foo-bar-baz --protocol udp --jump RETURN",
+			)}))
+			Expect(restored(fakeRunner, "/sbin/ip6tables-restore")).To(Equal([]string{filterBatch(
+				"-D foo-bar-baz --protocol udp --jump RETURN",
+			)}))
+		})
+
+		Context("when deleting a filter rule from ip6tables fails", func() {
+			BeforeEach(func() {
+				fakeRunner.WhenRunning(
+					fake_command_runner.CommandSpec{
+						Path: "/sbin/iptables",
+						Args: []string{"-w", "-t", "filter", "-S", "foo-bar-baz", "-v"},
+					},
+					func(cmd *exec.Cmd) error {
+						cmd.Stdout.Write([]byte("-N foo-bar-baz\n" +
+							"-A foo-bar-baz -p tcp -c 1 60 -j RETURN\n" +
+							"-A foo-bar-baz -p udp -c 2 120 -j RETURN\n" +
+							"-A foo-bar-baz -c 3 180 -g w--default\n"))
+						return nil
+					},
+				)
+
+				fakeRunner.WhenRunning(
+					fake_command_runner.CommandSpec{Path: "/sbin/ip6tables-restore"},
+					func(cmd *exec.Cmd) error {
+						return errors.New("oh no")
+					},
+				)
+			})
+
+			It("puts the chain in iptables back as it was, with the rule in its original position", func() {
+				Expect(subject.DeleteFilterRule(garden.NetOutRule{
+					Protocol: garden.ProtocolUDP,
+				})).To(MatchError(ContainSubstring("oh no")))
+
+				Expect(restored(fakeRunner, "/sbin/iptables-restore")).To(Equal([]string{
+					filterBatch("-D foo-bar-baz --protocol udp --jump RETURN"),
+					filterBatch(
+						":foo-bar-baz - [0:0]",
+						"-A foo-bar-baz -p tcp -c 1 60 -j RETURN",
+						"-A foo-bar-baz -p udp -c 2 120 -j RETURN",
+						"-A foo-bar-baz -c 3 180 -g w--default",
+					),
+				}))
+			})
+		})
+
+		Context("when listing the rules of iptables fails", func() {
+			BeforeEach(func() {
+				fakeRunner.WhenRunning(
+					fake_command_runner.CommandSpec{Path: "/sbin/iptables"},
+					func(cmd *exec.Cmd) error {
+						return errors.New("oh no")
+					},
+				)
+			})
+
+			It("returns an error without deleting anything", func() {
+				Expect(subject.DeleteFilterRule(garden.NetOutRule{
+					Protocol: garden.ProtocolUDP,
+				})).To(MatchError("iptables: list rules: oh no"))
+
+				Expect(restored(fakeRunner, "/sbin/iptables-restore")).To(BeEmpty())
+				Expect(restored(fakeRunner, "/sbin/ip6tables-restore")).To(BeEmpty())
+			})
+		})
+
+		Context("when deleting a filter rule from iptables fails", func() {
+			BeforeEach(func() {
+				fakeRunner.WhenRunning(
+					fake_command_runner.CommandSpec{Path: "/sbin/iptables-restore"},
+					func(cmd *exec.Cmd) error {
+						return errors.New("oh no")
+					},
+				)
+			})
+
+			It("leaves ip6tables alone", func() {
+				Expect(subject.DeleteFilterRule(garden.NetOutRule{
+					Protocol: garden.ProtocolUDP,
+				})).ToNot(Succeed())
+
+				Expect(restored(fakeRunner, "/sbin/ip6tables-restore")).To(BeEmpty())
+			})
+		})
+
+		It("returns the counters of the rules of both families", func() {
+			fakeRunner.WhenRunning(
+				fake_command_runner.CommandSpec{Path: "/sbin/iptables"},
+				func(cmd *exec.Cmd) error {
+					cmd.Stdout.Write([]byte("-A foo-bar-baz -p tcp -c 1 60 -j RETURN\n"))
+					return nil
+				},
+			)
+			fakeRunner.WhenRunning(
+				fake_command_runner.CommandSpec{Path: "/sbin/ip6tables"},
+				func(cmd *exec.Cmd) error {
+					cmd.Stdout.Write([]byte("-A foo-bar-baz -p tcp -c 2 160 -j RETURN\n"))
+					return nil
+				},
+			)
+
+			Expect(subject.Counters()).To(Equal([]RuleCounter{
+				{Rule: "-p tcp -j RETURN", Packets: 1, Bytes: 60},
+				{Rule: "-p tcp -j RETURN", IPv6: true, Packets: 2, Bytes: 160},
+			}))
+		})
+
+		Describe("PrependFilterRules", func() {
+			It("applies all of the rules in one transaction per family", func() {
+				Expect(subject.PrependFilterRules([]garden.NetOutRule{
+					{Protocol: garden.ProtocolTCP, Ports: []garden.PortRange{{Start: 80, End: 80}}},
+					{Protocol: garden.ProtocolUDP, Networks: []garden.IPRange{{Start: net.ParseIP("1.2.3.4")}}},
+				})).To(Succeed())
+
+				Expect(fakeRunner.ExecutedCommands()).To(HaveLen(2))
+				Expect(restored(fakeRunner, "/sbin/iptables-restore")).To(Equal([]string{filterBatch(
+					"-I foo-bar-baz 1 --protocol tcp --destination-port 80 --jump RETURN",
+					"-I foo-bar-baz 1 --protocol udp --destination 1.2.3.4 --jump RETURN",
+				)}))
+				Expect(restored(fakeRunner, "/sbin/ip6tables-restore")).To(Equal([]string{filterBatch(
+					"-I foo-bar-baz 1 --protocol tcp --destination-port 80 --jump RETURN",
+				)}))
+			})
+
+			Context("when any of the rules is invalid", func() {
+				It("returns the error without applying any of them", func() {
+					err := subject.PrependFilterRules([]garden.NetOutRule{
+						{Protocol: garden.ProtocolTCP},
+						{Protocol: garden.ProtocolICMP, Ports: []garden.PortRange{{Start: 1, End: 5}}},
+					})
+					Expect(err).To(MatchError("Ports cannot be specified for Protocol ICMP"))
+
+					Expect(fakeRunner.ExecutedCommands()).To(BeEmpty())
+				})
+			})
+
+			Context("when applying the rules of a family fails", func() {
+				BeforeEach(func() {
+					fakeRunner.WhenRunning(
+						fake_command_runner.CommandSpec{Path: "/sbin/ip6tables-restore"},
+						func(cmd *exec.Cmd) error {
+							return errors.New("oh no")
+						},
+					)
+				})
+
+				It("deletes the rules which were applied to the other family", func() {
+					Expect(subject.PrependFilterRules([]garden.NetOutRule{
+						{Protocol: garden.ProtocolTCP},
+						{Protocol: garden.ProtocolUDP},
+					})).ToNot(Succeed())
+
+					Expect(restored(fakeRunner, "/sbin/iptables-restore")).To(Equal([]string{
+						filterBatch(
+							"-I foo-bar-baz 1 --protocol tcp --jump RETURN",
+							"-I foo-bar-baz 1 --protocol udp --jump RETURN",
+						),
+						filterBatch(
+							"-D foo-bar-baz --protocol tcp --jump RETURN",
+							"-D foo-bar-baz --protocol udp --jump RETURN",
+						),
+					}))
+				})
+			})
+		})
+
+		It("applies an ICMP rule without networks to IPv4 only", func() {
+			Expect(subject.PrependFilterRule(garden.NetOutRule{
+				Protocol: garden.ProtocolICMP,
+			})).To(Succeed())
+
+			Expect(fakeRunner.ExecutedCommands()).To(HaveLen(1))
+			Expect(fakeRunner.ExecutedCommands()[0].Path).To(Equal("/sbin/iptables-restore"))
+		})
+
+		It("applies a filter rule for an IPv4 network to IPv4 only", func() {
+			Expect(subject.PrependFilterRule(garden.NetOutRule{
+				Networks: []garden.IPRange{{Start: net.ParseIP("1.2.3.4")}},
+			})).To(Succeed())
+
+			Expect(fakeRunner.ExecutedCommands()).To(HaveLen(1))
+			Expect(fakeRunner.ExecutedCommands()[0].Path).To(Equal("/sbin/iptables-restore"))
+		})
+	})
+
+	Describe("DetectRestoreWait", func() {
+		var fakeRunner *fake_command_runner.FakeCommandRunner
+		var usage string
+		var helpErr error
+
+		BeforeEach(func() {
+			fakeRunner = fake_command_runner.New()
+			helpErr = nil
+
+			fakeRunner.WhenRunning(fake_command_runner.CommandSpec{
+				Path: "/sbin/iptables-restore",
+				Args: []string{"--help"},
+			}, func(cmd *exec.Cmd) error {
+				cmd.Stderr.Write([]byte(usage))
+				return helpErr
+			})
+		})
+
+		Context("when iptables-restore supports --wait", func() {
+			BeforeEach(func() {
+				usage = "Usage: iptables-restore [-c] [-v] [-V] [-t] [-h] [-n] [-w secs] [-W usecs] [-T table] [-M command]\n" +
+					"	   [ --wait ]\n"
+			})
+
+			It("returns true", func() {
+				Expect(DetectRestoreWait(fakeRunner)).To(BeTrue())
+			})
+		})
+
+		Context("when iptables-restore does not support --wait", func() {
+			BeforeEach(func() {
+				usage = "Usage: iptables-restore [-b] [-c] [-v] [-t] [-h]\n" +
+					"	   [ --noflush ]\n"
+			})
+
+			It("returns false", func() {
+				Expect(DetectRestoreWait(fakeRunner)).To(BeFalse())
+			})
+		})
+
+		Context("when iptables-restore fails", func() {
+			BeforeEach(func() {
+				usage = "[ --wait ]"
+				helpErr = errors.New("oh no!")
+			})
+
+			It("returns an error, and false", func() {
+				supported, err := DetectRestoreWait(fakeRunner)
+				Expect(err).To(HaveOccurred())
+				Expect(supported).To(BeFalse())
+			})
+		})
+	})
+
+	Describe("UseRestoreWait", func() {
+		var fakeRunner *fake_command_runner.FakeCommandRunner
+		var chain Chain
+
+		BeforeEach(func() {
+			fakeRunner = fake_command_runner.New()
+			chain = NewLoggingChain("foo-bar-baz", false, fakeRunner, lagertest.NewTestLogger("test"))
+		})
+
+		Context("when --wait is supported", func() {
+			BeforeEach(func() {
+				UseRestoreWait(true)
+			})
+
+			It("restores with --wait", func() {
+				Expect(chain.Setup("logPrefix")).To(Succeed())
+
+				Expect(fakeRunner.ExecutedCommands()).To(HaveLen(1))
+				Expect(fakeRunner.ExecutedCommands()[0].Args[1:]).To(Equal([]string{"--noflush", "--wait"}))
+			})
+		})
+
+		Context("when --wait is not supported", func() {
+			BeforeEach(func() {
+				UseRestoreWait(false)
+			})
+
+			It("restores without --wait", func() {
+				Expect(chain.Setup("logPrefix")).To(Succeed())
+
+				Expect(fakeRunner.ExecutedCommands()).To(HaveLen(1))
+				Expect(fakeRunner.ExecutedCommands()[0].Args[1:]).To(Equal([]string{"--noflush"}))
+			})
+
+			It("does not run iptables while a restore is running", func() {
+				restoring := make(chan struct{})
+				release := make(chan struct{})
+
+				fakeRunner.WhenRunning(fake_command_runner.CommandSpec{
+					Path: "/sbin/iptables-restore",
+				}, func(*exec.Cmd) error {
+					close(restoring)
+					<-release
+					return nil
+				})
+
+				go chain.Setup("logPrefix")
+				Eventually(restoring).Should(BeClosed())
+
+				tornDown := make(chan struct{})
+				go func() {
+					chain.TearDown()
+					close(tornDown)
+				}()
+
+				Consistently(fakeRunner.ExecutedCommands).Should(HaveLen(1))
+
+				close(release)
+				Eventually(tornDown).Should(BeClosed())
+
+				Expect(fakeRunner).To(HaveExecutedSerially(
+					fake_command_runner.CommandSpec{
+						Path: "/sbin/iptables",
+						Args: []string{"-w", "-F", "foo-bar-baz-log"},
+					},
+				))
+			})
 		})
 	})
 })
+
+// restored returns what was passed to each run of the given iptables-restore
+// binary, checking that it was run so as to leave other rules alone.
+func restored(fakeRunner *fake_command_runner.FakeCommandRunner, path string) []string {
+	inputs := []string{}
+	for _, cmd := range fakeRunner.ExecutedCommands() {
+		if cmd.Path != path {
+			continue
+		}
+
+		Expect(cmd.Args[1:]).To(Equal([]string{"--noflush", "--wait"}))
+
+		input, err := ioutil.ReadAll(cmd.Stdin)
+		Expect(err).ToNot(HaveOccurred())
+
+		inputs = append(inputs, string(input))
+	}
+
+	return inputs
+}
+
+func filterBatch(rules ...string) string {
+	return "*filter\n" + strings.Join(rules, "\n") + "\nCOMMIT\n"
+}
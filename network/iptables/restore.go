@@ -0,0 +1,156 @@
+package iptables
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+
+	"github.com/cloudfoundry/gunk/command_runner"
+)
+
+var restoreBins = map[string]string{
+	iptablesBin:  "/sbin/iptables-restore",
+	ip6tablesBin: "/sbin/ip6tables-restore",
+}
+
+// restoreWait records whether iptables-restore takes the xtables lock itself
+// when given --wait, which it only accepts from iptables 1.6.2. Until
+// UseRestoreWait says otherwise, it is assumed not to.
+var restoreWait = struct {
+	sync.RWMutex
+	supported bool
+}{}
+
+// xtablesMutex serialises every iptables and iptables-restore command of this
+// process when iptables-restore cannot wait for the xtables lock, so that at
+// least they do not race each other.
+var xtablesMutex sync.Mutex
+
+// DetectRestoreWait checks whether iptables-restore accepts --wait. If the
+// check fails, --wait is reported as unsupported.
+func DetectRestoreWait(runner command_runner.CommandRunner) (bool, error) {
+	var usage bytes.Buffer
+	cmd := exec.Command(restoreBins[iptablesBin], "--help")
+	cmd.Stdout = &usage
+	cmd.Stderr = &usage
+
+	if err := runner.Run(cmd); err != nil {
+		return false, fmt.Errorf("iptables: detect restore --wait: %v, %v", err, usage.String())
+	}
+
+	return strings.Contains(usage.String(), "--wait"), nil
+}
+
+// UseRestoreWait sets whether restores are given --wait. If they are not, every
+// iptables command of this process is serialised instead. It is meant to be
+// called once, at startup, before any chain is used.
+func UseRestoreWait(supported bool) {
+	restoreWait.Lock()
+	defer restoreWait.Unlock()
+
+	restoreWait.supported = supported
+}
+
+func restoreWaitSupported() bool {
+	restoreWait.RLock()
+	defer restoreWait.RUnlock()
+
+	return restoreWait.supported
+}
+
+// run runs an iptables or iptables-restore command, holding xtablesMutex if
+// iptables-restore cannot wait for the xtables lock.
+func run(runner command_runner.CommandRunner, cmd *exec.Cmd) error {
+	if !restoreWaitSupported() {
+		xtablesMutex.Lock()
+		defer xtablesMutex.Unlock()
+	}
+
+	return runner.Run(cmd)
+}
+
+// A batch is a set of changes to a table which are applied in a single
+// iptables-restore transaction, so that however many rules it has, it costs one
+// process and one hold of the xtables lock, and either all of its changes are
+// made or none are.
+type batch struct {
+	table  string
+	chains []string
+	rules  [][]string
+}
+
+func newBatch(table string) *batch {
+	return &batch{table: table}
+}
+
+// declareChain creates the chain, or flushes it if it already exists.
+func (b *batch) declareChain(name string) {
+	b.chains = append(b.chains, name)
+}
+
+// add appends a rule, given as iptables arguments without -w or -t.
+func (b *batch) add(rule ...string) {
+	b.rules = append(b.rules, rule)
+}
+
+func (b *batch) empty() bool {
+	return len(b.chains) == 0 && len(b.rules) == 0
+}
+
+func (b *batch) String() string {
+	var buf bytes.Buffer
+
+	fmt.Fprintf(&buf, "*%s\n", b.table)
+
+	for _, chain := range b.chains {
+		fmt.Fprintf(&buf, ":%s - [0:0]\n", chain)
+	}
+
+	for _, rule := range b.rules {
+		args := make([]string, len(rule))
+		for i, arg := range rule {
+			args[i] = quote(arg)
+		}
+
+		fmt.Fprintln(&buf, strings.Join(args, " "))
+	}
+
+	fmt.Fprintln(&buf, "COMMIT")
+
+	return buf.String()
+}
+
+// apply runs the batch with the iptables-restore counterpart of bin. Rules
+// outside of the batch are left alone.
+func (b *batch) apply(runner command_runner.CommandRunner, bin string) error {
+	if b.empty() {
+		return nil
+	}
+
+	args := []string{"--noflush"}
+	if restoreWaitSupported() {
+		args = append(args, "--wait")
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.Command(restoreBins[bin], args...)
+	cmd.Stdin = strings.NewReader(b.String())
+	cmd.Stderr = &stderr
+
+	if err := run(runner, cmd); err != nil {
+		return fmt.Errorf("iptables: %v, %v", err, stderr.String())
+	}
+
+	return nil
+}
+
+// quote quotes an argument for iptables-restore if it would otherwise be split.
+func quote(arg string) string {
+	if arg != "" && !strings.ContainsAny(arg, " \t\"'") {
+		return arg
+	}
+
+	return `"` + strings.Replace(arg, `"`, `\"`, -1) + `"`
+}
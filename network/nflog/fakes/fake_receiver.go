@@ -0,0 +1,45 @@
+// This file was generated by counterfeiter
+package fakes
+
+import (
+	"sync"
+
+	"github.com/cloudfoundry-incubator/garden-linux/network/nflog"
+)
+
+type FakeReceiver struct {
+	ReceiveStub        func() ([][]byte, error)
+	receiveMutex       sync.RWMutex
+	receiveArgsForCall []struct{}
+	receiveReturns     struct {
+		result1 [][]byte
+		result2 error
+	}
+}
+
+func (fake *FakeReceiver) Receive() ([][]byte, error) {
+	fake.receiveMutex.Lock()
+	fake.receiveArgsForCall = append(fake.receiveArgsForCall, struct{}{})
+	fake.receiveMutex.Unlock()
+	if fake.ReceiveStub != nil {
+		return fake.ReceiveStub()
+	} else {
+		return fake.receiveReturns.result1, fake.receiveReturns.result2
+	}
+}
+
+func (fake *FakeReceiver) ReceiveCallCount() int {
+	fake.receiveMutex.RLock()
+	defer fake.receiveMutex.RUnlock()
+	return len(fake.receiveArgsForCall)
+}
+
+func (fake *FakeReceiver) ReceiveReturns(result1 [][]byte, result2 error) {
+	fake.ReceiveStub = nil
+	fake.receiveReturns = struct {
+		result1 [][]byte
+		result2 error
+	}{result1, result2}
+}
+
+var _ nflog.Receiver = new(FakeReceiver)
@@ -0,0 +1,263 @@
+// Package nflog decodes the packets which the log chains of containers send to
+// an NFLOG group, and logs them as the connections of the containers.
+package nflog
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"syscall"
+	"unsafe"
+
+	"github.com/pivotal-golang/lager"
+)
+
+//go:generate counterfeiter . Receiver
+
+// Receiver receives the bodies of the packet messages of an NFLOG group.
+type Receiver interface {
+	Receive() ([][]byte, error)
+}
+
+// A Connection is the first packet of a connection a container made, as
+// logged by its log chain, whose NFLOG prefix is the container's handle.
+type Connection struct {
+	Handle string
+
+	Protocol        string
+	Source          net.IP
+	SourcePort      uint16
+	Destination     net.IP
+	DestinationPort uint16
+}
+
+// Listener logs each packet it receives as a connection.
+type Listener struct {
+	receiver Receiver
+	logger   lager.Logger
+}
+
+func NewListener(receiver Receiver, logger lager.Logger) *Listener {
+	return &Listener{
+		receiver: receiver,
+		logger:   logger,
+	}
+}
+
+// Listen logs connections until receiving fails, e.g. because the receiver
+// has been closed. Packets which cannot be decoded are logged as errors.
+func (l *Listener) Listen() error {
+	for {
+		bodies, err := l.receiver.Receive()
+		if err == syscall.ENOBUFS {
+			// the kernel dropped packets the listener was too slow to receive
+			l.logger.Error("receive-buffer-overrun", err)
+			continue
+		}
+
+		if err != nil {
+			return err
+		}
+
+		for _, body := range bodies {
+			conn, err := decodePacket(body)
+			if err != nil {
+				l.logger.Error("decode-packet-failed", err)
+				continue
+			}
+
+			l.logger.Info("connection", lager.Data{
+				"handle":   conn.Handle,
+				"protocol": conn.Protocol,
+				"src":      conn.Source.String(),
+				"dst":      conn.Destination.String(),
+				"src-port": conn.SourcePort,
+				"port":     conn.DestinationPort,
+			})
+		}
+	}
+}
+
+const (
+	nfulaPayload = 9
+	nfulaPrefix  = 10
+
+	protocolICMP   = 1
+	protocolTCP    = 6
+	protocolUDP    = 17
+	protocolICMPv6 = 58
+)
+
+var protocolNames = map[uint8]string{
+	protocolICMP:   "icmp",
+	protocolTCP:    "tcp",
+	protocolUDP:    "udp",
+	protocolICMPv6: "icmpv6",
+}
+
+var errTruncated = errors.New("nflog: truncated packet")
+
+// decodePacket decodes the body of a packet message, which is a nfgenmsg
+// followed by attributes, of which the payload is the packet from its network
+// header on.
+func decodePacket(body []byte) (Connection, error) {
+	if len(body) < 4 {
+		return Connection{}, errTruncated
+	}
+
+	attrs, err := parseAttrs(body[4:])
+	if err != nil {
+		return Connection{}, err
+	}
+
+	payload, ok := attrs[nfulaPayload]
+	if !ok {
+		return Connection{}, errors.New("nflog: packet has no payload")
+	}
+
+	conn, err := decodeNetworkHeader(payload)
+	if err != nil {
+		return Connection{}, err
+	}
+
+	conn.Handle = string(bytes.TrimRight(attrs[nfulaPrefix], "\x00"))
+
+	return conn, nil
+}
+
+func decodeNetworkHeader(payload []byte) (Connection, error) {
+	if len(payload) < 1 {
+		return Connection{}, errTruncated
+	}
+
+	switch payload[0] >> 4 {
+	case 4:
+		if len(payload) < 20 {
+			return Connection{}, errTruncated
+		}
+
+		headerLen := int(payload[0]&0x0f) * 4
+		if headerLen < 20 || len(payload) < headerLen {
+			return Connection{}, errTruncated
+		}
+
+		return decodeTransportHeader(
+			net.IP(append([]byte{}, payload[12:16]...)),
+			net.IP(append([]byte{}, payload[16:20]...)),
+			payload[9],
+			payload[headerLen:],
+		)
+
+	case 6:
+		if len(payload) < 40 {
+			return Connection{}, errTruncated
+		}
+
+		protocol, transport, err := skipIPv6ExtensionHeaders(payload[6], payload[40:])
+		if err != nil {
+			return Connection{}, err
+		}
+
+		return decodeTransportHeader(
+			net.IP(append([]byte{}, payload[8:24]...)),
+			net.IP(append([]byte{}, payload[24:40]...)),
+			protocol,
+			transport,
+		)
+
+	default:
+		return Connection{}, fmt.Errorf("nflog: unknown IP version %d", payload[0]>>4)
+	}
+}
+
+// skipIPv6ExtensionHeaders returns the protocol of the transport header which
+// follows any extension headers, and the header itself.
+func skipIPv6ExtensionHeaders(next uint8, b []byte) (uint8, []byte, error) {
+	for {
+		var length int
+
+		switch next {
+		case 0, 43, 60: // hop-by-hop options, routing, destination options
+			if len(b) < 2 {
+				return 0, nil, errTruncated
+			}
+
+			length = (int(b[1]) + 1) * 8
+
+		case 44: // fragment
+			length = 8
+
+		default:
+			return next, b, nil
+		}
+
+		if len(b) < length {
+			return 0, nil, errTruncated
+		}
+
+		next, b = b[0], b[length:]
+	}
+}
+
+func decodeTransportHeader(src, dst net.IP, protocol uint8, transport []byte) (Connection, error) {
+	conn := Connection{
+		Protocol:    protocolNames[protocol],
+		Source:      src,
+		Destination: dst,
+	}
+
+	if conn.Protocol == "" {
+		conn.Protocol = fmt.Sprintf("%d", protocol)
+	}
+
+	if protocol == protocolTCP || protocol == protocolUDP {
+		if len(transport) < 4 {
+			return Connection{}, errTruncated
+		}
+
+		conn.SourcePort = binary.BigEndian.Uint16(transport[0:2])
+		conn.DestinationPort = binary.BigEndian.Uint16(transport[2:4])
+	}
+
+	return conn, nil
+}
+
+// parseAttrs returns the data of each netlink attribute, by type.
+func parseAttrs(b []byte) (map[uint16][]byte, error) {
+	attrs := map[uint16][]byte{}
+
+	for len(b) >= 4 {
+		length := int(native.Uint16(b[0:2]))
+		if length < 4 || length > len(b) {
+			return nil, errTruncated
+		}
+
+		attrs[native.Uint16(b[2:4])&0x3fff] = b[4:length]
+
+		if align(length) >= len(b) {
+			break
+		}
+
+		b = b[align(length):]
+	}
+
+	return attrs, nil
+}
+
+func align(length int) int {
+	return (length + 3) &^ 3
+}
+
+// native is the byte order of the host, which netlink messages are in.
+var native = nativeEndian()
+
+func nativeEndian() binary.ByteOrder {
+	x := uint16(1)
+	if *(*byte)(unsafe.Pointer(&x)) == 1 {
+		return binary.LittleEndian
+	}
+
+	return binary.BigEndian
+}
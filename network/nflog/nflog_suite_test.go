@@ -0,0 +1,13 @@
+package nflog_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestNflog(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Nflog Suite")
+}
@@ -0,0 +1,264 @@
+package nflog_test
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+	"syscall"
+	"unsafe"
+
+	"github.com/cloudfoundry-incubator/garden-linux/network/nflog"
+	"github.com/cloudfoundry-incubator/garden-linux/network/nflog/fakes"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pivotal-golang/lager"
+	"github.com/pivotal-golang/lager/lagertest"
+)
+
+var _ = Describe("Listener", func() {
+	var (
+		fakeReceiver *fakes.FakeReceiver
+		logger       *lagertest.TestLogger
+		listener     *nflog.Listener
+
+		// the results of successive receives, after which the receiver is
+		// closed
+		received    [][][]byte
+		receiveErrs []error
+		closedErr   error
+	)
+
+	BeforeEach(func() {
+		received = nil
+		receiveErrs = nil
+		closedErr = errors.New("closed")
+
+		fakeReceiver = new(fakes.FakeReceiver)
+		fakeReceiver.ReceiveStub = func() ([][]byte, error) {
+			call := fakeReceiver.ReceiveCallCount() - 1
+			if call < len(receiveErrs) && receiveErrs[call] != nil {
+				return nil, receiveErrs[call]
+			}
+
+			if call < len(received) {
+				return received[call], nil
+			}
+
+			return nil, closedErr
+		}
+
+		logger = lagertest.NewTestLogger("test")
+		listener = nflog.NewListener(fakeReceiver, logger)
+	})
+
+	connections := func() []lager.Data {
+		data := []lager.Data{}
+		for _, log := range logger.Logs() {
+			if log.Message == "test.connection" {
+				data = append(data, log.Data)
+			}
+		}
+
+		return data
+	}
+
+	It("returns the error which stopped it receiving", func() {
+		Expect(listener.Listen()).To(Equal(closedErr))
+	})
+
+	It("logs a TCP connection over IPv4 with the handle of its container", func() {
+		received = [][][]byte{{
+			packet("some-handle", ipv4(net.ParseIP("10.0.0.2"), net.ParseIP("8.8.8.8"), 6, ports(34567, 53))),
+		}}
+
+		Expect(listener.Listen()).To(Equal(closedErr))
+		Expect(connections()).To(Equal([]lager.Data{{
+			"handle":   "some-handle",
+			"protocol": "tcp",
+			"src":      "10.0.0.2",
+			"dst":      "8.8.8.8",
+			"src-port": float64(34567),
+			"port":     float64(53),
+		}}))
+	})
+
+	It("logs a UDP connection over IPv4 with IP options", func() {
+		header := ipv4(net.ParseIP("10.0.0.2"), net.ParseIP("8.8.4.4"), 17, nil)
+		header[0] = 0x46
+		header = append(header, 1, 1, 1, 0)
+
+		received = [][][]byte{{
+			packet("some-handle", append(header, ports(1234, 5678)...)),
+		}}
+
+		Expect(listener.Listen()).To(Equal(closedErr))
+		Expect(connections()).To(ConsistOf(lager.Data{
+			"handle":   "some-handle",
+			"protocol": "udp",
+			"src":      "10.0.0.2",
+			"dst":      "8.8.4.4",
+			"src-port": float64(1234),
+			"port":     float64(5678),
+		}))
+	})
+
+	It("logs a TCP connection over IPv6, skipping extension headers", func() {
+		// a hop-by-hop options header, which is followed by TCP
+		extension := []byte{6, 0, 1, 4, 0, 0, 0, 0}
+
+		received = [][][]byte{{
+			packet("some-handle", ipv6(net.ParseIP("fd00::2"), net.ParseIP("2001:db8::1"), 0, append(extension, ports(40000, 443)...))),
+		}}
+
+		Expect(listener.Listen()).To(Equal(closedErr))
+		Expect(connections()).To(ConsistOf(lager.Data{
+			"handle":   "some-handle",
+			"protocol": "tcp",
+			"src":      "fd00::2",
+			"dst":      "2001:db8::1",
+			"src-port": float64(40000),
+			"port":     float64(443),
+		}))
+	})
+
+	It("logs the connections of several packets and receives", func() {
+		received = [][][]byte{
+			{
+				packet("handle-a", ipv4(net.ParseIP("10.0.0.2"), net.ParseIP("1.2.3.4"), 6, ports(1, 80))),
+				packet("handle-b", ipv4(net.ParseIP("10.0.0.6"), net.ParseIP("1.2.3.4"), 6, ports(2, 80))),
+			},
+			{
+				packet("handle-a", ipv4(net.ParseIP("10.0.0.2"), net.ParseIP("1.2.3.4"), 6, ports(3, 80))),
+			},
+		}
+
+		Expect(listener.Listen()).To(Equal(closedErr))
+
+		handles := []interface{}{}
+		for _, conn := range connections() {
+			handles = append(handles, conn["handle"])
+		}
+
+		Expect(handles).To(Equal([]interface{}{"handle-a", "handle-b", "handle-a"}))
+	})
+
+	It("names protocols other than TCP and UDP by number, without ports", func() {
+		received = [][][]byte{{
+			packet("some-handle", ipv4(net.ParseIP("10.0.0.2"), net.ParseIP("1.2.3.4"), 132, nil)),
+		}}
+
+		Expect(listener.Listen()).To(Equal(closedErr))
+		Expect(connections()).To(ConsistOf(lager.Data{
+			"handle":   "some-handle",
+			"protocol": "132",
+			"src":      "10.0.0.2",
+			"dst":      "1.2.3.4",
+			"src-port": float64(0),
+			"port":     float64(0),
+		}))
+	})
+
+	Context("when a packet cannot be decoded", func() {
+		BeforeEach(func() {
+			truncated := ipv4(net.ParseIP("10.0.0.2"), net.ParseIP("1.2.3.4"), 6, ports(1, 80))
+			truncated = truncated[:len(truncated)-6]
+
+			received = [][][]byte{{
+				packet("some-handle", truncated),
+				packet("some-handle", []byte{0x50}),
+				{0, 0},
+				packet("other-handle", ipv4(net.ParseIP("10.0.0.6"), net.ParseIP("1.2.3.4"), 6, ports(2, 80))),
+			}}
+		})
+
+		It("logs an error and carries on", func() {
+			Expect(listener.Listen()).To(Equal(closedErr))
+
+			Expect(logger.LogMessages()).To(Equal([]string{
+				"test.decode-packet-failed",
+				"test.decode-packet-failed",
+				"test.decode-packet-failed",
+				"test.connection",
+			}))
+			Expect(connections()[0]["handle"]).To(Equal("other-handle"))
+		})
+	})
+
+	Context("when the receive buffer overruns", func() {
+		BeforeEach(func() {
+			receiveErrs = []error{syscall.ENOBUFS}
+			received = [][][]byte{
+				nil,
+				{packet("some-handle", ipv4(net.ParseIP("10.0.0.2"), net.ParseIP("1.2.3.4"), 6, ports(1, 80)))},
+			}
+		})
+
+		It("logs an error and carries on receiving", func() {
+			Expect(listener.Listen()).To(Equal(closedErr))
+
+			Expect(logger.LogMessages()).To(Equal([]string{
+				"test.receive-buffer-overrun",
+				"test.connection",
+			}))
+		})
+	})
+})
+
+// packet returns the body of a packet message for the payload, with the
+// handle as prefix.
+func packet(handle string, payload []byte) []byte {
+	body := []byte{syscall.AF_INET, 0, 0, 1}
+	body = append(body, attr(10, append([]byte(handle), 0))...)
+	body = append(body, attr(9, payload)...)
+
+	return body
+}
+
+func attr(attrType uint16, data []byte) []byte {
+	b := make([]byte, (4+len(data)+3)&^3)
+	native.PutUint16(b[0:2], uint16(4+len(data)))
+	native.PutUint16(b[2:4], attrType)
+	copy(b[4:], data)
+
+	return b
+}
+
+func ipv4(src, dst net.IP, protocol uint8, transport []byte) []byte {
+	header := make([]byte, 20)
+	header[0] = 0x45
+	header[8] = 64
+	header[9] = protocol
+	copy(header[12:16], src.To4())
+	copy(header[16:20], dst.To4())
+
+	return append(header, transport...)
+}
+
+func ipv6(src, dst net.IP, next uint8, rest []byte) []byte {
+	header := make([]byte, 40)
+	header[0] = 0x60
+	binary.BigEndian.PutUint16(header[4:6], uint16(len(rest)))
+	header[6] = next
+	header[7] = 64
+	copy(header[8:24], src.To16())
+	copy(header[24:40], dst.To16())
+
+	return append(header, rest...)
+}
+
+func ports(src, dst uint16) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint16(b[0:2], src)
+	binary.BigEndian.PutUint16(b[2:4], dst)
+
+	return b
+}
+
+var native = func() binary.ByteOrder {
+	x := uint16(1)
+	if *(*byte)(unsafe.Pointer(&x)) == 1 {
+		return binary.LittleEndian
+	}
+
+	return binary.BigEndian
+}()
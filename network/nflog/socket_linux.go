@@ -0,0 +1,160 @@
+package nflog
+
+import (
+	"bytes"
+	"encoding/binary"
+	"syscall"
+)
+
+const (
+	nfnlSubsysULOG = 4
+
+	nfulnlMsgPacket = 0
+	nfulnlMsgConfig = 1
+
+	nfulaCfgCmd  = 1
+	nfulaCfgMode = 2
+
+	nfulnlCfgCmdBind     = 1
+	nfulnlCfgCmdPfBind   = 3
+	nfulnlCfgCmdPfUnbind = 4
+
+	nfulnlCopyPacket = 2
+
+	// enough of each packet for its network and transport headers
+	copyRange = 128
+)
+
+// A Socket receives the packets of an NFLOG group.
+type Socket struct {
+	fd  int
+	seq uint32
+}
+
+// Dial binds a socket to the NFLOG group. Only one socket on the host can be
+// bound to a group.
+func Dial(group uint16) (*Socket, error) {
+	fd, err := syscall.Socket(syscall.AF_NETLINK, syscall.SOCK_RAW|syscall.SOCK_CLOEXEC, syscall.NETLINK_NETFILTER)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Socket{fd: fd}
+
+	if err := syscall.Bind(fd, &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK}); err != nil {
+		s.Close()
+		return nil, err
+	}
+
+	// kernels before 3.17 only log the families which are bound explicitly
+	for _, family := range []uint8{syscall.AF_INET, syscall.AF_INET6} {
+		for _, cmd := range []uint8{nfulnlCfgCmdPfUnbind, nfulnlCfgCmdPfBind} {
+			if err := s.configure(family, 0, nfulaCfgCmd, []byte{cmd}); err != nil {
+				s.Close()
+				return nil, err
+			}
+		}
+	}
+
+	if err := s.configure(syscall.AF_UNSPEC, group, nfulaCfgCmd, []byte{nfulnlCfgCmdBind}); err != nil {
+		s.Close()
+		return nil, err
+	}
+
+	// struct nfulnl_msg_config_mode is packed: a big endian copy range, the
+	// copy mode, and a byte of padding
+	mode := make([]byte, 6)
+	binary.BigEndian.PutUint32(mode[0:4], copyRange)
+	mode[4] = nfulnlCopyPacket
+
+	if err := s.configure(syscall.AF_UNSPEC, group, nfulaCfgMode, mode); err != nil {
+		s.Close()
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Receive returns the bodies of the packet messages of the next datagram.
+func (s *Socket) Receive() ([][]byte, error) {
+	buf := make([]byte, 65536)
+
+	n, _, err := syscall.Recvfrom(s.fd, buf, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	msgs, err := syscall.ParseNetlinkMessage(buf[:n])
+	if err != nil {
+		return nil, err
+	}
+
+	bodies := [][]byte{}
+	for _, msg := range msgs {
+		if msg.Header.Type == nfnlSubsysULOG<<8|nfulnlMsgPacket {
+			bodies = append(bodies, msg.Data)
+		}
+	}
+
+	return bodies, nil
+}
+
+func (s *Socket) Close() error {
+	return syscall.Close(s.fd)
+}
+
+// configure sends a config message, with a single attribute, and awaits its
+// acknowledgement.
+func (s *Socket) configure(family uint8, group uint16, attrType uint16, data []byte) error {
+	s.seq++
+
+	var msg bytes.Buffer
+
+	// nfgenmsg: the family, the version, and the big endian group
+	msg.Write([]byte{family, 0, byte(group >> 8), byte(group)})
+
+	attr := make([]byte, align(4+len(data)))
+	native.PutUint16(attr[0:2], uint16(4+len(data)))
+	native.PutUint16(attr[2:4], attrType)
+	copy(attr[4:], data)
+	msg.Write(attr)
+
+	header := make([]byte, syscall.NLMSG_HDRLEN)
+	native.PutUint32(header[0:4], uint32(syscall.NLMSG_HDRLEN+msg.Len()))
+	native.PutUint16(header[4:6], nfnlSubsysULOG<<8|nfulnlMsgConfig)
+	native.PutUint16(header[6:8], syscall.NLM_F_REQUEST|syscall.NLM_F_ACK)
+	native.PutUint32(header[8:12], s.seq)
+
+	if err := syscall.Sendto(s.fd, append(header, msg.Bytes()...), 0, &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK}); err != nil {
+		return err
+	}
+
+	buf := make([]byte, syscall.Getpagesize())
+	for {
+		n, _, err := syscall.Recvfrom(s.fd, buf, 0)
+		if err != nil {
+			return err
+		}
+
+		msgs, err := syscall.ParseNetlinkMessage(buf[:n])
+		if err != nil {
+			return err
+		}
+
+		for _, msg := range msgs {
+			if msg.Header.Seq != s.seq || msg.Header.Type != syscall.NLMSG_ERROR {
+				continue
+			}
+
+			if len(msg.Data) < 4 {
+				return syscall.EINVAL
+			}
+
+			if errno := int32(native.Uint32(msg.Data[0:4])); errno != 0 {
+				return syscall.Errno(-errno)
+			}
+
+			return nil
+		}
+	}
+}
@@ -0,0 +1,17 @@
+// +build !linux
+
+package nflog
+
+type Socket struct{}
+
+func Dial(group uint16) (*Socket, error) {
+	panic("not supported on this OS")
+}
+
+func (s *Socket) Receive() ([][]byte, error) {
+	panic("not supported on this OS")
+}
+
+func (s *Socket) Close() error {
+	panic("not supported on this OS")
+}
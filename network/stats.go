@@ -0,0 +1,67 @@
+package network
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// InterfaceStats are the counters the kernel keeps for a network interface.
+type InterfaceStats struct {
+	RxBytes   uint64
+	RxPackets uint64
+	RxDropped uint64
+	RxErrors  uint64
+
+	TxBytes   uint64
+	TxPackets uint64
+	TxDropped uint64
+	TxErrors  uint64
+}
+
+//go:generate counterfeiter . InterfaceStatter
+type InterfaceStatter interface {
+	Stats(iface string) (InterfaceStats, error)
+}
+
+// NewSysfsInterfaceStatter reads the counters of interfaces from their
+// statistics directories under sysClassNet, which is normally /sys/class/net.
+func NewSysfsInterfaceStatter(sysClassNet string) InterfaceStatter {
+	return &sysfsInterfaceStatter{sysClassNet: sysClassNet}
+}
+
+type sysfsInterfaceStatter struct {
+	sysClassNet string
+}
+
+func (s *sysfsInterfaceStatter) Stats(iface string) (InterfaceStats, error) {
+	var stats InterfaceStats
+
+	counters := map[string]*uint64{
+		"rx_bytes":   &stats.RxBytes,
+		"rx_packets": &stats.RxPackets,
+		"rx_dropped": &stats.RxDropped,
+		"rx_errors":  &stats.RxErrors,
+		"tx_bytes":   &stats.TxBytes,
+		"tx_packets": &stats.TxPackets,
+		"tx_dropped": &stats.TxDropped,
+		"tx_errors":  &stats.TxErrors,
+	}
+
+	for name, counter := range counters {
+		contents, err := ioutil.ReadFile(filepath.Join(s.sysClassNet, iface, "statistics", name))
+		if err != nil {
+			return InterfaceStats{}, InterfaceStatsError{err, iface}
+		}
+
+		value, err := strconv.ParseUint(strings.TrimSpace(string(contents)), 10, 64)
+		if err != nil {
+			return InterfaceStats{}, InterfaceStatsError{err, iface}
+		}
+
+		*counter = value
+	}
+
+	return stats, nil
+}
@@ -0,0 +1,79 @@
+package network_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/cloudfoundry-incubator/garden-linux/network"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("SysfsInterfaceStatter", func() {
+	var sysClassNet string
+
+	BeforeEach(func() {
+		var err error
+		sysClassNet, err = ioutil.TempDir("", "sys-class-net")
+		Expect(err).ToNot(HaveOccurred())
+
+		statistics := filepath.Join(sysClassNet, "w1abc-0", "statistics")
+		Expect(os.MkdirAll(statistics, 0755)).To(Succeed())
+
+		counters := map[string]string{
+			"rx_bytes":   "1000",
+			"rx_packets": "10",
+			"rx_dropped": "1",
+			"rx_errors":  "2",
+			"tx_bytes":   "5000",
+			"tx_packets": "50",
+			"tx_dropped": "3",
+			"tx_errors":  "4",
+		}
+
+		for name, value := range counters {
+			Expect(ioutil.WriteFile(filepath.Join(statistics, name), []byte(value+"\n"), 0644)).To(Succeed())
+		}
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(sysClassNet)
+	})
+
+	It("reads the counters of the interface", func() {
+		stats, err := network.NewSysfsInterfaceStatter(sysClassNet).Stats("w1abc-0")
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(stats).To(Equal(network.InterfaceStats{
+			RxBytes:   1000,
+			RxPackets: 10,
+			RxDropped: 1,
+			RxErrors:  2,
+			TxBytes:   5000,
+			TxPackets: 50,
+			TxDropped: 3,
+			TxErrors:  4,
+		}))
+	})
+
+	Context("when the interface does not exist", func() {
+		It("returns an InterfaceStatsError", func() {
+			_, err := network.NewSysfsInterfaceStatter(sysClassNet).Stats("w1def-0")
+			Expect(err).To(BeAssignableToTypeOf(network.InterfaceStatsError{}))
+		})
+	})
+
+	Context("when a counter is not a number", func() {
+		It("returns an InterfaceStatsError", func() {
+			Expect(ioutil.WriteFile(
+				filepath.Join(sysClassNet, "w1abc-0", "statistics", "tx_bytes"),
+				[]byte("lots\n"),
+				0644,
+			)).To(Succeed())
+
+			_, err := network.NewSysfsInterfaceStatter(sysClassNet).Stats("w1abc-0")
+			Expect(err).To(MatchError(ContainSubstring("network: failed to read statistics of interface w1abc-0")))
+		})
+	})
+})
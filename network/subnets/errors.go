@@ -18,6 +18,15 @@ var (
 	// ErrInvalidRange is returned by AcquireStatically and by Recover if the subnet range is invalid.
 	ErrInvalidRange = errors.New("subnet has invalid range")
 
+	// ErrNoDynamicRanges is returned by NewSubnetsFromRanges if it is given no ranges.
+	ErrNoDynamicRanges = errors.New("no dynamic allocation ranges were given")
+
+	// ErrOverlappingRanges is returned by NewSubnetsFromRanges if two of the ranges overlap.
+	ErrOverlappingRanges = errors.New("the dynamic allocation ranges overlap")
+
+	// ErrInvalidIPv6Range is returned by NewDualStackSubnets if the IPv6 range is not IPv6.
+	ErrInvalidIPv6Range = errors.New("the IPv6 range is not an IPv6 subnet")
+
 	// ErrInvalidIP is returned if a static IP is requested inside a subnet
 	// which does not contain that IP
 	ErrInvalidIP = errors.New("the requested IP is not within the subnet")
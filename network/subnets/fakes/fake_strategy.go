@@ -0,0 +1,86 @@
+// This file was generated by counterfeiter
+package fakes
+
+import (
+	"net"
+	"sync"
+
+	"github.com/cloudfoundry-incubator/garden-linux/network/subnets"
+)
+
+type FakeStrategy struct {
+	SelectSubnetStub        func(ranges []*net.IPNet, existing []*net.IPNet) (*net.IPNet, error)
+	selectSubnetMutex       sync.RWMutex
+	selectSubnetArgsForCall []struct {
+		ranges   []*net.IPNet
+		existing []*net.IPNet
+	}
+	selectSubnetReturns struct {
+		result1 *net.IPNet
+		result2 error
+	}
+	ReleasedStub        func(subnet *net.IPNet)
+	releasedMutex       sync.RWMutex
+	releasedArgsForCall []struct {
+		subnet *net.IPNet
+	}
+}
+
+func (fake *FakeStrategy) SelectSubnet(ranges []*net.IPNet, existing []*net.IPNet) (*net.IPNet, error) {
+	fake.selectSubnetMutex.Lock()
+	fake.selectSubnetArgsForCall = append(fake.selectSubnetArgsForCall, struct {
+		ranges   []*net.IPNet
+		existing []*net.IPNet
+	}{ranges, existing})
+	fake.selectSubnetMutex.Unlock()
+	if fake.SelectSubnetStub != nil {
+		return fake.SelectSubnetStub(ranges, existing)
+	} else {
+		return fake.selectSubnetReturns.result1, fake.selectSubnetReturns.result2
+	}
+}
+
+func (fake *FakeStrategy) SelectSubnetCallCount() int {
+	fake.selectSubnetMutex.RLock()
+	defer fake.selectSubnetMutex.RUnlock()
+	return len(fake.selectSubnetArgsForCall)
+}
+
+func (fake *FakeStrategy) SelectSubnetArgsForCall(i int) ([]*net.IPNet, []*net.IPNet) {
+	fake.selectSubnetMutex.RLock()
+	defer fake.selectSubnetMutex.RUnlock()
+	return fake.selectSubnetArgsForCall[i].ranges, fake.selectSubnetArgsForCall[i].existing
+}
+
+func (fake *FakeStrategy) SelectSubnetReturns(result1 *net.IPNet, result2 error) {
+	fake.SelectSubnetStub = nil
+	fake.selectSubnetReturns = struct {
+		result1 *net.IPNet
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeStrategy) Released(subnet *net.IPNet) {
+	fake.releasedMutex.Lock()
+	fake.releasedArgsForCall = append(fake.releasedArgsForCall, struct {
+		subnet *net.IPNet
+	}{subnet})
+	fake.releasedMutex.Unlock()
+	if fake.ReleasedStub != nil {
+		fake.ReleasedStub(subnet)
+	}
+}
+
+func (fake *FakeStrategy) ReleasedCallCount() int {
+	fake.releasedMutex.RLock()
+	defer fake.releasedMutex.RUnlock()
+	return len(fake.releasedArgsForCall)
+}
+
+func (fake *FakeStrategy) ReleasedArgsForCall(i int) *net.IPNet {
+	fake.releasedMutex.RLock()
+	defer fake.releasedMutex.RUnlock()
+	return fake.releasedArgsForCall[i].subnet
+}
+
+var _ subnets.Strategy = new(FakeStrategy)
@@ -9,10 +9,10 @@ import (
 )
 
 type FakeSubnetSelector struct {
-	SelectSubnetStub        func(dynamic *net.IPNet, existing []*net.IPNet) (*net.IPNet, error)
+	SelectSubnetStub        func(dynamic subnets.DynamicRanges, existing []*net.IPNet) (*net.IPNet, error)
 	selectSubnetMutex       sync.RWMutex
 	selectSubnetArgsForCall []struct {
-		dynamic  *net.IPNet
+		dynamic  subnets.DynamicRanges
 		existing []*net.IPNet
 	}
 	selectSubnetReturns struct {
@@ -21,10 +21,10 @@ type FakeSubnetSelector struct {
 	}
 }
 
-func (fake *FakeSubnetSelector) SelectSubnet(dynamic *net.IPNet, existing []*net.IPNet) (*net.IPNet, error) {
+func (fake *FakeSubnetSelector) SelectSubnet(dynamic subnets.DynamicRanges, existing []*net.IPNet) (*net.IPNet, error) {
 	fake.selectSubnetMutex.Lock()
 	fake.selectSubnetArgsForCall = append(fake.selectSubnetArgsForCall, struct {
-		dynamic  *net.IPNet
+		dynamic  subnets.DynamicRanges
 		existing []*net.IPNet
 	}{dynamic, existing})
 	fake.selectSubnetMutex.Unlock()
@@ -41,7 +41,7 @@ func (fake *FakeSubnetSelector) SelectSubnetCallCount() int {
 	return len(fake.selectSubnetArgsForCall)
 }
 
-func (fake *FakeSubnetSelector) SelectSubnetArgsForCall(i int) (*net.IPNet, []*net.IPNet) {
+func (fake *FakeSubnetSelector) SelectSubnetArgsForCall(i int) (subnets.DynamicRanges, []*net.IPNet) {
 	fake.selectSubnetMutex.RLock()
 	defer fake.selectSubnetMutex.RUnlock()
 	return fake.selectSubnetArgsForCall[i].dynamic, fake.selectSubnetArgsForCall[i].existing
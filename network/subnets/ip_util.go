@@ -45,3 +45,15 @@ func max(ipn *net.IPNet) net.IP {
 
 	return net.IP(max).To16()
 }
+
+// add returns the IP n addresses after the given one.
+func add(ip net.IP, n uint64) net.IP {
+	sum := clone(ip)
+	for i := len(sum) - 1; i >= 0 && n > 0; i-- {
+		n += uint64(sum[i])
+		sum[i] = byte(n)
+		n >>= 8
+	}
+
+	return sum
+}
@@ -10,9 +10,11 @@ type StaticSubnetSelector struct {
 	*net.IPNet
 }
 
-func (s StaticSubnetSelector) SelectSubnet(dynamic *net.IPNet, existing []*net.IPNet) (*net.IPNet, error) {
-	if overlaps(dynamic, s.IPNet) {
-		return nil, fmt.Errorf("the requested subnet (%v) overlaps the dynamic allocation range (%v)", s.IPNet.String(), dynamic.String())
+func (s StaticSubnetSelector) SelectSubnet(dynamic DynamicRanges, existing []*net.IPNet) (*net.IPNet, error) {
+	for _, r := range dynamic.Ranges {
+		if overlaps(r, s.IPNet) {
+			return nil, fmt.Errorf("the requested subnet (%v) overlaps the dynamic allocation range (%v)", s.IPNet.String(), r.String())
+		}
 	}
 
 	for _, e := range existing {
@@ -26,27 +28,23 @@ func (s StaticSubnetSelector) SelectSubnet(dynamic *net.IPNet, existing []*net.I
 
 type dynamicSubnetSelector int
 
-// DynamicSubnetSelector requests the next unallocated ("dynamic") subnet from the dynamic range.
-// Returns an error if there are no remaining subnets in the dynamic range.
-var DynamicSubnetSelector dynamicSubnetSelector = 0
-
-func (dynamicSubnetSelector) SelectSubnet(dynamic *net.IPNet, existing []*net.IPNet) (*net.IPNet, error) {
-	exists := make(map[string]bool)
-	for _, e := range existing {
-		exists[e.String()] = true
+// dynamicSubnetMask returns the mask of the subnets dynamically allocated from a range:
+// /30 for IPv4 and /126 for IPv6, i.e. a network, container, gateway and broadcast IP.
+func dynamicSubnetMask(dynamic *net.IPNet) net.IPMask {
+	if dynamic.IP.To4() == nil {
+		return net.CIDRMask(126, 128)
 	}
 
-	min := dynamic.IP
-	mask := net.CIDRMask(30, 32) // /30
-	for ip := min; dynamic.Contains(ip); ip = next(ip) {
-		subnet := &net.IPNet{ip, mask}
-		ip = next(next(next(ip)))
-		if dynamic.Contains(ip) && !exists[subnet.String()] {
-			return subnet, nil
-		}
-	}
+	return net.CIDRMask(30, 32)
+}
+
+// DynamicSubnetSelector requests an unallocated ("dynamic") subnet from the dynamic ranges, chosen
+// by the pool's Strategy. Subnets are /30s, or /126s if the dynamic ranges are IPv6.
+// Returns an error if there are no remaining subnets in the dynamic ranges.
+var DynamicSubnetSelector dynamicSubnetSelector = 0
 
-	return nil, ErrInsufficientSubnets
+func (dynamicSubnetSelector) SelectSubnet(dynamic DynamicRanges, existing []*net.IPNet) (*net.IPNet, error) {
+	return dynamic.Strategy.SelectSubnet(dynamic.Ranges, existing)
 }
 
 // StaticIPSelector requests a specific ("static") IP address. Returns an error if the IP is already
@@ -0,0 +1,167 @@
+package subnets
+
+import (
+	"math/rand"
+	"net"
+)
+
+//go:generate counterfeiter . Strategy
+
+// Strategy chooses which of the free subnets of a pool's dynamic ranges is allocated next. The pool
+// serialises calls to its strategy.
+type Strategy interface {
+	// Returns a subnet of one of the ranges which is not one of the existing subnets. If there is
+	// no such subnet, returns ErrInsufficientSubnets.
+	SelectSubnet(ranges []*net.IPNet, existing []*net.IPNet) (*net.IPNet, error)
+
+	// Released is called when a subnet of the ranges is deallocated.
+	Released(subnet *net.IPNet)
+}
+
+type firstFitStrategy struct{}
+
+// FirstFitStrategy allocates the lowest free subnet of the first range which has one.
+var FirstFitStrategy Strategy = firstFitStrategy{}
+
+func (firstFitStrategy) SelectSubnet(ranges []*net.IPNet, existing []*net.IPNet) (*net.IPNet, error) {
+	return firstFit(ranges, subnetSet(existing))
+}
+
+func (firstFitStrategy) Released(*net.IPNet) {}
+
+type randomStrategy struct {
+	rand *rand.Rand
+}
+
+// NewRandomStrategy returns a Strategy which allocates a free subnet picked at random from all of
+// the ranges.
+func NewRandomStrategy(seed int64) Strategy {
+	return &randomStrategy{rand: rand.New(rand.NewSource(seed))}
+}
+
+func (s *randomStrategy) SelectSubnet(ranges []*net.IPNet, existing []*net.IPNet) (*net.IPNet, error) {
+	total := 0
+	for _, r := range ranges {
+		total += capacity(r)
+	}
+
+	if total == 0 {
+		return nil, ErrInsufficientSubnets
+	}
+
+	taken := subnetSet(existing)
+
+	// probe onwards from a random subnet, so that the pick is random even
+	// when most of the subnets are taken
+	start := s.rand.Intn(total)
+	for i := 0; i < total; i++ {
+		subnet := subnetAt(ranges, (start+i)%total)
+		if !taken[subnet.String()] {
+			return subnet, nil
+		}
+	}
+
+	return nil, ErrInsufficientSubnets
+}
+
+func (s *randomStrategy) Released(*net.IPNet) {}
+
+type leastRecentlyReleasedStrategy struct {
+	released []string // in the order they were released
+}
+
+// NewLeastRecentlyReleasedStrategy returns a Strategy which allocates subnets which have never been
+// released before those which have, and otherwise the one released longest ago. This avoids reusing
+// an IP while peers may still have its ARP and conntrack entries cached.
+func NewLeastRecentlyReleasedStrategy() Strategy {
+	return &leastRecentlyReleasedStrategy{}
+}
+
+func (s *leastRecentlyReleasedStrategy) SelectSubnet(ranges []*net.IPNet, existing []*net.IPNet) (*net.IPNet, error) {
+	taken := subnetSet(existing)
+
+	skip := subnetSet(existing)
+	for _, r := range s.released {
+		skip[r] = true
+	}
+
+	if subnet, err := firstFit(ranges, skip); err == nil {
+		return subnet, nil
+	}
+
+	// the subnet stays in the list until it is seen to be taken, in case
+	// the pool fails to allocate it after all
+	for i := 0; i < len(s.released); i++ {
+		if taken[s.released[i]] {
+			s.released = append(s.released[:i], s.released[i+1:]...)
+			i--
+			continue
+		}
+
+		_, subnet, err := net.ParseCIDR(s.released[i])
+		if err != nil {
+			return nil, err
+		}
+
+		return subnet, nil
+	}
+
+	return nil, ErrInsufficientSubnets
+}
+
+func (s *leastRecentlyReleasedStrategy) Released(subnet *net.IPNet) {
+	released := subnet.String()
+
+	for i, r := range s.released {
+		if r == released {
+			s.released = append(s.released[:i], s.released[i+1:]...)
+			break
+		}
+	}
+
+	s.released = append(s.released, released)
+}
+
+func firstFit(ranges []*net.IPNet, skip map[string]bool) (*net.IPNet, error) {
+	for _, r := range ranges {
+		for i := 0; i < capacity(r); i++ {
+			subnet := nthSubnet(r, i)
+			if !skip[subnet.String()] {
+				return subnet, nil
+			}
+		}
+	}
+
+	return nil, ErrInsufficientSubnets
+}
+
+// subnetAt returns the i'th dynamic subnet of the ranges taken together.
+func subnetAt(ranges []*net.IPNet, i int) *net.IPNet {
+	for _, r := range ranges {
+		if c := capacity(r); i >= c {
+			i -= c
+			continue
+		}
+
+		return nthSubnet(r, i)
+	}
+
+	return nil
+}
+
+// nthSubnet returns the n'th dynamic subnet of a range.
+func nthSubnet(dynamic *net.IPNet, n int) *net.IPNet {
+	mask := dynamicSubnetMask(dynamic)
+	ones, bits := mask.Size()
+
+	return &net.IPNet{IP: add(dynamic.IP, uint64(n)<<uint(bits-ones)), Mask: mask}
+}
+
+func subnetSet(subnets []*net.IPNet) map[string]bool {
+	set := make(map[string]bool, len(subnets))
+	for _, s := range subnets {
+		set[s.String()] = true
+	}
+
+	return set
+}
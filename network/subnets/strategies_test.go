@@ -0,0 +1,153 @@
+package subnets_test
+
+import (
+	"net"
+
+	"github.com/cloudfoundry-incubator/garden-linux/network/subnets"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Strategies", func() {
+	var ranges []*net.IPNet
+
+	BeforeEach(func() {
+		ranges = []*net.IPNet{subnetPool("10.2.3.0/29"), subnetPool("10.9.0.0/30")}
+	})
+
+	subnetStrings := func(subnets ...*net.IPNet) []string {
+		strings := []string{}
+		for _, s := range subnets {
+			strings = append(strings, s.String())
+		}
+
+		return strings
+	}
+
+	Describe("FirstFitStrategy", func() {
+		It("selects the lowest free subnet of the first range which has one", func() {
+			subnet, err := subnets.FirstFitStrategy.SelectSubnet(ranges, nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(subnet.String()).To(Equal("10.2.3.0/30"))
+
+			subnet, err = subnets.FirstFitStrategy.SelectSubnet(ranges, []*net.IPNet{subnetPool("10.2.3.0/30")})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(subnet.String()).To(Equal("10.2.3.4/30"))
+
+			subnet, err = subnets.FirstFitStrategy.SelectSubnet(ranges, []*net.IPNet{subnetPool("10.2.3.0/30"), subnetPool("10.2.3.4/30")})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(subnet.String()).To(Equal("10.9.0.0/30"))
+		})
+
+		It("returns ErrInsufficientSubnets when every subnet is taken", func() {
+			_, err := subnets.FirstFitStrategy.SelectSubnet(ranges, []*net.IPNet{
+				subnetPool("10.2.3.0/30"), subnetPool("10.2.3.4/30"), subnetPool("10.9.0.0/30"),
+			})
+			Expect(err).To(Equal(subnets.ErrInsufficientSubnets))
+		})
+	})
+
+	Describe("RandomStrategy", func() {
+		var strategy subnets.Strategy
+
+		BeforeEach(func() {
+			strategy = subnets.NewRandomStrategy(42)
+		})
+
+		It("selects free subnets from all of the ranges", func() {
+			selected := map[string]bool{}
+			for i := 0; i < 100; i++ {
+				subnet, err := strategy.SelectSubnet(ranges, nil)
+				Expect(err).ToNot(HaveOccurred())
+
+				selected[subnet.String()] = true
+			}
+
+			Expect(selected).To(Equal(map[string]bool{
+				"10.2.3.0/30": true,
+				"10.2.3.4/30": true,
+				"10.9.0.0/30": true,
+			}))
+		})
+
+		It("never selects a taken subnet", func() {
+			for i := 0; i < 100; i++ {
+				subnet, err := strategy.SelectSubnet(ranges, []*net.IPNet{subnetPool("10.2.3.0/30"), subnetPool("10.9.0.0/30")})
+				Expect(err).ToNot(HaveOccurred())
+				Expect(subnet.String()).To(Equal("10.2.3.4/30"))
+			}
+		})
+
+		It("returns ErrInsufficientSubnets when every subnet is taken", func() {
+			_, err := strategy.SelectSubnet(ranges, []*net.IPNet{
+				subnetPool("10.2.3.0/30"), subnetPool("10.2.3.4/30"), subnetPool("10.9.0.0/30"),
+			})
+			Expect(err).To(Equal(subnets.ErrInsufficientSubnets))
+		})
+	})
+
+	Describe("LeastRecentlyReleasedStrategy", func() {
+		var strategy subnets.Strategy
+
+		BeforeEach(func() {
+			strategy = subnets.NewLeastRecentlyReleasedStrategy()
+		})
+
+		It("selects subnets which have never been released before those which have", func() {
+			strategy.Released(subnetPool("10.2.3.0/30"))
+
+			subnet, err := strategy.SelectSubnet(ranges, nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(subnet.String()).To(Equal("10.2.3.4/30"))
+
+			subnet, err = strategy.SelectSubnet(ranges, []*net.IPNet{subnetPool("10.2.3.4/30")})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(subnet.String()).To(Equal("10.9.0.0/30"))
+		})
+
+		It("otherwise selects the free subnet released longest ago", func() {
+			strategy.Released(subnetPool("10.9.0.0/30"))
+			strategy.Released(subnetPool("10.2.3.0/30"))
+			strategy.Released(subnetPool("10.2.3.4/30"))
+
+			var existing []*net.IPNet
+			for i := 0; i < 3; i++ {
+				subnet, err := strategy.SelectSubnet(ranges, existing)
+				Expect(err).ToNot(HaveOccurred())
+
+				existing = append(existing, subnet)
+			}
+
+			Expect(subnetStrings(existing...)).To(Equal([]string{"10.9.0.0/30", "10.2.3.0/30", "10.2.3.4/30"}))
+
+			_, err := strategy.SelectSubnet(ranges, existing)
+			Expect(err).To(Equal(subnets.ErrInsufficientSubnets))
+		})
+
+		It("treats a subnet released again as released most recently", func() {
+			strategy.Released(subnetPool("10.9.0.0/30"))
+			strategy.Released(subnetPool("10.2.3.0/30"))
+			strategy.Released(subnetPool("10.2.3.4/30"))
+			strategy.Released(subnetPool("10.9.0.0/30"))
+
+			subnet, err := strategy.SelectSubnet(ranges, nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(subnet.String()).To(Equal("10.2.3.0/30"))
+		})
+
+		It("selects a released subnet again if it was not allocated after all", func() {
+			strategy.Released(subnetPool("10.2.3.0/30"))
+			strategy.Released(subnetPool("10.2.3.4/30"))
+			strategy.Released(subnetPool("10.9.0.0/30"))
+
+			first, err := strategy.SelectSubnet(ranges, nil)
+			Expect(err).ToNot(HaveOccurred())
+
+			second, err := strategy.SelectSubnet(ranges, nil)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(second.String()).To(Equal(first.String()))
+		})
+	})
+})
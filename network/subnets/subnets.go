@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"math"
 	"net"
+	"sort"
 	"sync"
 
 	"github.com/cloudfoundry-incubator/garden-linux/linux_backend"
@@ -29,23 +30,58 @@ type Subnets interface {
 	// Remove an IP address so it appears to be associated with the given subnet.
 	Remove(*linux_backend.Network) error
 
-	// Returns the number of /30 subnets which can be Acquired by a DynamicSubnetSelector.
+	// Returns the number of subnets (/30s, or /126s for IPv6) which can be Acquired by a
+	// DynamicSubnetSelector, across all of the dynamic ranges.
 	Capacity() int
+
+	// Returns a copy of the allocations of the pool, for diagnostics.
+	Inventory() Inventory
+}
+
+// Inventory lists the allocated subnets of a pool, and the IP addresses allocated in each.
+type Inventory struct {
+	DynamicRanges []string
+	Capacity      int
+	Allocated     map[string][]string
+
+	DynamicIPv6Range string              `json:",omitempty"`
+	AllocatedIPv6    map[string][]string `json:",omitempty"`
 }
 
 type pool struct {
-	allocated    map[string][]net.IP // net.IPNet.String +> seq net.IP
-	dynamicRange *net.IPNet
-	mu           sync.Mutex
+	allocated map[string][]net.IP // net.IPNet.String +> seq net.IP
+	dynamic   DynamicRanges
+
+	allocatedIPv6    map[string][]net.IP
+	dynamicIPv6Range *net.IPNet
+
+	mu sync.Mutex
 }
 
 //go:generate counterfeiter . SubnetSelector
 
 // SubnetSelector is a strategy for selecting a subnet.
 type SubnetSelector interface {
-	// Returns a subnet based on a dynamic range and some existing statically-allocated
-	// subnets. If no suitable subnet can be found, returns an error.
-	SelectSubnet(dynamic *net.IPNet, existing []*net.IPNet) (*net.IPNet, error)
+	// Returns a subnet based on the dynamic ranges and some existing allocated subnets.
+	// If no suitable subnet can be found, returns an error.
+	SelectSubnet(dynamic DynamicRanges, existing []*net.IPNet) (*net.IPNet, error)
+}
+
+// DynamicRanges are the ranges which a pool dynamically allocates subnets from, and the strategy
+// with which it chooses among their free subnets.
+type DynamicRanges struct {
+	Ranges   []*net.IPNet
+	Strategy Strategy
+}
+
+func (d DynamicRanges) contains(subnet *net.IPNet) bool {
+	for _, r := range d.Ranges {
+		if r.Contains(subnet.IP) {
+			return true
+		}
+	}
+
+	return false
 }
 
 //go:generate counterfeiter . IPSelector
@@ -61,7 +97,52 @@ type IPSelector interface {
 // All dynamic allocations come from the range, static allocations are prohibited
 // from the dynamic range.
 func NewSubnets(ipNet *net.IPNet) (Subnets, error) {
-	return &pool{dynamicRange: ipNet, allocated: make(map[string][]net.IP)}, nil
+	return NewSubnetsFromRanges([]*net.IPNet{ipNet}, nil, FirstFitStrategy)
+}
+
+// NewDualStackSubnets creates a Subnets implementation which, as well as an IPv4 subnet
+// allocated as by NewSubnets, dynamically allocates each network an IPv6 subnet and address
+// from the given IPv6 range.
+func NewDualStackSubnets(ipNet, ipv6Net *net.IPNet) (Subnets, error) {
+	return NewSubnetsFromRanges([]*net.IPNet{ipNet}, ipv6Net, FirstFitStrategy)
+}
+
+// NewSubnetsFromRanges creates a Subnets implementation which dynamically allocates from
+// several ranges, choosing among their free subnets with the given strategy. Static allocations
+// are prohibited from all of the ranges. If ipv6Net is not nil, each network is also allocated
+// an IPv6 subnet and address from it, as by NewDualStackSubnets, on a first-fit basis.
+func NewSubnetsFromRanges(ranges []*net.IPNet, ipv6Net *net.IPNet, strategy Strategy) (Subnets, error) {
+	if len(ranges) == 0 {
+		return nil, ErrNoDynamicRanges
+	}
+
+	for i, r := range ranges {
+		if (r.IP.To4() == nil) != (ranges[0].IP.To4() == nil) {
+			return nil, ErrInvalidRange
+		}
+
+		for _, other := range ranges[:i] {
+			if overlaps(r, other) {
+				return nil, ErrOverlappingRanges
+			}
+		}
+	}
+
+	p := &pool{
+		dynamic:   DynamicRanges{Ranges: ranges, Strategy: strategy},
+		allocated: make(map[string][]net.IP),
+	}
+
+	if ipv6Net != nil {
+		if ipv6Net.IP.To4() != nil {
+			return nil, ErrInvalidIPv6Range
+		}
+
+		p.dynamicIPv6Range = ipv6Net
+		p.allocatedIPv6 = make(map[string][]net.IP)
+	}
+
+	return p, nil
 }
 
 // Acquire uses the given subnet and IP selectors to request a subnet, container IP address combination
@@ -71,20 +152,42 @@ func (p *pool) Acquire(sn SubnetSelector, i IPSelector) (network *linux_backend.
 	defer p.mu.Unlock()
 
 	network = &linux_backend.Network{}
-	if network.Subnet, err = sn.SelectSubnet(p.dynamicRange, existingSubnets(p.allocated)); err != nil {
+	if network.Subnet, network.IP, err = acquire(p.dynamic, p.allocated, sn, i); err != nil {
 		return nil, err
 	}
 
-	ips := p.allocated[network.Subnet.String()]
-	existingIPs := append(ips, NetworkIP(network.Subnet), GatewayIP(network.Subnet), BroadcastIP(network.Subnet))
-	if network.IP, err = i.SelectIP(network.Subnet, existingIPs); err != nil {
-		return nil, err
+	if p.dynamicIPv6Range != nil {
+		if network.IPv6Subnet, network.IPv6, err = acquire(p.dynamicIPv6(), p.allocatedIPv6, DynamicSubnetSelector, DynamicIPSelector); err != nil {
+			return nil, err
+		}
+
+		p.allocatedIPv6[network.IPv6Subnet.String()] = append(p.allocatedIPv6[network.IPv6Subnet.String()], network.IPv6)
 	}
 
-	p.allocated[network.Subnet.String()] = append(ips, network.IP)
+	p.allocated[network.Subnet.String()] = append(p.allocated[network.Subnet.String()], network.IP)
 	return network, nil
 }
 
+// acquire selects a subnet and IP address, without recording them as allocated.
+func acquire(dynamic DynamicRanges, allocated map[string][]net.IP, sn SubnetSelector, i IPSelector) (*net.IPNet, net.IP, error) {
+	subnet, err := sn.SelectSubnet(dynamic, existingSubnets(allocated))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	existingIPs := append(allocated[subnet.String()], NetworkIP(subnet), GatewayIP(subnet), BroadcastIP(subnet))
+	ip, err := i.SelectIP(subnet, existingIPs)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return subnet, ip, nil
+}
+
+func (p *pool) dynamicIPv6() DynamicRanges {
+	return DynamicRanges{Ranges: []*net.IPNet{p.dynamicIPv6Range}, Strategy: FirstFitStrategy}
+}
+
 // Recover re-allocates a given subnet and ip address combination in the pool. It returns
 // an error if the combination is already allocated.
 func (p *pool) Remove(network *linux_backend.Network) error {
@@ -95,10 +198,16 @@ func (p *pool) Remove(network *linux_backend.Network) error {
 		return ErrIpCannotBeNil
 	}
 
-	for _, existing := range p.allocated[network.Subnet.String()] {
-		if existing.Equal(network.IP) {
+	if _, found := indexOf(p.allocated[network.Subnet.String()], network.IP); found {
+		return ErrOverlapsExistingSubnet
+	}
+
+	if network.IPv6Subnet != nil && p.allocatedIPv6 != nil {
+		if _, found := indexOf(p.allocatedIPv6[network.IPv6Subnet.String()], network.IPv6); found {
 			return ErrOverlapsExistingSubnet
 		}
+
+		p.allocatedIPv6[network.IPv6Subnet.String()] = append(p.allocatedIPv6[network.IPv6Subnet.String()], network.IPv6)
 	}
 
 	p.allocated[network.Subnet.String()] = append(p.allocated[network.Subnet.String()], network.IP)
@@ -109,27 +218,112 @@ func (p *pool) Release(network *linux_backend.Network) error {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
-	subnetString := network.Subnet.String()
-	ips := p.allocated[subnetString]
+	found, deallocated := release(p.allocated, network.Subnet, network.IP)
+	if !found {
+		return ErrReleasedUnallocatedSubnet
+	}
 
-	if i, found := indexOf(ips, network.IP); found {
-		if reducedIps, empty := removeIPAtIndex(ips, i); empty {
-			delete(p.allocated, subnetString)
-		} else {
-			p.allocated[subnetString] = reducedIps
-		}
+	if deallocated && p.dynamic.contains(network.Subnet) {
+		p.dynamic.Strategy.Released(network.Subnet)
+	}
+
+	if network.IPv6Subnet != nil && p.allocatedIPv6 != nil {
+		release(p.allocatedIPv6, network.IPv6Subnet, network.IPv6)
+	}
+
+	return nil
+}
 
-		return nil
+// release removes an IP address from the allocations, deallocating its subnet if it was the
+// subnet's last. Returns whether the IP address was allocated, and whether the subnet was
+// deallocated.
+func release(allocated map[string][]net.IP, subnet *net.IPNet, ip net.IP) (bool, bool) {
+	subnetString := subnet.String()
+	ips := allocated[subnetString]
+
+	i, found := indexOf(ips, ip)
+	if !found {
+		return false, false
+	}
+
+	reducedIps, empty := removeIPAtIndex(ips, i)
+	if empty {
+		delete(allocated, subnetString)
+	} else {
+		allocated[subnetString] = reducedIps
 	}
 
-	return ErrReleasedUnallocatedSubnet
+	return true, empty
 }
 
-// Capacity returns the number of /30 subnets that can be allocated
-// from the pool's dynamic allocation range.
+// Capacity returns the number of subnets that can be allocated from the pool's
+// dynamic allocation ranges, or from its IPv6 range if that has fewer.
 func (m *pool) Capacity() int {
-	masked, total := m.dynamicRange.Mask.Size()
-	return int(math.Pow(2, float64(total-masked)) / 4)
+	c := 0
+	for _, r := range m.dynamic.Ranges {
+		if c += capacity(r); c > math.MaxInt32 {
+			c = math.MaxInt32
+		}
+	}
+
+	if m.dynamicIPv6Range != nil {
+		if ipv6Capacity := capacity(m.dynamicIPv6Range); ipv6Capacity < c {
+			c = ipv6Capacity
+		}
+	}
+
+	return c
+}
+
+// capacity returns the number of dynamic subnets in a range, which for IPv6 is
+// limited to what an int can hold.
+func capacity(dynamicRange *net.IPNet) int {
+	masked, total := dynamicRange.Mask.Size()
+	subnetMasked, _ := dynamicSubnetMask(dynamicRange).Size()
+
+	subnets := math.Pow(2, float64(total-masked)) / math.Pow(2, float64(total-subnetMasked))
+	if subnets > math.MaxInt32 {
+		return math.MaxInt32
+	}
+
+	return int(subnets)
+}
+
+func (p *pool) Inventory() Inventory {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	inventory := Inventory{
+		DynamicRanges: []string{},
+		Capacity:      p.Capacity(),
+		Allocated:     allocationStrings(p.allocated),
+	}
+
+	for _, r := range p.dynamic.Ranges {
+		inventory.DynamicRanges = append(inventory.DynamicRanges, r.String())
+	}
+
+	if p.dynamicIPv6Range != nil {
+		inventory.DynamicIPv6Range = p.dynamicIPv6Range.String()
+		inventory.AllocatedIPv6 = allocationStrings(p.allocatedIPv6)
+	}
+
+	return inventory
+}
+
+func allocationStrings(allocated map[string][]net.IP) map[string][]string {
+	result := make(map[string][]string, len(allocated))
+	for subnet, ips := range allocated {
+		ipStrings := make([]string, len(ips))
+		for i, ip := range ips {
+			ipStrings[i] = ip.String()
+		}
+
+		sort.Strings(ipStrings)
+		result[subnet] = ipStrings
+	}
+
+	return result
 }
 
 // Returns the gateway IP of a given subnet, which is always the maximum valid IP
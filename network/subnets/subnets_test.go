@@ -6,6 +6,7 @@ import (
 
 	"github.com/cloudfoundry-incubator/garden-linux/linux_backend"
 	"github.com/cloudfoundry-incubator/garden-linux/network/subnets"
+	"github.com/cloudfoundry-incubator/garden-linux/network/subnets/fakes"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -285,7 +286,7 @@ var _ = Describe("Subnet Pool", func() {
 
 					Context("but after it is released", func() {
 						It("dynamically allocates the released IP again", func() {
-							err := subnetpool.Release(&linux_backend.Network{static, ips[3]})
+							err := subnetpool.Release(&linux_backend.Network{Subnet: static, IP: ips[3]})
 							Expect(err).ToNot(HaveOccurred())
 
 							network, err := subnetpool.Acquire(subnets.StaticSubnetSelector{static}, subnets.DynamicIPSelector)
@@ -294,7 +295,7 @@ var _ = Describe("Subnet Pool", func() {
 						})
 
 						It("allows static allocation again", func() {
-							err := subnetpool.Release(&linux_backend.Network{static, ips[3]})
+							err := subnetpool.Release(&linux_backend.Network{Subnet: static, IP: ips[3]})
 							Expect(err).ToNot(HaveOccurred())
 
 							_, err = subnetpool.Acquire(subnets.StaticSubnetSelector{static}, subnets.StaticIPSelector{ips[3]})
@@ -354,7 +355,7 @@ var _ = Describe("Subnet Pool", func() {
 
 					Context("but after it is released", func() {
 						It("allows allocation again", func() {
-							err := subnetpool.Release(&linux_backend.Network{firstSubnetPool, firstContainerIP})
+							err := subnetpool.Release(&linux_backend.Network{Subnet: firstSubnetPool, IP: firstContainerIP})
 							Expect(err).ToNot(HaveOccurred())
 
 							_, err = subnetpool.Acquire(subnets.StaticSubnetSelector{secondSubnetPool}, subnets.DynamicIPSelector)
@@ -423,7 +424,7 @@ var _ = Describe("Subnet Pool", func() {
 						Expect(err).To(HaveOccurred())
 
 						// release
-						err = subnetpool.Release(&linux_backend.Network{network.Subnet, network.IP})
+						err = subnetpool.Release(&linux_backend.Network{Subnet: network.Subnet, IP: network.IP})
 						Expect(err).ToNot(HaveOccurred())
 
 						// third - should work now because of release
@@ -444,7 +445,7 @@ var _ = Describe("Subnet Pool", func() {
 							network, err := subnetpool.Acquire(subnets.StaticSubnetSelector{static}, subnets.DynamicIPSelector)
 							Expect(err).ToNot(HaveOccurred())
 
-							err = subnetpool.Release(&linux_backend.Network{network.Subnet, network.IP})
+							err = subnetpool.Release(&linux_backend.Network{Subnet: network.Subnet, IP: network.IP})
 							Expect(err).ToNot(HaveOccurred())
 						})
 					})
@@ -457,11 +458,11 @@ var _ = Describe("Subnet Pool", func() {
 						Expect(err).ToNot(HaveOccurred())
 
 						// release
-						err = subnetpool.Release(&linux_backend.Network{network.Subnet, network.IP})
+						err = subnetpool.Release(&linux_backend.Network{Subnet: network.Subnet, IP: network.IP})
 						Expect(err).ToNot(HaveOccurred())
 
 						// release again
-						err = subnetpool.Release(&linux_backend.Network{network.Subnet, network.IP})
+						err = subnetpool.Release(&linux_backend.Network{Subnet: network.Subnet, IP: network.IP})
 						Expect(err).To(HaveOccurred())
 						Expect(err).To(Equal(subnets.ErrReleasedUnallocatedSubnet))
 					})
@@ -543,13 +544,13 @@ var _ = Describe("Subnet Pool", func() {
 						out := make(chan error)
 						go func(out chan error) {
 							defer GinkgoRecover()
-							err := subnetpool.Release(&linux_backend.Network{acquired.Subnet, acquired.IP})
+							err := subnetpool.Release(&linux_backend.Network{Subnet: acquired.Subnet, IP: acquired.IP})
 							out <- err
 						}(out)
 
 						go func(out chan error) {
 							defer GinkgoRecover()
-							err := subnetpool.Release(&linux_backend.Network{acquired.Subnet, acquired.IP})
+							err := subnetpool.Release(&linux_backend.Network{Subnet: acquired.Subnet, IP: acquired.IP})
 							out <- err
 						}(out)
 
@@ -601,17 +602,17 @@ var _ = Describe("Subnet Pool", func() {
 				It("recovers the first time", func() {
 					_, static := networkParms("10.9.3.4/30")
 
-					err := subnetpool.Remove(&linux_backend.Network{static, net.ParseIP("10.9.3.5")})
+					err := subnetpool.Remove(&linux_backend.Network{Subnet: static, IP: net.ParseIP("10.9.3.5")})
 					Expect(err).ToNot(HaveOccurred())
 				})
 
 				It("does not allow recovering twice", func() {
 					_, static := networkParms("10.9.3.4/30")
 
-					err := subnetpool.Remove(&linux_backend.Network{static, net.ParseIP("10.9.3.5")})
+					err := subnetpool.Remove(&linux_backend.Network{Subnet: static, IP: net.ParseIP("10.9.3.5")})
 					Expect(err).ToNot(HaveOccurred())
 
-					err = subnetpool.Remove(&linux_backend.Network{static, net.ParseIP("10.9.3.5")})
+					err = subnetpool.Remove(&linux_backend.Network{Subnet: static, IP: net.ParseIP("10.9.3.5")})
 					Expect(err).To(HaveOccurred())
 				})
 
@@ -619,7 +620,7 @@ var _ = Describe("Subnet Pool", func() {
 					_, static := networkParms("10.9.3.4/30")
 
 					ip := net.ParseIP("10.9.3.5")
-					err := subnetpool.Remove(&linux_backend.Network{static, ip})
+					err := subnetpool.Remove(&linux_backend.Network{Subnet: static, IP: ip})
 					Expect(err).ToNot(HaveOccurred())
 
 					_, err = subnetpool.Acquire(subnets.StaticSubnetSelector{static}, subnets.StaticIPSelector{ip})
@@ -629,7 +630,7 @@ var _ = Describe("Subnet Pool", func() {
 				It("does not allow recovering without an explicit IP", func() {
 					_, static := networkParms("10.9.3.4/30")
 
-					err := subnetpool.Remove(&linux_backend.Network{static, nil})
+					err := subnetpool.Remove(&linux_backend.Network{Subnet: static, IP: nil})
 					Expect(err).To(HaveOccurred())
 				})
 			})
@@ -638,24 +639,24 @@ var _ = Describe("Subnet Pool", func() {
 				It("recovers the first time", func() {
 					_, static := networkParms("10.2.3.4/30")
 
-					err := subnetpool.Remove(&linux_backend.Network{static, net.ParseIP("10.2.3.5")})
+					err := subnetpool.Remove(&linux_backend.Network{Subnet: static, IP: net.ParseIP("10.2.3.5")})
 					Expect(err).ToNot(HaveOccurred())
 				})
 
 				It("does not allow recovering twice", func() {
 					_, static := networkParms("10.2.3.4/30")
 
-					err := subnetpool.Remove(&linux_backend.Network{static, net.ParseIP("10.2.3.5")})
+					err := subnetpool.Remove(&linux_backend.Network{Subnet: static, IP: net.ParseIP("10.2.3.5")})
 					Expect(err).ToNot(HaveOccurred())
 
-					err = subnetpool.Remove(&linux_backend.Network{static, net.ParseIP("10.2.3.5")})
+					err = subnetpool.Remove(&linux_backend.Network{Subnet: static, IP: net.ParseIP("10.2.3.5")})
 					Expect(err).To(HaveOccurred())
 				})
 
 				It("does not dynamically allocate a recovered network", func() {
 					_, static := networkParms("10.2.3.4/30")
 
-					err := subnetpool.Remove(&linux_backend.Network{static, net.ParseIP("10.2.3.1")})
+					err := subnetpool.Remove(&linux_backend.Network{Subnet: static, IP: net.ParseIP("10.2.3.1")})
 					Expect(err).ToNot(HaveOccurred())
 
 					network, err := subnetpool.Acquire(subnets.DynamicSubnetSelector, subnets.StaticIPSelector{net.ParseIP("10.2.3.1")})
@@ -670,6 +671,251 @@ var _ = Describe("Subnet Pool", func() {
 		})
 
 	})
+
+	Describe("Dual-stack allocation", func() {
+		var ipv6SubnetPool *net.IPNet
+
+		BeforeEach(func() {
+			defaultSubnetPool = subnetPool("10.2.3.0/29")
+			ipv6SubnetPool = subnetPool("fd00::/125")
+		})
+
+		JustBeforeEach(func() {
+			var err error
+			subnetpool, err = subnets.NewDualStackSubnets(defaultSubnetPool, ipv6SubnetPool)
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("rejects an IPv6 range which is not IPv6", func() {
+			_, err := subnets.NewDualStackSubnets(defaultSubnetPool, subnetPool("10.9.0.0/24"))
+			Expect(err).To(Equal(subnets.ErrInvalidIPv6Range))
+		})
+
+		It("allocates a /126 IPv6 subnet and address alongside the IPv4 ones", func() {
+			network, err := subnetpool.Acquire(subnets.DynamicSubnetSelector, subnets.DynamicIPSelector)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(network.Subnet.String()).To(Equal("10.2.3.0/30"))
+			Expect(network.IP.String()).To(Equal("10.2.3.1"))
+			Expect(network.IPv6Subnet.String()).To(Equal("fd00::/126"))
+			Expect(network.IPv6.String()).To(Equal("fd00::1"))
+			Expect(subnets.GatewayIP(network.IPv6Subnet).String()).To(Equal("fd00::2"))
+		})
+
+		It("allocates distinct IPv6 subnets", func() {
+			first, err := subnetpool.Acquire(subnets.DynamicSubnetSelector, subnets.DynamicIPSelector)
+			Expect(err).ToNot(HaveOccurred())
+
+			second, err := subnetpool.Acquire(subnets.DynamicSubnetSelector, subnets.DynamicIPSelector)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(second.IPv6Subnet.String()).To(Equal("fd00::4/126"))
+			Expect(second.IPv6Subnet.String()).ToNot(Equal(first.IPv6Subnet.String()))
+		})
+
+		It("has the capacity of the smaller of the ranges", func() {
+			Expect(subnetpool.Capacity()).To(Equal(2))
+
+			ipv6SubnetPool = subnetPool("fd00::/126")
+
+			smaller, err := subnets.NewDualStackSubnets(defaultSubnetPool, ipv6SubnetPool)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(smaller.Capacity()).To(Equal(1))
+		})
+
+		Context("when the IPv6 range is exhausted", func() {
+			BeforeEach(func() {
+				ipv6SubnetPool = subnetPool("fd00::/126")
+			})
+
+			It("fails without allocating an IPv4 subnet", func() {
+				_, err := subnetpool.Acquire(subnets.DynamicSubnetSelector, subnets.DynamicIPSelector)
+				Expect(err).ToNot(HaveOccurred())
+
+				_, err = subnetpool.Acquire(subnets.DynamicSubnetSelector, subnets.DynamicIPSelector)
+				Expect(err).To(Equal(subnets.ErrInsufficientSubnets))
+
+				_, err = subnetpool.Acquire(subnets.StaticSubnetSelector{subnetPool("10.9.0.0/30")}, subnets.DynamicIPSelector)
+				Expect(err).To(Equal(subnets.ErrInsufficientSubnets))
+
+				err = subnetpool.Remove(&linux_backend.Network{Subnet: subnetPool("10.9.0.0/30"), IP: net.ParseIP("10.9.0.1")})
+				Expect(err).ToNot(HaveOccurred())
+			})
+		})
+
+		It("releases the IPv6 subnet with the IPv4 one", func() {
+			network, err := subnetpool.Acquire(subnets.DynamicSubnetSelector, subnets.DynamicIPSelector)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(subnetpool.Release(network)).To(Succeed())
+
+			again, err := subnetpool.Acquire(subnets.DynamicSubnetSelector, subnets.DynamicIPSelector)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(again.IPv6Subnet.String()).To(Equal(network.IPv6Subnet.String()))
+		})
+
+		It("does not dynamically allocate a removed IPv6 subnet", func() {
+			_, static := networkParms("10.9.3.4/30")
+
+			err := subnetpool.Remove(&linux_backend.Network{
+				Subnet:     static,
+				IP:         net.ParseIP("10.9.3.5"),
+				IPv6Subnet: subnetPool("fd00::/126"),
+				IPv6:       net.ParseIP("fd00::1"),
+			})
+			Expect(err).ToNot(HaveOccurred())
+
+			network, err := subnetpool.Acquire(subnets.DynamicSubnetSelector, subnets.DynamicIPSelector)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(network.IPv6Subnet.String()).To(Equal("fd00::4/126"))
+		})
+	})
+
+	Describe("Inventory", func() {
+		BeforeEach(func() {
+			defaultSubnetPool = subnetPool("10.2.3.0/29")
+		})
+
+		It("lists the allocated subnets and IPs", func() {
+			_, err := subnetpool.Acquire(subnets.DynamicSubnetSelector, subnets.DynamicIPSelector)
+			Expect(err).ToNot(HaveOccurred())
+
+			for i := 0; i < 2; i++ {
+				_, err = subnetpool.Acquire(subnets.StaticSubnetSelector{subnetPool("10.9.0.0/29")}, subnets.DynamicIPSelector)
+				Expect(err).ToNot(HaveOccurred())
+			}
+
+			Expect(subnetpool.Inventory()).To(Equal(subnets.Inventory{
+				DynamicRanges: []string{"10.2.3.0/29"},
+				Capacity:      2,
+				Allocated: map[string][]string{
+					"10.2.3.0/30": {"10.2.3.1"},
+					"10.9.0.0/29": {"10.9.0.1", "10.9.0.2"},
+				},
+			}))
+		})
+
+		It("stops listing a subnet once its last IP is released", func() {
+			network, err := subnetpool.Acquire(subnets.DynamicSubnetSelector, subnets.DynamicIPSelector)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(subnetpool.Release(network)).To(Succeed())
+
+			Expect(subnetpool.Inventory().Allocated).To(BeEmpty())
+		})
+
+		Context("when the pool is dual-stack", func() {
+			JustBeforeEach(func() {
+				var err error
+				subnetpool, err = subnets.NewDualStackSubnets(defaultSubnetPool, subnetPool("fd00::/125"))
+				Expect(err).ToNot(HaveOccurred())
+			})
+
+			It("lists the allocated IPv6 subnets and IPs", func() {
+				_, err := subnetpool.Acquire(subnets.DynamicSubnetSelector, subnets.DynamicIPSelector)
+				Expect(err).ToNot(HaveOccurred())
+
+				inventory := subnetpool.Inventory()
+				Expect(inventory.DynamicIPv6Range).To(Equal("fd00::/125"))
+				Expect(inventory.AllocatedIPv6).To(Equal(map[string][]string{
+					"fd00::/126": {"fd00::1"},
+				}))
+			})
+		})
+	})
+
+	Describe("Multiple dynamic ranges", func() {
+		var ranges []*net.IPNet
+
+		BeforeEach(func() {
+			ranges = []*net.IPNet{subnetPool("10.2.3.0/30"), subnetPool("10.9.0.0/29")}
+		})
+
+		JustBeforeEach(func() {
+			var err error
+			subnetpool, err = subnets.NewSubnetsFromRanges(ranges, nil, subnets.FirstFitStrategy)
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("has the capacity of all of the ranges", func() {
+			Expect(subnetpool.Capacity()).To(Equal(3))
+		})
+
+		It("allocates from the next range once a range is exhausted", func() {
+			first, err := subnetpool.Acquire(subnets.DynamicSubnetSelector, subnets.DynamicIPSelector)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(first.Subnet.String()).To(Equal("10.2.3.0/30"))
+
+			second, err := subnetpool.Acquire(subnets.DynamicSubnetSelector, subnets.DynamicIPSelector)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(second.Subnet.String()).To(Equal("10.9.0.0/30"))
+		})
+
+		It("prohibits static allocations from any of the ranges", func() {
+			_, err := subnetpool.Acquire(subnets.StaticSubnetSelector{subnetPool("10.9.0.4/30")}, subnets.DynamicIPSelector)
+			Expect(err).To(MatchError("the requested subnet (10.9.0.4/30) overlaps the dynamic allocation range (10.9.0.0/29)"))
+		})
+
+		It("lists all of the ranges in its inventory", func() {
+			Expect(subnetpool.Inventory().DynamicRanges).To(Equal([]string{"10.2.3.0/30", "10.9.0.0/29"}))
+		})
+
+		It("rejects no ranges", func() {
+			_, err := subnets.NewSubnetsFromRanges(nil, nil, subnets.FirstFitStrategy)
+			Expect(err).To(Equal(subnets.ErrNoDynamicRanges))
+		})
+
+		It("rejects overlapping ranges", func() {
+			_, err := subnets.NewSubnetsFromRanges(append(ranges, subnetPool("10.9.0.4/30")), nil, subnets.FirstFitStrategy)
+			Expect(err).To(Equal(subnets.ErrOverlappingRanges))
+		})
+
+		It("rejects ranges of different address families", func() {
+			_, err := subnets.NewSubnetsFromRanges(append(ranges, subnetPool("fd00::/125")), nil, subnets.FirstFitStrategy)
+			Expect(err).To(Equal(subnets.ErrInvalidRange))
+		})
+
+		Context("with a strategy", func() {
+			var strategy *fakes.FakeStrategy
+
+			JustBeforeEach(func() {
+				strategy = new(fakes.FakeStrategy)
+				strategy.SelectSubnetReturns(subnetPool("10.9.0.4/30"), nil)
+
+				var err error
+				subnetpool, err = subnets.NewSubnetsFromRanges(ranges, nil, strategy)
+				Expect(err).ToNot(HaveOccurred())
+			})
+
+			It("lets the strategy select dynamic subnets from all of the ranges", func() {
+				network, err := subnetpool.Acquire(subnets.DynamicSubnetSelector, subnets.DynamicIPSelector)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(network.Subnet.String()).To(Equal("10.9.0.4/30"))
+
+				selectedFrom, _ := strategy.SelectSubnetArgsForCall(0)
+				Expect(selectedFrom).To(Equal(ranges))
+			})
+
+			It("tells the strategy when a dynamic subnet is deallocated", func() {
+				network, err := subnetpool.Acquire(subnets.DynamicSubnetSelector, subnets.DynamicIPSelector)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(subnetpool.Release(network)).To(Succeed())
+
+				Expect(strategy.ReleasedCallCount()).To(Equal(1))
+				Expect(strategy.ReleasedArgsForCall(0).String()).To(Equal("10.9.0.4/30"))
+			})
+
+			It("does not tell the strategy about static subnets", func() {
+				network, err := subnetpool.Acquire(subnets.StaticSubnetSelector{subnetPool("10.5.0.0/30")}, subnets.DynamicIPSelector)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(subnetpool.Release(network)).To(Succeed())
+
+				Expect(strategy.ReleasedCallCount()).To(Equal(0))
+			})
+		})
+	})
 })
 
 func subnetPool(networkString string) *net.IPNet {
@@ -0,0 +1,59 @@
+// This file was generated by counterfeiter
+package fakes
+
+import (
+	"sync"
+
+	"github.com/cloudfoundry-incubator/garden-linux/network/tc"
+)
+
+type FakeNetlink struct {
+	RequestStub        func(msgType uint16, flags uint16, body []byte) ([][]byte, error)
+	requestMutex       sync.RWMutex
+	requestArgsForCall []struct {
+		msgType uint16
+		flags   uint16
+		body    []byte
+	}
+	requestReturns struct {
+		result1 [][]byte
+		result2 error
+	}
+}
+
+func (fake *FakeNetlink) Request(msgType uint16, flags uint16, body []byte) ([][]byte, error) {
+	fake.requestMutex.Lock()
+	fake.requestArgsForCall = append(fake.requestArgsForCall, struct {
+		msgType uint16
+		flags   uint16
+		body    []byte
+	}{msgType, flags, body})
+	fake.requestMutex.Unlock()
+	if fake.RequestStub != nil {
+		return fake.RequestStub(msgType, flags, body)
+	} else {
+		return fake.requestReturns.result1, fake.requestReturns.result2
+	}
+}
+
+func (fake *FakeNetlink) RequestCallCount() int {
+	fake.requestMutex.RLock()
+	defer fake.requestMutex.RUnlock()
+	return len(fake.requestArgsForCall)
+}
+
+func (fake *FakeNetlink) RequestArgsForCall(i int) (uint16, uint16, []byte) {
+	fake.requestMutex.RLock()
+	defer fake.requestMutex.RUnlock()
+	return fake.requestArgsForCall[i].msgType, fake.requestArgsForCall[i].flags, fake.requestArgsForCall[i].body
+}
+
+func (fake *FakeNetlink) RequestReturns(result1 [][]byte, result2 error) {
+	fake.RequestStub = nil
+	fake.requestReturns = struct {
+		result1 [][]byte
+		result2 error
+	}{result1, result2}
+}
+
+var _ tc.Netlink = new(FakeNetlink)
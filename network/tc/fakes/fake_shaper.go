@@ -0,0 +1,181 @@
+// This file was generated by counterfeiter
+package fakes
+
+import (
+	"sync"
+
+	"github.com/cloudfoundry-incubator/garden-linux/network/tc"
+)
+
+type FakeShaper struct {
+	SetEgressStub        func(iface string, limit tc.TokenBucket) error
+	setEgressMutex       sync.RWMutex
+	setEgressArgsForCall []struct {
+		iface string
+		limit tc.TokenBucket
+	}
+	setEgressReturns struct {
+		result1 error
+	}
+	SetIngressStub        func(iface string, limit tc.TokenBucket) error
+	setIngressMutex       sync.RWMutex
+	setIngressArgsForCall []struct {
+		iface string
+		limit tc.TokenBucket
+	}
+	setIngressReturns struct {
+		result1 error
+	}
+	EgressStub        func(iface string) (tc.TokenBucket, error)
+	egressMutex       sync.RWMutex
+	egressArgsForCall []struct {
+		iface string
+	}
+	egressReturns struct {
+		result1 tc.TokenBucket
+		result2 error
+	}
+	IngressStub        func(iface string) (tc.TokenBucket, error)
+	ingressMutex       sync.RWMutex
+	ingressArgsForCall []struct {
+		iface string
+	}
+	ingressReturns struct {
+		result1 tc.TokenBucket
+		result2 error
+	}
+}
+
+func (fake *FakeShaper) SetEgress(iface string, limit tc.TokenBucket) error {
+	fake.setEgressMutex.Lock()
+	fake.setEgressArgsForCall = append(fake.setEgressArgsForCall, struct {
+		iface string
+		limit tc.TokenBucket
+	}{iface, limit})
+	fake.setEgressMutex.Unlock()
+	if fake.SetEgressStub != nil {
+		return fake.SetEgressStub(iface, limit)
+	} else {
+		return fake.setEgressReturns.result1
+	}
+}
+
+func (fake *FakeShaper) SetEgressCallCount() int {
+	fake.setEgressMutex.RLock()
+	defer fake.setEgressMutex.RUnlock()
+	return len(fake.setEgressArgsForCall)
+}
+
+func (fake *FakeShaper) SetEgressArgsForCall(i int) (string, tc.TokenBucket) {
+	fake.setEgressMutex.RLock()
+	defer fake.setEgressMutex.RUnlock()
+	return fake.setEgressArgsForCall[i].iface, fake.setEgressArgsForCall[i].limit
+}
+
+func (fake *FakeShaper) SetEgressReturns(result1 error) {
+	fake.SetEgressStub = nil
+	fake.setEgressReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeShaper) SetIngress(iface string, limit tc.TokenBucket) error {
+	fake.setIngressMutex.Lock()
+	fake.setIngressArgsForCall = append(fake.setIngressArgsForCall, struct {
+		iface string
+		limit tc.TokenBucket
+	}{iface, limit})
+	fake.setIngressMutex.Unlock()
+	if fake.SetIngressStub != nil {
+		return fake.SetIngressStub(iface, limit)
+	} else {
+		return fake.setIngressReturns.result1
+	}
+}
+
+func (fake *FakeShaper) SetIngressCallCount() int {
+	fake.setIngressMutex.RLock()
+	defer fake.setIngressMutex.RUnlock()
+	return len(fake.setIngressArgsForCall)
+}
+
+func (fake *FakeShaper) SetIngressArgsForCall(i int) (string, tc.TokenBucket) {
+	fake.setIngressMutex.RLock()
+	defer fake.setIngressMutex.RUnlock()
+	return fake.setIngressArgsForCall[i].iface, fake.setIngressArgsForCall[i].limit
+}
+
+func (fake *FakeShaper) SetIngressReturns(result1 error) {
+	fake.SetIngressStub = nil
+	fake.setIngressReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeShaper) Egress(iface string) (tc.TokenBucket, error) {
+	fake.egressMutex.Lock()
+	fake.egressArgsForCall = append(fake.egressArgsForCall, struct {
+		iface string
+	}{iface})
+	fake.egressMutex.Unlock()
+	if fake.EgressStub != nil {
+		return fake.EgressStub(iface)
+	} else {
+		return fake.egressReturns.result1, fake.egressReturns.result2
+	}
+}
+
+func (fake *FakeShaper) EgressCallCount() int {
+	fake.egressMutex.RLock()
+	defer fake.egressMutex.RUnlock()
+	return len(fake.egressArgsForCall)
+}
+
+func (fake *FakeShaper) EgressArgsForCall(i int) string {
+	fake.egressMutex.RLock()
+	defer fake.egressMutex.RUnlock()
+	return fake.egressArgsForCall[i].iface
+}
+
+func (fake *FakeShaper) EgressReturns(result1 tc.TokenBucket, result2 error) {
+	fake.EgressStub = nil
+	fake.egressReturns = struct {
+		result1 tc.TokenBucket
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeShaper) Ingress(iface string) (tc.TokenBucket, error) {
+	fake.ingressMutex.Lock()
+	fake.ingressArgsForCall = append(fake.ingressArgsForCall, struct {
+		iface string
+	}{iface})
+	fake.ingressMutex.Unlock()
+	if fake.IngressStub != nil {
+		return fake.IngressStub(iface)
+	} else {
+		return fake.ingressReturns.result1, fake.ingressReturns.result2
+	}
+}
+
+func (fake *FakeShaper) IngressCallCount() int {
+	fake.ingressMutex.RLock()
+	defer fake.ingressMutex.RUnlock()
+	return len(fake.ingressArgsForCall)
+}
+
+func (fake *FakeShaper) IngressArgsForCall(i int) string {
+	fake.ingressMutex.RLock()
+	defer fake.ingressMutex.RUnlock()
+	return fake.ingressArgsForCall[i].iface
+}
+
+func (fake *FakeShaper) IngressReturns(result1 tc.TokenBucket, result2 error) {
+	fake.IngressStub = nil
+	fake.ingressReturns = struct {
+		result1 tc.TokenBucket
+		result2 error
+	}{result1, result2}
+}
+
+var _ tc.Shaper = new(FakeShaper)
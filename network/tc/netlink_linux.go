@@ -0,0 +1,90 @@
+package tc
+
+import (
+	"sync/atomic"
+	"syscall"
+)
+
+// NewNetlink creates a Netlink which opens a socket of the NETLINK_ROUTE
+// family for each request.
+func NewNetlink() Netlink {
+	return &routeNetlink{}
+}
+
+type routeNetlink struct {
+	seq uint32
+}
+
+func (nl *routeNetlink) Request(msgType, flags uint16, body []byte) ([][]byte, error) {
+	fd, err := syscall.Socket(syscall.AF_NETLINK, syscall.SOCK_RAW|syscall.SOCK_CLOEXEC, syscall.NETLINK_ROUTE)
+	if err != nil {
+		return nil, err
+	}
+
+	defer syscall.Close(fd)
+
+	if err := syscall.Bind(fd, &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK}); err != nil {
+		return nil, err
+	}
+
+	dump := flags&nlmFDump == nlmFDump
+
+	flags |= syscall.NLM_F_REQUEST
+	if !dump {
+		flags |= syscall.NLM_F_ACK
+	}
+
+	seq := atomic.AddUint32(&nl.seq, 1)
+
+	header := make([]byte, syscall.NLMSG_HDRLEN)
+	native.PutUint32(header[0:4], uint32(syscall.NLMSG_HDRLEN+len(body)))
+	native.PutUint16(header[4:6], msgType)
+	native.PutUint16(header[6:8], flags)
+	native.PutUint32(header[8:12], seq)
+
+	if err := syscall.Sendto(fd, append(header, body...), 0, &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK}); err != nil {
+		return nil, err
+	}
+
+	bodies := [][]byte{}
+	buf := make([]byte, syscall.Getpagesize()*4)
+
+	for {
+		n, _, err := syscall.Recvfrom(fd, buf, 0)
+		if err != nil {
+			return nil, err
+		}
+
+		msgs, err := syscall.ParseNetlinkMessage(buf[:n])
+		if err != nil {
+			return nil, err
+		}
+
+		for _, msg := range msgs {
+			if msg.Header.Seq != seq {
+				continue
+			}
+
+			switch msg.Header.Type {
+			case syscall.NLMSG_DONE:
+				return bodies, nil
+
+			case syscall.NLMSG_ERROR:
+				if len(msg.Data) < 4 {
+					return nil, syscall.EINVAL
+				}
+
+				// an error of zero acknowledges the request
+				if errno := int32(native.Uint32(msg.Data[0:4])); errno != 0 {
+					return nil, syscall.Errno(-errno)
+				}
+
+				return bodies, nil
+
+			default:
+				// the buffer is reused by the next receive
+				bodies = append(bodies, append([]byte{}, msg.Data...))
+			}
+		}
+	}
+}
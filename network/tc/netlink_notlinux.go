@@ -0,0 +1,7 @@
+// +build !linux
+
+package tc
+
+func NewNetlink() Netlink {
+	panic("not supported on this OS")
+}
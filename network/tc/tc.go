@@ -0,0 +1,624 @@
+// Package tc limits the traffic of network interfaces with the kernel's
+// traffic control, which it programs over rtnetlink rather than with tc(8).
+package tc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"net"
+	"syscall"
+	"unsafe"
+)
+
+// A TokenBucket allows Rate bytes of traffic per second, with bursts of up to
+// Burst bytes. A zero Rate is no limit.
+type TokenBucket struct {
+	Rate  uint64
+	Burst uint64
+}
+
+//go:generate counterfeiter . Shaper
+type Shaper interface {
+	// SetEgress limits the traffic the interface sends with a token bucket
+	// filter, which replaces its root qdisc.
+	SetEgress(iface string, limit TokenBucket) error
+
+	// SetIngress limits the traffic the interface receives with a filter of
+	// its ingress qdisc, which drops what exceeds the limit.
+	SetIngress(iface string, limit TokenBucket) error
+
+	// Egress and Ingress return the limits the kernel has for the interface,
+	// or a zero TokenBucket if it has none.
+	Egress(iface string) (TokenBucket, error)
+	Ingress(iface string) (TokenBucket, error)
+}
+
+//go:generate counterfeiter . Netlink
+
+// Netlink sends rtnetlink requests. The body of each message is returned for
+// dump requests; the kernel's acknowledgement is awaited for others.
+type Netlink interface {
+	Request(msgType, flags uint16, body []byte) ([][]byte, error)
+}
+
+// NewShaper creates a Shaper which sends its requests with the given Netlink.
+func NewShaper(netlink Netlink) Shaper {
+	return &shaper{netlink: netlink}
+}
+
+// ShapeError is returned if the limit of an interface cannot be set or read
+type ShapeError struct {
+	Cause     error
+	Direction string
+	Interface string
+}
+
+func (err ShapeError) Error() string {
+	return fmt.Sprintf("tc: failed to shape %s traffic of %s: %v", err.Direction, err.Interface, err.Cause)
+}
+
+const (
+	rtmNewQdisc  = 36
+	rtmDelQdisc  = 37
+	rtmGetQdisc  = 38
+	rtmNewFilter = 44
+	rtmGetFilter = 46
+
+	nlmFReplace = 0x100
+	nlmFExcl    = 0x200
+	nlmFCreate  = 0x400
+	nlmFDump    = 0x300
+
+	tcaKind    = 1
+	tcaOptions = 2
+
+	tcaTBFParms  = 1
+	tcaTBFRtab   = 2
+	tcaTBFRate64 = 4
+
+	tcaU32ClassID = 1
+	tcaU32Sel     = 5
+	tcaU32Police  = 6
+
+	tcaPoliceTBF    = 1
+	tcaPoliceRate   = 2
+	tcaPoliceRate64 = 8
+
+	handleRoot    = 0xffffffff
+	handleIngress = 0xfffffff1
+
+	// the handles of the qdiscs this package adds; tc(8) calls them 1: and ffff:
+	tbfHandle     = 0x00010000
+	ingressHandle = 0xffff0000
+
+	linkLayerEthernet = 1
+	policeShot        = 2
+	u32Terminal       = 1
+	ethPAll           = 0x0003
+
+	// the kernel's scheduling clock has ticks of 64ns
+	ticksPerSecond = 1000000000 / 64
+
+	// packets wait up to 25ms for tokens before the token bucket filter drops them
+	tbfLatencyMillis = 25
+
+	// the largest packet the policer and its rate table account for, which
+	// covers generic receive offload as well as jumbo frames
+	policeMTU = 65535
+)
+
+type shaper struct {
+	netlink Netlink
+}
+
+func (s *shaper) SetEgress(iface string, limit TokenBucket) error {
+	if err := s.setEgress(iface, limit); err != nil {
+		return ShapeError{err, "egress", iface}
+	}
+
+	return nil
+}
+
+func (s *shaper) setEgress(iface string, limit TokenBucket) error {
+	index, err := interfaceIndex(iface)
+	if err != nil {
+		return err
+	}
+
+	if limit.Rate == 0 {
+		return s.deleteQdisc(index, handleRoot, 0)
+	}
+
+	buffer, err := ticks(limit.Burst, limit.Rate)
+	if err != nil {
+		return err
+	}
+
+	cellLog, rtab := rateTable(limit.Rate, policeMTU)
+	qopt := tbfQopt{
+		Rate:   ratespec(limit.Rate, cellLog),
+		Limit:  saturate(mulDiv(limit.Rate, tbfLatencyMillis, 1000, false) + limit.Burst),
+		Buffer: buffer,
+	}
+
+	options := []attr{
+		{typ: tcaTBFParms, data: encode(qopt)},
+		{typ: tcaTBFRtab, data: encode(rtab)},
+	}
+
+	if limit.Rate > math.MaxUint32 {
+		options = append(options, attr{typ: tcaTBFRate64, data: encode(limit.Rate)})
+	}
+
+	msg := tcMsg{Ifindex: index, Handle: tbfHandle, Parent: handleRoot}
+	_, err = s.netlink.Request(rtmNewQdisc, nlmFCreate|nlmFReplace, message(msg,
+		attr{typ: tcaKind, data: []byte("tbf\x00")},
+		attr{typ: tcaOptions, children: options},
+	))
+
+	return err
+}
+
+func (s *shaper) SetIngress(iface string, limit TokenBucket) error {
+	if err := s.setIngress(iface, limit); err != nil {
+		return ShapeError{err, "ingress", iface}
+	}
+
+	return nil
+}
+
+// setIngress replaces the ingress qdisc, rather than its filter, as deleting
+// the qdisc deletes any filters left by other means.
+func (s *shaper) setIngress(iface string, limit TokenBucket) error {
+	index, err := interfaceIndex(iface)
+	if err != nil {
+		return err
+	}
+
+	if err := s.deleteQdisc(index, handleIngress, ingressHandle); err != nil {
+		return err
+	}
+
+	if limit.Rate == 0 {
+		return nil
+	}
+
+	burst, err := ticks(limit.Burst, limit.Rate)
+	if err != nil {
+		return err
+	}
+
+	cellLog, rtab := rateTable(limit.Rate, policeMTU)
+	police := tcPolice{
+		Action: policeShot,
+		Burst:  burst,
+		Mtu:    policeMTU,
+		Rate:   ratespec(limit.Rate, cellLog),
+	}
+
+	policeAttrs := []attr{
+		{typ: tcaPoliceTBF, data: encode(police)},
+		{typ: tcaPoliceRate, data: encode(rtab)},
+	}
+
+	if limit.Rate > math.MaxUint32 {
+		policeAttrs = append(policeAttrs, attr{typ: tcaPoliceRate64, data: encode(limit.Rate)})
+	}
+
+	qdisc := tcMsg{Ifindex: index, Handle: ingressHandle, Parent: handleIngress}
+	_, err = s.netlink.Request(rtmNewQdisc, nlmFCreate|nlmFExcl, message(qdisc,
+		attr{typ: tcaKind, data: []byte("ingress\x00")},
+	))
+	if err != nil {
+		return err
+	}
+
+	// a u32 filter without a mask matches every packet, of every protocol
+	sel := u32Sel{Flags: u32Terminal, NKeys: 1}
+	filter := tcMsg{Ifindex: index, Parent: ingressHandle, Info: 1<<16 | uint32(htons(ethPAll))}
+	_, err = s.netlink.Request(rtmNewFilter, nlmFCreate|nlmFExcl, message(filter,
+		attr{typ: tcaKind, data: []byte("u32\x00")},
+		attr{typ: tcaOptions, children: []attr{
+			{typ: tcaU32Sel, data: encode(sel)},
+			{typ: tcaU32ClassID, data: encode(uint32(1))},
+			{typ: tcaU32Police, children: policeAttrs},
+		}},
+	))
+	if err != nil {
+		// the kernel may lack the u32 classifier or the police action
+		s.deleteQdisc(index, handleIngress, ingressHandle)
+		return err
+	}
+
+	return nil
+}
+
+// deleteQdisc deletes a qdisc, if the interface has one.
+func (s *shaper) deleteQdisc(index int32, parent, handle uint32) error {
+	msg := tcMsg{Ifindex: index, Handle: handle, Parent: parent}
+	_, err := s.netlink.Request(rtmDelQdisc, 0, message(msg))
+	if err == syscall.ENOENT || err == syscall.EINVAL {
+		return nil
+	}
+
+	return err
+}
+
+func (s *shaper) Egress(iface string) (TokenBucket, error) {
+	limit, err := s.egress(iface)
+	if err != nil {
+		return TokenBucket{}, ShapeError{err, "egress", iface}
+	}
+
+	return limit, nil
+}
+
+func (s *shaper) egress(iface string) (TokenBucket, error) {
+	index, err := interfaceIndex(iface)
+	if err != nil {
+		return TokenBucket{}, err
+	}
+
+	bodies, err := s.netlink.Request(rtmGetQdisc, nlmFDump, message(tcMsg{Ifindex: index}))
+	if err != nil {
+		return TokenBucket{}, err
+	}
+
+	for _, body := range bodies {
+		msg, attrs, err := parseMessage(body)
+		if err != nil {
+			return TokenBucket{}, err
+		}
+
+		if msg.Ifindex != index || msg.Parent != handleRoot || kind(attrs) != "tbf" {
+			continue
+		}
+
+		options, err := parseAttrs(attrs[tcaOptions])
+		if err != nil {
+			return TokenBucket{}, err
+		}
+
+		var qopt tbfQopt
+		if err := decode(options[tcaTBFParms], &qopt); err != nil {
+			return TokenBucket{}, err
+		}
+
+		return bucket(qopt.Rate.Rate, options[tcaTBFRate64], qopt.Buffer)
+	}
+
+	return TokenBucket{}, nil
+}
+
+func (s *shaper) Ingress(iface string) (TokenBucket, error) {
+	limit, err := s.ingress(iface)
+	if err != nil {
+		return TokenBucket{}, ShapeError{err, "ingress", iface}
+	}
+
+	return limit, nil
+}
+
+func (s *shaper) ingress(iface string) (TokenBucket, error) {
+	index, err := interfaceIndex(iface)
+	if err != nil {
+		return TokenBucket{}, err
+	}
+
+	bodies, err := s.netlink.Request(rtmGetFilter, nlmFDump, message(tcMsg{Ifindex: index, Parent: ingressHandle}))
+	if err != nil {
+		return TokenBucket{}, err
+	}
+
+	for _, body := range bodies {
+		msg, attrs, err := parseMessage(body)
+		if err != nil {
+			return TokenBucket{}, err
+		}
+
+		if msg.Ifindex != index || msg.Parent != ingressHandle || kind(attrs) != "u32" {
+			continue
+		}
+
+		options, err := parseAttrs(attrs[tcaOptions])
+		if err != nil {
+			return TokenBucket{}, err
+		}
+
+		// the u32 filter's hash table is dumped too, without a policer
+		if options[tcaU32Police] == nil {
+			continue
+		}
+
+		policeAttrs, err := parseAttrs(options[tcaU32Police])
+		if err != nil {
+			return TokenBucket{}, err
+		}
+
+		var police tcPolice
+		if err := decode(policeAttrs[tcaPoliceTBF], &police); err != nil {
+			return TokenBucket{}, err
+		}
+
+		return bucket(police.Rate.Rate, policeAttrs[tcaPoliceRate64], police.Burst)
+	}
+
+	return TokenBucket{}, nil
+}
+
+func interfaceIndex(iface string) (int32, error) {
+	intf, err := net.InterfaceByName(iface)
+	if err != nil {
+		return 0, err
+	}
+
+	return int32(intf.Index), nil
+}
+
+func kind(attrs map[uint16][]byte) string {
+	return string(bytes.TrimRight(attrs[tcaKind], "\x00"))
+}
+
+// bucket reads a limit back from the kernel. The rate is exact. The burst is
+// kept as the time the rate takes to send it, so it is exact when the rate is
+// below a byte per tick of the clock, about 15MB/s, and otherwise at most
+// rate/ticksPerSecond bytes over.
+func bucket(rate32 uint32, rate64 []byte, burstTicks uint32) (TokenBucket, error) {
+	rate := uint64(rate32)
+	if rate64 != nil {
+		if err := decode(rate64, &rate); err != nil {
+			return TokenBucket{}, err
+		}
+	}
+
+	if rate == 0 {
+		return TokenBucket{}, nil
+	}
+
+	return TokenBucket{
+		Rate:  rate,
+		Burst: mulDiv(uint64(burstTicks), rate, ticksPerSecond, false),
+	}, nil
+}
+
+// ticks is the time, in ticks of the kernel's clock, the rate takes to send the
+// given number of bytes, rounded up so that reading the burst back with
+// bucket gives at least the bytes.
+func ticks(size, rate uint64) (uint32, error) {
+	t := mulDiv(size, ticksPerSecond, rate, true)
+	if t > math.MaxUint32 {
+		return 0, fmt.Errorf("burst of %d bytes is too large for a rate of %d bytes per second", size, rate)
+	}
+
+	return uint32(t), nil
+}
+
+// rateTable is the time the rate takes to send packets of each size, in 256
+// cells of 1<<cellLog bytes which cover packets of up to mtu bytes.
+func rateTable(rate uint64, mtu uint32) (uint8, [256]uint32) {
+	var cellLog uint8
+	for (mtu >> cellLog) > 255 {
+		cellLog++
+	}
+
+	var table [256]uint32
+	for i := range table {
+		table[i] = saturate(mulDiv(uint64(i+1)<<cellLog, ticksPerSecond, rate, false))
+	}
+
+	return cellLog, table
+}
+
+func ratespec(rate uint64, cellLog uint8) tcRateSpec {
+	return tcRateSpec{
+		CellLog:   cellLog,
+		Linklayer: linkLayerEthernet,
+		CellAlign: -1,
+		Rate:      saturate(rate),
+	}
+}
+
+// mulDiv is a*b/c, without overflowing, saturated to the largest uint64.
+func mulDiv(a, b, c uint64, roundUp bool) uint64 {
+	n := new(big.Int).Mul(new(big.Int).SetUint64(a), new(big.Int).SetUint64(b))
+
+	d := new(big.Int).SetUint64(c)
+	if roundUp {
+		n.Add(n, d).Sub(n, big.NewInt(1))
+	}
+
+	n.Quo(n, d)
+	if n.BitLen() > 64 {
+		return math.MaxUint64
+	}
+
+	return n.Uint64()
+}
+
+func saturate(n uint64) uint32 {
+	if n > math.MaxUint32 {
+		return math.MaxUint32
+	}
+
+	return uint32(n)
+}
+
+// The structs below have the layout of the kernel's, from
+// linux/rtnetlink.h and linux/pkt_sched.h, without implicit padding.
+
+type tcMsg struct {
+	Family  uint8
+	Pad1    uint8
+	Pad2    uint16
+	Ifindex int32
+	Handle  uint32
+	Parent  uint32
+	Info    uint32
+}
+
+type tcRateSpec struct {
+	CellLog   uint8
+	Linklayer uint8
+	Overhead  uint16
+	CellAlign int16
+	Mpu       uint16
+	Rate      uint32
+}
+
+type tbfQopt struct {
+	Rate     tcRateSpec
+	PeakRate tcRateSpec
+	Limit    uint32
+	Buffer   uint32
+	Mtu      uint32
+}
+
+type tcPolice struct {
+	Index    uint32
+	Action   int32
+	Limit    uint32
+	Burst    uint32
+	Mtu      uint32
+	Rate     tcRateSpec
+	PeakRate tcRateSpec
+	Refcnt   int32
+	Bindcnt  int32
+	Capab    uint32
+}
+
+type u32Sel struct {
+	Flags    uint8
+	Offshift uint8
+	NKeys    uint8
+	Pad      uint8
+	Offmask  uint16
+	Off      uint16
+	Offoff   int16
+	Hoff     int16
+	Hmask    uint32
+
+	// a single key with no mask, which matches anything
+	Key u32Key
+}
+
+type u32Key struct {
+	Mask    uint32
+	Val     uint32
+	Off     int32
+	Offmask int32
+}
+
+// attr is a netlink attribute, with either data or nested attributes.
+type attr struct {
+	typ      uint16
+	data     []byte
+	children []attr
+}
+
+func (a attr) encode() []byte {
+	data := a.data
+	if a.children != nil {
+		data = encodeAttrs(a.children)
+	}
+
+	b := make([]byte, align(4+len(data)))
+	native.PutUint16(b[0:2], uint16(4+len(data)))
+	native.PutUint16(b[2:4], a.typ)
+	copy(b[4:], data)
+
+	return b
+}
+
+func encodeAttrs(attrs []attr) []byte {
+	var b []byte
+	for _, a := range attrs {
+		b = append(b, a.encode()...)
+	}
+
+	return b
+}
+
+func message(msg tcMsg, attrs ...attr) []byte {
+	return append(encode(msg), encodeAttrs(attrs)...)
+}
+
+func parseMessage(body []byte) (tcMsg, map[uint16][]byte, error) {
+	var msg tcMsg
+
+	size := binary.Size(msg)
+	if len(body) < size {
+		return msg, nil, errors.New("truncated message")
+	}
+
+	if err := decode(body[:size], &msg); err != nil {
+		return msg, nil, err
+	}
+
+	attrs, err := parseAttrs(body[size:])
+	return msg, attrs, err
+}
+
+// parseAttrs returns the data of each attribute, by type; nested attributes
+// are parsed from their parent's data.
+func parseAttrs(b []byte) (map[uint16][]byte, error) {
+	attrs := map[uint16][]byte{}
+
+	for len(b) >= 4 {
+		length := int(native.Uint16(b[0:2]))
+		if length < 4 || length > len(b) {
+			return nil, errors.New("truncated attribute")
+		}
+
+		// the nested flag is set by some kernels
+		attrs[native.Uint16(b[2:4])&0x3fff] = b[4:length]
+
+		if align(length) >= len(b) {
+			break
+		}
+
+		b = b[align(length):]
+	}
+
+	return attrs, nil
+}
+
+func align(length int) int {
+	return (length + 3) &^ 3
+}
+
+func encode(data interface{}) []byte {
+	var b bytes.Buffer
+	binary.Write(&b, native, data)
+	return b.Bytes()
+}
+
+func decode(b []byte, data interface{}) error {
+	if len(b) < binary.Size(data) {
+		return errors.New("truncated attribute")
+	}
+
+	return binary.Read(bytes.NewReader(b), native, data)
+}
+
+// native is the byte order of the host, which netlink messages are in.
+var native = nativeEndian()
+
+func nativeEndian() binary.ByteOrder {
+	x := uint16(1)
+	if *(*byte)(unsafe.Pointer(&x)) == 1 {
+		return binary.LittleEndian
+	}
+
+	return binary.BigEndian
+}
+
+// htons converts to network byte order, which tcm_info's protocol is in.
+func htons(n uint16) uint16 {
+	var b [2]byte
+	binary.BigEndian.PutUint16(b[:], n)
+	return native.Uint16(b[:])
+}
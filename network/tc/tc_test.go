@@ -0,0 +1,265 @@
+package tc_test
+
+import (
+	"bytes"
+	"errors"
+	"syscall"
+
+	"github.com/cloudfoundry-incubator/garden-linux/network/tc"
+	"github.com/cloudfoundry-incubator/garden-linux/network/tc/fakes"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Shaper", func() {
+	const (
+		rtmNewQdisc  = 36
+		rtmDelQdisc  = 37
+		rtmGetQdisc  = 38
+		rtmNewFilter = 44
+		rtmGetFilter = 46
+
+		nlmFReplace = 0x100
+		nlmFExcl    = 0x200
+		nlmFCreate  = 0x400
+		nlmFDump    = 0x300
+	)
+
+	var (
+		fakeNetlink *fakes.FakeNetlink
+		shaper      tc.Shaper
+
+		// the bodies of the qdiscs and filters created, which the kernel
+		// dumps in the same format
+		qdiscs  [][]byte
+		filters [][]byte
+	)
+
+	BeforeEach(func() {
+		qdiscs = nil
+		filters = nil
+
+		fakeNetlink = new(fakes.FakeNetlink)
+		fakeNetlink.RequestStub = func(msgType, flags uint16, body []byte) ([][]byte, error) {
+			switch msgType {
+			case rtmNewQdisc:
+				qdiscs = append(qdiscs, body)
+			case rtmNewFilter:
+				filters = append(filters, body)
+			case rtmDelQdisc:
+				return nil, syscall.ENOENT
+			case rtmGetQdisc:
+				return qdiscs, nil
+			case rtmGetFilter:
+				return filters, nil
+			}
+
+			return nil, nil
+		}
+
+		shaper = tc.NewShaper(fakeNetlink)
+	})
+
+	Describe("SetEgress", func() {
+		It("replaces the root qdisc with a token bucket filter", func() {
+			Expect(shaper.SetEgress("lo", tc.TokenBucket{Rate: 1000, Burst: 500})).To(Succeed())
+
+			Expect(fakeNetlink.RequestCallCount()).To(Equal(1))
+
+			msgType, flags, body := fakeNetlink.RequestArgsForCall(0)
+			Expect(msgType).To(BeEquivalentTo(rtmNewQdisc))
+			Expect(flags).To(BeEquivalentTo(nlmFCreate | nlmFReplace))
+			Expect(bytes.Contains(body, []byte("tbf\x00"))).To(BeTrue())
+		})
+
+		It("is read back exactly by Egress", func() {
+			limits := []tc.TokenBucket{
+				{Rate: 1000, Burst: 500},
+				{Rate: 128 * 1024, Burst: 64 * 1024},
+				{Rate: 15 * 1000 * 1000, Burst: 1234567},
+			}
+
+			for _, limit := range limits {
+				Expect(shaper.SetEgress("lo", limit)).To(Succeed())
+				Expect(shaper.Egress("lo")).To(Equal(limit))
+
+				qdiscs = nil
+			}
+		})
+
+		Context("when the rate does not fit in 32 bits", func() {
+			It("reads back the exact rate", func() {
+				limit := tc.TokenBucket{Rate: 5 * 1000 * 1000 * 1000, Burst: 10 * 1000 * 1000}
+				Expect(shaper.SetEgress("lo", limit)).To(Succeed())
+
+				egress, err := shaper.Egress("lo")
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(egress.Rate).To(Equal(limit.Rate))
+				Expect(egress.Burst).To(BeNumerically(">=", limit.Burst))
+				Expect(egress.Burst).To(BeNumerically("<", limit.Burst+limit.Rate/(1000000000/64)))
+			})
+		})
+
+		Context("when the rate is zero", func() {
+			It("deletes the root qdisc, if there is one", func() {
+				Expect(shaper.SetEgress("lo", tc.TokenBucket{})).To(Succeed())
+
+				Expect(fakeNetlink.RequestCallCount()).To(Equal(1))
+
+				msgType, _, _ := fakeNetlink.RequestArgsForCall(0)
+				Expect(msgType).To(BeEquivalentTo(rtmDelQdisc))
+			})
+		})
+
+		Context("when the burst takes too long to send at the rate", func() {
+			It("returns a ShapeError without sending a request", func() {
+				err := shaper.SetEgress("lo", tc.TokenBucket{Rate: 1, Burst: 1 << 40})
+				Expect(err).To(BeAssignableToTypeOf(tc.ShapeError{}))
+				Expect(err).To(MatchError(ContainSubstring("too large")))
+
+				Expect(fakeNetlink.RequestCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when the interface does not exist", func() {
+			It("returns a ShapeError", func() {
+				err := shaper.SetEgress("nosuchiface", tc.TokenBucket{Rate: 1000, Burst: 500})
+				Expect(err).To(BeAssignableToTypeOf(tc.ShapeError{}))
+				Expect(err).To(MatchError(ContainSubstring("tc: failed to shape egress traffic of nosuchiface")))
+			})
+		})
+
+		Context("when the request fails", func() {
+			It("returns a ShapeError", func() {
+				fakeNetlink.RequestReturns(nil, errors.New("oh no"))
+
+				err := shaper.SetEgress("lo", tc.TokenBucket{Rate: 1000, Burst: 500})
+				Expect(err).To(MatchError("tc: failed to shape egress traffic of lo: oh no"))
+			})
+		})
+	})
+
+	Describe("Egress", func() {
+		Context("when the interface has no token bucket filter", func() {
+			It("returns no limit", func() {
+				Expect(shaper.SetIngress("lo", tc.TokenBucket{Rate: 1000, Burst: 500})).To(Succeed())
+
+				Expect(shaper.Egress("lo")).To(Equal(tc.TokenBucket{}))
+			})
+		})
+
+		It("dumps the qdiscs", func() {
+			_, err := shaper.Egress("lo")
+			Expect(err).ToNot(HaveOccurred())
+
+			msgType, flags, _ := fakeNetlink.RequestArgsForCall(0)
+			Expect(msgType).To(BeEquivalentTo(rtmGetQdisc))
+			Expect(flags).To(BeEquivalentTo(nlmFDump))
+		})
+	})
+
+	Describe("SetIngress", func() {
+		It("replaces the ingress qdisc with one whose filter polices all traffic", func() {
+			Expect(shaper.SetIngress("lo", tc.TokenBucket{Rate: 1000, Burst: 500})).To(Succeed())
+
+			Expect(fakeNetlink.RequestCallCount()).To(Equal(3))
+
+			msgType, _, _ := fakeNetlink.RequestArgsForCall(0)
+			Expect(msgType).To(BeEquivalentTo(rtmDelQdisc))
+
+			msgType, flags, body := fakeNetlink.RequestArgsForCall(1)
+			Expect(msgType).To(BeEquivalentTo(rtmNewQdisc))
+			Expect(flags).To(BeEquivalentTo(nlmFCreate | nlmFExcl))
+			Expect(bytes.Contains(body, []byte("ingress\x00"))).To(BeTrue())
+
+			msgType, flags, body = fakeNetlink.RequestArgsForCall(2)
+			Expect(msgType).To(BeEquivalentTo(rtmNewFilter))
+			Expect(flags).To(BeEquivalentTo(nlmFCreate | nlmFExcl))
+			Expect(bytes.Contains(body, []byte("u32\x00"))).To(BeTrue())
+		})
+
+		It("is read back exactly by Ingress", func() {
+			limits := []tc.TokenBucket{
+				{Rate: 1000, Burst: 500},
+				{Rate: 128 * 1024, Burst: 64 * 1024},
+				{Rate: 15 * 1000 * 1000, Burst: 1234567},
+			}
+
+			for _, limit := range limits {
+				Expect(shaper.SetIngress("lo", limit)).To(Succeed())
+				Expect(shaper.Ingress("lo")).To(Equal(limit))
+
+				filters = nil
+			}
+		})
+
+		Context("when the rate is zero", func() {
+			It("only deletes the ingress qdisc", func() {
+				Expect(shaper.SetIngress("lo", tc.TokenBucket{})).To(Succeed())
+
+				Expect(fakeNetlink.RequestCallCount()).To(Equal(1))
+
+				msgType, _, _ := fakeNetlink.RequestArgsForCall(0)
+				Expect(msgType).To(BeEquivalentTo(rtmDelQdisc))
+			})
+		})
+
+		Context("when adding the filter fails", func() {
+			It("deletes the ingress qdisc again", func() {
+				fakeNetlink.RequestStub = func(msgType, flags uint16, body []byte) ([][]byte, error) {
+					if msgType == rtmNewFilter {
+						return nil, syscall.ENOENT
+					}
+
+					return nil, nil
+				}
+
+				err := shaper.SetIngress("lo", tc.TokenBucket{Rate: 1000, Burst: 500})
+				Expect(err).To(Equal(tc.ShapeError{syscall.ENOENT, "ingress", "lo"}))
+
+				Expect(fakeNetlink.RequestCallCount()).To(Equal(4))
+
+				msgType, _, _ := fakeNetlink.RequestArgsForCall(3)
+				Expect(msgType).To(BeEquivalentTo(rtmDelQdisc))
+			})
+		})
+
+		Context("when deleting the ingress qdisc fails", func() {
+			It("returns a ShapeError", func() {
+				fakeNetlink.RequestReturns(nil, syscall.EPERM)
+
+				err := shaper.SetIngress("lo", tc.TokenBucket{Rate: 1000, Burst: 500})
+				Expect(err).To(Equal(tc.ShapeError{syscall.EPERM, "ingress", "lo"}))
+			})
+		})
+	})
+
+	Describe("Ingress", func() {
+		Context("when the interface has no ingress filter", func() {
+			It("returns no limit", func() {
+				Expect(shaper.SetEgress("lo", tc.TokenBucket{Rate: 1000, Burst: 500})).To(Succeed())
+
+				Expect(shaper.Ingress("lo")).To(Equal(tc.TokenBucket{}))
+			})
+		})
+
+		It("dumps the filters", func() {
+			_, err := shaper.Ingress("lo")
+			Expect(err).ToNot(HaveOccurred())
+
+			msgType, flags, _ := fakeNetlink.RequestArgsForCall(0)
+			Expect(msgType).To(BeEquivalentTo(rtmGetFilter))
+			Expect(flags).To(BeEquivalentTo(nlmFDump))
+		})
+
+		Context("when a dumped message is truncated", func() {
+			It("returns a ShapeError", func() {
+				fakeNetlink.RequestReturns([][]byte{{1, 2, 3}}, nil)
+
+				_, err := shaper.Ingress("lo")
+				Expect(err).To(MatchError("tc: failed to shape ingress traffic of lo: truncated message"))
+			})
+		})
+	})
+})
@@ -1,141 +1,114 @@
 package bandwidth_manager
 
 import (
-	"bytes"
-	"fmt"
-	"os/exec"
 	"path"
-	"regexp"
-	"strconv"
 
 	"github.com/cloudfoundry-incubator/garden"
-	"github.com/cloudfoundry-incubator/garden-linux/old/logging"
-	"github.com/cloudfoundry/gunk/command_runner"
+	"github.com/cloudfoundry-incubator/garden-linux/network/tc"
+	"github.com/cloudfoundry-incubator/garden-linux/process"
 	"github.com/pivotal-golang/lager"
 )
 
-var IN_RATE_PATTERN = regexp.MustCompile(`qdisc tbf [0-9a-f]+: root refcnt \d+ rate (\d+)([KMG]?)bit burst (\d+)([KMG]?)b`)
-var OUT_RATE_PATTERN = regexp.MustCompile(`police 0x[0-9a-f]+ rate (\d+)([KMG]?)bit burst (\d+)([KMG]?)b`)
-
 type BandwidthManager interface {
 	SetLimits(lager.Logger, garden.BandwidthLimits) error
+	SetDirectionalLimits(lager.Logger, Limits) error
 	GetLimits(lager.Logger) (garden.ContainerBandwidthStat, error)
 }
 
+// Limits limit the traffic into the container (Ingress) and the traffic out
+// of it (Egress) independently. A zero Rate is no limit.
+type Limits struct {
+	Ingress tc.TokenBucket
+	Egress  tc.TokenBucket
+}
+
 type ContainerBandwidthManager struct {
 	containerPath string
-	containerID   string
 
-	runner command_runner.CommandRunner
+	shaper tc.Shaper
 }
 
-func New(containerPath, containerID string, runner command_runner.CommandRunner) *ContainerBandwidthManager {
+func New(containerPath string, shaper tc.Shaper) *ContainerBandwidthManager {
 	return &ContainerBandwidthManager{
 		containerPath: containerPath,
-		containerID:   containerID,
 
-		runner: runner,
+		shaper: shaper,
 	}
 }
 
+// SetLimits limits the traffic into the container and out of it alike.
 func (m *ContainerBandwidthManager) SetLimits(
 	logger lager.Logger,
 	limits garden.BandwidthLimits,
 ) error {
-	runner := logging.Runner{
-		CommandRunner: m.runner,
-		Logger:        logger,
+	bucket := tc.TokenBucket{
+		Rate:  limits.RateInBytesPerSecond,
+		Burst: limits.BurstRateInBytesPerSecond,
 	}
 
-	setRate := exec.Command(path.Join(m.containerPath, "net_rate.sh"))
-	setRate.Env = []string{
-		fmt.Sprintf("BURST=%d", limits.BurstRateInBytesPerSecond),
-		fmt.Sprintf("RATE=%d", limits.RateInBytesPerSecond*8),
-	}
-
-	return runner.Run(setRate)
+	return m.SetDirectionalLimits(logger, Limits{Ingress: bucket, Egress: bucket})
 }
 
-func (m *ContainerBandwidthManager) GetLimits(logger lager.Logger) (garden.ContainerBandwidthStat, error) {
-	limits := garden.ContainerBandwidthStat{}
-
-	runner := logging.Runner{
-		CommandRunner: m.runner,
-		Logger:        logger,
-	}
-
-	egressOut := new(bytes.Buffer)
-
-	egress := exec.Command(path.Join(m.containerPath, "net.sh"), "get_egress_info")
-	egress.Env = []string{"ID=" + m.containerID}
-	egress.Stdout = egressOut
-
-	err := runner.Run(egress)
+// SetDirectionalLimits shapes the host side of the container's veth pair: the
+// traffic it sends goes into the container, and the traffic it receives came
+// out of it.
+func (m *ContainerBandwidthManager) SetDirectionalLimits(logger lager.Logger, limits Limits) error {
+	iface, err := m.hostInterface()
 	if err != nil {
-		return limits, err
+		return err
 	}
 
-	matches := IN_RATE_PATTERN.FindStringSubmatch(string(egressOut.Bytes()))
-	if matches != nil {
-		inRate, err := strconv.ParseUint(matches[1], 10, 0)
-		if err != nil {
-			return limits, err
-		}
-
-		inBurst, err := strconv.ParseUint(matches[3], 10, 0)
-		if err != nil {
-			return limits, err
-		}
+	logger.Debug("set-limits", lager.Data{
+		"interface": iface,
+		"limits":    limits,
+	})
 
-		inRateUnit := matches[2]
-		inBurstUnit := matches[4]
-
-		limits.InRate = convertUnits(inRate, inRateUnit) / 8
-		limits.InBurst = convertUnits(inBurst, inBurstUnit)
+	if err := m.shaper.SetEgress(iface, limits.Ingress); err != nil {
+		logger.Error("set-ingress-limit-failed", err)
+		return err
 	}
 
-	ingressOut := new(bytes.Buffer)
+	if err := m.shaper.SetIngress(iface, limits.Egress); err != nil {
+		logger.Error("set-egress-limit-failed", err)
+		return err
+	}
 
-	ingress := exec.Command(path.Join(m.containerPath, "net.sh"), "get_ingress_info")
-	ingress.Env = []string{"ID=" + m.containerID}
-	ingress.Stdout = ingressOut
+	return nil
+}
 
-	err = runner.Run(ingress)
+func (m *ContainerBandwidthManager) GetLimits(logger lager.Logger) (garden.ContainerBandwidthStat, error) {
+	iface, err := m.hostInterface()
 	if err != nil {
-		return limits, err
+		return garden.ContainerBandwidthStat{}, err
 	}
 
-	matches = OUT_RATE_PATTERN.FindStringSubmatch(string(ingressOut.Bytes()))
-	if matches != nil {
-		outRate, err := strconv.ParseUint(matches[1], 10, 0)
-		if err != nil {
-			return limits, err
-		}
-
-		outBurst, err := strconv.ParseUint(matches[3], 10, 0)
-		if err != nil {
-			return limits, err
-		}
-
-		outRateUnit := matches[2]
-		outBurstUnit := matches[4]
+	in, err := m.shaper.Egress(iface)
+	if err != nil {
+		logger.Error("get-ingress-limit-failed", err)
+		return garden.ContainerBandwidthStat{}, err
+	}
 
-		limits.OutRate = convertUnits(outRate, outRateUnit) / 8
-		limits.OutBurst = convertUnits(outBurst, outBurstUnit)
+	out, err := m.shaper.Ingress(iface)
+	if err != nil {
+		logger.Error("get-egress-limit-failed", err)
+		return garden.ContainerBandwidthStat{}, err
 	}
 
-	return limits, err
+	return garden.ContainerBandwidthStat{
+		InRate:   in.Rate,
+		InBurst:  in.Burst,
+		OutRate:  out.Rate,
+		OutBurst: out.Burst,
+	}, nil
 }
 
-func convertUnits(num uint64, unit string) uint64 {
-	switch unit {
-	case "K":
-		return num * 1024
-	case "M":
-		return num * (1024 ^ 2)
-	case "G":
-		return num * (1024 ^ 3)
-	default:
-		return num
+// hostInterface is the name of the host side of the container's veth pair,
+// which the setup script records in the container's config.
+func (m *ContainerBandwidthManager) hostInterface() (string, error) {
+	config, err := process.EnvFromFile(path.Join(m.containerPath, "etc", "config"))
+	if err != nil {
+		return "", err
 	}
+
+	return config["network_host_iface"], nil
 }
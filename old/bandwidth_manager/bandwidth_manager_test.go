@@ -2,223 +2,182 @@ package bandwidth_manager_test
 
 import (
 	"errors"
-	"fmt"
-	"os/exec"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	"github.com/pivotal-golang/lager/lagertest"
 
 	"github.com/cloudfoundry-incubator/garden"
+	"github.com/cloudfoundry-incubator/garden-linux/network/tc"
+	"github.com/cloudfoundry-incubator/garden-linux/network/tc/fakes"
 	"github.com/cloudfoundry-incubator/garden-linux/old/bandwidth_manager"
-	"github.com/cloudfoundry/gunk/command_runner/fake_command_runner"
-	. "github.com/cloudfoundry/gunk/command_runner/fake_command_runner/matchers"
 )
 
-var fakeRunner *fake_command_runner.FakeCommandRunner
+var fakeShaper *fakes.FakeShaper
 var logger *lagertest.TestLogger
+var containerPath string
 var bandwidthManager *bandwidth_manager.ContainerBandwidthManager
 
-var _ = Describe("setting rate limits", func() {
-	BeforeEach(func() {
-		fakeRunner = fake_command_runner.New()
-		logger = lagertest.NewTestLogger("test")
-		bandwidthManager = bandwidth_manager.New("/depot/some-id", "some-id", fakeRunner)
-	})
+var _ = BeforeEach(func() {
+	var err error
+	containerPath, err = ioutil.TempDir("", "depot")
+	Expect(err).ToNot(HaveOccurred())
+
+	Expect(os.Mkdir(filepath.Join(containerPath, "etc"), 0755)).To(Succeed())
+	Expect(ioutil.WriteFile(
+		filepath.Join(containerPath, "etc", "config"),
+		[]byte("id=some-id\nnetwork_host_iface=w1some-id-0\n"),
+		0644,
+	)).To(Succeed())
+
+	fakeShaper = new(fakes.FakeShaper)
+	logger = lagertest.NewTestLogger("test")
+	bandwidthManager = bandwidth_manager.New(containerPath, fakeShaper)
+})
 
-	It("executes net_rate.sh with the appropriate environment", func() {
-		limits := garden.BandwidthLimits{
+var _ = AfterEach(func() {
+	os.RemoveAll(containerPath)
+})
+
+var _ = Describe("setting rate limits", func() {
+	It("limits the traffic into and out of the container alike", func() {
+		err := bandwidthManager.SetLimits(logger, garden.BandwidthLimits{
 			RateInBytesPerSecond:      128,
 			BurstRateInBytesPerSecond: 256,
-		}
-
-		err := bandwidthManager.SetLimits(logger, limits)
+		})
 		Expect(err).ToNot(HaveOccurred())
 
-		Expect(fakeRunner).To(HaveExecutedSerially(
-			fake_command_runner.CommandSpec{
-				Path: "/depot/some-id/net_rate.sh",
-				Env: []string{
-					"BURST=256",
-					fmt.Sprintf("RATE=%d", 128*8),
-				},
-			},
-		))
+		Expect(fakeShaper.SetEgressCallCount()).To(Equal(1))
+		iface, limit := fakeShaper.SetEgressArgsForCall(0)
+		Expect(iface).To(Equal("w1some-id-0"))
+		Expect(limit).To(Equal(tc.TokenBucket{Rate: 128, Burst: 256}))
+
+		Expect(fakeShaper.SetIngressCallCount()).To(Equal(1))
+		iface, limit = fakeShaper.SetIngressArgsForCall(0)
+		Expect(iface).To(Equal("w1some-id-0"))
+		Expect(limit).To(Equal(tc.TokenBucket{Rate: 128, Burst: 256}))
+	})
+
+	Describe("with different limits for each direction", func() {
+		It("limits the traffic the host side of the veth pair sends to the container's ingress", func() {
+			err := bandwidthManager.SetDirectionalLimits(logger, bandwidth_manager.Limits{
+				Ingress: tc.TokenBucket{Rate: 1000, Burst: 2000},
+				Egress:  tc.TokenBucket{Rate: 3000, Burst: 4000},
+			})
+			Expect(err).ToNot(HaveOccurred())
+
+			_, limit := fakeShaper.SetEgressArgsForCall(0)
+			Expect(limit).To(Equal(tc.TokenBucket{Rate: 1000, Burst: 2000}))
+		})
+
+		It("limits the traffic the host side of the veth pair receives to the container's egress", func() {
+			err := bandwidthManager.SetDirectionalLimits(logger, bandwidth_manager.Limits{
+				Ingress: tc.TokenBucket{Rate: 1000, Burst: 2000},
+				Egress:  tc.TokenBucket{Rate: 3000, Burst: 4000},
+			})
+			Expect(err).ToNot(HaveOccurred())
+
+			_, limit := fakeShaper.SetIngressArgsForCall(0)
+			Expect(limit).To(Equal(tc.TokenBucket{Rate: 3000, Burst: 4000}))
+		})
 	})
 
-	Context("when net_rate.sh fails", func() {
+	Context("when limiting the traffic into the container fails", func() {
 		nastyError := errors.New("oh no!")
 
 		BeforeEach(func() {
-			fakeRunner.WhenRunning(
-				fake_command_runner.CommandSpec{
-					Path: "/depot/some-id/net_rate.sh",
-				}, func(*exec.Cmd) error {
-					return nastyError
-				},
-			)
+			fakeShaper.SetEgressReturns(nastyError)
 		})
 
-		It("returns the error", func() {
+		It("returns the error without limiting the traffic out of it", func() {
 			err := bandwidthManager.SetLimits(logger, garden.BandwidthLimits{
 				RateInBytesPerSecond:      128,
 				BurstRateInBytesPerSecond: 256,
 			})
 			Expect(err).To(Equal(nastyError))
+
+			Expect(fakeShaper.SetIngressCallCount()).To(Equal(0))
 		})
 	})
-})
 
-var _ = Describe("getting bandwidth limits", func() {
-	BeforeEach(func() {
-		fakeRunner = fake_command_runner.New()
-		logger = lagertest.NewTestLogger("test")
-		bandwidthManager = bandwidth_manager.New("/depot/some-id", "some-id", fakeRunner)
-	})
+	Context("when limiting the traffic out of the container fails", func() {
+		nastyError := errors.New("oh no!")
 
-	It("executes net.sh get_egress_info and get_ingress_info", func() {
-		fakeRunner.WhenRunning(fake_command_runner.CommandSpec{
-			Path: "/depot/some-id/net.sh",
-			Args: []string{"get_egress_info"},
-			Env:  []string{"ID=some-id"},
-		}, func(cmd *exec.Cmd) error {
-			cmd.Stdout.Write([]byte(`qdisc tbf 8010: root refcnt 2 rate 8192bit burst 64Kb lat 24.4ms
-qdisc ingress ffff: parent ffff:fff1 ----------------
-`))
-			return nil
+		BeforeEach(func() {
+			fakeShaper.SetIngressReturns(nastyError)
 		})
 
-		fakeRunner.WhenRunning(fake_command_runner.CommandSpec{
-			Path: "/depot/some-id/net.sh",
-			Args: []string{"get_ingress_info"},
-			Env:  []string{"ID=some-id"},
-		}, func(cmd *exec.Cmd) error {
-			cmd.Stdout.Write([]byte(`filter protocol ip pref 1 u32
-filter protocol ip pref 1 u32 fh 800: ht divisor 1
-filter protocol ip pref 1 u32 fh 800::800 order 2048 key ht 800 bkt 0 flowid :1
-  match 00000000/00000000 at 12
- police 0x10 rate 8192bit burst 64Kb mtu 2Kb action drop overhead 0b
-ref 1 bind 1
-`))
-			return nil
+		It("returns the error", func() {
+			err := bandwidthManager.SetLimits(logger, garden.BandwidthLimits{
+				RateInBytesPerSecond:      128,
+				BurstRateInBytesPerSecond: 256,
+			})
+			Expect(err).To(Equal(nastyError))
 		})
-
-		usage, err := bandwidthManager.GetLimits(logger)
-		Expect(err).ToNot(HaveOccurred())
-
-		Expect(usage.InRate).To(Equal(uint64(1024)))
-		Expect(usage.InBurst).To(Equal(uint64(65536)))
-
-		Expect(usage.OutRate).To(Equal(uint64(1024)))
-		Expect(usage.OutBurst).To(Equal(uint64(65536)))
 	})
 
-	Context("when net.sh get_egress_info fails", func() {
-		disaster := errors.New("oh no!")
-
+	Context("when the container's config cannot be read", func() {
 		BeforeEach(func() {
-			fakeRunner.WhenRunning(fake_command_runner.CommandSpec{
-				Path: "/depot/some-id/net.sh",
-				Args: []string{"get_egress_info"},
-				Env:  []string{"ID=some-id"},
-			}, func(*exec.Cmd) error {
-				return disaster
-			})
+			Expect(os.Remove(filepath.Join(containerPath, "etc", "config"))).To(Succeed())
 		})
 
-		It("returns the error", func() {
-			_, err := bandwidthManager.GetLimits(logger)
-			Expect(err).To(Equal(disaster))
+		It("returns an error without shaping anything", func() {
+			err := bandwidthManager.SetLimits(logger, garden.BandwidthLimits{})
+			Expect(err).To(HaveOccurred())
+
+			Expect(fakeShaper.SetEgressCallCount()).To(Equal(0))
+			Expect(fakeShaper.SetIngressCallCount()).To(Equal(0))
 		})
 	})
+})
 
-	Context("when net.sh get_egress_info output doesn't match", func() {
-		It("returns 0 limits and does not error", func() {
-			fakeRunner.WhenRunning(fake_command_runner.CommandSpec{
-				Path: "/depot/some-id/net.sh",
-				Args: []string{"get_egress_info"},
-				Env:  []string{"ID=some-id"},
-			}, func(cmd *exec.Cmd) error {
-				cmd.Stdout.Write([]byte(`qdisc pfifo_fast 0: root refcnt 2 bands 3 priomap  1 2 2 2 1 2 0 0 1 1 1 1 1 1 1 1
-`))
-				return nil
-			})
-
-			fakeRunner.WhenRunning(fake_command_runner.CommandSpec{
-				Path: "/depot/some-id/net.sh",
-				Args: []string{"get_ingress_info"},
-				Env:  []string{"ID=some-id"},
-			}, func(cmd *exec.Cmd) error {
-				cmd.Stdout.Write([]byte(`filter protocol ip pref 1 u32
-filter protocol ip pref 1 u32 fh 800: ht divisor 1
-filter protocol ip pref 1 u32 fh 800::800 order 2048 key ht 800 bkt 0 flowid :1
-  match 00000000/00000000 at 12
- police 0x10 rate 8192bit burst 64Kb mtu 2Kb action drop overhead 0b
-ref 1 bind 1
-`))
-				return nil
-			})
+var _ = Describe("getting bandwidth limits", func() {
+	BeforeEach(func() {
+		fakeShaper.EgressReturns(tc.TokenBucket{Rate: 1000, Burst: 2000}, nil)
+		fakeShaper.IngressReturns(tc.TokenBucket{Rate: 3000, Burst: 4000}, nil)
+	})
 
-			usage, err := bandwidthManager.GetLimits(logger)
-			Expect(err).ToNot(HaveOccurred())
+	It("returns the exact limits of the host side of the veth pair, from the container's point of view", func() {
+		limits, err := bandwidthManager.GetLimits(logger)
+		Expect(err).ToNot(HaveOccurred())
 
-			Expect(usage.InRate).To(Equal(uint64(0)))
-			Expect(usage.InBurst).To(Equal(uint64(0)))
+		Expect(fakeShaper.EgressArgsForCall(0)).To(Equal("w1some-id-0"))
+		Expect(fakeShaper.IngressArgsForCall(0)).To(Equal("w1some-id-0"))
 
-			Expect(usage.OutRate).To(Equal(uint64(1024)))
-			Expect(usage.OutBurst).To(Equal(uint64(65536)))
-		})
+		Expect(limits).To(Equal(garden.ContainerBandwidthStat{
+			InRate:   1000,
+			InBurst:  2000,
+			OutRate:  3000,
+			OutBurst: 4000,
+		}))
 	})
 
-	Context("when net.sh get_ingress_info fails", func() {
-		disaster := errors.New("oh no!")
+	Context("when reading the limit of the traffic into the container fails", func() {
+		nastyError := errors.New("oh no!")
 
 		BeforeEach(func() {
-			fakeRunner.WhenRunning(fake_command_runner.CommandSpec{
-				Path: "/depot/some-id/net.sh",
-				Args: []string{"get_ingress_info"},
-				Env:  []string{"ID=some-id"},
-			}, func(*exec.Cmd) error {
-				return disaster
-			})
+			fakeShaper.EgressReturns(tc.TokenBucket{}, nastyError)
 		})
 
 		It("returns the error", func() {
 			_, err := bandwidthManager.GetLimits(logger)
-			Expect(err).To(Equal(disaster))
+			Expect(err).To(Equal(nastyError))
 		})
 	})
 
-	Context("when net.sh get_ingress_info output doesn't match", func() {
-		It("returns 0 limits and does not error", func() {
-			fakeRunner.WhenRunning(fake_command_runner.CommandSpec{
-				Path: "/depot/some-id/net.sh",
-				Args: []string{"get_egress_info"},
-				Env:  []string{"ID=some-id"},
-			}, func(cmd *exec.Cmd) error {
-				cmd.Stdout.Write([]byte(`qdisc tbf 8010: root refcnt 2 rate 8192bit burst 64Kb lat 24.4ms
-qdisc ingress ffff: parent ffff:fff1 ----------------
-`))
-				return nil
-			})
-
-			fakeRunner.WhenRunning(fake_command_runner.CommandSpec{
-				Path: "/depot/some-id/net.sh",
-				Args: []string{"get_ingress_info"},
-				Env:  []string{"ID=some-id"},
-			}, func(cmd *exec.Cmd) error {
-				cmd.Stdout.Write([]byte(``))
-				return nil
-			})
-
-			usage, err := bandwidthManager.GetLimits(logger)
-			Expect(err).ToNot(HaveOccurred())
+	Context("when reading the limit of the traffic out of the container fails", func() {
+		nastyError := errors.New("oh no!")
 
-			Expect(usage.InRate).To(Equal(uint64(1024)))
-			Expect(usage.InBurst).To(Equal(uint64(65536)))
+		BeforeEach(func() {
+			fakeShaper.IngressReturns(tc.TokenBucket{}, nastyError)
+		})
 
-			Expect(usage.OutRate).To(Equal(uint64(0)))
-			Expect(usage.OutBurst).To(Equal(uint64(0)))
+		It("returns the error", func() {
+			_, err := bandwidthManager.GetLimits(logger)
+			Expect(err).To(Equal(nastyError))
 		})
 	})
 })
@@ -2,6 +2,7 @@ package fake_bandwidth_manager
 
 import (
 	"github.com/cloudfoundry-incubator/garden"
+	"github.com/cloudfoundry-incubator/garden-linux/old/bandwidth_manager"
 	"github.com/pivotal-golang/lager"
 )
 
@@ -9,6 +10,9 @@ type FakeBandwidthManager struct {
 	SetLimitsError error
 	EnforcedLimits []garden.BandwidthLimits
 
+	SetDirectionalLimitsError error
+	EnforcedDirectionalLimits []bandwidth_manager.Limits
+
 	GetLimitsError  error
 	GetLimitsResult garden.ContainerBandwidthStat
 }
@@ -27,6 +31,16 @@ func (m *FakeBandwidthManager) SetLimits(logger lager.Logger, limits garden.Band
 	return nil
 }
 
+func (m *FakeBandwidthManager) SetDirectionalLimits(logger lager.Logger, limits bandwidth_manager.Limits) error {
+	if m.SetDirectionalLimitsError != nil {
+		return m.SetDirectionalLimitsError
+	}
+
+	m.EnforcedDirectionalLimits = append(m.EnforcedDirectionalLimits, limits)
+
+	return nil
+}
+
 func (m *FakeBandwidthManager) GetLimits(logger lager.Logger) (garden.ContainerBandwidthStat, error) {
 	if m.GetLimitsError != nil {
 		return garden.ContainerBandwidthStat{}, m.GetLimitsError
@@ -0,0 +1,188 @@
+// The debug_server package extends the cf_debug_server endpoints with dumps of
+// the allocation state of the server's pools, and with the network counters of
+// its containers, which the garden API has no fields for, and with their
+// lifecycle events.
+package debug_server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/cloudfoundry-incubator/cf-debug-server"
+	"github.com/cloudfoundry-incubator/garden"
+	"github.com/cloudfoundry-incubator/garden-linux/linux_backend"
+	"github.com/cloudfoundry-incubator/garden-linux/network/bridgemgr"
+	"github.com/cloudfoundry-incubator/garden-linux/network/subnets"
+	"github.com/cloudfoundry-incubator/garden-linux/old/port_pool"
+	"github.com/pivotal-golang/lager"
+	"github.com/tedsuo/ifrit"
+	"github.com/tedsuo/ifrit/http_server"
+)
+
+const PoolsPath = "/debug/pools"
+
+// NetworkMetricsPath serves the network counters of the containers whose
+// handles are given as handle query parameters, keyed by handle.
+const NetworkMetricsPath = "/debug/network-metrics"
+
+// Pools are the pools whose inventories are served.
+type Pools struct {
+	Subnets subnets.Subnets
+	Ports   *port_pool.PortPool
+	Bridges bridgemgr.BridgeManager
+}
+
+// PoolsInventory is the JSON document served at PoolsPath.
+type PoolsInventory struct {
+	Subnets subnets.Inventory
+	Ports   port_pool.Inventory
+	Bridges bridgemgr.Inventory
+}
+
+// EventsPath serves the recent lifecycle events of containers as a JSON
+// list, oldest first. Given follow=true, it instead streams the events which
+// follow the request, one JSON object per line, until the client goes away.
+// The events are limited to the container with the handle query parameter,
+// and to the containers with every property given as a property=key:value
+// query parameter.
+const EventsPath = "/debug/events"
+
+//go:generate counterfeiter -o fakes/fake_backend.go . Backend
+
+// Backend is the part of the backend whose state is served.
+type Backend interface {
+	BulkNetworkMetrics(handles []string) (map[string]linux_backend.ContainerNetworkMetricsEntry, error)
+}
+
+// Events is the part of the event bus whose events are served.
+type Events interface {
+	Recent(linux_backend.EventFilter) []linux_backend.ContainerEvent
+	Subscribe(linux_backend.EventFilter) *linux_backend.EventSubscription
+	Unsubscribe(*linux_backend.EventSubscription)
+}
+
+func Run(address string, sink *lager.ReconfigurableSink, pools Pools, backend Backend, events Events) error {
+	p := ifrit.Invoke(http_server.New(address, Handler(sink, pools, backend, events)))
+	select {
+	case <-p.Ready():
+	case err := <-p.Wait():
+		return err
+	}
+	return nil
+}
+
+// Handler serves the cf_debug_server endpoints, the inventories of the
+// pools at PoolsPath, the network counters at NetworkMetricsPath, and the
+// lifecycle events at EventsPath.
+func Handler(sink *lager.ReconfigurableSink, pools Pools, backend Backend, events Events) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/", cf_debug_server.Handler(sink))
+	mux.Handle(PoolsPath, PoolsHandler(pools))
+	mux.Handle(NetworkMetricsPath, NetworkMetricsHandler(backend))
+	mux.Handle(EventsPath, EventsHandler(events))
+
+	return mux
+}
+
+func PoolsHandler(pools Pools) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(PoolsInventory{
+			Subnets: pools.Subnets.Inventory(),
+			Ports:   pools.Ports.Inventory(),
+			Bridges: pools.Bridges.Inventory(),
+		})
+	})
+}
+
+func NetworkMetricsHandler(backend Backend) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		metrics, err := backend.BulkNetworkMetrics(r.URL.Query()["handle"])
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(metrics)
+	})
+}
+
+func EventsHandler(events Events) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		query := r.URL.Query()
+
+		filter := linux_backend.EventFilter{
+			Handle:     query.Get("handle"),
+			Properties: garden.Properties{},
+		}
+
+		for _, property := range query["property"] {
+			kv := strings.SplitN(property, ":", 2)
+			if len(kv) != 2 {
+				http.Error(w, "property must be given as key:value: "+property, http.StatusBadRequest)
+				return
+			}
+
+			filter.Properties[kv[0]] = kv[1]
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+
+		if query.Get("follow") != "true" {
+			json.NewEncoder(w).Encode(events.Recent(filter))
+			return
+		}
+
+		flusher, canFlush := w.(http.Flusher)
+		closeNotifier, canNotify := w.(http.CloseNotifier)
+		if !canFlush || !canNotify {
+			http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+			return
+		}
+
+		closed := closeNotifier.CloseNotify()
+
+		subscription := events.Subscribe(filter)
+		defer events.Unsubscribe(subscription)
+
+		w.WriteHeader(http.StatusOK)
+		flusher.Flush()
+
+		encoder := json.NewEncoder(w)
+
+		for {
+			select {
+			case event, ok := <-subscription.Events:
+				if !ok {
+					return
+				}
+
+				if err := encoder.Encode(event); err != nil {
+					return
+				}
+
+				flusher.Flush()
+
+			case <-closed:
+				return
+			}
+		}
+	})
+}
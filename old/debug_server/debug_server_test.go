@@ -0,0 +1,283 @@
+package debug_server_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/cloudfoundry-incubator/garden"
+	"github.com/cloudfoundry-incubator/garden-linux/linux_backend"
+	"github.com/cloudfoundry-incubator/garden-linux/network"
+	"github.com/cloudfoundry-incubator/garden-linux/network/bridgemgr"
+	"github.com/cloudfoundry-incubator/garden-linux/network/bridgemgr/fake_bridge_manager"
+	"github.com/cloudfoundry-incubator/garden-linux/network/subnets"
+	"github.com/cloudfoundry-incubator/garden-linux/old/debug_server"
+	"github.com/cloudfoundry-incubator/garden-linux/old/debug_server/fakes"
+	"github.com/cloudfoundry-incubator/garden-linux/old/port_pool"
+	"github.com/pivotal-golang/lager"
+	"github.com/pivotal-golang/lager/lagertest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("DebugServer", func() {
+	var handler http.Handler
+	var fakeBackend *fakes.FakeBackend
+	var eventBus *linux_backend.EventBus
+
+	BeforeEach(func() {
+		_, dynamicRange, err := net.ParseCIDR("10.2.0.0/29")
+		Expect(err).ToNot(HaveOccurred())
+
+		subnetPool, err := subnets.NewSubnets(dynamicRange)
+		Expect(err).ToNot(HaveOccurred())
+
+		_, err = subnetPool.Acquire(subnets.DynamicSubnetSelector, subnets.DynamicIPSelector)
+		Expect(err).ToNot(HaveOccurred())
+
+		portPool := port_pool.New(61000, 3)
+		_, err = portPool.Acquire()
+		Expect(err).ToNot(HaveOccurred())
+
+		bridges := new(fake_bridge_manager.FakeBridgeManager)
+		bridges.InventoryReturns(bridgemgr.Inventory{
+			Bridges: map[string]bridgemgr.Reservation{
+				"wb-1": {Subnet: "10.2.0.0/30", ContainerIDs: []string{"some-id"}},
+			},
+		})
+
+		sink := lager.NewReconfigurableSink(lagertest.NewTestSink(), lager.INFO)
+
+		fakeBackend = new(fakes.FakeBackend)
+		eventBus = linux_backend.NewEventBus(10)
+
+		handler = debug_server.Handler(sink, debug_server.Pools{
+			Subnets: subnetPool,
+			Ports:   portPool,
+			Bridges: bridges,
+		}, fakeBackend, eventBus)
+	})
+
+	Describe(debug_server.PoolsPath, func() {
+		It("dumps the inventories of the pools as JSON", func() {
+			request, err := http.NewRequest("GET", debug_server.PoolsPath, nil)
+			Expect(err).ToNot(HaveOccurred())
+
+			response := httptest.NewRecorder()
+			handler.ServeHTTP(response, request)
+
+			Expect(response.Code).To(Equal(http.StatusOK))
+			Expect(response.Header().Get("Content-Type")).To(Equal("application/json"))
+
+			var inventory debug_server.PoolsInventory
+			Expect(json.NewDecoder(response.Body).Decode(&inventory)).To(Succeed())
+
+			Expect(inventory.Subnets.DynamicRanges).To(Equal([]string{"10.2.0.0/29"}))
+			Expect(inventory.Subnets.Allocated).To(HaveLen(1))
+
+			Expect(inventory.Ports.Free).To(Equal([]uint32{61001, 61002}))
+			Expect(inventory.Ports.Acquired).To(Equal([]uint32{61000}))
+
+			Expect(inventory.Bridges.Bridges).To(HaveKeyWithValue(
+				"wb-1",
+				bridgemgr.Reservation{Subnet: "10.2.0.0/30", ContainerIDs: []string{"some-id"}},
+			))
+		})
+
+		It("rejects anything other than GET", func() {
+			request, err := http.NewRequest("POST", debug_server.PoolsPath, nil)
+			Expect(err).ToNot(HaveOccurred())
+
+			response := httptest.NewRecorder()
+			handler.ServeHTTP(response, request)
+
+			Expect(response.Code).To(Equal(http.StatusMethodNotAllowed))
+		})
+	})
+
+	Describe(debug_server.NetworkMetricsPath, func() {
+		It("dumps the network counters of the containers with the given handles as JSON", func() {
+			fakeBackend.BulkNetworkMetricsReturns(map[string]linux_backend.ContainerNetworkMetricsEntry{
+				"handle-a": {
+					Metrics: linux_backend.NetworkMetrics{
+						Interface: network.InterfaceStats{RxBytes: 1, TxBytes: 2},
+					},
+				},
+				"handle-b": {
+					Err: garden.NewError("oh no!"),
+				},
+			}, nil)
+
+			request, err := http.NewRequest("GET", debug_server.NetworkMetricsPath+"?handle=handle-a&handle=handle-b", nil)
+			Expect(err).ToNot(HaveOccurred())
+
+			response := httptest.NewRecorder()
+			handler.ServeHTTP(response, request)
+
+			Expect(response.Code).To(Equal(http.StatusOK))
+			Expect(response.Header().Get("Content-Type")).To(Equal("application/json"))
+
+			Expect(fakeBackend.BulkNetworkMetricsArgsForCall(0)).To(Equal([]string{"handle-a", "handle-b"}))
+
+			var metrics map[string]linux_backend.ContainerNetworkMetricsEntry
+			Expect(json.NewDecoder(response.Body).Decode(&metrics)).To(Succeed())
+
+			Expect(metrics["handle-a"].Metrics.Interface).To(Equal(network.InterfaceStats{RxBytes: 1, TxBytes: 2}))
+			Expect(metrics["handle-b"].Err).To(HaveOccurred())
+		})
+
+		Context("when the backend fails", func() {
+			BeforeEach(func() {
+				fakeBackend.BulkNetworkMetricsReturns(nil, errors.New("oh no!"))
+			})
+
+			It("responds with an internal server error", func() {
+				request, err := http.NewRequest("GET", debug_server.NetworkMetricsPath, nil)
+				Expect(err).ToNot(HaveOccurred())
+
+				response := httptest.NewRecorder()
+				handler.ServeHTTP(response, request)
+
+				Expect(response.Code).To(Equal(http.StatusInternalServerError))
+			})
+		})
+
+		It("rejects anything other than GET", func() {
+			request, err := http.NewRequest("POST", debug_server.NetworkMetricsPath, nil)
+			Expect(err).ToNot(HaveOccurred())
+
+			response := httptest.NewRecorder()
+			handler.ServeHTTP(response, request)
+
+			Expect(response.Code).To(Equal(http.StatusMethodNotAllowed))
+		})
+	})
+
+	Describe(debug_server.EventsPath, func() {
+		BeforeEach(func() {
+			eventBus.Emit(linux_backend.ContainerEvent{
+				Type:       linux_backend.EventCreated,
+				Handle:     "handle-a",
+				Properties: garden.Properties{"owner": "me"},
+			})
+
+			eventBus.Emit(linux_backend.ContainerEvent{
+				Type:       linux_backend.EventCreated,
+				Handle:     "handle-b",
+				Properties: garden.Properties{"owner": "you"},
+			})
+
+			eventBus.Emit(linux_backend.ContainerEvent{
+				Type:   linux_backend.EventStarted,
+				Handle: "handle-a",
+			})
+		})
+
+		recentEvents := func(query string) []linux_backend.ContainerEvent {
+			request, err := http.NewRequest("GET", debug_server.EventsPath+query, nil)
+			Expect(err).ToNot(HaveOccurred())
+
+			response := httptest.NewRecorder()
+			handler.ServeHTTP(response, request)
+
+			Expect(response.Code).To(Equal(http.StatusOK))
+			Expect(response.Header().Get("Content-Type")).To(Equal("application/json"))
+
+			var events []linux_backend.ContainerEvent
+			Expect(json.NewDecoder(response.Body).Decode(&events)).To(Succeed())
+
+			return events
+		}
+
+		It("dumps the recent events as JSON, oldest first", func() {
+			events := recentEvents("")
+			Expect(events).To(HaveLen(3))
+			Expect(events[0].Handle).To(Equal("handle-a"))
+			Expect(events[1].Handle).To(Equal("handle-b"))
+			Expect(events[2].Type).To(Equal(linux_backend.EventStarted))
+		})
+
+		It("dumps only the events of the given handle", func() {
+			events := recentEvents("?handle=handle-b")
+			Expect(events).To(HaveLen(1))
+			Expect(events[0].Handle).To(Equal("handle-b"))
+		})
+
+		It("dumps only the events of containers with the given properties", func() {
+			events := recentEvents("?property=owner:me")
+			Expect(events).To(HaveLen(1))
+			Expect(events[0].Handle).To(Equal("handle-a"))
+		})
+
+		It("rejects properties which are not key:value", func() {
+			request, err := http.NewRequest("GET", debug_server.EventsPath+"?property=owner", nil)
+			Expect(err).ToNot(HaveOccurred())
+
+			response := httptest.NewRecorder()
+			handler.ServeHTTP(response, request)
+
+			Expect(response.Code).To(Equal(http.StatusBadRequest))
+		})
+
+		Context("when following", func() {
+			var server *httptest.Server
+
+			BeforeEach(func() {
+				server = httptest.NewServer(handler)
+			})
+
+			AfterEach(func() {
+				server.Close()
+			})
+
+			It("streams the matching events which follow, one per line", func() {
+				response, err := http.Get(server.URL + debug_server.EventsPath + "?follow=true&handle=handle-a")
+				Expect(err).ToNot(HaveOccurred())
+				defer response.Body.Close()
+
+				Expect(response.StatusCode).To(Equal(http.StatusOK))
+
+				eventBus.Emit(linux_backend.ContainerEvent{Type: linux_backend.EventStopped, Handle: "handle-b"})
+				eventBus.Emit(linux_backend.ContainerEvent{Type: linux_backend.EventStopped, Handle: "handle-a"})
+				eventBus.Emit(linux_backend.ContainerEvent{Type: linux_backend.EventDestroyed, Handle: "handle-a"})
+
+				lines := bufio.NewScanner(response.Body)
+
+				var event linux_backend.ContainerEvent
+
+				Expect(lines.Scan()).To(BeTrue())
+				Expect(json.Unmarshal(lines.Bytes(), &event)).To(Succeed())
+				Expect(event.Type).To(Equal(linux_backend.EventStopped))
+				Expect(event.Handle).To(Equal("handle-a"))
+
+				Expect(lines.Scan()).To(BeTrue())
+				Expect(json.Unmarshal(lines.Bytes(), &event)).To(Succeed())
+				Expect(event.Type).To(Equal(linux_backend.EventDestroyed))
+			})
+		})
+
+		It("rejects anything other than GET", func() {
+			request, err := http.NewRequest("POST", debug_server.EventsPath, nil)
+			Expect(err).ToNot(HaveOccurred())
+
+			response := httptest.NewRecorder()
+			handler.ServeHTTP(response, request)
+
+			Expect(response.Code).To(Equal(http.StatusMethodNotAllowed))
+		})
+	})
+
+	It("still serves the cf_debug_server endpoints", func() {
+		request, err := http.NewRequest("GET", "/debug/pprof/", nil)
+		Expect(err).ToNot(HaveOccurred())
+
+		response := httptest.NewRecorder()
+		handler.ServeHTTP(response, request)
+
+		Expect(response.Code).To(Equal(http.StatusOK))
+	})
+})
@@ -0,0 +1,56 @@
+// This file was generated by counterfeiter
+package fakes
+
+import (
+	"sync"
+
+	"github.com/cloudfoundry-incubator/garden-linux/linux_backend"
+	"github.com/cloudfoundry-incubator/garden-linux/old/debug_server"
+)
+
+type FakeBackend struct {
+	BulkNetworkMetricsStub        func(handles []string) (map[string]linux_backend.ContainerNetworkMetricsEntry, error)
+	bulkNetworkMetricsMutex       sync.RWMutex
+	bulkNetworkMetricsArgsForCall []struct {
+		handles []string
+	}
+	bulkNetworkMetricsReturns struct {
+		result1 map[string]linux_backend.ContainerNetworkMetricsEntry
+		result2 error
+	}
+}
+
+func (fake *FakeBackend) BulkNetworkMetrics(handles []string) (map[string]linux_backend.ContainerNetworkMetricsEntry, error) {
+	fake.bulkNetworkMetricsMutex.Lock()
+	fake.bulkNetworkMetricsArgsForCall = append(fake.bulkNetworkMetricsArgsForCall, struct {
+		handles []string
+	}{handles})
+	fake.bulkNetworkMetricsMutex.Unlock()
+	if fake.BulkNetworkMetricsStub != nil {
+		return fake.BulkNetworkMetricsStub(handles)
+	} else {
+		return fake.bulkNetworkMetricsReturns.result1, fake.bulkNetworkMetricsReturns.result2
+	}
+}
+
+func (fake *FakeBackend) BulkNetworkMetricsCallCount() int {
+	fake.bulkNetworkMetricsMutex.RLock()
+	defer fake.bulkNetworkMetricsMutex.RUnlock()
+	return len(fake.bulkNetworkMetricsArgsForCall)
+}
+
+func (fake *FakeBackend) BulkNetworkMetricsArgsForCall(i int) []string {
+	fake.bulkNetworkMetricsMutex.RLock()
+	defer fake.bulkNetworkMetricsMutex.RUnlock()
+	return fake.bulkNetworkMetricsArgsForCall[i].handles
+}
+
+func (fake *FakeBackend) BulkNetworkMetricsReturns(result1 map[string]linux_backend.ContainerNetworkMetricsEntry, result2 error) {
+	fake.BulkNetworkMetricsStub = nil
+	fake.bulkNetworkMetricsReturns = struct {
+		result1 map[string]linux_backend.ContainerNetworkMetricsEntry
+		result2 error
+	}{result1, result2}
+}
+
+var _ debug_server.Backend = new(FakeBackend)
@@ -117,6 +117,81 @@ function setup_filter() {
   iptables -w -I ${filter_forward_chain} -i $default_interface --jump ACCEPT
 }
 
+function teardown_filter_ipv6() {
+  # Prune garden-forward chain
+  ip6tables -w -S ${filter_forward_chain} 2> /dev/null |
+    grep "\-g ${filter_instance_prefix}" |
+    sed -e "s/-A/-D/" -e "s/\s\+\$//" |
+    xargs --no-run-if-empty --max-lines=1 ip6tables -w
+
+  # Prune per-instance chains
+  ip6tables -w -S 2> /dev/null |
+    grep "^-A ${filter_instance_prefix}" |
+    sed -e "s/-A/-D/" -e "s/\s\+\$//" |
+    xargs --no-run-if-empty --max-lines=1 ip6tables -w
+
+  # Delete per-instance chains
+  ip6tables -w -S 2> /dev/null |
+    grep "^-N ${filter_instance_prefix}" |
+    sed -e "s/-N/-X/" -e "s/\s\+\$//" |
+    xargs --no-run-if-empty --max-lines=1 ip6tables -w
+
+  # Remove jump to garden-forward from FORWARD
+  ip6tables -w -S FORWARD 2> /dev/null |
+    grep " -j ${filter_forward_chain}" |
+    sed -e "s/-A/-D/" -e "s/\s\+\$//" |
+    xargs --no-run-if-empty --max-lines=1 ip6tables -w
+
+  ip6tables -w -F ${filter_forward_chain} 2> /dev/null || true
+  ip6tables -w -F ${filter_default_chain} 2> /dev/null || true
+
+  # Remove jump to filter input chain from INPUT
+  ip6tables -w -S INPUT 2> /dev/null |
+    grep " -j ${filter_input_chain}" |
+    sed -e "s/-A/-D/" -e "s/\s\+\$//" |
+    xargs --no-run-if-empty --max-lines=1 ip6tables -w
+
+  # Empty and delete filter input chain
+  ip6tables -w -F ${filter_input_chain} 2> /dev/null || true
+  ip6tables -w -X ${filter_input_chain} 2> /dev/null || true
+}
+
+# Mirrors setup_filter for dual-stack containers. IPv6 traffic is routed rather
+# than NATed, so there is no IPv6 equivalent of setup_nat.
+function setup_filter_ipv6() {
+  teardown_filter_ipv6
+
+  default_interface=$(ip -6 route show | grep default | cut -d' ' -f5 | head -1)
+
+  ip6tables -w -N ${filter_input_chain} 2> /dev/null || ip6tables -w -F ${filter_input_chain}
+
+  if [ -n "$default_interface" ]; then
+    ip6tables -w -I ${filter_input_chain} -i $default_interface --jump ACCEPT
+  fi
+
+  ip6tables -w -A ${filter_input_chain} -m conntrack --ctstate ESTABLISHED,RELATED --jump ACCEPT
+
+  if [ "${GARDEN_IPTABLES_ALLOW_HOST_ACCESS}" != "true" ]; then
+    ip6tables -w -A ${filter_input_chain} --jump REJECT --reject-with icmp6-adm-prohibited
+  else
+    ip6tables -w -A ${filter_input_chain} --jump ACCEPT
+  fi
+
+  ip6tables -w -A INPUT -i ${GARDEN_NETWORK_INTERFACE_PREFIX}+ --jump ${filter_input_chain}
+
+  ip6tables -w -N ${filter_forward_chain} 2> /dev/null || ip6tables -w -F ${filter_forward_chain}
+  ip6tables -w -A ${filter_forward_chain} -j DROP
+
+  ip6tables -w -N ${filter_default_chain} 2> /dev/null || ip6tables -w -F ${filter_default_chain}
+  ip6tables -w -A ${filter_default_chain} -m conntrack --ctstate ESTABLISHED,RELATED -j ACCEPT
+
+  ip6tables -w -A FORWARD -i ${GARDEN_NETWORK_INTERFACE_PREFIX}+ --jump ${filter_forward_chain}
+
+  if [ -n "$default_interface" ]; then
+    ip6tables -w -I ${filter_forward_chain} -i $default_interface --jump ACCEPT
+  fi
+}
+
 function teardown_nat() {
   # Prune prerouting chain
   iptables -w -t nat -S ${nat_prerouting_chain} 2> /dev/null |
@@ -176,10 +251,19 @@ case "${1}" in
 
     # Enable forwarding
     echo 1 > /proc/sys/net/ipv4/ip_forward
+
+    if [ "${GARDEN_NETWORK_IPV6:-false}" = "true" ]; then
+      setup_filter_ipv6
+      echo 1 > /proc/sys/net/ipv6/conf/all/forwarding
+    fi
     ;;
   teardown)
     teardown_filter
     teardown_nat
+
+    if [ "${GARDEN_NETWORK_IPV6:-false}" = "true" ]; then
+      teardown_filter_ipv6
+    fi
     ;;
   *)
     echo "Unknown command: ${1}" 1>&2
@@ -9,8 +9,6 @@ cd $(dirname $0)
 
 source ./etc/config
 
-./net.sh teardown
-
 cgroup_path="${GARDEN_CGROUP_PATH}"
 
 if [ -f ./run/wshd.pid ]
@@ -21,6 +19,14 @@ then
   path=${cgroup_path}/cpu/instance-$id
   tasks=$path/tasks
 
+  # A paused container must be thawed for its tasks to die.
+  freezer_state=${cgroup_path}/freezer/instance-$id/freezer.state
+
+  if [ -f $freezer_state ]
+  then
+    echo THAWED > $freezer_state
+  fi
+
   if [ -d $path ]
   then
     # Kill the container's init pid; the kernel will reap all tasks.
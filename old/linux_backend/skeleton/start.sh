@@ -15,8 +15,6 @@ then
   exit 1
 fi
 
-./net.sh setup
-
 if [ "$root_uid" -eq 0 ]
 then
   ./bin/wshd --run ./run --lib ./lib --root $rootfs_path --title "wshd: $id" --userns disabled
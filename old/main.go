@@ -11,6 +11,7 @@ import (
 	"runtime"
 	"strings"
 	"syscall"
+	"time"
 
 	"github.com/cloudfoundry/gunk/command_runner"
 	"github.com/cloudfoundry/gunk/localip"
@@ -31,7 +32,10 @@ import (
 	"github.com/cloudfoundry-incubator/garden-linux/network/bridgemgr"
 	"github.com/cloudfoundry-incubator/garden-linux/network/devices"
 	"github.com/cloudfoundry-incubator/garden-linux/network/iptables"
+	"github.com/cloudfoundry-incubator/garden-linux/network/nflog"
 	"github.com/cloudfoundry-incubator/garden-linux/network/subnets"
+	"github.com/cloudfoundry-incubator/garden-linux/network/tc"
+	"github.com/cloudfoundry-incubator/garden-linux/old/debug_server"
 	"github.com/cloudfoundry-incubator/garden-linux/old/port_pool"
 	"github.com/cloudfoundry-incubator/garden-linux/old/quota_manager"
 	"github.com/cloudfoundry-incubator/garden-linux/old/repository_fetcher"
@@ -66,6 +70,18 @@ var snapshotsPath = flag.String(
 	"directory in which to store container state to persist through restarts",
 )
 
+var journalPath = flag.String(
+	"journal",
+	"",
+	"file in which to journal container creation and destruction to re-adopt containers after a crash",
+)
+
+var eventHistorySize = flag.Int(
+	"eventHistory",
+	1000,
+	"number of recent container lifecycle events to keep for replay",
+)
+
 var binPath = flag.String(
 	"bin",
 	"",
@@ -122,7 +138,15 @@ var uidMappingOffset = flag.Int(
 
 var networkPool = flag.String("networkPool",
 	DefaultNetworkPool,
-	"Pool of dynamically allocated container subnets")
+	"Pool of dynamically allocated container subnets; a comma-separated list of CIDR blocks")
+
+var networkPoolStrategy = flag.String("networkPoolStrategy",
+	"first-fit",
+	"how dynamically allocated container subnets are chosen, one of 'first-fit', 'random' or 'least-recently-released'")
+
+var networkPoolIPv6 = flag.String("networkPoolIPv6",
+	"",
+	"Pool of dynamically allocated IPv6 container subnets; if set, containers are dual-stack")
 
 var denyNetworks = flag.String(
 	"denyNetworks",
@@ -184,6 +208,12 @@ var iptablesLogMethod = flag.String(
 	"type of iptable logging to use, one of 'kernel' or 'nflog' (default: kernel)",
 )
 
+var logNetOutConnections = flag.Bool(
+	"logNetOutConnections",
+	false,
+	"log the connections logged by containers' NetOut rules; requires -iptablesLogMethod=nflog",
+)
+
 var mtu = flag.Int(
 	"mtu",
 	DefaultMTUSize,
@@ -194,6 +224,11 @@ var externalIP = flag.String(
 	"",
 	"IP address to use to reach container's mapped ports")
 
+var additionalExternalIPs = flag.String(
+	"additionalExternalIPs",
+	"",
+	"comma-separated list of further IPv4 addresses of the host which container ports may be mapped on")
+
 func Main() {
 
 	cf_debug_server.AddFlags(flag.CommandLine)
@@ -203,9 +238,6 @@ func Main() {
 	runtime.GOMAXPROCS(runtime.NumCPU())
 
 	logger, reconfigurableSink := cf_lager.New("garden-linux")
-	if dbgAddr := cf_debug_server.DebugAddress(flag.CommandLine); dbgAddr != "" {
-		cf_debug_server.Run(dbgAddr, reconfigurableSink)
-	}
 
 	initializeDropsonde(logger)
 
@@ -228,8 +260,51 @@ func Main() {
 		return
 	}
 
-	_, dynamicRange, _ := net.ParseCIDR(*networkPool)
-	subnetPool, _ := subnets.NewSubnets(dynamicRange)
+	if *eventHistorySize < 0 {
+		println("-eventHistory parameter must not be negative")
+		println()
+		flag.Usage()
+		return
+	}
+
+	var dynamicRanges []*net.IPNet
+	for _, cidr := range strings.Split(*networkPool, ",") {
+		_, dynamicRange, err := net.ParseCIDR(strings.TrimSpace(cidr))
+		if err != nil {
+			logger.Fatal("invalid-network-pool", err)
+		}
+
+		dynamicRanges = append(dynamicRanges, dynamicRange)
+	}
+
+	var strategy subnets.Strategy
+	switch *networkPoolStrategy {
+	case "first-fit":
+		strategy = subnets.FirstFitStrategy
+	case "random":
+		strategy = subnets.NewRandomStrategy(time.Now().UnixNano())
+	case "least-recently-released":
+		strategy = subnets.NewLeastRecentlyReleasedStrategy()
+	default:
+		println("-networkPoolStrategy value not recognized")
+		println()
+		flag.Usage()
+		return
+	}
+
+	var dynamicIPv6Range *net.IPNet
+	if *networkPoolIPv6 != "" {
+		var err error
+		_, dynamicIPv6Range, err = net.ParseCIDR(*networkPoolIPv6)
+		if err != nil {
+			logger.Fatal("invalid-ipv6-network-pool", err)
+		}
+	}
+
+	subnetPool, err := subnets.NewSubnetsFromRanges(dynamicRanges, dynamicIPv6Range, strategy)
+	if err != nil {
+		logger.Fatal("invalid-network-pool", err)
+	}
 
 	// TODO: use /proc/sys/net/ipv4/ip_local_port_range by default (end + 1)
 	portPool := port_pool.New(uint32(*portPoolStart), uint32(*portPoolSize))
@@ -247,10 +322,39 @@ func Main() {
 		return
 	}
 
+	if *logNetOutConnections {
+		if useKernelLogging {
+			println("-logNetOutConnections requires -iptablesLogMethod=nflog")
+			println()
+			flag.Usage()
+			return
+		}
+
+		socket, err := nflog.Dial(iptables.NFLogGroup)
+		if err != nil {
+			logger.Fatal("failed-to-listen-for-netout-connections", err)
+		}
+
+		go func() {
+			err := nflog.NewListener(socket, logger.Session("netout-connections")).Listen()
+			logger.Error("netout-connections-listener-exited", err)
+		}()
+	}
+
 	config := sysconfig.NewConfig(*tag, *allowHostAccess)
+	config.NetworkIPv6 = *networkPoolIPv6 != ""
 
 	runner := sysconfig.NewRunner(config, linux_command_runner.New())
 
+	restoreWait, err := iptables.DetectRestoreWait(runner)
+	if err != nil {
+		logger.Error("failed-to-detect-iptables-restore-wait", err)
+	}
+
+	iptables.UseRestoreWait(restoreWait)
+
+	logger.Info("iptables-restore-wait", lager.Data{"supported": restoreWait})
+
 	quotaManager := quota_manager.New(runner, getMountPoint(logger, *depotPath), *binPath)
 
 	if *disableQuotas {
@@ -308,9 +412,16 @@ func Main() {
 
 	filterProvider := &provider{
 		useKernelLogging: useKernelLogging,
+		ipv6:             config.NetworkIPv6,
 		chainPrefix:      config.IPTables.Filter.InstancePrefix,
-		runner:           runner,
-		log:              logger,
+		globalChains: iptables.GlobalChains{
+			ForwardChain:     config.IPTables.Filter.ForwardChain,
+			DefaultChain:     config.IPTables.Filter.DefaultChain,
+			PreroutingChain:  config.IPTables.NAT.PreroutingChain,
+			PostroutingChain: config.IPTables.NAT.PostroutingChain,
+		},
+		runner: runner,
+		log:    logger,
 	}
 
 	if *externalIP == "" {
@@ -327,6 +438,32 @@ func Main() {
 		panic(fmt.Sprintf("Value of -externalIP %s could not be converted to an IP", *externalIP))
 	}
 
+	parsedAdditionalExternalIPs := []net.IP{}
+	for _, ipStr := range strings.Split(*additionalExternalIPs, ",") {
+		if ipStr == "" {
+			continue
+		}
+
+		ip := net.ParseIP(ipStr)
+		if ip == nil || ip.To4() == nil {
+			panic(fmt.Sprintf("Value of -additionalExternalIPs %s could not be converted to an IPv4 address", ipStr))
+		}
+
+		parsedAdditionalExternalIPs = append(parsedAdditionalExternalIPs, ip)
+	}
+
+	bridgeManager := bridgemgr.New("w"+config.Tag+"b-", &devices.Bridge{}, &devices.Link{})
+
+	eventBus := linux_backend.NewEventBus(*eventHistorySize)
+
+	var containerRepo linux_backend.ContainerRepository = container_repository.New()
+	snapshotSavers := linux_backend.SnapshotSavers{linux_backend.NewSnapshotSaver(*snapshotsPath)}
+	if *journalPath != "" {
+		journal := container_repository.NewJournaled(logger, *journalPath)
+		containerRepo = journal
+		snapshotSavers = append(snapshotSavers, journal)
+	}
+
 	pool := container_pool.New(
 		logger,
 		*binPath,
@@ -335,9 +472,10 @@ func Main() {
 		rootFSProviders,
 		*uidMappingOffset,
 		parsedExternalIP,
+		parsedAdditionalExternalIPs,
 		*mtu,
 		subnetPool,
-		bridgemgr.New("w"+config.Tag+"b-", &devices.Bridge{}, &devices.Link{}),
+		bridgeManager,
 		filterProvider,
 		iptables.NewGlobalChain(config.IPTables.Filter.DefaultChain, runner, logger.Session("global-chain")),
 		portPool,
@@ -345,17 +483,29 @@ func Main() {
 		strings.Split(*allowNetworks, ","),
 		runner,
 		quotaManager,
+		tc.NewShaper(tc.NewNetlink()),
+		network.NewSysfsInterfaceStatter("/sys/class/net"),
+		snapshotSavers,
+		eventBus,
 	)
 
 	systemInfo := system_info.NewProvider(*depotPath)
 
-	backend := linux_backend.New(logger, pool, container_repository.New(), systemInfo, *snapshotsPath)
+	backend := linux_backend.New(logger, pool, containerRepo, systemInfo, *snapshotsPath, eventBus)
 
 	err = backend.Setup()
 	if err != nil {
 		logger.Fatal("failed-to-set-up-backend", err)
 	}
 
+	if dbgAddr := cf_debug_server.DebugAddress(flag.CommandLine); dbgAddr != "" {
+		debug_server.Run(dbgAddr, reconfigurableSink, debug_server.Pools{
+			Subnets: subnetPool,
+			Ports:   portPool,
+			Bridges: bridgeManager,
+		}, backend, eventBus)
+	}
+
 	graceTime := *containerGraceTime
 
 	gardenServer := server.New(*listenNetwork, *listenAddr, graceTime, backend, logger)
@@ -415,11 +565,25 @@ func initializeDropsonde(logger lager.Logger) {
 
 type provider struct {
 	useKernelLogging bool
+	ipv6             bool
 	chainPrefix      string
+	globalChains     iptables.GlobalChains
 	runner           command_runner.CommandRunner
 	log              lager.Logger
 }
 
 func (p *provider) ProvideFilter(containerId string) network.Filter {
+	if p.ipv6 {
+		return network.NewFilter(iptables.NewDualStackLoggingChain(p.chainPrefix+containerId, p.useKernelLogging, p.runner, p.log.Session(containerId).Session("filter")))
+	}
+
 	return network.NewFilter(iptables.NewLoggingChain(p.chainPrefix+containerId, p.useKernelLogging, p.runner, p.log.Session(containerId).Session("filter")))
 }
+
+func (p *provider) ProvideInstanceChain(containerId string) iptables.InstanceChain {
+	if p.ipv6 {
+		return iptables.NewDualStackInstanceChain(p.chainPrefix+containerId, p.globalChains, p.runner, p.log.Session(containerId).Session("instance-chain"))
+	}
+
+	return iptables.NewInstanceChain(p.chainPrefix+containerId, p.globalChains, p.runner, p.log.Session(containerId).Session("instance-chain"))
+}
@@ -27,6 +27,16 @@ func (e PortTakenError) Error() string {
 	return fmt.Sprintf("port already acquired: %d", e.Port)
 }
 
+// Inventory lists the ports of a pool which are free, and those which have
+// been acquired or removed.
+type Inventory struct {
+	Start uint32
+	Size  uint32
+
+	Free     []uint32
+	Acquired []uint32
+}
+
 func New(start, size uint32) *PortPool {
 	pool := []uint32{}
 
@@ -97,3 +107,31 @@ func (p *PortPool) Release(port uint32) {
 
 	p.pool = append(p.pool, port)
 }
+
+func (p *PortPool) Inventory() Inventory {
+	p.poolMutex.Lock()
+	defer p.poolMutex.Unlock()
+
+	free := map[uint32]bool{}
+	for _, port := range p.pool {
+		free[port] = true
+	}
+
+	inventory := Inventory{
+		Start: p.start,
+		Size:  p.size,
+
+		Free:     []uint32{},
+		Acquired: []uint32{},
+	}
+
+	for port := p.start; port < p.start+p.size; port++ {
+		if free[port] {
+			inventory.Free = append(inventory.Free, port)
+		} else {
+			inventory.Acquired = append(inventory.Acquired, port)
+		}
+	}
+
+	return inventory
+}
@@ -119,4 +119,28 @@ var _ = Describe("Port pool", func() {
 			})
 		})
 	})
+
+	Describe("inventory", func() {
+		It("lists the free ports and the acquired or removed ports, in order", func() {
+			pool := port_pool.New(10000, 5)
+
+			port, err := pool.Acquire()
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(pool.Remove(10003)).To(Succeed())
+
+			_, err = pool.Acquire()
+			Expect(err).ToNot(HaveOccurred())
+
+			pool.Release(port)
+
+			Expect(pool.Inventory()).To(Equal(port_pool.Inventory{
+				Start: 10000,
+				Size:  5,
+
+				Free:     []uint32{10000, 10002, 10004},
+				Acquired: []uint32{10001, 10003},
+			}))
+		})
+	})
 })
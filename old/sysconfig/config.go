@@ -10,6 +10,7 @@ import (
 type Config struct {
 	CgroupPath             string
 	NetworkInterfacePrefix string
+	NetworkIPv6            bool
 	IPTables               IPTablesConfig
 	Tag                    string
 }
@@ -62,6 +63,7 @@ func (config Config) Environ() process.Env {
 		"GARDEN_CGROUP_PATH": config.CgroupPath,
 
 		"GARDEN_NETWORK_INTERFACE_PREFIX": config.NetworkInterfacePrefix,
+		"GARDEN_NETWORK_IPV6":             strconv.FormatBool(config.NetworkIPv6),
 		"GARDEN_TAG":                      config.Tag,
 
 		"GARDEN_IPTABLES_ALLOW_HOST_ACCESS":  strconv.FormatBool(config.IPTables.Filter.AllowHostAccess),
@@ -7,7 +7,6 @@ import (
 	"os/exec"
 	"path"
 	"sync"
-	"syscall"
 
 	"github.com/cloudfoundry-incubator/garden"
 	"github.com/cloudfoundry/gunk/command_runner"
@@ -39,6 +38,7 @@ type Process struct {
 
 type Signaller interface {
 	Signal(os.Signal) error
+	SignalGroup(os.Signal) error
 }
 
 func NewProcess(
@@ -87,14 +87,16 @@ func (p *Process) SetTTY(tty garden.TTYSpec) error {
 }
 
 func (p *Process) Signal(s garden.Signal) error {
-	switch s {
-	case garden.SignalKill:
-		return p.signaller.Signal(os.Kill)
-	case garden.SignalTerminate:
-		return p.signaller.Signal(syscall.SIGTERM)
-	default:
-		return fmt.Errorf("process_tracker: failed to send signal: unknown signal: %d", s)
+	signal, group, err := mapSignal(s)
+	if err != nil {
+		return err
+	}
+
+	if group {
+		return p.signaller.SignalGroup(signal)
 	}
+
+	return p.signaller.Signal(signal)
 }
 
 func (p *Process) Spawn(cmd *exec.Cmd, tty *garden.TTYSpec) (ready, active chan error) {
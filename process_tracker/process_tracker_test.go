@@ -86,6 +86,36 @@ var _ = Describe("Running processes", func() {
 			Expect(process.Signal(garden.Signal(999))).To(MatchError(HaveSuffix("failed to send signal: unknown signal: 999")))
 			Expect(signaller.sent).To(BeNil())
 		})
+
+		It("sends the other signals which processes commonly handle", func() {
+			Expect(process.Signal(process_tracker.SignalHangup)).To(Succeed())
+			Expect(process.Signal(process_tracker.SignalInterrupt)).To(Succeed())
+			Expect(process.Signal(process_tracker.SignalQuit)).To(Succeed())
+			Expect(process.Signal(process_tracker.SignalUser1)).To(Succeed())
+			Expect(process.Signal(process_tracker.SignalUser2)).To(Succeed())
+
+			Expect(signaller.sent).To(Equal([]os.Signal{
+				syscall.SIGHUP,
+				syscall.SIGINT,
+				syscall.SIGQUIT,
+				syscall.SIGUSR1,
+				syscall.SIGUSR2,
+			}))
+		})
+
+		Context("when the signal is for the process group", func() {
+			It("sends the signal to the process group", func() {
+				Expect(process.Signal(process_tracker.SignalHangup | process_tracker.SignalProcessGroup)).To(Succeed())
+
+				Expect(signaller.sentGroup).To(Equal([]os.Signal{syscall.SIGHUP}))
+				Expect(signaller.sent).To(BeNil())
+			})
+
+			It("errors when the signal is unsupported", func() {
+				Expect(process.Signal(garden.Signal(999) | process_tracker.SignalProcessGroup)).To(MatchError(ContainSubstring("unknown signal")))
+				Expect(signaller.sentGroup).To(BeNil())
+			})
+		})
 	})
 
 	It("streams the process's stdout and stderr", func() {
@@ -337,10 +367,16 @@ var _ = Describe("Listing active process IDs", func() {
 })
 
 type FakeSignaller struct {
-	sent []os.Signal
+	sent      []os.Signal
+	sentGroup []os.Signal
 }
 
 func (f *FakeSignaller) Signal(s os.Signal) error {
 	f.sent = append(f.sent, s)
 	return nil
 }
+
+func (f *FakeSignaller) SignalGroup(s os.Signal) error {
+	f.sentGroup = append(f.sentGroup, s)
+	return nil
+}
@@ -0,0 +1,61 @@
+package process_tracker
+
+import (
+	"fmt"
+	"syscall"
+
+	"github.com/cloudfoundry-incubator/garden"
+)
+
+// Signals beyond the two garden defines. They continue garden's numbering so
+// that they can be passed anywhere a garden.Signal is taken.
+//
+// They can only be sent in-process, e.g. through a garden.Process returned by
+// the backend. The garden server forwards only garden.SignalKill and
+// garden.SignalTerminate from its clients; any other signal sent over the wire
+// is logged as unknown and closes the process's input stream.
+const (
+	SignalHangup garden.Signal = garden.SignalKill + 1 + iota
+	SignalInterrupt
+	SignalQuit
+	SignalUser1
+	SignalUser2
+	SignalAlarm
+	SignalContinue
+	SignalStop
+	SignalTerminalStop
+	SignalWindowChange
+)
+
+// SignalProcessGroup may be or-ed into any signal to deliver it to the whole
+// process group of the process rather than just the process itself. Like the
+// signals above, it is not forwarded by the garden server.
+const SignalProcessGroup garden.Signal = 1 << 16
+
+var signals = map[garden.Signal]syscall.Signal{
+	garden.SignalTerminate: syscall.SIGTERM,
+	garden.SignalKill:      syscall.SIGKILL,
+	SignalHangup:           syscall.SIGHUP,
+	SignalInterrupt:        syscall.SIGINT,
+	SignalQuit:             syscall.SIGQUIT,
+	SignalUser1:            syscall.SIGUSR1,
+	SignalUser2:            syscall.SIGUSR2,
+	SignalAlarm:            syscall.SIGALRM,
+	SignalContinue:         syscall.SIGCONT,
+	SignalStop:             syscall.SIGSTOP,
+	SignalTerminalStop:     syscall.SIGTSTP,
+	SignalWindowChange:     syscall.SIGWINCH,
+}
+
+// mapSignal returns the OS signal for a garden signal, and whether it is to
+// be sent to the process group.
+func mapSignal(s garden.Signal) (syscall.Signal, bool, error) {
+	group := s&SignalProcessGroup != 0
+
+	signal, found := signals[s&^SignalProcessGroup]
+	if !found {
+		return 0, false, fmt.Errorf("process_tracker: failed to send signal: unknown signal: %d", s)
+	}
+
+	return signal, group, nil
+}